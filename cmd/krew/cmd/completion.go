@@ -0,0 +1,246 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/krew/pkg/installation"
+)
+
+// completeCommand is the name of the hidden command the completion scripts
+// call to get the candidates for the word being completed.
+const completeCommand = "__complete"
+
+// argsCompletionFunc returns the candidates for the positional arguments of a
+// command.
+type argsCompletionFunc func() ([]string, error)
+
+// argsCompletions are the argument completions of the commands.
+var argsCompletions = map[*cobra.Command]argsCompletionFunc{}
+
+// setArgsCompletion registers f to complete the positional arguments of cmd.
+func setArgsCompletion(cmd *cobra.Command, f argsCompletionFunc) {
+	argsCompletions[cmd] = f
+}
+
+// completeIndexPlugins returns the names of the plugins in the indexes.
+func completeIndexPlugins() ([]string, error) {
+	if err := checkIndex(nil, nil); err != nil {
+		return nil, err
+	}
+	keys, _, err := loadAllPlugins()
+	if err != nil {
+		return nil, err
+	}
+	refs := pluginRefs(keys)
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, refs[key])
+	}
+	return names, nil
+}
+
+// completeInstalledPlugins returns the names of the installed plugins,
+// including the broken ones.
+func completeInstalledPlugins() ([]string, error) {
+	installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+	if err != nil {
+		return nil, err
+	}
+	names, err := installation.ListBrokenPlugins(paths.InstallPath(), paths.BinPath())
+	if err != nil {
+		return nil, err
+	}
+	for name := range installed {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// completionCandidates returns the sorted candidates starting with toComplete
+// for the word after args: the subcommands of the root command, the flags of
+// the command, or its positional arguments not already in args.
+func completionCandidates(root *cobra.Command, args []string, toComplete string) ([]string, error) {
+	cmd, rest, err := root.Find(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	switch {
+	case strings.HasPrefix(toComplete, "-"):
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if !f.Hidden {
+				candidates = append(candidates, "--"+f.Name)
+			}
+		})
+	case cmd == root:
+		for _, c := range root.Commands() {
+			if c.IsAvailableCommand() {
+				candidates = append(candidates, c.Name())
+			}
+		}
+	case argsCompletions[cmd] != nil:
+		all, err := argsCompletions[cmd]()
+		if err != nil {
+			return nil, err
+		}
+		given := make(map[string]bool)
+		for _, arg := range rest {
+			given[arg] = true
+		}
+		for _, name := range all {
+			if !given[name] {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, toComplete) {
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// bashCompletionFunc returns the function the bash completion script calls for
+// the arguments of commands, which asks krew for the completions of the
+// commands in argsCompletions.
+func bashCompletionFunc(root *cobra.Command) string {
+	var cmds []string
+	for cmd := range argsCompletions {
+		cmds = append(cmds, strings.Replace(cmd.CommandPath(), " ", "_", -1))
+	}
+	sort.Strings(cmds)
+	if len(cmds) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`__custom_func() {
+    case ${last_command} in
+        %s)
+            local IFS=$'\n'
+            COMPREPLY=( $(%s %s "${words[@]:1:cword-1}" "${cur}" 2>/dev/null) )
+            ;;
+    esac
+}
+`, strings.Join(cmds, " | "), root.Name(), completeCommand)
+}
+
+const zshCompletionTemplate = `#compdef %[1]s
+
+_%[1]s() {
+  local -a candidates
+  candidates=("${(@f)$(%[1]s %[2]s "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null)}")
+  compadd -a candidates
+}
+
+compdef _%[1]s %[1]s
+`
+
+const fishCompletionTemplate = `function __%[1]s_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    %[1]s %[2]s $args (commandline -ct) 2>/dev/null
+end
+
+complete -c %[1]s -f -a '(__%[1]s_complete)'
+`
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, root *cobra.Command, shell string) error {
+	switch shell {
+	case "bash":
+		root.BashCompletionFunction = bashCompletionFunc(root)
+		var buf bytes.Buffer
+		if err := root.GenBashCompletion(&buf); err != nil {
+			return errors.Wrap(err, "failed to generate bash completion")
+		}
+		_, err := buf.WriteTo(w)
+		return err
+	case "zsh":
+		_, err := fmt.Fprintf(w, zshCompletionTemplate, root.Name(), completeCommand)
+		return err
+	case "fish":
+		_, err := fmt.Fprintf(w, fishCompletionTemplate, root.Name(), completeCommand)
+		return err
+	}
+	return errors.Errorf("unsupported shell %q, must be one of: bash, zsh, fish", shell)
+}
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion SHELL",
+	Short: "Output shell completion code for bash, zsh or fish",
+	Long: `Output shell completion code for the specified shell (bash, zsh or fish).
+The completion code completes the commands and flags of krew, and the plugin
+names for install, info and uninstall. It requires krew to be in the PATH.
+
+Examples:
+  To load the completions in the current bash shell, run:
+    source <(krew completion bash)
+
+  To load the completions for every new zsh session, run:
+    krew completion zsh > "${fpath[1]}/_krew"
+
+  To load the completions for every new fish session, run:
+    krew completion fish > ~/.config/fish/completions/krew.fish`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return writeCompletion(os.Stdout, rootCmd, args[0])
+	},
+	Args: cobra.ExactArgs(1),
+}
+
+// completeCmd prints the completion candidates for the last argument given
+// the preceding ones, it is called by the completion scripts.
+var completeCmd = &cobra.Command{
+	Use:                completeCommand + " [ARG...] WORD",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var toComplete string
+		if len(args) > 0 {
+			toComplete = args[len(args)-1]
+			args = args[:len(args)-1]
+		}
+		candidates, err := completionCandidates(rootCmd, args, toComplete)
+		if err != nil {
+			// completion must not print errors in the middle of the command line
+			glog.V(4).Infof("failed to complete %q: %v", toComplete, err)
+			return nil
+		}
+		for _, c := range candidates {
+			fmt.Fprintln(os.Stdout, c)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(completeCmd)
+}
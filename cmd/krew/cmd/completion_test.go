@@ -0,0 +1,100 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func Test_completionCandidates(t *testing.T) {
+	run := func(*cobra.Command, []string) {}
+	root := &cobra.Command{Use: "krew"}
+	install := &cobra.Command{Use: "install", Run: run}
+	install.Flags().Bool("dry-run", false, "")
+	install.Flags().String("manifest", "", "")
+	install.Flags().String("archive", "", "")
+	install.Flags().MarkHidden("archive")
+	uninstall := &cobra.Command{Use: "uninstall", Aliases: []string{"remove"}, Run: run}
+	version := &cobra.Command{Use: "version", Run: run}
+	hidden := &cobra.Command{Use: "hidden", Hidden: true, Run: run}
+	root.AddCommand(install, uninstall, version, hidden)
+
+	setArgsCompletion(install, func() ([]string, error) { return []string{"foo", "bar", "baz"}, nil })
+	setArgsCompletion(uninstall, func() ([]string, error) { return []string{"foo"}, nil })
+	defer func() {
+		delete(argsCompletions, install)
+		delete(argsCompletions, uninstall)
+	}()
+
+	tests := []struct {
+		name       string
+		args       []string
+		toComplete string
+		want       []string
+	}{
+		{"commands", nil, "", []string{"install", "uninstall", "version"}},
+		{"commands with prefix", nil, "u", []string{"uninstall"}},
+		{"plugins", []string{"install"}, "", []string{"bar", "baz", "foo"}},
+		{"plugins with prefix", []string{"install"}, "b", []string{"bar", "baz"}},
+		{"plugins already given", []string{"install", "bar"}, "", []string{"baz", "foo"}},
+		{"plugins after flag", []string{"install", "--dry-run"}, "f", []string{"foo"}},
+		{"alias", []string{"remove"}, "", []string{"foo"}},
+		{"flags", []string{"install"}, "--d", []string{"--dry-run"}},
+		{"hidden flags", []string{"install"}, "--a", nil},
+		{"no args completion", []string{"version"}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := completionCandidates(root, tt.args, tt.toComplete)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("completionCandidates(%v, %q) = %v, want %v", tt.args, tt.toComplete, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeCompletion(t *testing.T) {
+	root := &cobra.Command{Use: "krew"}
+	install := &cobra.Command{Use: "install", Run: func(*cobra.Command, []string) {}}
+	root.AddCommand(install)
+	setArgsCompletion(install, func() ([]string, error) { return nil, nil })
+	defer delete(argsCompletions, install)
+
+	for shell, want := range map[string]string{
+		"bash": "krew_install",
+		"zsh":  "#compdef krew",
+		"fish": "complete -c krew",
+	} {
+		var buf bytes.Buffer
+		if err := writeCompletion(&buf, root, shell); err != nil {
+			t.Errorf("%s: %v", shell, err)
+			continue
+		}
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%s completion does not contain %q:\n%s", shell, want, buf.String())
+		}
+	}
+	if err := writeCompletion(&bytes.Buffer{}, root, "tcsh"); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// configFileName is the name of the optional file in the krew root with the
+// defaults of command flags.
+const configFileName = "config.yaml"
+
+// krewConfig is the content of the config file. Each field is the default of a
+// flag, which is used unless the flag or the environment variable overriding
+// it is set.
+type krewConfig struct {
+	// Platform is the default of --platform, in OS/ARCH format.
+	Platform string `json:"platform,omitempty"`
+	// DownloadMirror is the default of --download-mirror, in FROM=TO format.
+	DownloadMirror []string `json:"downloadMirror,omitempty"`
+	// NoUpdateIndex is the default of --no-update-index.
+	NoUpdateIndex bool `json:"noUpdateIndex,omitempty"`
+}
+
+// loadConfig reads the config file at path. A missing file is an empty config.
+// Unknown fields are rejected, so that a misspelled flag is not ignored.
+func loadConfig(path string) (krewConfig, error) {
+	var cfg krewConfig
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, errors.Wrap(err, "failed to read the config file")
+	}
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "failed to parse the config file %s", path)
+	}
+	if bytes.Equal(bytes.TrimSpace(j), []byte("null")) {
+		return cfg, nil // empty file
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, errors.Wrapf(err, "failed to parse the config file %s", path)
+	}
+	return cfg, nil
+}
+
+// configFlagValues returns the values of cfg by the flag names they are the
+// default of. Values whose environment variable is set in getenv are left out,
+// as the environment takes precedence over the config file.
+func configFlagValues(cfg krewConfig, getenv func(string) string) map[string]string {
+	values := make(map[string]string)
+	if cfg.Platform != "" && getenv("KREW_OS") == "" && getenv("KREW_ARCH") == "" {
+		values[platformFlag] = cfg.Platform
+	}
+	if len(cfg.DownloadMirror) > 0 && getenv("KREW_DOWNLOAD_MIRROR") == "" {
+		values[downloadMirrorFlag] = strings.Join(cfg.DownloadMirror, ",")
+	}
+	if cfg.NoUpdateIndex {
+		values[noUpdateIndexFlag] = strconv.FormatBool(cfg.NoUpdateIndex)
+	}
+	return values
+}
+
+// applyConfig sets the flags of cmd that were not specified on the command
+// line to the values of cfg. Flags that cmd doesn't have are ignored.
+func applyConfig(cmd *cobra.Command, cfg krewConfig, getenv func(string) string) error {
+	for name, value := range configFlagValues(cfg, getenv) {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		glog.V(4).Infof("Using --%s=%s from the config file", name, value)
+		if err := f.Value.Set(value); err != nil {
+			return errors.Wrapf(err, "invalid %s in the config file", name)
+		}
+	}
+	return nil
+}
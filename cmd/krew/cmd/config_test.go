@@ -0,0 +1,130 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_loadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    krewConfig
+		wantErr bool
+	}{
+		{name: "missing"},
+		{name: "empty", content: "\n"},
+		{
+			name:    "all fields",
+			content: "platform: linux/arm64\ndownloadMirror:\n- https://github.com/=https://mirror.internal/\nnoUpdateIndex: true\n",
+			want: krewConfig{
+				Platform:       "linux/arm64",
+				DownloadMirror: []string{"https://github.com/=https://mirror.internal/"},
+				NoUpdateIndex:  true,
+			},
+		},
+		{name: "unknown field", content: "platfrom: linux/arm64\n", wantErr: true},
+		{name: "invalid yaml", content: "platform: [\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			if tt.name != "missing" {
+				tmpDir.Write(configFileName, []byte(tt.content))
+			}
+			got, err := loadConfig(tmpDir.Path(configFileName))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("loadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applyConfig(t *testing.T) {
+	cfg := krewConfig{
+		Platform:       "linux/arm64",
+		DownloadMirror: []string{"https://a/=https://b/", "https://c/=https://d/"},
+		NoUpdateIndex:  true,
+	}
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want map[string]string
+	}{
+		{
+			name: "config overrides the defaults",
+			want: map[string]string{
+				platformFlag:       "linux/arm64",
+				downloadMirrorFlag: "[https://a/=https://b/,https://c/=https://d/]",
+				noUpdateIndexFlag:  "true",
+			},
+		},
+		{
+			name: "flags override the config",
+			args: []string{"--platform=darwin/amd64", "--no-update-index=false"},
+			want: map[string]string{
+				platformFlag:       "darwin/amd64",
+				downloadMirrorFlag: "[https://a/=https://b/,https://c/=https://d/]",
+				noUpdateIndexFlag:  "false",
+			},
+		},
+		{
+			name: "environment overrides the config",
+			env:  map[string]string{"KREW_OS": "windows", "KREW_DOWNLOAD_MIRROR": "https://e/=https://f/"},
+			want: map[string]string{
+				platformFlag:       "",
+				downloadMirrorFlag: "[]",
+				noUpdateIndexFlag:  "true",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addPlatformFlag(cmd)
+			addDownloadMirrorFlag(cmd)
+			addNoUpdateIndexFlag(cmd)
+			if err := cmd.Flags().Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			getenv := func(key string) string { return tt.env[key] }
+			if err := applyConfig(cmd, cfg, getenv); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := cmd.Flags().Lookup(name).Value.String(); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_applyConfig_missingFlags(t *testing.T) {
+	cmd := &cobra.Command{}
+	if err := applyConfig(cmd, krewConfig{Platform: "linux/arm64", NoUpdateIndex: true}, func(string) string { return "" }); err != nil {
+		t.Fatalf("applyConfig() error = %v, expected flags the command doesn't have to be ignored", err)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/gitutil"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
+	"sigs.k8s.io/krew/pkg/installation"
+)
+
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+var doctorOutput string
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common problems with the krew setup",
+	Long: `Check the krew setup for common problems and print a checklist with a hint
+on how to fix each failed check.
+
+The checks are:
+  - the bin directory is in your PATH
+  - the install and bin directories are writable
+  - the plugin indexes are present and can be parsed
+  - no installed plugins have a broken link and no links in the bin
+    directory point to removed installations
+  - the KREW_OS and KREW_ARCH overrides are valid platforms
+
+The command fails if any of the checks fail, warnings don't fail it.
+
+Examples:
+  kubectl krew doctor
+  kubectl krew doctor -o json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks := doctorChecks(paths, os.Getenv)
+		if err := printDoctorChecks(os.Stdout, checks, doctorOutput); err != nil {
+			return err
+		}
+		var failed int
+		for _, c := range checks {
+			if c.Status == doctorFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return errors.Errorf("%d of %d checks failed", failed, len(checks))
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat(doctorOutput)
+	},
+	Args: cobra.NoArgs,
+}
+
+// doctorCheck is the result of a check printed by doctor.
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// doctorChecks runs the doctor checks for the krew paths, getenv looks up the
+// PATH and the KREW_OS and KREW_ARCH environment variables.
+func doctorChecks(p environment.Paths, getenv func(string) string) []doctorCheck {
+	var checks []doctorCheck
+	add := func(name, status, message, hint string) {
+		checks = append(checks, doctorCheck{Name: name, Status: status, Message: message, Hint: hint})
+	}
+
+	installErr := environment.EnsureWritableDir(p.InstallPath())
+	if installErr != nil {
+		add("install directory is writable", doctorFail, installErr.Error(),
+			fmt.Sprintf("fix the permissions of %s or use --root to choose another directory", p.InstallPath()))
+	} else {
+		add("install directory is writable", doctorPass, p.InstallPath(), "")
+	}
+	binErr := environment.EnsureWritableDir(p.BinPath())
+	if binErr != nil {
+		add("bin directory is writable", doctorFail, binErr.Error(),
+			fmt.Sprintf("fix the permissions of %s or use --bin-dir to choose another directory", p.BinPath()))
+	} else {
+		add("bin directory is writable", doctorPass, p.BinPath(), "")
+	}
+	if containsString(filepath.SplitList(getenv("PATH")), p.BinPath()) {
+		add("bin directory is in PATH", doctorPass, p.BinPath(), "")
+	} else {
+		add("bin directory is in PATH", doctorFail, fmt.Sprintf("%s is not in PATH", p.BinPath()),
+			fmt.Sprintf("add it to PATH in your shell profile, e.g. export PATH=\"%s:$PATH\"", p.BinPath()))
+	}
+
+	checks = append(checks, doctorIndexChecks(p)...)
+
+	if installErr == nil && binErr == nil {
+		broken, err := installation.ListBrokenPlugins(p.InstallPath(), p.BinPath())
+		switch {
+		case err != nil:
+			add("installed plugins have working links", doctorFail, err.Error(), "")
+		case len(broken) > 0:
+			add("installed plugins have working links", doctorFail,
+				fmt.Sprintf("broken plugins: %s", strings.Join(broken, ", ")),
+				`run "kubectl krew list --repair" to reinstall them`)
+		default:
+			add("installed plugins have working links", doctorPass, "", "")
+		}
+		dangling, err := installation.ListDanglingLinks(p.BinPath())
+		switch {
+		case err != nil:
+			add("no dangling links in the bin directory", doctorFail, err.Error(), "")
+		case len(dangling) > 0:
+			add("no dangling links in the bin directory", doctorFail,
+				fmt.Sprintf("dangling links: %s", strings.Join(dangling, ", ")),
+				`run "kubectl krew list --repair" to remove them`)
+		default:
+			add("no dangling links in the bin directory", doctorPass, "", "")
+		}
+	}
+
+	envOS, envArch := getenv("KREW_OS"), getenv("KREW_ARCH")
+	switch goos, goarch, err := installation.OSArch(envOS, envArch); {
+	case err != nil:
+		add("OS/arch overrides are valid", doctorFail, err.Error(), "unset KREW_OS and KREW_ARCH or set them to valid GOOS and GOARCH values")
+	case goos != runtime.GOOS || goarch != runtime.GOARCH:
+		add("OS/arch overrides are valid", doctorWarn,
+			fmt.Sprintf("plugins are installed for %s/%s, this system is %s/%s", goos, goarch, runtime.GOOS, runtime.GOARCH),
+			"unset KREW_OS and KREW_ARCH unless you install plugins for another system")
+	default:
+		add("OS/arch overrides are valid", doctorPass, fmt.Sprintf("%s/%s", goos, goarch), "")
+	}
+	return checks
+}
+
+// doctorIndexChecks checks that the indexes of p are cloned and their plugin
+// manifests can be parsed.
+func doctorIndexChecks(p environment.Paths) []doctorCheck {
+	indexes, err := indexoperations.ListIndexes(p)
+	if err != nil {
+		return []doctorCheck{{Name: "indexes can be listed", Status: doctorFail, Message: err.Error()}}
+	}
+	var checks []doctorCheck
+	for _, idx := range indexes {
+		c := doctorCheck{Name: fmt.Sprintf("index %q is present and parseable", idx.Name)}
+		path := p.IndexPathFor(idx.Name)
+		if cloned, err := gitutil.IsGitCloned(path); err != nil {
+			c.Status, c.Message = doctorFail, err.Error()
+		} else if !cloned {
+			c.Status, c.Message = doctorFail, fmt.Sprintf("%s is not initialized", path)
+			c.Hint = `run "kubectl krew update"`
+		} else if list, err := indexscanner.LoadPluginListFromFS(path); err != nil {
+			c.Status, c.Message = doctorFail, err.Error()
+			c.Hint = fmt.Sprintf(`remove %s and run "kubectl krew update" to clone it again`, path)
+		} else {
+			c.Status, c.Message = doctorPass, fmt.Sprintf("%d plugins", len(list.Items))
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// printDoctorChecks prints the checks as a checklist, or in the json or yaml
+// output format.
+func printDoctorChecks(out io.Writer, checks []doctorCheck, format string) error {
+	switch format {
+	case outputFormatJSON:
+		return printJSON(out, checks)
+	case outputFormatYAML:
+		return printYAML(out, checks)
+	}
+	for _, c := range checks {
+		line := fmt.Sprintf("[%s] %s", strings.ToUpper(c.Status), c.Name)
+		if c.Message != "" {
+			line += ": " + c.Message
+		}
+		fmt.Fprintln(out, line)
+		if c.Hint != "" {
+			fmt.Fprintf(out, "       hint: %s\n", c.Hint)
+		}
+	}
+	return nil
+}
+
+func init() {
+	doctorCmd.Flags().StringVarP(&doctorOutput, "output", "o", outputFormatTable, "Output format. One of: table|json|yaml")
+	rootCmd.AddCommand(doctorCmd)
+}
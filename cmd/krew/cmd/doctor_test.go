@@ -0,0 +1,121 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_doctorChecks(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p, err := environment.NewPaths(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"PATH": p.BinPath()}
+	getenv := func(key string) string { return env[key] }
+
+	statuses := func() map[string]doctorCheck {
+		m := make(map[string]doctorCheck)
+		for _, c := range doctorChecks(p, getenv) {
+			m[c.Name] = c
+		}
+		return m
+	}
+
+	got := statuses()
+	for name, want := range map[string]string{
+		"install directory is writable":            doctorPass,
+		"bin directory is writable":                doctorPass,
+		"bin directory is in PATH":                 doctorPass,
+		`index "default" is present and parseable`: doctorFail,
+		"installed plugins have working links":     doctorPass,
+		"no dangling links in the bin directory":   doctorPass,
+		"OS/arch overrides are valid":              doctorPass,
+	} {
+		if got[name].Status != want {
+			t.Errorf("check %q status = %q, want %q (%+v)", name, got[name].Status, want, got[name])
+		}
+	}
+	if hint := got[`index "default" is present and parseable`].Hint; !strings.Contains(hint, "krew update") {
+		t.Errorf("index check hint = %q, expected to suggest krew update", hint)
+	}
+
+	env["PATH"] = "/usr/bin"
+	if err := os.Symlink(tmpDir.Path("store/foo/v1/kubectl-foo"), filepath.Join(p.BinPath(), "kubectl-foo")); err != nil {
+		t.Fatal(err)
+	}
+	otherOS := "windows"
+	if runtime.GOOS == otherOS {
+		otherOS = "linux"
+	}
+	env["KREW_OS"] = otherOS
+	got = statuses()
+	for name, want := range map[string]string{
+		"bin directory is in PATH":               doctorFail,
+		"no dangling links in the bin directory": doctorFail,
+		"OS/arch overrides are valid":            doctorWarn,
+	} {
+		if got[name].Status != want {
+			t.Errorf("check %q status = %q, want %q (%+v)", name, got[name].Status, want, got[name])
+		}
+	}
+
+	env["KREW_ARCH"] = "not-an-arch"
+	if c := statuses()["OS/arch overrides are valid"]; c.Status != doctorFail {
+		t.Errorf("OS/arch check with an invalid KREW_ARCH = %+v, want a failure", c)
+	}
+}
+
+func Test_printDoctorChecks(t *testing.T) {
+	checks := []doctorCheck{
+		{Name: "bin directory is in PATH", Status: doctorFail, Message: "/krew/bin is not in PATH", Hint: "add it to PATH"},
+		{Name: "bin directory is writable", Status: doctorPass, Message: "/krew/bin"},
+	}
+
+	var buf bytes.Buffer
+	if err := printDoctorChecks(&buf, checks, outputFormatTable); err != nil {
+		t.Fatal(err)
+	}
+	want := `[FAIL] bin directory is in PATH: /krew/bin is not in PATH
+       hint: add it to PATH
+[PASS] bin directory is writable: /krew/bin
+`
+	if buf.String() != want {
+		t.Errorf("printDoctorChecks() table =\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := printDoctorChecks(&buf, checks, outputFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var got []doctorCheck
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("printDoctorChecks() json output is invalid: %v\n%s", err, buf.String())
+	}
+	if len(got) != 2 || got[0] != checks[0] || got[1] != checks[1] {
+		t.Errorf("printDoctorChecks() json = %+v, want %+v", got, checks)
+	}
+}
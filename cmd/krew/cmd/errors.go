@@ -0,0 +1,125 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/installation"
+)
+
+// errorFormatFlag is the name of the flag to choose how the error of a failed
+// command is printed.
+const errorFormatFlag = "error-format"
+
+// errorFormats are the values of --error-format.
+var errorFormats = []string{"text", "json"}
+
+// pluginError is the error of a command that failed because of a single
+// plugin. It keeps the plugin name and its error for --error-format=json,
+// while the message stays the same as without it.
+type pluginError struct {
+	msg    string
+	plugin string
+	err    error
+}
+
+func (e *pluginError) Error() string { return e.msg }
+
+// Cause returns the error of the plugin.
+func (e *pluginError) Cause() error { return e.err }
+
+// failedPluginsError returns the error of a command that failed for the
+// plugins in failed. errs has the errors of the plugins by name, it is used
+// if a single plugin failed.
+func failedPluginsError(action string, failed []string, errs map[string]error) error {
+	msg := fmt.Sprintf("failed to %s some plugins: %+v", action, failed)
+	if len(failed) == 1 && errs[failed[0]] != nil {
+		return &pluginError{msg: msg, plugin: failed[0], err: errs[failed[0]]}
+	}
+	return errors.New(msg)
+}
+
+// errorJSON is the output of --error-format=json.
+type errorJSON struct {
+	Error  string `json:"error"`
+	Code   string `json:"code"`
+	Plugin string `json:"plugin,omitempty"`
+}
+
+// errorCodes are the codes of the typed errors in --error-format=json.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{installation.ErrNoMatchingPlatform, "no_matching_platform"},
+	{installation.ErrAlreadyInstalled, "already_installed"},
+	{installation.ErrNotInstalled, "not_installed"},
+	{installation.ErrIsAlreadyUpgraded, "already_upgraded"},
+	{installation.ErrChecksumMismatch, "checksum_mismatch"},
+	{context.Canceled, "interrupted"},
+	{context.DeadlineExceeded, "timeout"},
+}
+
+// errorCode returns the code of the typed error err, or "error" if it's not a
+// typed error. It follows both the Cause of github.com/pkg/errors and Unwrap.
+func errorCode(err error) string {
+	for ; err != nil; err = cause(err) {
+		for _, c := range errorCodes {
+			if stderrors.Is(err, c.err) {
+				return c.code
+			}
+		}
+		var hookErr *installation.HookError
+		if stderrors.As(err, &hookErr) {
+			return "hook_failed"
+		}
+	}
+	return "error"
+}
+
+// errorPlugin returns the name of the plugin err is about, if it's known.
+func errorPlugin(err error) string {
+	for ; err != nil; err = cause(err) {
+		if pe, ok := err.(*pluginError); ok {
+			return pe.plugin
+		}
+	}
+	return ""
+}
+
+// cause returns the error wrapped by err with github.com/pkg/errors, or nil.
+func cause(err error) error {
+	c, ok := err.(interface{ Cause() error })
+	if !ok {
+		return nil
+	}
+	return c.Cause()
+}
+
+// printErrorJSON prints the error of a failed command for
+// --error-format=json.
+func printErrorJSON(out io.Writer, err error) error {
+	return json.NewEncoder(out).Encode(errorJSON{
+		Error:  err.Error(),
+		Code:   errorCode(err),
+		Plugin: errorPlugin(err),
+	})
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/download"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/installation"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func TestPrintErrorJSON_noMatchingPlatform(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{{
+				URI:      "https://example.com/foo.tar.gz",
+				Sha256:   "deadbeef",
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "plan9"}},
+				Files:    []index.FileOperation{{From: "*", To: "."}},
+				Bin:      "kubectl-foo",
+			}},
+		},
+	}
+	installErr := installation.InstallPlugin(installation.InstallOpts{
+		Plugin:       plugin,
+		InstallPath:  tmpDir.Path("store"),
+		BinPath:      tmpDir.Path("bin"),
+		DownloadPath: tmpDir.Path("downloads"),
+	})
+	if installErr == nil {
+		t.Fatal("InstallPlugin() expected no matching platform")
+	}
+
+	var out bytes.Buffer
+	err := failedPluginsError("install", []string{"foo"}, map[string]error{"foo": installErr})
+	if err := printErrorJSON(&out, err); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("printErrorJSON() printed invalid json %q: %v", out.String(), err)
+	}
+	want := map[string]interface{}{
+		"error":  "failed to install some plugins: [foo]",
+		"code":   "no_matching_platform",
+		"plugin": "foo",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("printErrorJSON() = %v, want %v", got, want)
+	}
+	if bytes.Count(out.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("printErrorJSON() = %q, expected a single line", out.String())
+	}
+}
+
+func Test_errorCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   string
+		wantPlugin string
+	}{
+		{"untyped", errors.New("failed"), "error", ""},
+		{"sentinel", installation.ErrNotInstalled, "not_installed", ""},
+		{"wrapped sentinel", errors.Wrap(installation.ErrAlreadyInstalled, "can't install"), "already_installed", ""},
+		{"checksum", errors.Wrap(&download.ChecksumError{Expected: "a", Actual: "b"}, "failed to verify"), "checksum_mismatch", ""},
+		{"hook", errors.Wrap(&installation.HookError{Plugin: "foo", Script: "hook.sh", ExitCode: 1}, "failed"), "hook_failed", ""},
+		{"timeout", errors.Wrap(context.DeadlineExceeded, "failed"), "timeout", ""},
+		{"exit status", &exitCodeError{code: 1, msg: "no plugins found"}, "error", ""},
+		{
+			name:       "single plugin",
+			err:        failedPluginsError("upgrade", []string{"foo"}, map[string]error{"foo": installation.ErrNotInstalled}),
+			wantCode:   "not_installed",
+			wantPlugin: "foo",
+		},
+		{
+			name:     "several plugins",
+			err:      failedPluginsError("upgrade", []string{"foo", "bar"}, map[string]error{"foo": installation.ErrNotInstalled}),
+			wantCode: "error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(tt.err); got != tt.wantCode {
+				t.Errorf("errorCode() = %q, want %q", got, tt.wantCode)
+			}
+			if got := errorPlugin(tt.err); got != tt.wantPlugin {
+				t.Errorf("errorPlugin() = %q, want %q", got, tt.wantPlugin)
+			}
+		})
+	}
+}
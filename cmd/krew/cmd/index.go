@@ -0,0 +1,89 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
+)
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage custom plugin indexes",
+	Long: `Manage custom plugin indexes.
+
+In addition to the default plugin index, plugins can be discovered and
+installed from custom index repositories. Plugins from a custom index can be
+referred to as INDEX/PLUGIN, for example "kubectl krew install company/foo".`,
+}
+
+var indexAddCmd = &cobra.Command{
+	Use:     "add",
+	Short:   "Add a custom plugin index",
+	Long:    `Add a custom plugin index by cloning its git repository.`,
+	Example: "  kubectl krew index add NAME URL",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, url := args[0], args[1]
+		if err := indexoperations.AddIndex(paths, name, url); err != nil {
+			return errors.Wrapf(err, "failed to add index %q", name)
+		}
+		fmt.Fprintf(infoOut, "Added index %s\n", name)
+		return nil
+	},
+}
+
+var indexListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured plugin indexes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		indexes, err := indexoperations.ListIndexes(paths)
+		if err != nil {
+			return errors.Wrap(err, "failed to list indexes")
+		}
+		var rows [][]string
+		for _, idx := range indexes {
+			rows = append(rows, []string{idx.Name, idx.URL})
+		}
+		return printTable(os.Stdout, []string{"INDEX", "URL"}, rows)
+	},
+}
+
+var indexRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Short:   "Remove a custom plugin index",
+	Long:    `Remove a custom plugin index. Plugins installed from it are not uninstalled.`,
+	Example: "  kubectl krew index remove NAME",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := indexoperations.DeleteIndex(paths, args[0]); err != nil {
+			return errors.Wrapf(err, "failed to remove index %q", args[0])
+		}
+		fmt.Fprintf(infoOut, "Removed index %s\n", args[0])
+		return nil
+	},
+	Aliases: []string{"rm"},
+}
+
+func init() {
+	indexCmd.AddCommand(indexAddCmd, indexListCmd, indexRemoveCmd)
+	rootCmd.AddCommand(indexCmd)
+}
@@ -18,16 +18,21 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
+	"text/template"
 	"unicode"
 
 	"sigs.k8s.io/krew/pkg/index"
-	"sigs.k8s.io/krew/pkg/index/indexscanner"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
 	"sigs.k8s.io/krew/pkg/installation"
 
+	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // infoCmd represents the info command
@@ -37,31 +42,70 @@ var infoCmd = &cobra.Command{
 	Long: `Show information about a kubectl plugin.
 
 This command can be used to print information such as its download URL, last
-available version, platform availability and the caveats.
+available version, platform availability and the caveats. The platform that
+would be installed on the current system is marked with "*".
 
-Example:
-  kubectl krew info PLUGIN`,
+Examples:
+  kubectl krew info PLUGIN
+
+  To only print the URI that would be downloaded for another platform, run:
+    kubectl krew info --uri --platform=linux/arm64 PLUGIN`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		plugin, err := indexscanner.LoadPluginFileFromFS(paths.IndexPath(), args[0])
+		plugin, err := indexoperations.LoadPlugin(paths, args[0])
 		if os.IsNotExist(err) {
 			return errors.Errorf("plugin %q not found", args[0])
 		} else if err != nil {
 			return errors.Wrap(err, "failed to load plugin manifest")
 		}
-		printPluginInfo(os.Stdout, plugin)
-		return nil
+		goos, goarch, err := osArchFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if infoURI {
+			return printPluginURI(os.Stdout, plugin, goos, goarch)
+		}
+		return printPluginInfo(os.Stdout, plugin, goos, goarch)
 	},
 	PreRunE: checkIndex,
 	Args:    cobra.ExactArgs(1),
 }
 
-func printPluginInfo(out io.Writer, plugin index.Plugin) {
+var infoURI bool
+
+// printPluginURI prints the download URI of the platform matching goos/goarch.
+func printPluginURI(out io.Writer, plugin index.Plugin, goos, goarch string) error {
+	platform, ok, err := installation.GetMatchingPlatformFor(plugin, goos, goarch)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the matching platform for plugin %s", plugin.Name)
+	}
+	if !ok {
+		return errors.Errorf("plugin %q has no platform matching %s/%s", plugin.Name, goos, goarch)
+	}
+	_, err = fmt.Fprintln(out, platform.URI)
+	return err
+}
+
+// printPluginInfo prints the plugin metadata, the download details of the
+// platform matching goos/goarch and the selectors of all platforms.
+func printPluginInfo(out io.Writer, plugin index.Plugin, goos, goarch string) error {
+	platform, ok, err := installation.GetMatchingPlatformFor(plugin, goos, goarch)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the matching platform for plugin %s", plugin.Name)
+	}
+
 	fmt.Fprintf(out, "NAME: %s\n", plugin.Name)
-	if platform, ok, err := installation.GetMatchingPlatform(plugin); err == nil && ok {
+	if plugin.Spec.ShortDescription != "" {
+		fmt.Fprintf(out, "SHORT DESCRIPTION: %s\n", plugin.Spec.ShortDescription)
+	}
+	if !ok {
+		fmt.Fprintf(out, "PLATFORM: %s/%s (not supported)\n", goos, goarch)
+	} else {
+		fmt.Fprintf(out, "PLATFORM: %s/%s\n", goos, goarch)
 		if platform.URI != "" {
 			fmt.Fprintf(out, "URI: %s\n", platform.URI)
 			fmt.Fprintf(out, "SHA256: %s\n", platform.Sha256)
 		}
+		fmt.Fprintf(out, "BIN: %s\n", platform.Bin)
 	}
 	if plugin.Spec.Version != "" {
 		fmt.Fprintf(out, "VERSION: %s\n", plugin.Spec.Version)
@@ -69,12 +113,61 @@ func printPluginInfo(out io.Writer, plugin index.Plugin) {
 	if plugin.Spec.Homepage != "" {
 		fmt.Fprintf(out, "HOMEPAGE: %s\n", plugin.Spec.Homepage)
 	}
+	fmt.Fprintln(out, "PLATFORMS:")
+	for _, p := range plugin.Spec.Platforms {
+		marker := " "
+		if ok && reflect.DeepEqual(p, platform) {
+			marker = "*"
+		}
+		fmt.Fprintf(out, "  %s %s\n", marker, metav1.FormatLabelSelector(p.Selector))
+	}
 	if plugin.Spec.Description != "" {
 		fmt.Fprintf(out, "DESCRIPTION: \n%s\n", plugin.Spec.Description)
 	}
 	if plugin.Spec.Caveats != "" {
-		fmt.Fprintln(out, prepCaveats(plugin.Spec.Caveats))
+		installDir := filepath.Join(paths.InstallPath(), plugin.Name)
+		if ok {
+			installDir = filepath.Join(installDir, installation.PlatformVersion(plugin, platform))
+		}
+		fmt.Fprintln(out, prepCaveats(renderCaveats(plugin, installDir)))
+	}
+	return nil
+}
+
+// caveatsData are the values that can be used in the caveats of a plugin
+// manifest as Go template fields, such as "{{.BinPath}}".
+type caveatsData struct {
+	// KrewRoot is the base directory of krew.
+	KrewRoot string
+	// BinPath is the directory of the plugin executable links.
+	BinPath string
+	// InstallDir is the directory of the installed version of the plugin.
+	InstallDir string
+}
+
+// renderCaveats returns the caveats of the plugin with the template fields
+// replaced by the krew directories. Caveats that are not a valid template are
+// returned unchanged.
+func renderCaveats(plugin index.Plugin, installDir string) string {
+	caveats := plugin.Spec.Caveats
+	if !strings.Contains(caveats, "{{") {
+		return caveats
+	}
+	t, err := template.New("caveats").Parse(caveats)
+	if err != nil {
+		glog.V(1).Infof("Printing the caveats of plugin %s unchanged, they are not a valid template: %v", plugin.Name, err)
+		return caveats
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, caveatsData{
+		KrewRoot:   paths.BasePath(),
+		BinPath:    paths.BinPath(),
+		InstallDir: installDir,
+	}); err != nil {
+		glog.V(1).Infof("Printing the caveats of plugin %s unchanged, rendering them failed: %v", plugin.Name, err)
+		return caveats
 	}
+	return b.String()
 }
 
 // prepCaveats converts caveats string to an indented format ready for printing.
@@ -96,5 +189,8 @@ func prepCaveats(s string) string {
 }
 
 func init() {
+	setArgsCompletion(infoCmd, completeIndexPlugins)
+	addPlatformFlag(infoCmd)
+	infoCmd.Flags().BoolVar(&infoURI, "uri", false, "Only print the download URI of the platform matching the current system or --platform")
 	rootCmd.AddCommand(infoCmd)
 }
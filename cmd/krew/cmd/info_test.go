@@ -0,0 +1,129 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func Test_printPluginInfo(t *testing.T) {
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec: index.PluginSpec{
+			Version:  "v1.0.0",
+			Homepage: "https://example.com/foo",
+			Caveats:  "needs jq",
+			Platforms: []index.Platform{{
+				URI:    "https://example.com/foo-linux.tar.gz",
+				Sha256: "deadbeef",
+				Bin:    "kubectl-foo",
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"os": "linux"},
+				},
+			}, {
+				URI:    "https://example.com/foo-windows.zip",
+				Sha256: "cafebabe",
+				Bin:    "kubectl-foo.exe",
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"os": "windows"},
+				},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		os        string
+		want      []string
+		wantNotIn []string
+	}{
+		{
+			name: "matching platform",
+			os:   "windows",
+			want: []string{
+				"PLATFORM: windows/amd64\n",
+				"URI: https://example.com/foo-windows.zip\n",
+				"SHA256: cafebabe\n",
+				"VERSION: v1.0.0\n",
+				"HOMEPAGE: https://example.com/foo\n",
+				"    os=linux\n",
+				"  * os=windows\n",
+				" |  needs jq\n",
+			},
+			wantNotIn: []string{"foo-linux.tar.gz"},
+		},
+		{
+			name: "no matching platform",
+			os:   "darwin",
+			want: []string{
+				"PLATFORM: darwin/amd64 (not supported)\n",
+				"    os=linux\n",
+				"    os=windows\n",
+			},
+			wantNotIn: []string{"URI:", "*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printPluginInfo(&buf, plugin, tt.os, "amd64"); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			for _, s := range tt.want {
+				if !strings.Contains(got, s) {
+					t.Errorf("output does not contain %q:\n%s", s, got)
+				}
+			}
+			for _, s := range tt.wantNotIn {
+				if strings.Contains(got, s) {
+					t.Errorf("output contains %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_printPluginURI(t *testing.T) {
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{{
+				URI: "https://example.com/foo-linux-arm64.tar.gz",
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"os": "linux", "arch": "arm64"},
+				},
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printPluginURI(&buf, plugin, "linux", "arm64"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "https://example.com/foo-linux-arm64.tar.gz\n"; got != want {
+		t.Errorf("printPluginURI() = %q, want %q", got, want)
+	}
+
+	err := printPluginURI(&bytes.Buffer{}, plugin, "linux", "amd64")
+	if err == nil || !strings.Contains(err.Error(), `plugin "foo" has no platform matching linux/amd64`) {
+		t.Errorf("printPluginURI() error = %v for a platform that doesn't match", err)
+	}
+}
@@ -16,10 +16,21 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"sigs.k8s.io/krew/pkg/environment"
 	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
 	"sigs.k8s.io/krew/pkg/index/indexscanner"
 	"sigs.k8s.io/krew/pkg/installation"
 
@@ -29,7 +40,10 @@ import (
 )
 
 func init() {
-	var manifest, forceDownloadFile *string
+	var manifest, manifestURL, forceDownloadFile, pinVersion, fromFile *string
+	var dryRun, allowEmulation, allPlatforms, force, noDeps *bool
+	var retries *int
+	var timeout *time.Duration
 
 	// installCmd represents the install command
 	installCmd := &cobra.Command{
@@ -43,54 +57,98 @@ Examples:
   To install one or multiple plugins, run:
     kubectl krew install NAME [NAME...]
 
-  To install plugins from a file, run:
-    kubectl krew install < file.txt
+  To install a specific version of a plugin, run:
+    kubectl krew install NAME@VERSION
+
+  To install plugins from a file with one NAME or NAME@VERSION per line, run:
+    kubectl krew install --from-file=plugins.txt
+  or:
+    kubectl krew install < plugins.txt
+  Blank lines and text after "#" are ignored.
 
   (For developers) To provide a custom plugin manifest, use the --manifest
   argument Similarly, instead of downloading files from a URL, you can specify a
   local --archive file:
 	kubectl krew install --manifest=FILE [--archive=FILE]
+  A manifest that is not in an index, such as the one of a pull request, can
+  also be downloaded with --manifest-url:
+	kubectl krew install --manifest-url=URL
+
+  To see what would be downloaded and installed without doing it, run:
+    kubectl krew install --dry-run NAME [NAME...]
+
+  To reinstall a plugin that is already installed (for example, to recover
+  from a corrupted installation), run:
+    kubectl krew install --force NAME
+
+  To also extract the binaries of all other platforms of a plugin, e.g. for a
+  krew root shared by systems with different OS/archs, run:
+    kubectl krew install --all-platforms NAME
 
 Remarks:
-  If a plugin is already installed, it will be skipped.
+  The plugins that a plugin requires are installed before it, unless --no-deps
+  is set. Plugins whose requirements fail to install are not installed.
+  If a plugin is already installed, it will be skipped unless --force is set.
   Failure to install a plugin will not stop the installation of other plugins.
+  When installing multiple plugins, a summary of the installed, skipped and
+  failed plugins is printed at the end.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var pluginNames = make([]string, len(args))
 			copy(pluginNames, args)
 
-			if !isTerminal(os.Stdin) && (len(pluginNames) != 0 || *manifest != "") {
-				fmt.Fprintln(os.Stderr, "WARNING: Detected stdin, but discarding it because of --manifest or args")
+			if *manifest != "" && *manifestURL != "" {
+				return errors.New("--manifest and --manifest-url can't be specified together")
 			}
+			customManifest := *manifest != "" || *manifestURL != ""
 
-			if !isTerminal(os.Stdin) && (len(pluginNames) == 0 && *manifest == "") {
-				fmt.Fprintln(os.Stderr, "Reading plugin names via stdin")
-				scanner := bufio.NewScanner(os.Stdin)
-				scanner.Split(bufio.ScanLines)
-				for scanner.Scan() {
-					if name := scanner.Text(); name != "" {
-						pluginNames = append(pluginNames, name)
-					}
+			if *fromFile != "" {
+				if len(pluginNames) != 0 || customManifest {
+					return errors.New("--from-file can't be specified with --manifest, --manifest-url or args")
+				}
+				f, err := os.Open(*fromFile)
+				if err != nil {
+					return errors.Wrap(err, "failed to open the --from-file file")
+				}
+				pluginNames, err = readPluginList(f)
+				f.Close()
+				if err != nil {
+					return errors.Wrapf(err, "failed to read plugin names from %s", *fromFile)
+				}
+			} else if !isTerminal(os.Stdin) && (len(pluginNames) != 0 || customManifest) {
+				fmt.Fprintln(os.Stderr, "WARNING: Detected stdin, but discarding it because of --manifest or args")
+			} else if !isTerminal(os.Stdin) && (len(pluginNames) == 0 && !customManifest) {
+				fmt.Fprintln(infoOut, "Reading plugin names via stdin")
+				names, err := readPluginList(os.Stdin)
+				if err != nil {
+					return errors.Wrap(err, "failed to read plugin names from stdin")
 				}
+				pluginNames = names
 			}
 
-			if len(pluginNames) != 0 && *manifest != "" {
+			if len(pluginNames) != 0 && customManifest {
 				return errors.New("must specify either specify stdin or --manifest or args")
 			}
 
-			if *forceDownloadFile != "" && *manifest == "" {
-				return errors.New("--archive can be specified only with --manifest")
+			if *allPlatforms && *forceDownloadFile != "" {
+				return errors.New("--all-platforms can't be specified with --archive")
 			}
 
-			var install []index.Plugin
-			for _, name := range pluginNames {
-				plugin, err := indexscanner.LoadPluginFileFromFS(paths.IndexPath(), name)
-				if err != nil {
-					return errors.Wrapf(err, "failed to load plugin %q from the index", name)
+			if *retries < 0 {
+				return errors.Errorf("--retries must not be negative, got %d", *retries)
+			}
+
+			if *forceDownloadFile != "" && !customManifest {
+				return errors.New("--archive can be specified only with --manifest or --manifest-url")
+			}
+			if *forceDownloadFile != "" {
+				if err := checkArchiveFile(*forceDownloadFile); err != nil {
+					return err
 				}
-				install = append(install, plugin)
 			}
 
+			install, versions, indexes, failed := loadPluginsToInstall(pluginNames)
+
 			if *manifest != "" {
 				plugin, err := indexscanner.ReadPluginFile(*manifest)
 				if err != nil {
@@ -100,56 +158,403 @@ Remarks:
 					return errors.Wrap(err, "plugin manifest validation error")
 				}
 				install = append(install, plugin)
+				versions = append(versions, "")
+			}
+			if *manifestURL != "" {
+				client, err := httpClientFromFlags(cmd)
+				if err != nil {
+					return err
+				}
+				plugin, err := fetchManifest(rootContext, client, *manifestURL)
+				if err != nil {
+					return err
+				}
+				if err := plugin.Validate(plugin.Name); err != nil {
+					return errors.Wrap(err, "plugin manifest validation error")
+				}
+				fmt.Fprintf(os.Stderr, "WARNING: Installing plugin %s from %s, which is not in a plugin index.\n"+
+					"Its manifest was not reviewed, only install it if you trust its author.\n", plugin.Name, *manifestURL)
+				install = append(install, plugin)
+				versions = append(versions, "")
 			}
 
-			if len(install) > 1 && *manifest != "" {
+			if len(install) > 1 && customManifest {
 				return errors.New("can't use --manifest option with multiple plugins")
 			}
 
 			if len(install) == 0 {
+				if len(failed) > 0 {
+					return errors.Errorf("failed to install some plugins: %+v", failed)
+				}
 				return cmd.Help()
 			}
 
+			if *pinVersion != "" {
+				if len(install) > 1 {
+					return errors.New("--version can be specified only with a single plugin")
+				}
+				if versions[0] != "" && versions[0] != *pinVersion {
+					return errors.Errorf("conflicting versions %q and --version=%q", versions[0], *pinVersion)
+				}
+				versions[0] = *pinVersion
+			}
+
+			requestedVersions := make(map[string]string, len(install))
+			for i, plugin := range install {
+				requestedVersions[plugin.Name] = versions[i]
+			}
+			if !*noDeps {
+				resolved, err := installation.ResolveDependencies(install, func(name string) (index.Plugin, error) {
+					plugin, indexName, err := indexoperations.FindPlugin(paths, name)
+					if err == nil {
+						indexes[plugin.Name] = indexName
+					}
+					return plugin, err
+				})
+				if err != nil {
+					return errors.Wrap(err, "failed to resolve the plugin requirements")
+				}
+				install = resolved
+			}
+
 			// Print plugin namesFromFile
 			for _, plugin := range install {
 				glog.V(2).Infof("Will install plugin: %s\n", plugin.Name)
 			}
 
-			var failed []string
+			goos, goarch, err := osArchFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			client, err := httpClientFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			mirrors, err := mirrorsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			extractLimits, err := extractLimitsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			signatureVerifier, err := signatureVerifierFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			var installed, skipped []string
+			// the errors of the failed plugins for --error-format=json
+			failedErrs := make(map[string]error)
 			// Do install
 			for _, plugin := range install {
-				fmt.Fprintf(os.Stderr, "Installing plugin: %s\n", plugin.Name)
-				err := installation.Install(paths, plugin, *forceDownloadFile)
-				if err == installation.ErrIsAlreadyInstalled {
+				if err := rootContext.Err(); err != nil {
+					return errors.Wrap(err, "installation was interrupted")
+				}
+				version, isRequested := requestedVersions[plugin.Name]
+				if failedReq := failedRequirements(plugin, failed); !*noDeps && len(failedReq) > 0 {
+					glog.Warningf("Skipping plugin %s, the plugins it requires failed to install: %v", plugin.Name, failedReq)
+					failed = append(failed, plugin.Name)
+					continue
+				}
+				// the --archive and --force flags only apply to the
+				// requested plugins, not to the plugins they require
+				var archive string
+				if isRequested {
+					archive = *forceDownloadFile
+				}
+				opts := installation.InstallOpts{
+					Plugin:              plugin,
+					Index:               indexes[plugin.Name],
+					Version:             version,
+					InstallPath:         paths.InstallPath(),
+					BinPath:             paths.BinPath(),
+					DownloadPath:        paths.DownloadPath(),
+					ArchiveFileOverride: archive,
+					ForceOS:             goos,
+					ForceArch:           goarch,
+					AllowEmulation:      *allowEmulation,
+					AllPlatforms:        *allPlatforms,
+					Force:               *force && isRequested,
+					Retries:             *retries,
+					HTTPClient:          client,
+					Progress:            progressFromFlags(cmd),
+					Mirrors:             mirrors,
+					AllowLocalURIs:      allowLocalURIsFromFlags(cmd),
+					ExtractLimits:       extractLimits,
+					SignatureVerifier:   signatureVerifier,
+					ResumeDownloads:     resumeFromFlags(cmd),
+					RelativeLinks:       relativeLinksFromFlags(cmd),
+					AllowHooks:          allowHooksFromFlags(cmd),
+					HookOutput:          infoOut,
+				}
+				if *dryRun {
+					plan, err := installation.PlanInstall(opts)
+					if err == installation.ErrAlreadyInstalled {
+						fmt.Fprintf(infoOut, "Skipping plugin %s, it is already installed\n", plugin.Name)
+						skipped = append(skipped, plugin.Name)
+						continue
+					}
+					if err != nil {
+						glog.Warningf("failed to resolve plugin %q: %v", plugin.Name, err)
+						failed = append(failed, plugin.Name)
+						failedErrs[plugin.Name] = err
+						continue
+					}
+					printInstallPlan(os.Stdout, plugin.Name, plan, archive)
+					continue
+				}
+
+				fmt.Fprintf(infoOut, "Installing plugin: %s\n", plugin.Name)
+				err := installWithTimeout(opts, *timeout)
+				if err == installation.ErrAlreadyInstalled {
 					glog.Warningf("Skipping plugin %s, it is already installed", plugin.Name)
+					skipped = append(skipped, plugin.Name)
 					continue
 				}
 				if err != nil {
 					glog.Warningf("failed to install plugin %q: %v", plugin.Name, err)
 					failed = append(failed, plugin.Name)
+					failedErrs[plugin.Name] = err
 					continue
 				}
-				if plugin.Spec.Caveats != "" {
-					fmt.Fprintln(os.Stderr, prepCaveats(plugin.Spec.Caveats))
-				}
-				fmt.Fprintf(os.Stderr, "Installed plugin: %s\n", plugin.Name)
+				printInstalledCaveats(infoOut, plugin)
+				fmt.Fprintf(infoOut, "Installed plugin: %s\n", plugin.Name)
+				installed = append(installed, plugin.Name)
+			}
+			if !*dryRun && len(installed)+len(skipped)+len(failed) > 1 {
+				printSummary(infoOut, []summaryGroup{
+					{"Installed", installed},
+					{"Skipped (already installed)", skipped},
+					{"Failed", failed},
+				})
 			}
 			if len(failed) > 0 {
-				return errors.Errorf("failed to install some plugins: %+v", failed)
+				return failedPluginsError("install", failed, failedErrs)
 			}
 			return nil
 		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if *manifest == "" {
-				return ensureIndexUpdated(cmd, args)
+			if *timeout < 0 {
+				return errors.Errorf("--timeout must not be negative, got %s", *timeout)
 			}
-			glog.V(4).Infof("--manifest specified, not ensuring plugin index")
+			if !*dryRun {
+				// fail before downloading anything if plugins can't be installed
+				if err := environment.EnsureWritableDirs(paths); err != nil {
+					return err
+				}
+			}
+			if *manifest == "" && *manifestURL == "" {
+				if *dryRun {
+					// a dry run must not reach the network
+					return checkIndex(cmd, args)
+				}
+				return ensureIndexUpdatedOrExists(cmd, args)
+			}
+			glog.V(4).Infof("--manifest or --manifest-url specified, not ensuring plugin index")
 			return nil
 		},
 	}
 
 	manifest = installCmd.Flags().String("manifest", "", "(Development-only) specify plugin manifest directly.")
+	manifestURL = installCmd.Flags().String("manifest-url", "", "Download the plugin manifest from the http(s) URL instead of using an index")
 	forceDownloadFile = installCmd.Flags().String("archive", "", "(Development-only) force all downloads to use the specified file")
+	pinVersion = installCmd.Flags().String("version", "", "Install the specified version of the plugin, fails if the index has a different version")
+	dryRun = installCmd.Flags().Bool("dry-run", false, "Print the resolved version, download URI, file operations and executable link of the plugins without installing them")
+	force = installCmd.Flags().Bool("force", false, "Reinstall plugins that are already installed")
+	fromFile = installCmd.Flags().String("from-file", "", "Install the plugins listed in the file, one NAME or NAME@VERSION per line")
+	noDeps = installCmd.Flags().Bool("no-deps", false, "Don't install the plugins that the plugins require")
+	allowEmulation = installCmd.Flags().Bool("allow-emulation", false, "Install the binary of an emulated architecture (e.g. darwin/amd64 on darwin/arm64) if the plugin has none for the current one")
+	allPlatforms = installCmd.Flags().Bool("all-platforms", false, "Also download and extract the binaries of the other platforms of the plugins, only the binary of the current platform is linked")
+	timeout = installCmd.Flags().Duration("timeout", 5*time.Minute, "Maximum time to download and extract each plugin, 0 disables the timeout")
+	addAllowHooksFlag(installCmd)
+	addAllowLocalURIsFlag(installCmd)
+	addRelativeLinksFlag(installCmd)
+	addExtractLimitFlags(installCmd)
+	addTrustedKeyFlag(installCmd)
+	addResumeFlag(installCmd)
+	retries = installCmd.Flags().Int("retries", installation.DefaultDownloadRetries, "Number of times to retry downloads failing with network or server errors")
+	addNoUpdateIndexFlag(installCmd)
+	addPlatformFlag(installCmd)
+	addCACertFlag(installCmd)
+	addDownloadMirrorFlag(installCmd)
+
+	setArgsCompletion(installCmd, completeIndexPlugins)
 
 	rootCmd.AddCommand(installCmd)
 }
+
+// failedRequirements returns the plugins required by plugin that are in failed.
+func failedRequirements(plugin index.Plugin, failed []string) []string {
+	var out []string
+	for _, name := range plugin.Spec.Requires {
+		if containsString(failed, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// maxManifestSize is the maximum size of a plugin manifest downloaded with
+// --manifest-url.
+const maxManifestSize = 1 << 20
+
+// fetchManifest downloads and parses the plugin manifest at the http(s) URL
+// uri with client.
+func fetchManifest(ctx context.Context, client *http.Client, uri string) (index.Plugin, error) {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return index.Plugin{}, errors.Errorf("invalid manifest URL %q, must be an http(s) URL", uri)
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to create the request for %s", uri)
+	}
+	glog.V(2).Infof("Downloading the plugin manifest from %s", uri)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to download the manifest from %s", uri)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return index.Plugin{}, errors.Errorf("failed to download the manifest from %s, status code %d", uri, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return index.Plugin{}, errors.Wrapf(err, "failed to download the manifest from %s", uri)
+	}
+	if len(b) > maxManifestSize {
+		return index.Plugin{}, errors.Errorf("the manifest at %s is larger than %d bytes", uri, maxManifestSize)
+	}
+	plugin, err := indexscanner.DecodePluginFile(bytes.NewReader(b))
+	return plugin, errors.Wrapf(err, "failed to parse the manifest from %s", uri)
+}
+
+// installWithTimeout installs the plugin, aborting the download and the
+// extraction if they take longer than timeout. A zero timeout disables it.
+func installWithTimeout(opts installation.InstallOpts, timeout time.Duration) error {
+	ctx := rootContext
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := installation.InstallPluginContext(ctx, opts)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errors.Wrapf(ctx.Err(), "installing the plugin timed out after %s (increase --timeout)", timeout)
+	}
+	return err
+}
+
+// printInstalledCaveats prints the caveats of the installed plugin, rendered
+// with the directory of its installed version.
+func printInstalledCaveats(out io.Writer, plugin index.Plugin) {
+	if plugin.Spec.Caveats == "" {
+		return
+	}
+	installDir := filepath.Join(paths.InstallPath(), plugin.Name)
+	if r, err := installation.ReadReceipt(paths.InstallPath(), plugin.Name); err == nil {
+		installDir = filepath.Join(installDir, r.Version)
+	} else {
+		glog.V(1).Infof("Failed to read the receipt of plugin %s: %v", plugin.Name, err)
+	}
+	fmt.Fprintln(out, prepCaveats(renderCaveats(plugin, installDir)))
+}
+
+// readPluginList reads plugin names (or NAME@VERSION references) from r, one
+// per line. Blank lines and the text after a "#" are ignored.
+func readPluginList(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// loadPluginsToInstall loads the plugins referenced by NAME or NAME@VERSION
+// from the index. It returns the plugins with their requested versions, the
+// indexes they are from by plugin name, and the references that failed to load,
+// which don't stop loading the others.
+func loadPluginsToInstall(refs []string) (plugins []index.Plugin, versions []string, indexes map[string]string, failed []string) {
+	indexes = make(map[string]string)
+	for _, ref := range refs {
+		name, version, err := splitPluginVersion(ref)
+		if err != nil {
+			glog.Warningf("%v", err)
+			failed = append(failed, ref)
+			continue
+		}
+		plugin, indexName, err := indexoperations.FindPlugin(paths, name)
+		if err != nil {
+			glog.Warningf("failed to load plugin %q from the index: %v", name, err)
+			failed = append(failed, ref)
+			continue
+		}
+		plugins = append(plugins, plugin)
+		versions = append(versions, version)
+		indexes[plugin.Name] = indexName
+	}
+	return plugins, versions, indexes, failed
+}
+
+// splitPluginVersion splits a NAME@VERSION argument into the plugin name and
+// version. The version is empty if it is not specified.
+func splitPluginVersion(arg string) (string, string, error) {
+	i := strings.LastIndex(arg, "@")
+	if i < 0 {
+		return arg, "", nil
+	}
+	name, version := arg[:i], arg[i+1:]
+	if name == "" || version == "" {
+		return "", "", errors.Errorf("invalid plugin %q, must be in NAME@VERSION format", arg)
+	}
+	return name, version, nil
+}
+
+// checkArchiveFile returns an error if the --archive file at path is not a
+// readable regular file.
+func checkArchiveFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return errors.Errorf("--archive file %q does not exist", path)
+	} else if err != nil {
+		return errors.Errorf("--archive file %q is not readable: %v", path, err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Errorf("--archive file %q is not readable: %v", path, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return errors.Errorf("--archive must be a regular file, %q is not", path)
+	}
+	return nil
+}
+
+// printInstallPlan prints the operations installing a plugin would perform.
+func printInstallPlan(out io.Writer, name string, plan installation.InstallPlan, archiveOverride string) {
+	fmt.Fprintf(out, "Plugin: %s\n", name)
+	fmt.Fprintf(out, "  Version: %s\n", plan.Version)
+	if plan.ReplacedVersion != "" {
+		fmt.Fprintf(out, "  Replaces installed version: %s\n", plan.ReplacedVersion)
+	}
+	if archiveOverride != "" {
+		fmt.Fprintf(out, "  URI: %s (overridden by --archive=%s)\n", plan.Platform.URI, archiveOverride)
+	} else {
+		fmt.Fprintf(out, "  URI: %s\n", plan.Platform.URI)
+	}
+	fmt.Fprintf(out, "  Install directory: %s\n", plan.InstallDir)
+	fmt.Fprintln(out, "  File operations:")
+	for _, fo := range plan.Platform.Files {
+		fmt.Fprintf(out, "    %s -> %s\n", fo.From, fo.To)
+	}
+	fmt.Fprintf(out, "  Bin: %s -> %s\n", plan.BinLink, plan.BinTarget)
+}
@@ -0,0 +1,257 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/installation"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_splitPluginVersion(t *testing.T) {
+	tests := []struct {
+		arg         string
+		wantName    string
+		wantVersion string
+		wantErr     bool
+	}{
+		{arg: "foo", wantName: "foo"},
+		{arg: "foo@v1.0.0", wantName: "foo", wantVersion: "v1.0.0"},
+		{arg: "company/foo@v1.0.0", wantName: "company/foo", wantVersion: "v1.0.0"},
+		{arg: "foo@", wantErr: true},
+		{arg: "@v1.0.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		name, version, err := splitPluginVersion(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitPluginVersion(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if name != tt.wantName || version != tt.wantVersion {
+			t.Errorf("splitPluginVersion(%q) = (%q, %q), want (%q, %q)", tt.arg, name, version, tt.wantName, tt.wantVersion)
+		}
+	}
+}
+
+func Test_readPluginList(t *testing.T) {
+	in := "# plugins for the team\nfoo\n\n  bar@v1.0.0  \nbaz # needed for debugging\n   # indented comment\n"
+	got, err := readPluginList(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"foo", "bar@v1.0.0", "baz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("readPluginList() = %q, want %q", got, want)
+	}
+}
+
+func Test_loadPluginsToInstall(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	manifest, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "pkg", "index", "indexscanner", "testdata", "testindex", "plugins", "foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write(filepath.Join("index", "plugins", "foo.yaml"), manifest)
+
+	defer func(p environment.Paths) { paths = p }(paths)
+	defer os.Setenv("KREW_ROOT", os.Getenv("KREW_ROOT"))
+	os.Setenv("KREW_ROOT", tmpDir.Root())
+	paths = environment.MustGetKrewPaths()
+
+	plugins, versions, indexes, failed := loadPluginsToInstall([]string{"foo", "missing", "foo@", "foo@v1.0.0"})
+	var names []string
+	for _, p := range plugins {
+		names = append(names, p.Name)
+	}
+	if want := []string{"foo", "foo"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("loadPluginsToInstall() plugins = %q, want %q", names, want)
+	}
+	if want := []string{"", "v1.0.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("loadPluginsToInstall() versions = %q, want %q", versions, want)
+	}
+	if want := map[string]string{"foo": "default"}; !reflect.DeepEqual(indexes, want) {
+		t.Errorf("loadPluginsToInstall() indexes = %v, want %v", indexes, want)
+	}
+	if want := []string{"missing", "foo@"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("loadPluginsToInstall() failed = %q, want %q", failed, want)
+	}
+}
+
+func Test_printInstalledCaveats(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write(filepath.Join("store", "foo", "receipt.yaml"), []byte("plugin: foo\nversion: v1.0.0\n"))
+
+	defer func(p environment.Paths) { paths = p }(paths)
+	defer os.Setenv("KREW_ROOT", os.Getenv("KREW_ROOT"))
+	os.Setenv("KREW_ROOT", tmpDir.Root())
+	paths = environment.MustGetKrewPaths()
+
+	plugin := index.Plugin{Spec: index.PluginSpec{Caveats: "Add {{.InstallDir}}/lib to LD_LIBRARY_PATH."}}
+	plugin.Name = "foo"
+	var out bytes.Buffer
+	printInstalledCaveats(&out, plugin)
+	if want := " |  Add " + filepath.Join(tmpDir.Root(), "store", "foo", "v1.0.0") + "/lib to LD_LIBRARY_PATH.\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("printInstalledCaveats() = %q, want it to contain %q", out.String(), want)
+	}
+
+	// caveats that are not a valid template are printed unchanged
+	plugin.Spec.Caveats = "Requires {{jq}}."
+	out.Reset()
+	printInstalledCaveats(&out, plugin)
+	if want := " |  Requires {{jq}}.\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("printInstalledCaveats() = %q, want it to contain %q", out.String(), want)
+	}
+
+	plugin.Spec.Caveats = ""
+	out.Reset()
+	printInstalledCaveats(&out, plugin)
+	if out.Len() != 0 {
+		t.Errorf("printInstalledCaveats() = %q for a plugin without caveats", out.String())
+	}
+}
+
+func Test_checkArchiveFile(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("foo.tar.gz", []byte("archive"))
+	tmpDir.Write("dir/.keep", nil)
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"regular file", tmpDir.Path("foo.tar.gz"), ""},
+		{"missing", tmpDir.Path("missing.tar.gz"), "does not exist"},
+		{"directory", tmpDir.Path("dir"), "must be a regular file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkArchiveFile(tt.path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkArchiveFile(%q) error = %v", tt.path, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.HasPrefix(err.Error(), "--archive") {
+				t.Errorf("checkArchiveFile(%q) error = %v, want an --archive error containing %q", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_installWithTimeout(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	// the server sends the archive slower than the timeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec: index.PluginSpec{Platforms: []index.Platform{{
+			URI:      server.URL + "/foo.tar.gz",
+			Sha256:   "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116",
+			Bin:      "kubectl-foo",
+			Selector: &metav1.LabelSelector{},
+		}}},
+	}
+	start := time.Now()
+	err := installWithTimeout(installation.InstallOpts{
+		Plugin:       plugin,
+		InstallPath:  tmpDir.Path("store"),
+		BinPath:      tmpDir.Path("bin"),
+		DownloadPath: tmpDir.Path("downloads"),
+	}, 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Fatalf("installWithTimeout() error = %v, want a timeout", err)
+	}
+	if code := errorCode(err); code != "timeout" {
+		t.Errorf("errorCode() of the timeout = %q, want %q", code, "timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("installWithTimeout() returned after %s, expected the download to be aborted", elapsed)
+	}
+	for _, dir := range []string{"store/foo", "downloads/foo"} {
+		if _, err := os.Stat(tmpDir.Path(dir)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed after the timeout, err = %v", dir, err)
+		}
+	}
+}
+
+func Test_fetchManifest(t *testing.T) {
+	manifest, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "pkg", "index", "indexscanner", "testdata", "testindex", "plugins", "foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/foo.yaml":
+			w.Write(manifest)
+		case "/large.yaml":
+			w.Write(bytes.Repeat([]byte("#"), maxManifestSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	plugin, err := fetchManifest(context.Background(), server.Client(), server.URL+"/foo.yaml")
+	if err != nil {
+		t.Fatalf("fetchManifest() error = %v", err)
+	}
+	if plugin.Name != "foo" {
+		t.Errorf("fetchManifest() plugin name = %q, want foo", plugin.Name)
+	}
+	if err := plugin.Validate(plugin.Name); err != nil {
+		t.Errorf("expected the fetched manifest to be valid: %v", err)
+	}
+	if _, ok, err := installation.GetMatchingPlatformFor(plugin, "linux", "amd64"); err != nil || !ok {
+		t.Errorf("GetMatchingPlatformFor() = %v, %v, expected a matching platform", ok, err)
+	}
+
+	for _, uri := range []string{server.URL + "/missing.yaml", server.URL + "/large.yaml", "file:///etc/passwd", "foo.yaml"} {
+		if _, err := fetchManifest(context.Background(), server.Client(), uri); err == nil {
+			t.Errorf("fetchManifest(%q) expected an error", uri)
+		}
+	}
+}
@@ -15,19 +15,30 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
 	"sigs.k8s.io/krew/pkg/installation"
 )
 
 func init() {
+	var repair *bool
+	var outputFormat *string
+	var sortKey *string
+
 	// listCmd represents the list command
 	listCmd := &cobra.Command{
 		Use:   "list",
@@ -37,52 +48,325 @@ func init() {
 Remarks:
   Redirecting the output of this command to a program or file will only print
   the names of the plugins installed. This output can be piped back to the
-  "install" command.`,
+  "install" command.
+
+  Plugins whose installation was interrupted or whose installation directory
+  was removed manually are listed as broken. Reinstall them with "kubectl krew
+  install --force", or remove them with "kubectl krew uninstall". Run with
+  --repair to remove the leftover links of removed installation directories.
+
+  With -o json or -o yaml, the name, version and the platform selector of the
+  installed plugins are printed. The platform is null if the plugin is no longer
+  in the index.
+
+  The plugins are sorted by name. With --sort=version, they are sorted from the
+  oldest to the newest version, and with --sort=date, from the earliest to the
+  latest installation. Plugins installed by krew versions that didn't record
+  the installation date are listed last.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if *repair {
+				removed, err := installation.RemoveDanglingLinks(paths.BinPath())
+				if err != nil {
+					return errors.Wrap(err, "failed to remove dangling plugin links")
+				}
+				for _, path := range removed {
+					fmt.Fprintf(infoOut, "Removed dangling link %s\n", path)
+				}
+			}
+
 			plugins, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
 			if err != nil {
 				return errors.Wrap(err, "failed to find all installed versions")
 			}
+			broken, err := installation.ListBrokenPlugins(paths.InstallPath(), paths.BinPath())
+			if err != nil {
+				return errors.Wrap(err, "failed to find broken plugin installations")
+			}
+
+			switch *outputFormat {
+			case outputFormatJSON, outputFormatYAML:
+				goos, goarch, err := installation.OSArch("", "")
+				if err != nil {
+					return errors.Wrap(err, "failed to determine the platform")
+				}
+				inventory, err := listInventory(plugins, broken, goos, goarch)
+				if err != nil {
+					return err
+				}
+				markRenamedPlugins(inventory, renamedPlugins())
+				sortInstalledPlugins(inventory, *sortKey)
+				if *outputFormat == outputFormatJSON {
+					return printJSON(os.Stdout, inventory)
+				}
+				return printYAML(os.Stdout, inventory)
+			}
 
 			// return sorted list of plugin names when piped to other commands or file
 			if !isTerminal(os.Stdout) {
+				installed := installedPluginList(plugins, nil)
+				sortInstalledPlugins(installed, *sortKey)
 				var names []string
-				for name := range plugins {
-					names = append(names, name)
+				for _, p := range installed {
+					names = append(names, p.Name)
 				}
-				sort.Strings(names)
 				fmt.Fprintln(os.Stdout, strings.Join(names, "\n"))
 				return nil
 			}
 
 			// print table
+			all := installedPluginList(plugins, broken)
+			markRenamedPlugins(all, renamedPlugins())
+			sortInstalledPlugins(all, *sortKey)
 			var rows [][]string
-			for p, version := range plugins {
-				rows = append(rows, []string{p, version})
+			var renamed []installedPlugin
+			for _, p := range all {
+				version := p.Version
+				if p.Status != "" {
+					version = p.Status
+				}
+				if p.RenamedTo != "" {
+					version += fmt.Sprintf(" (renamed to %s)", p.RenamedTo)
+					renamed = append(renamed, p)
+				}
+				rows = append(rows, []string{p.Name, version})
+			}
+			if err := printTable(os.Stdout, []string{"PLUGIN", "VERSION"}, rows); err != nil {
+				return err
+			}
+			for _, p := range renamed {
+				fmt.Fprintf(os.Stderr, "\nPlugin %s was renamed to %s. To get its updates, run \"kubectl krew uninstall %s\" and \"kubectl krew install %s\".\n",
+					p.Name, p.RenamedTo, p.Name, p.RenamedTo)
 			}
-			rows = sortByFirstColumn(rows)
-			return printTable(os.Stdout, []string{"PLUGIN", "VERSION"}, rows)
+			if len(broken) > 0 {
+				fmt.Fprintf(os.Stderr, "\nSome plugin installations are broken. Reinstall them with \"kubectl krew install --force %s\", or remove them with \"kubectl krew uninstall %s\".\n",
+					strings.Join(broken, " "), strings.Join(broken, " "))
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(*outputFormat); err != nil {
+				return err
+			}
+			if !containsString(listSortKeys, *sortKey) {
+				return errors.Errorf("unsupported sort key %q, must be one of: %s", *sortKey, strings.Join(listSortKeys, ", "))
+			}
+			return checkIndex(cmd, args)
+		},
+		PostRun: func(cmd *cobra.Command, args []string) {
+			notifyUpgrades(infoOut)
 		},
-		PreRunE: checkIndex,
 	}
 
+	repair = listCmd.Flags().Bool("repair", false, "Remove the links of plugins whose installation directory does not exist")
+	outputFormat = listCmd.Flags().StringP("output", "o", outputFormatTable, "Output format. One of: table|json|yaml")
+	sortKey = listCmd.Flags().String("sort", listSortName, "Sort the plugins by "+strings.Join(listSortKeys, "|"))
 	rootCmd.AddCommand(listCmd)
 }
 
+// installedPlugin is a single plugin entry printed by the list command in
+// the json and yaml output formats.
+type installedPlugin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+
+	// Status is "broken" for plugins whose installation is incomplete, which
+	// have no version.
+	Status string `json:"status,omitempty"`
+
+	// Platform is the selector of the plugin platform matching the system,
+	// it is nil if the plugin is not in the index or no platform matches.
+	Platform *string `json:"platform"`
+
+	// RenamedTo is the current name of the plugin if it is installed under a
+	// former name of a plugin in the index.
+	RenamedTo string `json:"renamedTo,omitempty"`
+}
+
+// Sort keys accepted by the --sort flag of the list command.
+const (
+	listSortName    = "name"
+	listSortVersion = "version"
+	listSortDate    = "date"
+)
+
+var listSortKeys = []string{listSortName, listSortVersion, listSortDate}
+
+// installedPluginList returns the installed and broken plugins sorted by name
+// like lessName.
+func installedPluginList(plugins map[string]string, broken []string) []installedPlugin {
+	all := make([]installedPlugin, 0, len(plugins)+len(broken))
+	for name, version := range plugins {
+		all = append(all, installedPlugin{Name: name, Version: version})
+	}
+	for _, name := range broken {
+		all = append(all, installedPlugin{Name: name, Status: pluginStatusBroken})
+	}
+	// the map order is random, names only differing in case are sorted
+	// byte-wise
+	sort.Slice(all, func(a, b int) bool {
+		if strings.EqualFold(all[a].Name, all[b].Name) {
+			return all[a].Name < all[b].Name
+		}
+		return lessName(all[a].Name, all[b].Name)
+	})
+	return all
+}
+
+// lessName returns true if plugin name a sorts before b. Names are compared
+// case-insensitively, so that "apple" sorts before "Zoo".
+func lessName(a, b string) bool {
+	return strings.ToLower(a) < strings.ToLower(b)
+}
+
+// sortInstalledPlugins sorts plugins, which are sorted by name, by the given
+// sort key. Plugins with the same version or installation date stay sorted by
+// name. Plugins without a version or a recorded installation date are sorted
+// last.
+func sortInstalledPlugins(plugins []installedPlugin, key string) {
+	switch key {
+	case listSortVersion:
+		sort.SliceStable(plugins, func(a, b int) bool {
+			va, vb := plugins[a].Version, plugins[b].Version
+			if va == "" || vb == "" {
+				return va != "" && vb == ""
+			}
+			return installation.CompareVersions(va, vb) < 0
+		})
+	case listSortDate:
+		installedAt := make(map[string]time.Time, len(plugins))
+		for _, p := range plugins {
+			r, err := installation.ReadReceipt(paths.InstallPath(), p.Name)
+			if err != nil {
+				glog.V(2).Infof("Can't read the installation date of plugin %s: %v", p.Name, err)
+				continue
+			}
+			installedAt[p.Name] = r.InstalledAt
+		}
+		sort.SliceStable(plugins, func(a, b int) bool {
+			ta, tb := installedAt[plugins[a].Name], installedAt[plugins[b].Name]
+			if ta.IsZero() || tb.IsZero() {
+				return !ta.IsZero() && tb.IsZero()
+			}
+			return ta.Before(tb)
+		})
+	}
+}
+
+// listInventory returns the installed and broken plugins sorted by name, with
+// the platforms they match for goos/goarch in the index.
+func listInventory(plugins map[string]string, broken []string, goos, goarch string) ([]installedPlugin, error) {
+	all := installedPluginList(plugins, broken)
+
+	out := make([]installedPlugin, 0, len(all))
+	for _, p := range all {
+		name := p.Name
+		plugin, err := indexoperations.LoadPlugin(paths, name)
+		if os.IsNotExist(err) {
+			glog.V(2).Infof("Plugin %s is not in the index", name)
+		} else if err != nil {
+			glog.Warningf("failed to load plugin %q from the index: %v", name, err)
+		} else {
+			platform, ok, err := installation.GetMatchingPlatformFor(plugin, goos, goarch)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get the matching platform for plugin %s", name)
+			}
+			if ok {
+				selector := metav1.FormatLabelSelector(platform.Selector)
+				p.Platform = &selector
+			}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// renamedPlugins returns the names of the plugins in the default index by their
+// former names. It is empty if the index can't be loaded.
+func renamedPlugins() map[string]string {
+	list, err := indexscanner.LoadPluginListFromFSCached(paths.IndexPath())
+	if err != nil {
+		glog.V(2).Infof("Can't find the renamed plugins: %v", err)
+		return nil
+	}
+	return indexscanner.RenamedPlugins(list)
+}
+
+// markRenamedPlugins sets the current name of the plugins that are installed
+// under a former name in renamed.
+func markRenamedPlugins(plugins []installedPlugin, renamed map[string]string) {
+	for i, p := range plugins {
+		plugins[i].RenamedTo = renamed[p.Name]
+	}
+}
+
+// pluginStatusBroken is the status of plugins that have an installation
+// directory, but no working link, e.g. because their installation was
+// interrupted.
+const pluginStatusBroken = "broken"
+
+// Output formats accepted by the --output flag.
+const (
+	outputFormatTable = "table"
+	outputFormatJSON  = "json"
+	outputFormatYAML  = "yaml"
+	outputFormatName  = "name"
+	outputFormatWide  = "wide"
+	// outputFormatNDJSON prints newline delimited JSON, one object per line.
+	outputFormatNDJSON = "ndjson"
+)
+
+// validateOutputFormat returns an error if format is not table, json, yaml or
+// one of the additional formats the command supports.
+func validateOutputFormat(format string, additional ...string) error {
+	formats := append([]string{outputFormatTable, outputFormatJSON, outputFormatYAML}, additional...)
+	if containsString(formats, format) {
+		return nil
+	}
+	return errors.Errorf("unsupported output format %q, must be one of: %s", format, strings.Join(formats, ", "))
+}
+
 func printTable(out io.Writer, columns []string, rows [][]string) error {
 	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
-	fmt.Fprintf(w, strings.Join(columns, "\t"))
+	fmt.Fprint(w, strings.Join(columns, "\t"))
 	fmt.Fprintln(w)
 	for _, values := range rows {
-		fmt.Fprintf(w, strings.Join(values, "\t"))
+		fmt.Fprint(w, strings.Join(values, "\t"))
 		fmt.Fprintln(w)
 	}
 	return w.Flush()
 }
 
-func sortByFirstColumn(rows [][]string) [][]string {
-	sort.Slice(rows, func(a, b int) bool {
-		return rows[a][0] < rows[b][0]
-	})
-	return rows
+func printJSON(out io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal json output")
+	}
+	_, err = fmt.Fprintln(out, string(b))
+	return err
+}
+
+func printYAML(out io.Writer, v interface{}) error {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal yaml output")
+	}
+	_, err = out.Write(b)
+	return err
+}
+
+// summaryGroup is a group of plugins in the summary of a command operating on
+// multiple plugins, such as the plugins that failed.
+type summaryGroup struct {
+	title   string
+	plugins []string
+}
+
+// printSummary prints the plugins of the non-empty groups.
+func printSummary(out io.Writer, groups []summaryGroup) {
+	fmt.Fprintln(out, "\nSummary:")
+	for _, group := range groups {
+		if len(group.plugins) > 0 {
+			fmt.Fprintf(out, "  %s: %s\n", group.title, strings.Join(group.plugins, ", "))
+		}
+	}
 }
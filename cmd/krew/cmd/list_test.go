@@ -0,0 +1,127 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_listInventory(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	manifest, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "pkg", "index", "indexscanner", "testdata", "testindex", "plugins", "foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write(filepath.Join("index", "plugins", "foo.yaml"), manifest)
+
+	defer func(p environment.Paths) { paths = p }(paths)
+	defer os.Setenv("KREW_ROOT", os.Getenv("KREW_ROOT"))
+	os.Setenv("KREW_ROOT", tmpDir.Root())
+	paths = environment.MustGetKrewPaths()
+
+	got, err := listInventory(map[string]string{"foo": "v1.0.0", "gone": "v2.0.0"}, []string{"bar"}, "linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	platform := "os in (linux,macos)"
+	want := []installedPlugin{
+		{Name: "bar", Status: pluginStatusBroken},
+		{Name: "foo", Version: "v1.0.0", Platform: &platform},
+		{Name: "gone", Version: "v2.0.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listInventory() = %+v, want %+v", got, want)
+	}
+
+	got, err = listInventory(map[string]string{"foo": "v1.0.0"}, nil, "darwin", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Platform != nil {
+		t.Errorf("expected no platform for an unsupported system, got %q", *got[0].Platform)
+	}
+}
+
+func Test_sortInstalledPlugins(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write(filepath.Join("store", "foo", "receipt.yaml"), []byte("plugin: foo\nversion: v1.10.0\ninstalledAt: 2020-03-01T00:00:00Z\n"))
+	tmpDir.Write(filepath.Join("store", "bar", "receipt.yaml"), []byte("plugin: bar\nversion: v1.9.0\ninstalledAt: 2020-01-01T00:00:00Z\n"))
+	tmpDir.Write(filepath.Join("store", "qux", "receipt.yaml"), []byte("plugin: qux\nversion: deadbeef\ninstalledAt: 2020-02-01T00:00:00Z\n"))
+
+	defer func(p environment.Paths) { paths = p }(paths)
+	defer os.Setenv("KREW_ROOT", os.Getenv("KREW_ROOT"))
+	os.Setenv("KREW_ROOT", tmpDir.Root())
+	paths = environment.MustGetKrewPaths()
+
+	// baz has no receipt and broken has no version
+	plugins := map[string]string{"foo": "v1.10.0", "bar": "v1.9.0", "baz": "v1.9.0", "qux": "deadbeef"}
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{listSortName, []string{"bar", "baz", "broken", "foo", "qux"}},
+		{listSortVersion, []string{"bar", "baz", "foo", "qux", "broken"}},
+		{listSortDate, []string{"bar", "qux", "foo", "baz", "broken"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			list := installedPluginList(plugins, []string{"broken"})
+			sortInstalledPlugins(list, tt.key)
+			var got []string
+			for _, p := range list {
+				got = append(got, p.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortInstalledPlugins(%s) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_installedPluginList_mixedCase(t *testing.T) {
+	plugins := map[string]string{"Zoo": "v1", "apple": "v1", "Banana": "v1", "banana": "v1", "cherry": "v1"}
+	want := []string{"apple", "Banana", "banana", "broken", "cherry", "Zoo"}
+	for i := 0; i < 10; i++ { // map iteration order is random
+		var got []string
+		for _, p := range installedPluginList(plugins, []string{"broken"}) {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("installedPluginList() = %v, want %v", got, want)
+		}
+	}
+}
+
+func Test_markRenamedPlugins(t *testing.T) {
+	list := installedPluginList(map[string]string{"old-foo": "v1", "bar": "v2"}, []string{"old-baz"})
+	markRenamedPlugins(list, map[string]string{"old-foo": "foo", "old-baz": "baz"})
+	got := make(map[string]string)
+	for _, p := range list {
+		got[p.Name] = p.RenamedTo
+	}
+	want := map[string]string{"old-foo": "foo", "bar": "", "old-baz": "baz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("markRenamedPlugins() = %v, want %v", got, want)
+	}
+}
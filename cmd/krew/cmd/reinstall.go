@@ -0,0 +1,170 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
+	"sigs.k8s.io/krew/pkg/installation"
+)
+
+var reinstallToLatest bool
+
+// reinstallAllCmd represents the reinstall-all command
+var reinstallAllCmd = &cobra.Command{
+	Use:   "reinstall-all",
+	Short: "Reinstall all installed plugins from the index",
+	Long: `Reinstall all installed plugins from their manifests in the index, e.g. after
+moving the krew root directory.
+
+The plugins are reinstalled at their installed version, which fails for the
+plugins whose version is no longer in the index. Use --to-latest to reinstall
+them at the version in the index instead. Plugins that fail to reinstall don't
+stop the reinstallation of the other plugins.
+
+Examples:
+  kubectl krew reinstall-all
+  kubectl krew reinstall-all --to-latest`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+		if err != nil {
+			return errors.Wrap(err, "failed to find all installed versions")
+		}
+		if len(installed) == 0 {
+			fmt.Fprintln(infoOut, "No plugins are installed")
+			return nil
+		}
+
+		goos, goarch, err := osArchFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		client, err := httpClientFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		mirrors, err := mirrorsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		extractLimits, err := extractLimitsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		signatureVerifier, err := signatureVerifierFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		opts := installation.InstallOpts{
+			InstallPath:       paths.InstallPath(),
+			BinPath:           paths.BinPath(),
+			DownloadPath:      paths.DownloadPath(),
+			ForceOS:           goos,
+			ForceArch:         goarch,
+			Retries:           installation.DefaultDownloadRetries,
+			HTTPClient:        client,
+			Progress:          progressFromFlags(cmd),
+			Mirrors:           mirrors,
+			AllowLocalURIs:    allowLocalURIsFromFlags(cmd),
+			ExtractLimits:     extractLimits,
+			SignatureVerifier: signatureVerifier,
+			ResumeDownloads:   resumeFromFlags(cmd),
+			RelativeLinks:     relativeLinksFromFlags(cmd),
+			AllowHooks:        allowHooksFromFlags(cmd),
+			HookOutput:        infoOut,
+		}
+
+		install := func(opts installation.InstallOpts) error {
+			return installation.InstallPluginContext(rootContext, opts)
+		}
+		reinstalled, failed := reinstallPlugins(infoOut, installed, reinstallToLatest, opts, install)
+		if err := rootContext.Err(); err != nil {
+			return errors.Wrap(err, "reinstallation was interrupted")
+		}
+		printSummary(infoOut, []summaryGroup{
+			{"Reinstalled", reinstalled},
+			{"Failed", failed},
+		})
+		if len(failed) > 0 {
+			return errors.Errorf("failed to reinstall some plugins: %+v", failed)
+		}
+		return nil
+	},
+	PreRunE: ensureIndexUpdatedOrExists,
+	Args:    cobra.NoArgs,
+}
+
+// reinstallPlugins reinstalls the installed plugins, a map of the plugin names
+// to their versions, in the order of their names with install. The plugins are
+// reinstalled at their installed versions unless toLatest is set, opts
+// configures everything else. It returns the names of the reinstalled plugins
+// and of the plugins that failed to reinstall, and stops once rootContext is
+// canceled.
+func reinstallPlugins(out io.Writer, installed map[string]string, toLatest bool, opts installation.InstallOpts, install func(installation.InstallOpts) error) (reinstalled, failed []string) {
+	names := make([]string, 0, len(installed))
+	for name := range installed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if rootContext.Err() != nil {
+			break
+		}
+		plugin, indexName, err := indexoperations.FindPlugin(paths, receiptPluginRef(name))
+		if err != nil {
+			glog.Warningf("failed to load the index file for plugin %q: %v", name, err)
+			failed = append(failed, name)
+			continue
+		}
+
+		opts.Plugin = plugin
+		opts.Index = indexName
+		opts.Version = ""
+		if !toLatest {
+			opts.Version = installed[name]
+		}
+		opts.Force = true
+		fmt.Fprintf(out, "Reinstalling plugin: %s\n", name)
+		if err := install(opts); err != nil {
+			glog.Warningf("failed to reinstall plugin %q: %v", name, err)
+			failed = append(failed, name)
+			continue
+		}
+		fmt.Fprintf(out, "Reinstalled plugin: %s\n", name)
+		reinstalled = append(reinstalled, name)
+	}
+	return reinstalled, failed
+}
+
+func init() {
+	reinstallAllCmd.Flags().BoolVar(&reinstallToLatest, "to-latest", false, "Reinstall the plugins at the version in the index instead of their installed version")
+	addNoUpdateIndexFlag(reinstallAllCmd)
+	addDownloadMirrorFlag(reinstallAllCmd)
+	addCACertFlag(reinstallAllCmd)
+	addAllowHooksFlag(reinstallAllCmd)
+	addAllowLocalURIsFlag(reinstallAllCmd)
+	addRelativeLinksFlag(reinstallAllCmd)
+	addExtractLimitFlags(reinstallAllCmd)
+	addTrustedKeyFlag(reinstallAllCmd)
+	addResumeFlag(reinstallAllCmd)
+	rootCmd.AddCommand(reinstallAllCmd)
+}
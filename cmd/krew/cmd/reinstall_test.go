@@ -0,0 +1,105 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/installation"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_reinstallPlugins(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	for _, name := range []string{"foo", "bar"} {
+		manifest, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "pkg", "index", "indexscanner", "testdata", "testindex", "plugins", name+".yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpDir.Write(filepath.Join("index", "plugins", name+".yaml"), manifest)
+	}
+
+	defer func(p environment.Paths) { paths = p }(paths)
+	defer os.Setenv("KREW_ROOT", os.Getenv("KREW_ROOT"))
+	os.Setenv("KREW_ROOT", tmpDir.Root())
+	paths = environment.MustGetKrewPaths()
+
+	installed := map[string]string{"foo": "v1.0.0", "bar": "v2.0.0"}
+	tests := []struct {
+		name            string
+		toLatest        bool
+		installErr      map[string]error
+		wantVersions    map[string]string
+		wantReinstalled []string
+		wantFailed      []string
+	}{
+		{
+			name:            "pinned to the installed versions",
+			wantVersions:    map[string]string{"bar": "v2.0.0", "foo": "v1.0.0"},
+			wantReinstalled: []string{"bar", "foo"},
+		},
+		{
+			name:            "to latest",
+			toLatest:        true,
+			wantVersions:    map[string]string{"bar": "", "foo": ""},
+			wantReinstalled: []string{"bar", "foo"},
+		},
+		{
+			name:            "failed plugin",
+			installErr:      map[string]error{"bar": errors.New("version not in the index")},
+			wantVersions:    map[string]string{"bar": "v2.0.0", "foo": "v1.0.0"},
+			wantReinstalled: []string{"foo"},
+			wantFailed:      []string{"bar"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := make(map[string]string)
+			install := func(opts installation.InstallOpts) error {
+				if !opts.Force {
+					t.Errorf("plugin %s reinstalled without Force", opts.Plugin.Name)
+				}
+				if opts.InstallPath != paths.InstallPath() || opts.Index != "default" {
+					t.Errorf("plugin %s reinstalled with InstallPath=%q Index=%q", opts.Plugin.Name, opts.InstallPath, opts.Index)
+				}
+				versions[opts.Plugin.Name] = opts.Version
+				return tt.installErr[opts.Plugin.Name]
+			}
+			var out bytes.Buffer
+			opts := installation.InstallOpts{InstallPath: paths.InstallPath(), BinPath: paths.BinPath()}
+			reinstalled, failed := reinstallPlugins(&out, installed, tt.toLatest, opts, install)
+			if !reflect.DeepEqual(reinstalled, tt.wantReinstalled) || !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("reinstallPlugins() = %v, %v, want %v, %v", reinstalled, failed, tt.wantReinstalled, tt.wantFailed)
+			}
+			if !reflect.DeepEqual(versions, tt.wantVersions) {
+				t.Errorf("reinstallPlugins() installed versions %v, want %v", versions, tt.wantVersions)
+			}
+		})
+	}
+
+	reinstalled, failed := reinstallPlugins(ioutil.Discard, map[string]string{"gone": "v1"}, false, installation.InstallOpts{},
+		func(installation.InstallOpts) error { return nil })
+	if len(reinstalled) != 0 || !reflect.DeepEqual(failed, []string{"gone"}) {
+		t.Errorf("reinstallPlugins() of a plugin not in the index = %v, %v, want it to fail", reinstalled, failed)
+	}
+}
@@ -15,13 +15,26 @@
 package cmd
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 
 	isatty "github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/krew/pkg/download"
 	"sigs.k8s.io/krew/pkg/environment"
 	"sigs.k8s.io/krew/pkg/gitutil"
+	"sigs.k8s.io/krew/pkg/installation"
 
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
@@ -30,6 +43,15 @@ import (
 
 var (
 	paths environment.Paths // krew paths used by the process
+
+	// rootContext is canceled when the process is interrupted, commands pass it
+	// on to stop downloads. (The vendored cobra has no cmd.Context().)
+	rootContext = context.Background()
+
+	// infoOut receives the status messages of the commands, such as the
+	// plugins being installed. It discards them with --quiet. Warnings,
+	// errors and the requested output are not status messages.
+	infoOut io.Writer = os.Stderr
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -40,12 +62,87 @@ var rootCmd = &cobra.Command{
 You can invoke krew through kubectl: "kubectl krew [command]..."`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyVerbosityFlags(cmd.Flags()); err != nil {
+			return err
+		}
+		if format, _ := cmd.Flags().GetString(errorFormatFlag); !containsString(errorFormats, format) {
+			return errors.Errorf("unsupported --%s %q, must be one of: %s", errorFormatFlag, format, strings.Join(errorFormats, ", "))
+		}
+		p, err := pathsFromFlags(cmd, paths)
+		if err != nil {
+			return err
+		}
+		paths = p
+		cfg, err := loadConfig(filepath.Join(paths.BasePath(), configFileName))
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(cmd, cfg, os.Getenv); err != nil {
+			return err
+		}
+		return ensureDirs(paths.BasePath(),
+			paths.DownloadPath(),
+			paths.InstallPath(),
+			paths.BinPath())
+	},
+}
+
+// Names of the flags to override the krew base directory and the directory of
+// the plugin executables.
+const (
+	rootFlag   = "root"
+	binDirFlag = "bin-dir"
+)
+
+// pathsFromFlags returns p with the directories of the --root and --bin-dir
+// flags. It warns if the bin directory of --bin-dir is not in PATH, as kubectl
+// wouldn't find the plugins linked there.
+func pathsFromFlags(cmd *cobra.Command, p environment.Paths) (environment.Paths, error) {
+	if root, _ := cmd.Flags().GetString(rootFlag); root != "" {
+		var err error
+		if p, err = environment.NewPaths(root); err != nil {
+			return p, errors.Wrapf(err, "invalid --%s", rootFlag)
+		}
+		glog.V(4).Infof("Using --%s=%s", rootFlag, p.BasePath())
+	}
+	if binDir, _ := cmd.Flags().GetString(binDirFlag); binDir != "" {
+		var err error
+		if p, err = p.WithBinPath(binDir); err != nil {
+			return p, errors.Wrapf(err, "invalid --%s", binDirFlag)
+		}
+		glog.V(4).Infof("Using --%s=%s", binDirFlag, p.BinPath())
+		if !containsString(filepath.SplitList(os.Getenv("PATH")), p.BinPath()) {
+			glog.Warningf("The bin directory %q is not in PATH, kubectl won't find the plugins linked there", p.BinPath())
+		}
+	}
+	return p, nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	ctx, cancel := interruptContext(context.Background())
+	defer cancel()
+	rootContext = ctx
+
 	if err := rootCmd.Execute(); err != nil {
+		status := 255 // like glog.Fatal
+		exitErr, isExitErr := err.(*exitCodeError)
+		if isExitErr {
+			status = exitErr.code
+		}
+		if format, _ := rootCmd.PersistentFlags().GetString(errorFormatFlag); format == "json" {
+			if perr := printErrorJSON(os.Stderr, err); perr != nil {
+				glog.Warningf("failed to print the error as json: %v", perr)
+			}
+			glog.Flush()
+			os.Exit(status)
+		}
+		if isExitErr {
+			fmt.Fprintln(os.Stderr, exitErr.msg)
+			os.Exit(status)
+		}
 		if glog.V(1) {
 			glog.Fatalf("%+v", err) // with stack trace
 		} else {
@@ -54,6 +151,16 @@ func Execute() {
 	}
 }
 
+// exitCodeError is returned by commands to exit the process with the specified
+// status. Execute prints msg to stderr without logging it, or as JSON with
+// --error-format=json.
+type exitCodeError struct {
+	code int
+	msg  string
+}
+
+func (e *exitCodeError) Error() string { return e.msg }
+
 func init() {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	flag.CommandLine.Parse([]string{}) // convince pkg/flag we parsed the flags
@@ -65,12 +172,10 @@ func init() {
 	flag.Set("logtostderr", "true") // Set glog default to stderr
 
 	paths = environment.MustGetKrewPaths()
-	if err := ensureDirs(paths.BasePath(),
-		paths.DownloadPath(),
-		paths.InstallPath(),
-		paths.BinPath()); err != nil {
-		glog.Fatal(err)
-	}
+	rootCmd.PersistentFlags().String(rootFlag, "", "Base directory of krew (default $KREW_ROOT or ~/.krew)")
+	addVerbosityFlags(rootCmd.PersistentFlags())
+	rootCmd.PersistentFlags().String(binDirFlag, "", "Directory to link the plugin executables in (default the bin directory in the base directory)")
+	rootCmd.PersistentFlags().String(errorFormatFlag, "text", "Format of the error of a failed command: "+strings.Join(errorFormats, " or "))
 }
 
 func checkIndex(_ *cobra.Command, _ []string) error {
@@ -82,6 +187,229 @@ func checkIndex(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// platformFlag is the name of the flag to match plugin platforms against an
+// OS/arch other than the current system's.
+const platformFlag = "platform"
+
+func addPlatformFlag(cmd *cobra.Command) {
+	cmd.Flags().String(platformFlag, "", "Match plugin platforms against the specified OS/ARCH (e.g. linux/arm64) instead of the current system")
+}
+
+// osArchFromFlags returns the OS/arch to match plugin platforms against. The
+// --platform flag takes precedence over the KREW_OS and KREW_ARCH environment
+// variables.
+func osArchFromFlags(cmd *cobra.Command) (string, string, error) {
+	var goos, goarch string
+	if v, _ := cmd.Flags().GetString(platformFlag); v != "" {
+		pieces := strings.Split(v, "/")
+		if len(pieces) != 2 || pieces[0] == "" || pieces[1] == "" {
+			return "", "", errors.Errorf("invalid --%s value %q, must be in OS/ARCH format", platformFlag, v)
+		}
+		goos, goarch = pieces[0], pieces[1]
+	}
+	goos, goarch, err := installation.OSArch(goos, goarch)
+	return goos, goarch, errors.Wrap(err, "failed to determine the platform")
+}
+
+// caCertFlag is the name of the flag to trust an additional CA certificate
+// bundle for the downloads.
+const caCertFlag = "ca-cert"
+
+func addCACertFlag(cmd *cobra.Command) {
+	cmd.Flags().String(caCertFlag, "", "Path to a PEM encoded CA certificate bundle to trust in addition to the system's when downloading plugins")
+}
+
+// httpClientFromFlags returns the HTTP client to download plugins with. The
+// --ca-cert flag takes precedence over the KREW_CA_CERT environment variable.
+func httpClientFromFlags(cmd *cobra.Command) (*http.Client, error) {
+	caCert, _ := cmd.Flags().GetString(caCertFlag)
+	if caCert == "" {
+		caCert = os.Getenv("KREW_CA_CERT")
+	}
+	client, err := download.NewHTTPClient(caCert)
+	return client, errors.Wrap(err, "failed to configure the HTTP client")
+}
+
+// trustedKeyFlag is the name of the flag to verify the signatures of plugin
+// archives with.
+const trustedKeyFlag = "trusted-key"
+
+func addTrustedKeyFlag(cmd *cobra.Command) {
+	cmd.Flags().String(trustedKeyFlag, "", "Verify the signatures of plugin archives against this key, in TYPE:PATH format with TYPE gpg (a keyring) or cosign (a public key)")
+}
+
+// signatureVerifierFromFlags returns the verifier of the plugin archive
+// signatures, or nil if no trusted key is configured. The --trusted-key flag
+// takes precedence over the KREW_TRUSTED_KEY environment variable.
+func signatureVerifierFromFlags(cmd *cobra.Command) (download.SignatureVerifier, error) {
+	key, _ := cmd.Flags().GetString(trustedKeyFlag)
+	if key == "" {
+		key = os.Getenv("KREW_TRUSTED_KEY")
+	}
+	if key == "" {
+		return nil, nil
+	}
+	v, err := download.ParseSignatureVerifier(key)
+	return v, errors.Wrap(err, "failed to configure the signature verification")
+}
+
+// downloadMirrorFlag is the name of the flag to download plugin archives from
+// a mirror.
+const downloadMirrorFlag = "download-mirror"
+
+func addDownloadMirrorFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSlice(downloadMirrorFlag, nil, "Rewrite download URIs starting with FROM to start with TO, in FROM=TO format (e.g. https://github.com/=https://mirror.internal/github/), can be repeated")
+}
+
+// mirrorsFromFlags returns the rules to rewrite the download URIs with. The
+// --download-mirror flag takes precedence over the comma separated rules in the
+// KREW_DOWNLOAD_MIRROR environment variable.
+func mirrorsFromFlags(cmd *cobra.Command) ([]download.MirrorRule, error) {
+	rules, _ := cmd.Flags().GetStringSlice(downloadMirrorFlag)
+	if len(rules) == 0 {
+		if env := os.Getenv("KREW_DOWNLOAD_MIRROR"); env != "" {
+			rules = strings.Split(env, ",")
+		}
+	}
+	mirrors, err := download.ParseMirrorRules(rules)
+	return mirrors, errors.Wrap(err, "failed to parse the download mirrors")
+}
+
+// Names of the flags to make the commands print less or more. The glog flag
+// -v also sets the log level, --verbose is a friendlier way of raising it.
+const (
+	quietFlag   = "quiet"
+	verboseFlag = "verbose"
+)
+
+func addVerbosityFlags(flags *pflag.FlagSet) {
+	flags.BoolP(quietFlag, "q", false, "Only print warnings, errors and the requested output, without status messages and download progress")
+	flags.Count(verboseFlag, "Print more details of what krew is doing, repeat to print even more (same as -v=N)")
+}
+
+// applyVerbosityFlags sets the glog level to the number of times --verbose
+// is specified, unless -v sets a higher level, and discards the status
+// messages if --quiet is specified.
+func applyVerbosityFlags(flags *pflag.FlagSet) error {
+	quiet, _ := flags.GetBool(quietFlag)
+	verbose, _ := flags.GetCount(verboseFlag)
+	if quiet && verbose > 0 {
+		return errors.Errorf("--%s and --%s can't be specified together", quietFlag, verboseFlag)
+	}
+	if quiet {
+		infoOut = ioutil.Discard
+	}
+	if verbose > 0 {
+		v := flag.Lookup("v")
+		if level, _ := strconv.Atoi(v.Value.String()); level < verbose {
+			if err := v.Value.Set(strconv.Itoa(verbose)); err != nil {
+				return errors.Wrapf(err, "failed to set the log level")
+			}
+		}
+	}
+	return nil
+}
+
+// progressFromFlags returns the writer to report the download progress to,
+// which is stderr if it is a terminal and the --quiet flag is not set, or nil.
+func progressFromFlags(cmd *cobra.Command) io.Writer {
+	if quiet, _ := cmd.Flags().GetBool(quietFlag); quiet {
+		return nil
+	}
+	if !isTerminal(os.Stderr) {
+		glog.V(2).Infof("Not reporting the download progress, stderr is not a terminal")
+		return nil
+	}
+	return os.Stderr
+}
+
+// Names of the flags to limit the extraction of plugin archives.
+const (
+	maxExtractSizeFlag  = "max-extract-size"
+	maxExtractFilesFlag = "max-extract-files"
+)
+
+func addExtractLimitFlags(cmd *cobra.Command) {
+	cmd.Flags().String(maxExtractSizeFlag, resource.NewQuantity(download.DefaultExtractLimits.MaxSize, resource.BinarySI).String(),
+		"Maximum total size of the files extracted from a plugin archive, such as 200Mi or 1Gi, 0 disables the limit")
+	cmd.Flags().Int(maxExtractFilesFlag, download.DefaultExtractLimits.MaxFiles,
+		"Maximum number of files extracted from a plugin archive, 0 disables the limit")
+}
+
+// extractLimitsFromFlags returns the extraction limits of the
+// --max-extract-size and --max-extract-files flags.
+func extractLimitsFromFlags(cmd *cobra.Command) (download.ExtractLimits, error) {
+	sizeFlag, _ := cmd.Flags().GetString(maxExtractSizeFlag)
+	size, err := resource.ParseQuantity(sizeFlag)
+	if err != nil {
+		return download.ExtractLimits{}, errors.Wrapf(err, "invalid --%s value %q", maxExtractSizeFlag, sizeFlag)
+	}
+	files, _ := cmd.Flags().GetInt(maxExtractFilesFlag)
+	if size.Sign() < 0 || files < 0 {
+		return download.ExtractLimits{}, errors.Errorf("--%s and --%s must not be negative", maxExtractSizeFlag, maxExtractFilesFlag)
+	}
+	// a zero limit would be replaced with the default one
+	limits := download.ExtractLimits{MaxSize: size.Value(), MaxFiles: files}
+	if limits.MaxSize == 0 {
+		limits.MaxSize = -1
+	}
+	if limits.MaxFiles == 0 {
+		limits.MaxFiles = -1
+	}
+	return limits, nil
+}
+
+// resumeFlag is the name of the flag to resume interrupted downloads.
+const resumeFlag = "resume"
+
+func addResumeFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool(resumeFlag, false, "Continue interrupted downloads, also from earlier runs, where they stopped if the server supports range requests")
+}
+
+func resumeFromFlags(cmd *cobra.Command) bool {
+	resume, _ := cmd.Flags().GetBool(resumeFlag)
+	return resume
+}
+
+// relativeLinksFlag is the name of the flag to link plugin executables with
+// relative paths.
+const relativeLinksFlag = "relative-links"
+
+func addRelativeLinksFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool(relativeLinksFlag, false, "Link the plugin executables with paths relative to the bin directory, so that the krew root can be moved")
+}
+
+func relativeLinksFromFlags(cmd *cobra.Command) bool {
+	relative, _ := cmd.Flags().GetBool(relativeLinksFlag)
+	return relative
+}
+
+// allowHooksFlag is the name of the flag to run the post-install hooks of
+// plugins.
+const allowHooksFlag = "allow-hooks"
+
+func addAllowHooksFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool(allowHooksFlag, false, "Run the post-install hooks of plugins, which can run arbitrary commands as the current user (only their environment variables are filtered)")
+}
+
+func allowHooksFromFlags(cmd *cobra.Command) bool {
+	allow, _ := cmd.Flags().GetBool(allowHooksFlag)
+	return allow
+}
+
+// allowLocalURIsFlag is the name of the flag to read plugin archives with a
+// file:// URI or an absolute path from the local filesystem.
+const allowLocalURIsFlag = "allow-local-uris"
+
+func addAllowLocalURIsFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool(allowLocalURIsFlag, false, "Read plugin archives with a file:// URI or an absolute path from the local filesystem")
+}
+
+func allowLocalURIsFromFlags(cmd *cobra.Command) bool {
+	allow, _ := cmd.Flags().GetBool(allowLocalURIsFlag)
+	return allow
+}
+
 func ensureDirs(paths ...string) error {
 	for _, p := range paths {
 		glog.V(4).Infof("Ensure creating dir: %q", p)
@@ -92,6 +420,24 @@ func ensureDirs(paths ...string) error {
 	return nil
 }
 
+// interruptContext returns a context that is canceled when the process receives
+// SIGINT or SIGTERM. Another signal after that terminates the process as usual.
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case s := <-sig:
+			glog.V(1).Infof("Received %v, cancelling", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 func isTerminal(f *os.File) bool {
 	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
 }
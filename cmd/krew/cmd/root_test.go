@@ -0,0 +1,150 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/installation"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_pathsFromFlags_binDir(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	root, err := environment.NewPaths(tmpDir.Path("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String(rootFlag, "", "")
+	cmd.Flags().String(binDirFlag, "", "")
+	if err := cmd.Flags().Set(binDirFlag, tmpDir.Path("custom-bin")); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pathsFromFlags(cmd, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.BinPath(), tmpDir.Path("custom-bin"); got != want {
+		t.Fatalf("BinPath() = %q, want %q", got, want)
+	}
+	if got, want := p.InstallPath(), root.InstallPath(); got != want {
+		t.Fatalf("InstallPath() = %q, want %q", got, want)
+	}
+
+	// the plugin installed to the overridden bin directory is only found there
+	if err := os.MkdirAll(p.BinPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := filepath.Abs(filepath.Join("..", "..", "..", "pkg", "installation", "testdata", "archives", "foo.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := installation.InstallPlugin(installation.InstallOpts{
+		Plugin: index.Plugin{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+			Spec: index.PluginSpec{
+				Platforms: []index.Platform{{
+					URI:      archive,
+					Sha256:   "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116",
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": runtime.GOOS}},
+					Files:    []index.FileOperation{{From: "*", To: "."}},
+					Bin:      "kubectl-foo",
+				}},
+			},
+		},
+		InstallPath:    p.InstallPath(),
+		BinPath:        p.BinPath(),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllowLocalURIs: true,
+	}); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	installed, err := installation.ListInstalledPlugins(p.InstallPath(), p.BinPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := installed["foo"]; !ok {
+		t.Errorf("ListInstalledPlugins() = %v, want foo with --%s", installed, binDirFlag)
+	}
+	installed, err = installation.ListInstalledPlugins(root.InstallPath(), root.BinPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := installed["foo"]; ok {
+		t.Errorf("ListInstalledPlugins() = %v, want no foo without --%s", installed, binDirFlag)
+	}
+}
+
+func Test_applyVerbosityFlags(t *testing.T) {
+	v := flag.Lookup("v")
+	defer v.Value.Set(v.Value.String())
+	defer func(out io.Writer) { infoOut = out }(infoOut)
+
+	tests := []struct {
+		name      string
+		args      []string
+		level     string
+		wantLevel string
+		wantQuiet bool
+		wantErr   bool
+	}{
+		{name: "default", level: "0", wantLevel: "0"},
+		{name: "verbose", args: []string{"--verbose"}, level: "0", wantLevel: "1"},
+		{name: "verbose twice", args: []string{"--verbose", "--verbose"}, level: "0", wantLevel: "2"},
+		{name: "higher -v level is kept", args: []string{"--verbose"}, level: "4", wantLevel: "4"},
+		{name: "quiet", args: []string{"-q"}, level: "0", wantLevel: "0", wantQuiet: true},
+		{name: "quiet and verbose", args: []string{"--quiet", "--verbose"}, level: "0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			infoOut = os.Stderr
+			if err := v.Value.Set(tt.level); err != nil {
+				t.Fatal(err)
+			}
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			addVerbosityFlags(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := applyVerbosityFlags(flags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyVerbosityFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := v.Value.String(); got != tt.wantLevel {
+				t.Errorf("log level = %s, want %s", got, tt.wantLevel)
+			}
+			if got := infoOut == ioutil.Discard; got != tt.wantQuiet {
+				t.Errorf("status messages discarded = %v, want %v", got, tt.wantQuiet)
+			}
+		})
+	}
+}
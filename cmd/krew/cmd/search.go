@@ -15,18 +15,44 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"path"
+	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
 	"sigs.k8s.io/krew/pkg/index/indexscanner"
 
 	"github.com/sahilm/fuzzy"
 	"github.com/spf13/cobra"
+	"golang.org/x/text/unicode/norm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/krew/pkg/index"
 	"sigs.k8s.io/krew/pkg/installation"
 )
 
+var (
+	searchOutputFormat string
+	searchFields       string
+	searchMaxDesc      int
+	searchSort         string
+	searchFailOnEmpty  bool
+	searchStatuses     []string
+	searchInstalled    bool
+	searchIndexPath    string
+	searchIndex        string
+	searchMatchMode    string
+	searchExactFirst   bool
+
+	searchIncludeUnavailable bool
+)
+
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
 	Use:   "search",
@@ -39,68 +65,810 @@ Examples:
     kubectl krew search
 
   To fuzzy search plugins with a keyword:
-    kubectl krew search KEYWORD`,
+    kubectl krew search KEYWORD
+
+  To only list the plugins matching all of multiple keywords:
+    kubectl krew search network policy
+
+  To list the matching plugins alphabetically instead of by relevance:
+    kubectl krew search --sort=name KEYWORD
+
+  To only list the plugins whose names start with a prefix, match a glob
+  pattern, or are equal to the keyword, instead of fuzzy matching:
+    kubectl krew search --match=prefix view-
+    kubectl krew search --match=glob 'view-*'
+    kubectl krew search --match=exact ctx
+
+  To only list the plugin named like the keyword if there is one, and fall
+  back to the fuzzy matches otherwise:
+    kubectl krew search --exact-first ctx
+
+  To also search in plugin descriptions:
+    kubectl krew search --search-fields=all KEYWORD
+
+  To hide the plugins that can't be installed on this OS/arch:
+    kubectl krew search --include-unavailable=false
+
+  To only list the installed plugins, marking the ones with an upgrade available:
+    kubectl krew search --installed-only
+
+  To also print the full versions and the homepages of the plugins:
+    kubectl krew search -o wide
+
+  To print the descriptions without truncating them:
+    kubectl krew search --max-desc=0
+
+  To print the results in a machine-readable format:
+    kubectl krew search -o json
+
+  To print each result as a JSON object on its own line as soon as it is
+  found, e.g. to process large indexes:
+    kubectl krew search -o ndjson
+
+  To only print the names of the plugins, one per line:
+    kubectl krew search -o name --status=available | xargs kubectl krew info
+
+  To only search the plugins of the index named "company":
+    kubectl krew search --index=company
+
+  To search the plugins of an index directory or index.tar.gz archive, or of
+  plugin manifests separated by "---" lines read from stdin, instead of the
+  configured indexes:
+    kubectl krew search --index-path=./krew-index
+    kubectl krew search --index-path=./index.tar.gz
+    cat plugins/*.yaml | kubectl krew search --index-path=- KEYWORD`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		plugins, err := indexscanner.LoadPluginListFromFS(paths.IndexPath())
+		goos, goarch, err := osArchFromFlags(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to load the index")
+			return err
 		}
-		names := make([]string, len(plugins.Items))
-		pluginMap := make(map[string]index.Plugin, len(plugins.Items))
-		for i, p := range plugins.Items {
-			names[i] = p.Name
-			pluginMap[p.Name] = p
+
+		var keys []pluginKey
+		var pluginMap map[pluginKey]index.Plugin
+		if searchIndexPath != "" {
+			keys, pluginMap, err = loadPluginsFromIndexPath(searchIndexPath, os.Stdin)
+		} else {
+			keys, pluginMap, err = loadAllPlugins()
+		}
+		if err != nil {
+			return err
+		}
+		// the plugins are named unambiguously among all indexes, also if only
+		// one index is searched
+		refs := pluginRefs(keys)
+		if searchIndex != "" {
+			keys = pluginKeysOfIndex(keys, searchIndex)
 		}
 
 		installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
 		if err != nil {
 			return errors.Wrap(err, "failed to load installed plugins")
 		}
+		broken, err := installation.ListBrokenPlugins(paths.InstallPath(), paths.BinPath())
+		if err != nil {
+			return errors.Wrap(err, "failed to find broken plugin installations")
+		}
 
-		var matchNames []string
+		var matches []searchMatch
 		if len(args) > 0 {
-			matches := fuzzy.Find(strings.Join(args, ""), names)
-			for _, m := range matches {
-				matchNames = append(matchNames, m.Str)
+			matches, err = searchPlugins(args, keys, pluginMap, searchFields, searchMatchMode)
+			if err != nil {
+				return err
 			}
+			matches = orderSearchMatches(args, matches, searchSort, searchExactFirst)
 		} else {
-			matchNames = names
+			// listing all plugins has no relevance to sort by
+			for _, key := range keys {
+				matches = append(matches, searchMatch{key: key, field: searchFieldName})
+			}
+			sortMatchesByName(matches)
 		}
 
-		// No plugins found
-		if len(matchNames) == 0 {
+		// with -o ndjson, the results are printed as they are found instead
+		// of being collected
+		var results []searchResult
+		var found int
+		emit := func(r searchResult) error {
+			results = append(results, r)
 			return nil
 		}
+		if searchOutputFormat == outputFormatNDJSON {
+			emit = ndjsonWriter(os.Stdout)
+		}
 
-		var rows [][]string
-		cols := []string{"NAME", "DESCRIPTION", "STATUS"}
-		for _, name := range matchNames {
-			plugin := pluginMap[name]
-			var status string
-			if _, ok := installed[name]; ok {
-				status = "installed"
-			} else if _, ok, err := installation.GetMatchingPlatform(plugin); err != nil {
+		showMatchedField := len(args) > 0 && searchFields != searchFieldName
+		for _, m := range matches {
+			name := refs[m.key]
+			plugin := pluginMap[m.key]
+			platform, hasPlatform, err := installation.GetMatchingPlatformFor(plugin, goos, goarch)
+			if err != nil {
 				return errors.Wrapf(err, "failed to get the matching platform for plugin %s", name)
-			} else if ok {
-				status = "available"
+			}
+			var status string
+			installedVersion, isInstalled := installed[plugin.Name]
+			if isInstalled && !installedFromIndex(plugin.Name, m.key.index) {
+				// the plugin with the same name from another index is installed
+				installedVersion, isInstalled = "", false
+			}
+			if isInstalled {
+				status = searchStatusInstalled
+			} else if containsString(broken, plugin.Name) {
+				status = pluginStatusBroken
+			} else if hasPlatform {
+				status = searchStatusAvailable
 			} else {
-				status = "unavailable"
+				status = searchStatusUnavailable
+			}
+			if !showSearchStatus(status, searchStatuses, searchIncludeUnavailable) {
+				continue
+			}
+			r := searchResult{
+				Name:             name,
+				Index:            m.key.index,
+				Description:      plugin.Spec.ShortDescription,
+				Status:           status,
+				Version:          availableVersion(plugin, platform, hasPlatform),
+				InstalledVersion: installedVersion,
+				Homepage:         plugin.Spec.Homepage,
+			}
+			if status == searchStatusUnavailable {
+				r.SupportedPlatforms = supportedPlatforms(plugin)
+			}
+			if isInstalled && hasPlatform {
+				if r.UpgradeAvailable, err = installation.HasUpgradeFor(paths.InstallPath(), plugin, installedVersion, goos, goarch); err != nil {
+					return errors.Wrapf(err, "failed to check for an upgrade of plugin %s", name)
+				}
+			}
+			if showMatchedField {
+				r.MatchedField = m.field
+			}
+			if err := emit(r); err != nil {
+				return errors.Wrap(err, "failed to write the search result")
+			}
+			found++
+		}
+
+		// No plugins found
+		if found == 0 {
+			if searchFailOnEmpty {
+				msg := "no plugins found"
+				if len(args) > 0 {
+					msg = fmt.Sprintf("no plugins found matching %q", strings.Join(args, " "))
+				}
+				return &exitCodeError{code: 1, msg: msg}
+			}
+			switch searchOutputFormat {
+			case outputFormatTable, outputFormatWide, outputFormatYAML:
+				// printing nothing is an empty YAML document
+				return nil
+			case outputFormatJSON:
+				return printJSON(os.Stdout, []searchResult{})
 			}
-			rows = append(rows, []string{name, limitString(plugin.Spec.ShortDescription, 50), status})
 		}
-		rows = sortByFirstColumn(rows)
+
+		switch searchOutputFormat {
+		case outputFormatNDJSON:
+			return nil
+		case outputFormatJSON:
+			return printJSON(os.Stdout, results)
+		case outputFormatYAML:
+			return printYAML(os.Stdout, results)
+		case outputFormatName:
+			return printSearchNames(os.Stdout, results)
+		}
+
+		wide := searchOutputFormat == outputFormatWide
+		maxDesc := searchMaxDesc
+		if wide && !cmd.Flags().Changed("max-desc") {
+			maxDesc = searchWideMaxDesc
+		}
+		cols, rows := searchTable(results, wide, maxDesc, showMatchedField)
 		return printTable(os.Stdout, cols, rows)
 	},
-	PreRunE: checkIndex,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(searchOutputFormat, outputFormatName, outputFormatWide, outputFormatNDJSON); err != nil {
+			return err
+		}
+		if searchMaxDesc < 0 {
+			return errors.Errorf("--max-desc must not be negative, got %d", searchMaxDesc)
+		}
+		switch searchSort {
+		case searchSortRelevance, searchSortName:
+		default:
+			return errors.Errorf("unsupported --sort value %q, must be one of: %s, %s",
+				searchSort, searchSortRelevance, searchSortName)
+		}
+		for _, status := range searchStatuses {
+			switch status {
+			case searchStatusInstalled, searchStatusAvailable, searchStatusUnavailable, pluginStatusBroken:
+			default:
+				return errors.Errorf("unsupported --status value %q, must be one of: %s, %s, %s, %s",
+					status, searchStatusInstalled, searchStatusAvailable, searchStatusUnavailable, pluginStatusBroken)
+			}
+		}
+		if !searchIncludeUnavailable && containsString(searchStatuses, searchStatusUnavailable) {
+			return errors.Errorf("--status=%s can't be specified with --include-unavailable=false", searchStatusUnavailable)
+		}
+		if searchInstalled {
+			if len(searchStatuses) > 0 {
+				return errors.New("--installed-only can't be specified with --status")
+			}
+			searchStatuses = []string{searchStatusInstalled}
+		}
+		switch searchMatchMode {
+		case searchMatchFuzzy, searchMatchPrefix, searchMatchGlob, searchMatchExact:
+		default:
+			return errors.Errorf("unsupported --match value %q, must be one of: %s, %s, %s, %s",
+				searchMatchMode, searchMatchFuzzy, searchMatchPrefix, searchMatchGlob, searchMatchExact)
+		}
+		switch searchFields {
+		case searchFieldName, searchFieldDescription, searchFieldAll:
+		default:
+			return errors.Errorf("unsupported --search-fields value %q, must be one of: %s, %s, %s",
+				searchFields, searchFieldName, searchFieldDescription, searchFieldAll)
+		}
+		if searchIndexPath != "" {
+			if searchIndex != "" {
+				return errors.New("--index can't be specified with --index-path")
+			}
+			return validateIndexPath(searchIndexPath)
+		}
+		if searchIndex != "" {
+			if err := validateIndexName(searchIndex); err != nil {
+				return err
+			}
+		}
+		return checkIndex(cmd, args)
+	},
+	PostRun: func(cmd *cobra.Command, args []string) {
+		notifyUpgrades(infoOut)
+	},
+}
+
+// showSearchStatus returns true if the plugins with status are shown, which are
+// the ones with one of statuses if any are specified. Plugins that are
+// unavailable on the OS/arch are hidden unless includeUnavailable is set.
+func showSearchStatus(status string, statuses []string, includeUnavailable bool) bool {
+	if status == searchStatusUnavailable && !includeUnavailable {
+		return false
+	}
+	return len(statuses) == 0 || containsString(statuses, status)
+}
+
+// availableVersion returns the version of the plugin that would be installed
+// from the matching platform. Plugins without a matching platform have only the
+// version in their manifest, if any.
+func availableVersion(plugin index.Plugin, platform index.Platform, hasPlatform bool) string {
+	if !hasPlatform {
+		return plugin.Spec.Version
+	}
+	return installation.PlatformVersion(plugin, platform)
+}
+
+// searchResult is a single plugin entry printed by the search command.
+type searchResult struct {
+	Name string `json:"name"`
+	// Index is the name of the index of the plugin, it is empty for the
+	// plugins of --index-path.
+	Index       string `json:"index,omitempty"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+
+	// Version is the version of the plugin that would be installed on the
+	// platform.
+	Version  string `json:"version,omitempty"`
+	Homepage string `json:"homepage,omitempty"`
+
+	// InstalledVersion is the version of the plugin if it is installed.
+	InstalledVersion string `json:"installedVersion,omitempty"`
+	// UpgradeAvailable is true if the plugin is installed and Version would
+	// replace the installed version on upgrade.
+	UpgradeAvailable bool `json:"upgradeAvailable,omitempty"`
+
+	// SupportedPlatforms are the selectors of the plugin platforms, it is only
+	// set for plugins that are unavailable on the platform.
+	SupportedPlatforms []string `json:"supportedPlatforms,omitempty"`
+
+	// MatchedField is the plugin field the search keyword matched, it is only
+	// set when searching fields other than the name.
+	MatchedField string `json:"matchedField,omitempty"`
+}
+
+// supportedPlatforms returns the selectors of the platforms of the plugin in
+// the format of label selectors, such as "os=darwin,arch=arm64". Platforms
+// without a selector match no system and are left out.
+func supportedPlatforms(plugin index.Plugin) []string {
+	var out []string
+	for _, p := range plugin.Spec.Platforms {
+		if p.Selector == nil {
+			continue
+		}
+		if selector := metav1.FormatLabelSelector(p.Selector); !containsString(out, selector) {
+			out = append(out, selector)
+		}
+	}
+	return out
+}
+
+// Plugin fields that can be searched with --search-fields.
+const (
+	searchFieldName        = "name"
+	searchFieldDescription = "description"
+	searchFieldAll         = "all"
+)
+
+// searchMatch is a plugin matching the search keyword and the field that
+// matched it.
+type searchMatch struct {
+	key   pluginKey
+	field string
+}
+
+// pluginKey identifies a plugin in the search results by its index and name,
+// as plugins of different indexes can have the same name. The index is empty
+// for the plugins of --index-path.
+type pluginKey struct {
+	index string
+	name  string
+}
+
+// Statuses of the plugins in the search results, which can be filtered with
+// --status, in addition to pluginStatusBroken.
+const (
+	searchStatusInstalled   = "installed"
+	searchStatusAvailable   = "available"
+	searchStatusUnavailable = "unavailable"
+)
+
+// Ways of matching the keyword against the plugin fields that can be selected
+// with --match.
+const (
+	searchMatchFuzzy  = "fuzzy"
+	searchMatchPrefix = "prefix"
+	searchMatchGlob   = "glob"
+	searchMatchExact  = "exact"
+)
+
+// Orders of the search results that can be selected with --sort.
+const (
+	searchSortRelevance = "relevance"
+	searchSortName      = "name"
+)
+
+// searchPlugins matches the keywords against the plugin fields selected by
+// fields (name, description or all) in the way selected by mode (fuzzy, prefix,
+// glob or exact). A field matches if all keywords match it. A plugin matching
+// on multiple fields is only returned once, with its name match taking
+// precedence. The matches are ordered by relevance, with name matches before
+// description matches.
+func searchPlugins(keywords []string, keys []pluginKey, plugins map[pluginKey]index.Plugin, fields, mode string) ([]searchMatch, error) {
+	var out []searchMatch
+	seen := make(map[pluginKey]bool)
+
+	if fields == searchFieldName || fields == searchFieldAll {
+		candidates, canonical := namesWithFormerNames(keys, plugins)
+		matched, err := matchKeywords(keywords, candidates, mode)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range matched {
+			key := canonical[i]
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, searchMatch{key: key, field: searchFieldName})
+		}
+	}
+
+	if fields == searchFieldDescription || fields == searchFieldAll {
+		descriptions := make([]string, len(keys))
+		for i, key := range keys {
+			descriptions[i] = plugins[key].Spec.ShortDescription
+		}
+		matched, err := matchKeywords(keywords, descriptions, mode)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range matched {
+			key := keys[i]
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, searchMatch{key: key, field: searchFieldDescription})
+		}
+	}
+	return out, nil
+}
+
+// namesWithFormerNames returns the names of the plugins followed by the former
+// names of the renamed plugins, and the key of the plugin each of them belongs
+// to. Former names that are the name of a plugin are left out.
+func namesWithFormerNames(keys []pluginKey, plugins map[pluginKey]index.Plugin) ([]string, []pluginKey) {
+	candidates := make([]string, 0, len(keys))
+	canonical := append([]pluginKey(nil), keys...)
+	isName := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		candidates = append(candidates, key.name)
+		isName[key.name] = true
+	}
+	for _, key := range keys {
+		for _, former := range plugins[key].Spec.RenamedFrom {
+			if !isName[former] {
+				candidates = append(candidates, former)
+				canonical = append(canonical, key)
+			}
+		}
+	}
+	return candidates, canonical
+}
+
+// orderSearchMatches orders the matches of the keywords, which are in the order
+// of relevance, by sortBy. The exact name matches are moved to the front
+// afterwards as in preferExactNameMatches, so they stay first when sorting by
+// name.
+func orderSearchMatches(keywords []string, matches []searchMatch, sortBy string, exactOnly bool) []searchMatch {
+	if sortBy == searchSortName {
+		sortMatchesByName(matches)
+	}
+	return preferExactNameMatches(keywords, matches, exactOnly)
+}
+
+// sortMatchesByName sorts matches by the plugin names like lessName. Names only
+// differing in case and plugins with the same name in several indexes keep
+// their order.
+func sortMatchesByName(matches []searchMatch) {
+	sort.SliceStable(matches, func(a, b int) bool {
+		return lessName(matches[a].key.name, matches[b].key.name)
+	})
+}
+
+// preferExactNameMatches moves the plugins whose name is equal to one of the
+// keywords to the front of matches. If exactOnly is set and there are such
+// plugins, the other matches are dropped.
+func preferExactNameMatches(keywords []string, matches []searchMatch, exactOnly bool) []searchMatch {
+	isKeyword := make(map[string]bool, len(keywords))
+	for _, k := range keywords {
+		isKeyword[normalizeSearchText(k)] = true
+	}
+	var exact, other []searchMatch
+	for _, m := range matches {
+		if m.field == searchFieldName && isKeyword[normalizeSearchText(m.key.name)] {
+			exact = append(exact, m)
+		} else {
+			other = append(other, m)
+		}
+	}
+	if len(exact) > 0 && exactOnly {
+		return exact
+	}
+	return append(exact, other...)
+}
+
+// matchKeywords returns the indexes of the values in list matching all of the
+// keywords, in the order of the matches of the first keyword.
+func matchKeywords(keywords []string, list []string, mode string) ([]int, error) {
+	var out []int
+	for i, keyword := range keywords {
+		matched, err := matchKeyword(keyword, list, mode)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			out = matched
+			continue
+		}
+		isMatched := make(map[int]bool, len(matched))
+		for _, j := range matched {
+			isMatched[j] = true
+		}
+		var both []int
+		for _, j := range out {
+			if isMatched[j] {
+				both = append(both, j)
+			}
+		}
+		out = both
+	}
+	return out, nil
+}
+
+// matchKeyword returns the indexes of the values in list matching the keyword
+// in the way selected by mode. The keyword and the values are compared with
+// normalizeSearchText. Fuzzy matches are ordered by relevance, the other
+// matches are in the order of list.
+func matchKeyword(keyword string, list []string, mode string) ([]int, error) {
+	keyword = normalizeSearchText(keyword)
+	normalized := make([]string, len(list))
+	for i, s := range list {
+		normalized[i] = normalizeSearchText(s)
+	}
+	list = normalized
+
+	var out []int
+	if mode == searchMatchFuzzy {
+		for _, m := range fuzzy.Find(keyword, list) {
+			out = append(out, m.Index)
+		}
+		return out, nil
+	}
+	for i, s := range list {
+		var ok bool
+		switch mode {
+		case searchMatchPrefix:
+			ok = strings.HasPrefix(s, keyword)
+		case searchMatchExact:
+			ok = s == keyword
+		case searchMatchGlob:
+			var err error
+			if ok, err = path.Match(keyword, s); err != nil {
+				return nil, errors.Wrapf(err, "invalid glob pattern %q", keyword)
+			}
+		default:
+			return nil, errors.Errorf("unsupported match mode %q", mode)
+		}
+		if ok {
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
+
+// searchWideMaxDesc is the default width of the DESCRIPTION column with
+// -o wide.
+const searchWideMaxDesc = 120
+
+// searchTable returns the columns and rows of the search results table. The
+// status of installed plugins that can be upgraded is marked as such. The wide
+// table has an additional HOMEPAGE column, doesn't truncate versions and
+// lists the platforms that unavailable plugins support. The INDEX column is
+// only shown if some of the plugins are from custom indexes.
+func searchTable(results []searchResult, wide bool, maxDesc int, showMatchedField bool) ([]string, [][]string) {
+	var showIndex bool
+	for _, r := range results {
+		if r.Index != "" && r.Index != constants.DefaultIndexName {
+			showIndex = true
+		}
+	}
+	cols := []string{"NAME"}
+	if showIndex {
+		cols = append(cols, "INDEX")
+	}
+	cols = append(cols, "DESCRIPTION", "STATUS", "VERSION")
+	if wide {
+		cols = append(cols, "HOMEPAGE")
+	}
+	if showMatchedField {
+		cols = append(cols, "MATCH")
+	}
+	var rows [][]string
+	for _, r := range results {
+		version := r.InstalledVersion
+		if version == "" {
+			version = r.Version
+		}
+		if !wide {
+			version = limitString(version, 12)
+		}
+		status := r.Status
+		if r.UpgradeAvailable {
+			status += " (upgrade available)"
+		}
+		if wide && len(r.SupportedPlatforms) > 0 {
+			status += " (supports: " + strings.Join(r.SupportedPlatforms, "; ") + ")"
+		}
+		row := []string{r.Name}
+		if showIndex {
+			row = append(row, r.Index)
+		}
+		row = append(row, limitString(r.Description, maxDesc), status, version)
+		if wide {
+			row = append(row, r.Homepage)
+		}
+		if showMatchedField {
+			row = append(row, r.MatchedField)
+		}
+		rows = append(rows, row)
+	}
+	return cols, rows
+}
+
+// normalizeSearchText lowercases s and removes the accents from its letters,
+// so that e.g. "Istio" and "ístio" both match "istio".
+func normalizeSearchText(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue // combining accent
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// ndjsonWriter returns a function that writes each search result to out as a
+// JSON object on its own line.
+func ndjsonWriter(out io.Writer) func(searchResult) error {
+	enc := json.NewEncoder(out)
+	return func(r searchResult) error { return enc.Encode(r) }
+}
+
+// printSearchNames prints the names of the plugins in results, one per line.
+func printSearchNames(out io.Writer, results []searchResult) error {
+	for _, r := range results {
+		if _, err := fmt.Fprintln(out, r.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAllPlugins loads the plugins from all configured indexes and returns
+// their keys in the order of the indexes and the plugins by their keys.
+func loadAllPlugins() ([]pluginKey, map[pluginKey]index.Plugin, error) {
+	indexes, err := indexoperations.ListIndexes(paths)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list plugin indexes")
+	}
+
+	var keys []pluginKey
+	pluginMap := make(map[pluginKey]index.Plugin)
+	for _, idx := range indexes {
+		plugins, err := indexscanner.LoadPluginListFromFSCached(paths.IndexPathFor(idx.Name))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to load the index %q", idx.Name)
+		}
+		for _, p := range plugins.Items {
+			key := pluginKey{index: idx.Name, name: p.Name}
+			keys = append(keys, key)
+			pluginMap[key] = p
+		}
+	}
+	return keys, pluginMap, nil
+}
+
+// validateIndexName returns an error if no index named name is configured.
+func validateIndexName(name string) error {
+	indexes, err := indexoperations.ListIndexes(paths)
+	if err != nil {
+		return errors.Wrap(err, "failed to list plugin indexes")
+	}
+	for _, idx := range indexes {
+		if idx.Name == name {
+			return nil
+		}
+	}
+	return errors.Errorf("index %q is not configured, see \"kubectl krew index list\"", name)
+}
+
+// pluginKeysOfIndex returns the keys of the plugins in the index named
+// indexName.
+func pluginKeysOfIndex(keys []pluginKey, indexName string) []pluginKey {
+	var out []pluginKey
+	for _, key := range keys {
+		if key.index == indexName {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// pluginRefs returns the names the plugins are referred to with. A plugin in a
+// custom index is named {index}/{plugin} if a plugin with the same name is in
+// another index.
+func pluginRefs(keys []pluginKey) map[pluginKey]string {
+	count := make(map[string]int, len(keys))
+	for _, key := range keys {
+		count[key.name]++
+	}
+	refs := make(map[pluginKey]string, len(keys))
+	for _, key := range keys {
+		ref := key.name
+		if count[key.name] > 1 && key.index != "" && key.index != constants.DefaultIndexName {
+			ref = key.index + "/" + key.name
+		}
+		refs[key] = ref
+	}
+	return refs
+}
+
+// installedFromIndex returns false if the receipt of the installed plugin
+// records that it was installed from an index other than indexName. A receipt
+// without an index, e.g. of a plugin installed from a manifest file or by an
+// older krew, counts as the default index. Plugins without a receipt match any
+// index, as does an empty indexName, as with --index-path.
+func installedFromIndex(name, indexName string) bool {
+	if indexName == "" {
+		return true
+	}
+	receipt, err := installation.ReadReceipt(paths.InstallPath(), name)
+	if err != nil {
+		return true
+	}
+	if receipt.Index == "" {
+		return indexName == constants.DefaultIndexName
+	}
+	return receipt.Index == indexName
+}
+
+// validateIndexPath checks that path is "-" (stdin), an existing directory or
+// an index tarball.
+func validateIndexPath(path string) error {
+	if path == "-" {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrap(err, "invalid --index-path")
+	}
+	if !fi.IsDir() && !strings.HasSuffix(path, ".tar.gz") {
+		return errors.Errorf("invalid --index-path %q, it is not a directory or a .tar.gz index archive", path)
+	}
+	return nil
+}
+
+// loadPluginsFromIndexPath loads the plugins of the index directory at path, or
+// of the plugin manifests read from stdin if path is "-". The keys of the
+// plugins have no index name, as there is a single index.
+func loadPluginsFromIndexPath(path string, stdin io.Reader) ([]pluginKey, map[pluginKey]index.Plugin, error) {
+	var plugins index.PluginList
+	var err error
+	if path == "-" {
+		plugins, err = indexscanner.LoadPluginListFromReader(stdin)
+	} else {
+		plugins, err = indexscanner.LoadPluginListFromFS(path)
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load the plugins from --index-path %q", path)
+	}
+	keys := make([]pluginKey, 0, len(plugins.Items))
+	pluginMap := make(map[pluginKey]index.Plugin, len(plugins.Items))
+	for _, p := range plugins.Items {
+		key := pluginKey{name: p.Name}
+		keys = append(keys, key)
+		pluginMap[key] = p
+	}
+	return keys, pluginMap, nil
+}
+
+// containsString returns true if s is in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
+// limitString truncates s to at most length runes, replacing the end with
+// "..." if it is longer. Lengths of 3 or less disable truncation.
 func limitString(s string, length int) string {
-	if len(s) > length && length > 3 {
-		s = s[:length-3] + "..."
+	r := []rune(s)
+	if len(r) > length && length > 3 {
+		s = string(r[:length-3]) + "..."
 	}
 	return s
 }
 
 func init() {
+	// search never updates the index, the flag is accepted so that it can be
+	// passed consistently with install and upgrade.
+	addNoUpdateIndexFlag(searchCmd)
+	searchCmd.Flags().MarkHidden(noUpdateIndexFlag)
+	addPlatformFlag(searchCmd)
+	searchCmd.Flags().StringVar(&searchFields, "search-fields", searchFieldName, "Plugin fields to match the keyword against. One of: name|description|all")
+	searchCmd.Flags().StringSliceVar(&searchStatuses, "status", nil, "Only show plugins with the specified status, can be repeated. One of: installed|available|unavailable|broken")
+	searchCmd.Flags().BoolVar(&searchIncludeUnavailable, "include-unavailable", true, "Show the plugins that have no binary for the OS/arch, set to false to hide them")
+	searchCmd.Flags().BoolVar(&searchInstalled, "installed-only", false, "Only show installed plugins, same as --status=installed")
+	searchCmd.Flags().StringVar(&searchMatchMode, "match", searchMatchFuzzy, "How to match the keyword against the plugin fields. One of: fuzzy|prefix|glob|exact")
+	searchCmd.Flags().BoolVar(&searchExactFirst, "exact-first", false, "If a plugin name is equal to the keyword, only list that plugin instead of all matches")
+	searchCmd.Flags().BoolVar(&searchFailOnEmpty, "fail-on-empty", false, "Exit with status 1 if no plugins are found")
+	searchCmd.Flags().StringVar(&searchSort, "sort", searchSortRelevance, "Order of the results when searching with a keyword. One of: relevance|name")
+	searchCmd.Flags().IntVar(&searchMaxDesc, "max-desc", 50, "Maximum width of the DESCRIPTION column in the table output (120 with -o wide), 0 disables truncation")
+	searchCmd.Flags().StringVar(&searchIndexPath, "index-path", "", `Search the index at this directory or .tar.gz archive instead of the configured indexes, or "-" to read plugin manifests separated by "---" lines from stdin`)
+	searchCmd.Flags().StringVar(&searchIndex, "index", "", "Only search the plugins of the index with this name")
+	searchCmd.Flags().StringVarP(&searchOutputFormat, "output", "o", outputFormatTable, "Output format. One of: table|wide|json|ndjson|yaml|name")
 	rootCmd.AddCommand(searchCmd)
 }
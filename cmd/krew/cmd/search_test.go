@@ -0,0 +1,703 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/installation"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_limitString(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		length int
+		want   string
+	}{
+		{"shorter", "foo", 5, "foo"},
+		{"exact length", "hello", 5, "hello"},
+		{"truncated", "hello world", 8, "hello..."},
+		{"truncation disabled", "hello world", 3, "hello world"},
+		{"no limit", "hello world", 0, "hello world"},
+		{"multibyte shorter", "日本語", 5, "日本語"},
+		{"multibyte truncated", "日本語の説明文です", 6, "日本語..."},
+		{"emoji truncated", "🚀🚀🚀🚀🚀🚀", 5, "🚀🚀..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limitString(tt.in, tt.length); got != tt.want {
+				t.Errorf("limitString(%q, %d) = %q, want %q", tt.in, tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+// testSearchIndex returns the keys of the plugins with the names, as if they
+// were loaded from --index-path, and the plugins by their keys.
+func testSearchIndex(names []string, plugins map[string]index.Plugin) ([]pluginKey, map[pluginKey]index.Plugin) {
+	keys := make([]pluginKey, 0, len(names))
+	byKey := make(map[pluginKey]index.Plugin, len(plugins))
+	for _, name := range names {
+		key := pluginKey{name: name}
+		keys = append(keys, key)
+		byKey[key] = plugins[name]
+	}
+	return keys, byKey
+}
+
+func Test_searchPlugins_relevance(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"config-cleanup": {Spec: index.PluginSpec{ShortDescription: "Automatically clean up your kubeconfig"}},
+		"ctx":            {Spec: index.PluginSpec{ShortDescription: "Switch between contexts in your kubeconfig"}},
+		"cat-exporter":   {Spec: index.PluginSpec{ShortDescription: "Inspect certificates"}},
+		"view-secret":    {Spec: index.PluginSpec{ShortDescription: "Decode secrets"}},
+	}
+	names := []string{"cat-exporter", "config-cleanup", "ctx", "view-secret"}
+	keys, byKey := testSearchIndex(names, plugins)
+
+	tests := []struct {
+		name    string
+		keyword string
+		fields  string
+		want    []searchMatch
+	}{
+		{
+			name:    "exact name match first",
+			keyword: "ctx",
+			fields:  searchFieldName,
+			want: []searchMatch{
+				{key: pluginKey{name: "ctx"}, field: searchFieldName},
+				{key: pluginKey{name: "cat-exporter"}, field: searchFieldName},
+			},
+		},
+		{
+			name:    "name matches before description matches",
+			keyword: "config",
+			fields:  searchFieldAll,
+			want: []searchMatch{
+				{key: pluginKey{name: "config-cleanup"}, field: searchFieldName},
+				{key: pluginKey{name: "ctx"}, field: searchFieldDescription},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := searchPlugins([]string{tt.keyword}, keys, byKey, tt.fields, searchMatchFuzzy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchPlugins(%q) = %v, want %v", tt.keyword, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_searchPlugins_formerNames(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"view-secret": {Spec: index.PluginSpec{ShortDescription: "Decode secrets", RenamedFrom: []string{"secret-view", "ctx"}}},
+		"ctx":         {Spec: index.PluginSpec{ShortDescription: "Switch contexts"}},
+	}
+	names := []string{"ctx", "view-secret"}
+	keys, byKey := testSearchIndex(names, plugins)
+
+	tests := []struct {
+		keyword string
+		mode    string
+		want    []searchMatch
+	}{
+		{keyword: "secret-view", mode: searchMatchExact, want: []searchMatch{{key: pluginKey{name: "view-secret"}, field: searchFieldName}}},
+		{keyword: "secret", mode: searchMatchFuzzy, want: []searchMatch{{key: pluginKey{name: "view-secret"}, field: searchFieldName}}},
+		// a former name that is the name of another plugin finds that plugin
+		{keyword: "ctx", mode: searchMatchExact, want: []searchMatch{{key: pluginKey{name: "ctx"}, field: searchFieldName}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			got, err := searchPlugins([]string{tt.keyword}, keys, byKey, searchFieldName, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchPlugins(%q) = %v, want %v", tt.keyword, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_searchPlugins_matchModes(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"ctx":          {Spec: index.PluginSpec{ShortDescription: "Switch between contexts"}},
+		"ctx-switcher": {Spec: index.PluginSpec{ShortDescription: "Switch contexts"}},
+		"cat-exporter": {Spec: index.PluginSpec{ShortDescription: "Inspect certificates"}},
+		"view-secret":  {Spec: index.PluginSpec{ShortDescription: "Decode secrets"}},
+	}
+	names := []string{"cat-exporter", "ctx", "ctx-switcher", "view-secret"}
+	keys, byKey := testSearchIndex(names, plugins)
+
+	tests := []struct {
+		mode    string
+		keyword string
+		fields  string
+		want    []string
+		wantErr bool
+	}{
+		{mode: searchMatchFuzzy, keyword: "ctx", fields: searchFieldName, want: []string{"ctx", "ctx-switcher", "cat-exporter"}},
+		{mode: searchMatchPrefix, keyword: "ctx", fields: searchFieldName, want: []string{"ctx", "ctx-switcher"}},
+		{mode: searchMatchPrefix, keyword: "Switch", fields: searchFieldDescription, want: []string{"ctx", "ctx-switcher"}},
+		{mode: searchMatchGlob, keyword: "*-s*", fields: searchFieldName, want: []string{"ctx-switcher", "view-secret"}},
+		{mode: searchMatchGlob, keyword: "*secrets", fields: searchFieldAll, want: []string{"view-secret"}},
+		{mode: searchMatchGlob, keyword: "[", fields: searchFieldName, wantErr: true},
+		{mode: searchMatchExact, keyword: "ctx", fields: searchFieldName, want: []string{"ctx"}},
+		{mode: searchMatchExact, keyword: "ct", fields: searchFieldName, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.keyword, func(t *testing.T) {
+			matches, err := searchPlugins([]string{tt.keyword}, keys, byKey, tt.fields, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("searchPlugins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.key.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchPlugins(%q) with --match=%s = %v, want %v", tt.keyword, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_preferExactNameMatches(t *testing.T) {
+	matches := []searchMatch{
+		{key: pluginKey{name: "ctx-switcher"}, field: searchFieldName},
+		{key: pluginKey{name: "CTX"}, field: searchFieldName},
+		{key: pluginKey{name: "cat-exporter"}, field: searchFieldName},
+		{key: pluginKey{name: "ctx-desc"}, field: searchFieldDescription},
+	}
+	tests := []struct {
+		name      string
+		keywords  []string
+		exactOnly bool
+		want      []string
+	}{
+		{name: "exact match first", keywords: []string{"ctx"}, want: []string{"CTX", "ctx-switcher", "cat-exporter", "ctx-desc"}},
+		{name: "only exact match", keywords: []string{"ctx"}, exactOnly: true, want: []string{"CTX"}},
+		{name: "no exact match", keywords: []string{"ct"}, exactOnly: true, want: []string{"ctx-switcher", "CTX", "cat-exporter", "ctx-desc"}},
+		{name: "description is not an exact match", keywords: []string{"ctx-desc"}, exactOnly: true, want: []string{"ctx-switcher", "CTX", "cat-exporter", "ctx-desc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append([]searchMatch(nil), matches...)
+			var got []string
+			for _, m := range preferExactNameMatches(tt.keywords, in, tt.exactOnly) {
+				got = append(got, m.key.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("preferExactNameMatches(%q, %v) = %v, want %v", tt.keywords, tt.exactOnly, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sortMatchesByName(t *testing.T) {
+	matches := []searchMatch{
+		{key: pluginKey{name: "Zoo"}, field: searchFieldName},
+		{key: pluginKey{name: "ctx"}, field: searchFieldDescription},
+		{key: pluginKey{name: "apple"}, field: searchFieldName},
+		{key: pluginKey{name: "CTX"}, field: searchFieldName},
+		{key: pluginKey{name: "Banana"}, field: searchFieldName},
+	}
+	want := []searchMatch{
+		{key: pluginKey{name: "apple"}, field: searchFieldName},
+		{key: pluginKey{name: "Banana"}, field: searchFieldName},
+		{key: pluginKey{name: "ctx"}, field: searchFieldDescription},
+		{key: pluginKey{name: "CTX"}, field: searchFieldName},
+		{key: pluginKey{name: "Zoo"}, field: searchFieldName},
+	}
+	sortMatchesByName(matches)
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("sortMatchesByName() = %v, want %v", matches, want)
+	}
+}
+
+func Test_orderSearchMatches(t *testing.T) {
+	matches := []searchMatch{
+		{key: pluginKey{name: "ctx-switcher"}, field: searchFieldName},
+		{key: pluginKey{name: "ctx"}, field: searchFieldName},
+		{key: pluginKey{name: "cat-exporter"}, field: searchFieldName},
+		{key: pluginKey{name: "access-ctx"}, field: searchFieldDescription},
+	}
+	tests := []struct {
+		name      string
+		sortBy    string
+		exactOnly bool
+		want      []string
+	}{
+		{name: "relevance", sortBy: searchSortRelevance, want: []string{"ctx", "ctx-switcher", "cat-exporter", "access-ctx"}},
+		{name: "name", sortBy: searchSortName, want: []string{"ctx", "access-ctx", "cat-exporter", "ctx-switcher"}},
+		{name: "name exact first", sortBy: searchSortName, exactOnly: true, want: []string{"ctx"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append([]searchMatch(nil), matches...)
+			var got []string
+			for _, m := range orderSearchMatches([]string{"ctx"}, in, tt.sortBy, tt.exactOnly) {
+				got = append(got, m.key.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderSearchMatches(%q, %v) = %v, want %v", tt.sortBy, tt.exactOnly, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_searchPlugins_multipleKeywords(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"network-tools":  {Spec: index.PluginSpec{ShortDescription: "Debug network connectivity"}},
+		"policy-check":   {Spec: index.PluginSpec{ShortDescription: "Check pod security policies"}},
+		"network-policy": {Spec: index.PluginSpec{ShortDescription: "Visualize network policies"}},
+		"np-viewer":      {Spec: index.PluginSpec{ShortDescription: "View policies of the network"}},
+	}
+	names := []string{"network-policy", "network-tools", "np-viewer", "policy-check"}
+	keys, byKey := testSearchIndex(names, plugins)
+
+	tests := []struct {
+		keywords []string
+		fields   string
+		want     []string
+	}{
+		{keywords: []string{"network"}, fields: searchFieldName, want: []string{"network-tools", "network-policy"}},
+		{keywords: []string{"policy", "network"}, fields: searchFieldName, want: []string{"network-policy"}},
+		{keywords: []string{"network", "policies"}, fields: searchFieldDescription, want: []string{"network-policy", "np-viewer"}},
+		{keywords: []string{"policy", "check", "network"}, fields: searchFieldAll, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.keywords, " "), func(t *testing.T) {
+			matches, err := searchPlugins(tt.keywords, keys, byKey, tt.fields, searchMatchFuzzy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.key.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchPlugins(%q) = %v, want %v", tt.keywords, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadPluginsFromIndexPath(t *testing.T) {
+	indexDir := filepath.Join("..", "..", "..", "pkg", "index", "indexscanner", "testdata", "testindex")
+	manifest, err := ioutil.ReadFile(filepath.Join(indexDir, "plugins", "foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, plugins, err := loadPluginsFromIndexPath("-", bytes.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []pluginKey{{name: "foo"}}) || plugins[pluginKey{name: "foo"}].Name != "foo" {
+		t.Errorf("loadPluginsFromIndexPath(-) = %v, %v", keys, plugins)
+	}
+
+	keys, _, err = loadPluginsFromIndexPath(indexDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []pluginKey{{name: "bar"}, {name: "foo"}}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("loadPluginsFromIndexPath(%s) = %v, want %v", indexDir, keys, want)
+	}
+}
+
+func Test_validateIndexPath(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("file", nil)
+	tmpDir.Write("index.tar.gz", nil)
+
+	for path, wantErr := range map[string]bool{
+		"-":                         false,
+		tmpDir.Root():               false,
+		tmpDir.Path("index.tar.gz"): false,
+		tmpDir.Path("file"):         true,
+		tmpDir.Path("notexist"):     true,
+	} {
+		if err := validateIndexPath(path); (err != nil) != wantErr {
+			t.Errorf("validateIndexPath(%q) error = %v, wantErr %v", path, err, wantErr)
+		}
+	}
+}
+
+func Test_printSearchNames(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printSearchNames(&buf, []searchResult{{Name: "foo", Description: "d"}, {Name: "bar"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "foo\nbar\n"; got != want {
+		t.Errorf("printSearchNames() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := printSearchNames(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("printSearchNames() = %q for no results, want no output", buf.String())
+	}
+}
+
+func Test_validateOutputFormat(t *testing.T) {
+	if err := validateOutputFormat(outputFormatName); err == nil {
+		t.Errorf("expected %q to be rejected unless it is allowed", outputFormatName)
+	}
+	if err := validateOutputFormat(outputFormatName, outputFormatName); err != nil {
+		t.Errorf("validateOutputFormat() error = %v", err)
+	}
+	if err := validateOutputFormat(outputFormatYAML, outputFormatName); err != nil {
+		t.Errorf("validateOutputFormat() error = %v", err)
+	}
+}
+
+func Test_showSearchStatus(t *testing.T) {
+	plugin := index.Plugin{Spec: index.PluginSpec{Platforms: []index.Platform{{
+		URI:      "https://example.com/foo.tar.gz",
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "plan9"}},
+	}}}}
+	_, hasPlatform, err := installation.GetMatchingPlatformFor(plugin, "linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasPlatform {
+		t.Fatal("expected the plugin to have no matching platform")
+	}
+
+	tests := []struct {
+		name               string
+		status             string
+		statuses           []string
+		includeUnavailable bool
+		want               bool
+	}{
+		{name: "unavailable included", status: searchStatusUnavailable, includeUnavailable: true, want: true},
+		{name: "unavailable hidden", status: searchStatusUnavailable, includeUnavailable: false, want: false},
+		{name: "available shown", status: searchStatusAvailable, includeUnavailable: false, want: true},
+		{name: "installed shown", status: searchStatusInstalled, includeUnavailable: false, want: true},
+		{name: "status filter", status: searchStatusAvailable, statuses: []string{searchStatusInstalled}, includeUnavailable: true, want: false},
+		{name: "unavailable status filter", status: searchStatusUnavailable, statuses: []string{searchStatusUnavailable}, includeUnavailable: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := showSearchStatus(tt.status, tt.statuses, tt.includeUnavailable); got != tt.want {
+				t.Errorf("showSearchStatus(%q, %q, %v) = %v, want %v", tt.status, tt.statuses, tt.includeUnavailable, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_availableVersion(t *testing.T) {
+	platform := index.Platform{
+		URI:      "https://example.com/foo.tar.gz",
+		Sha256:   "8B40A4AD57ACEEA70CC35652113A63E80C963310AF781D2A7E116E0CDAD21116",
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "linux"}},
+		Bin:      "kubectl-foo",
+	}
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec:       index.PluginSpec{Platforms: []index.Platform{platform}},
+	}
+
+	matched, ok, err := installation.GetMatchingPlatformFor(plugin, "linux", "amd64")
+	if err != nil || !ok {
+		t.Fatalf("GetMatchingPlatformFor() = %v, %v", ok, err)
+	}
+	if got, want := availableVersion(plugin, matched, ok), strings.ToLower(platform.Sha256); got != want {
+		t.Errorf("availableVersion() = %q, want the checksum %q", got, want)
+	}
+
+	plugin.Spec.Version = "v1.2.0"
+	if got, want := availableVersion(plugin, matched, ok), "v1.2.0"; got != want {
+		t.Errorf("availableVersion() with a semantic version = %q, want %q", got, want)
+	}
+
+	_, ok, err = installation.GetMatchingPlatformFor(plugin, "darwin", "amd64")
+	if err != nil || ok {
+		t.Fatalf("GetMatchingPlatformFor() = %v, %v, expected no match", ok, err)
+	}
+	if got, want := availableVersion(plugin, index.Platform{}, ok), "v1.2.0"; got != want {
+		t.Errorf("availableVersion() without a matching platform = %q, want %q", got, want)
+	}
+}
+
+func Test_searchTable(t *testing.T) {
+	results := []searchResult{
+		{Name: "foo", Description: "a plugin with a long description", Status: searchStatusAvailable, Version: "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116", Homepage: "https://example.com/foo"},
+		{Name: "bar", Description: "bar", Status: searchStatusInstalled, Version: "v1.1.0", InstalledVersion: "v1.0.0", UpgradeAvailable: true},
+		{Name: "qux", Description: "qux", Status: searchStatusInstalled, Version: "v2.0.0", InstalledVersion: "v2.0.0"},
+		{Name: "baz", Description: "baz", Status: searchStatusUnavailable, SupportedPlatforms: []string{"os=darwin", "os in (linux,windows),arch=arm64"}},
+	}
+
+	cols, rows := searchTable(results, false, 10, false)
+	wantCols := []string{"NAME", "DESCRIPTION", "STATUS", "VERSION"}
+	wantRows := [][]string{
+		{"foo", "a plugi...", searchStatusAvailable, "8b40a4ad5..."},
+		{"bar", "bar", "installed (upgrade available)", "v1.0.0"},
+		{"qux", "qux", searchStatusInstalled, "v2.0.0"},
+		{"baz", "baz", searchStatusUnavailable, ""},
+	}
+	if !reflect.DeepEqual(cols, wantCols) || !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("searchTable() = %v %v, want %v %v", cols, rows, wantCols, wantRows)
+	}
+
+	cols, rows = searchTable(results, true, 0, true)
+	wantCols = []string{"NAME", "DESCRIPTION", "STATUS", "VERSION", "HOMEPAGE", "MATCH"}
+	wantRows = [][]string{
+		{"foo", "a plugin with a long description", searchStatusAvailable, results[0].Version, "https://example.com/foo", ""},
+		{"bar", "bar", "installed (upgrade available)", "v1.0.0", "", ""},
+		{"qux", "qux", searchStatusInstalled, "v2.0.0", "", ""},
+		{"baz", "baz", "unavailable (supports: os=darwin; os in (linux,windows),arch=arm64)", "", "", ""},
+	}
+	if !reflect.DeepEqual(cols, wantCols) || !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("wide searchTable() = %v %v, want %v %v", cols, rows, wantCols, wantRows)
+	}
+}
+
+func Test_searchPlugins_normalized(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"istio-tools": {Spec: index.PluginSpec{ShortDescription: "Manage Istio meshes"}},
+		"café":        {Spec: index.PluginSpec{ShortDescription: "Brew coffee"}},
+		"ctx":         {Spec: index.PluginSpec{ShortDescription: "Switch contexts"}},
+	}
+	names := []string{"café", "ctx", "istio-tools"}
+	keys, byKey := testSearchIndex(names, plugins)
+
+	tests := []struct {
+		keyword string
+		mode    string
+		fields  string
+		want    []string
+	}{
+		{keyword: "Istio", mode: searchMatchFuzzy, fields: searchFieldName, want: []string{"istio-tools"}},
+		{keyword: "ISTIO", mode: searchMatchPrefix, fields: searchFieldName, want: []string{"istio-tools"}},
+		{keyword: "cafe", mode: searchMatchFuzzy, fields: searchFieldName, want: []string{"café"}},
+		{keyword: "CAFÉ", mode: searchMatchExact, fields: searchFieldName, want: []string{"café"}},
+		{keyword: "ístio", mode: searchMatchFuzzy, fields: searchFieldDescription, want: []string{"istio-tools"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.keyword, func(t *testing.T) {
+			matches, err := searchPlugins([]string{tt.keyword}, keys, byKey, tt.fields, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.key.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchPlugins(%q) = %v, want %v", tt.keyword, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_normalizeSearchText(t *testing.T) {
+	for in, want := range map[string]string{
+		"Istio":    "istio",
+		"Café":     "cafe",
+		"ÅNGSTRÖM": "angstrom",
+		"ｆｕｌｌ":     "full", // compatibility decomposition of full-width letters
+		"日本語":      "日本語",
+	} {
+		if got := normalizeSearchText(in); got != want {
+			t.Errorf("normalizeSearchText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func Test_supportedPlatforms(t *testing.T) {
+	plugin := index.Plugin{Spec: index.PluginSpec{Platforms: []index.Platform{
+		{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "darwin", "arch": "arm64"}}},
+		{Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "os",
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{"linux", "windows"},
+		}}}},
+		{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "darwin", "arch": "arm64"}}},
+		{},
+	}}}
+	want := []string{"arch=arm64,os=darwin", "os in (linux,windows)"}
+	if got := supportedPlatforms(plugin); !reflect.DeepEqual(got, want) {
+		t.Errorf("supportedPlatforms() = %q, want %q", got, want)
+	}
+}
+
+func Test_ndjsonWriter(t *testing.T) {
+	var buf bytes.Buffer
+	write := ndjsonWriter(&buf)
+	results := []searchResult{
+		{Name: "foo", Description: "the <foo> plugin", Status: searchStatusInstalled, Version: "v1.0.0", InstalledVersion: "v1.0.0"},
+		{Name: "bar", Status: searchStatusUnavailable, SupportedPlatforms: []string{"os=darwin"}},
+	}
+	for i, r := range results {
+		if err := write(r); err != nil {
+			t.Fatal(err)
+		}
+		// each result is written as soon as it is found
+		if lines := strings.Count(buf.String(), "\n"); lines != i+1 {
+			t.Fatalf("after writing %d results, the output has %d lines", i+1, lines)
+		}
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	var got []searchResult
+	for scanner.Scan() {
+		var r searchResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q is not a JSON object: %v", scanner.Text(), err)
+		}
+		got = append(got, r)
+	}
+	if !reflect.DeepEqual(got, results) {
+		t.Errorf("ndjsonWriter() wrote %+v, want %+v", got, results)
+	}
+}
+
+func Test_loadAllPlugins_overlappingIndexes(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	testdata := filepath.Join("..", "..", "..", "pkg", "index", "indexscanner", "testdata", "testindex", "plugins")
+	for dir, names := range map[string][]string{
+		"index":           {"foo", "bar"},
+		"indexes/company": {"foo"},
+	} {
+		for _, name := range names {
+			manifest, err := ioutil.ReadFile(filepath.Join(testdata, name+".yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dir != "index" {
+				manifest = bytes.Replace(manifest, []byte(`shortDescription: "exists"`), []byte(`shortDescription: "company foo"`), 1)
+			}
+			tmpDir.Write(filepath.Join(dir, "plugins", name+".yaml"), manifest)
+		}
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", "https://example.com/company.git"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmpDir.Path("indexes/company")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v, %s", args, err, out)
+		}
+	}
+
+	defer func(p environment.Paths) { paths = p }(paths)
+	defer os.Setenv("KREW_ROOT", os.Getenv("KREW_ROOT"))
+	os.Setenv("KREW_ROOT", tmpDir.Root())
+	paths = environment.MustGetKrewPaths()
+
+	keys, plugins, err := loadAllPlugins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultFoo, companyFoo := pluginKey{"default", "foo"}, pluginKey{"company", "foo"}
+	if want := []pluginKey{{"default", "bar"}, defaultFoo, companyFoo}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("loadAllPlugins() keys = %v, want %v", keys, want)
+	}
+	if got := plugins[defaultFoo].Spec.ShortDescription; got != "exists" {
+		t.Errorf("plugin foo of the default index has description %q, want it not to be overwritten", got)
+	}
+	if got := plugins[companyFoo].Spec.ShortDescription; got != "company foo" {
+		t.Errorf("plugin foo of the company index has description %q", got)
+	}
+
+	refs := pluginRefs(keys)
+	if got, want := []string{refs[defaultFoo], refs[companyFoo], refs[pluginKey{"default", "bar"}]}, []string{"foo", "company/foo", "bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pluginRefs() = %v, want %v", got, want)
+	}
+
+	matches, err := searchPlugins([]string{"foo"}, keys, plugins, searchFieldName, searchMatchExact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []searchMatch{{key: defaultFoo, field: searchFieldName}, {key: companyFoo, field: searchFieldName}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("searchPlugins(foo) = %v, want a match in each index %v", matches, want)
+	}
+
+	if got, want := pluginKeysOfIndex(keys, "company"), []pluginKey{companyFoo}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pluginKeysOfIndex(company) = %v, want %v", got, want)
+	}
+	if err := validateIndexName("company"); err != nil {
+		t.Errorf("validateIndexName(company) error = %v", err)
+	}
+	if err := validateIndexName("not-found"); err == nil {
+		t.Error("validateIndexName() of an index that is not configured expected an error")
+	}
+
+	// only the row of the index the plugin was installed from is installed
+	tmpDir.Write(filepath.Join("store", "foo", "receipt.yaml"), []byte("plugin: foo\nversion: v1.0.0\nindex: company\n"))
+	if installedFromIndex("foo", "default") || !installedFromIndex("foo", "company") || !installedFromIndex("foo", "") {
+		t.Error("installedFromIndex() expected foo to be installed from the company index only")
+	}
+	tmpDir.Write(filepath.Join("store", "foo", "receipt.yaml"), []byte("plugin: foo\nversion: v1.0.0\n"))
+	if !installedFromIndex("foo", "default") || installedFromIndex("foo", "company") {
+		t.Error("installedFromIndex() expected foo without an index in its receipt to be installed from the default index only")
+	}
+}
+
+func Test_searchTable_indexColumn(t *testing.T) {
+	results := []searchResult{
+		{Name: "foo", Index: "default", Description: "foo", Status: searchStatusAvailable, Version: "v1.0.0"},
+		{Name: "company/foo", Index: "company", Description: "company foo", Status: searchStatusInstalled, Version: "v2.0.0", InstalledVersion: "v2.0.0"},
+	}
+	cols, rows := searchTable(results, false, 0, false)
+	wantCols := []string{"NAME", "INDEX", "DESCRIPTION", "STATUS", "VERSION"}
+	wantRows := [][]string{
+		{"foo", "default", "foo", searchStatusAvailable, "v1.0.0"},
+		{"company/foo", "company", "company foo", searchStatusInstalled, "v2.0.0"},
+	}
+	if !reflect.DeepEqual(cols, wantCols) || !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("searchTable() = %v %v, want %v %v", cols, rows, wantCols, wantRows)
+	}
+
+	// plugins of the default index only don't need the column
+	cols, _ = searchTable(results[:1], false, 0, false)
+	if want := []string{"NAME", "DESCRIPTION", "STATUS", "VERSION"}; !reflect.DeepEqual(cols, want) {
+		t.Errorf("searchTable() of the default index = %v, want %v", cols, want)
+	}
+
+	b, err := json.Marshal(results[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"index":"company"`) {
+		t.Errorf("json search result = %s, expected the index", b)
+	}
+}
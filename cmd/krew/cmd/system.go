@@ -0,0 +1,369 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/gitutil"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
+	"sigs.k8s.io/krew/pkg/installation"
+)
+
+// systemCmd represents the system command
+var systemCmd = &cobra.Command{
+	Use:   "system",
+	Short: "Perform krew maintenance tasks",
+	Long: `Perform krew maintenance tasks and checks that don't fit into the
+other commands.`,
+}
+
+// validateNameCmd represents the system validate-name command
+var validateNameCmd = &cobra.Command{
+	Use:   "validate-name NAME",
+	Short: "Check if a plugin name is allowed",
+	Long: fmt.Sprintf(`Check if a plugin name is allowed before publishing a plugin.
+
+Remarks:
+  %s.
+
+Example:
+  kubectl krew system validate-name view-secret`, pluginNameRules()),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := index.ValidatePluginName(args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "The plugin name %q is valid\n", args[0])
+		return nil
+	},
+	Args: cobra.ExactArgs(1),
+}
+
+// pluginNameRules returns the description of the allowed plugin names.
+func pluginNameRules() string {
+	description, _ := index.SafePluginNameRules()
+	return description
+}
+
+// validateIndexCmd represents the system validate-index command
+var validateIndexCmd = &cobra.Command{
+	Use:   "validate-index DIR",
+	Short: "Check if all plugin manifests in an index are valid",
+	Long: `Check if all plugin manifests in an index directory can be loaded, are valid
+and have no colliding plugin names. DIR is the root of the index repository,
+which has the plugin manifests in its plugins/ directory.
+
+Example:
+  kubectl krew system validate-index ./krew-index`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := indexscanner.LoadPluginListFromFSStrict(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "invalid index %s", args[0])
+		}
+		fmt.Fprintf(os.Stdout, "All %d plugin manifests in the index are valid\n", len(list.Items))
+		return nil
+	},
+	Args: cobra.ExactArgs(1),
+}
+
+var systemInfoOutput string
+
+// systemInfoCmd represents the system info command
+var systemInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the krew directories and indexes and check that they are usable",
+	Long: `Show the directories krew uses and check that plugins can be installed.
+
+The install and bin directories are created if they don't exist, and must be
+writable. The bin directory should be in your PATH for kubectl to find the
+installed plugins.
+
+The plugin indexes are shown with their URL, the checked out commit and when
+they were last updated, to find out if search results may be stale.
+
+Example:
+  kubectl krew system info
+  kubectl krew system info --root=/opt/krew
+  kubectl krew system info -o json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rows, ok := systemInfo(paths, os.Getenv("PATH"))
+		indexes, err := systemIndexes(paths)
+		if err != nil {
+			return err
+		}
+		if err := printSystemInfo(os.Stdout, rows, indexes, systemInfoOutput); err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("plugins can't be installed, see the status of the directories above")
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat(systemInfoOutput)
+	},
+	Args: cobra.NoArgs,
+}
+
+// directoryInfo is a krew directory printed by system info.
+type directoryInfo struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// indexInfo is a plugin index printed by system info.
+type indexInfo struct {
+	Name        string     `json:"name"`
+	Path        string     `json:"path"`
+	URL         string     `json:"url,omitempty"`
+	Commit      string     `json:"commit,omitempty"`
+	LastUpdated *time.Time `json:"lastUpdated,omitempty"`
+	Status      string     `json:"status"`
+}
+
+// systemIndexes returns the metadata of the default index and the custom
+// indexes of p. Failing to read the metadata of an index is shown in its
+// status.
+func systemIndexes(p environment.Paths) ([]indexInfo, error) {
+	indexes, err := indexoperations.ListIndexes(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the indexes")
+	}
+	var out []indexInfo
+	for _, idx := range indexes {
+		info := indexInfo{Name: idx.Name, Path: p.IndexPathFor(idx.Name), URL: idx.URL, Status: "ok"}
+		if cloned, err := gitutil.IsGitCloned(info.Path); err != nil {
+			info.Status = err.Error()
+			out = append(out, info)
+			continue
+		} else if !cloned {
+			info.Status = `not initialized (run "kubectl krew update")`
+			out = append(out, info)
+			continue
+		}
+		if url, err := gitutil.GetRemoteURL(info.Path); err == nil {
+			info.URL = url
+		}
+		if info.Commit, err = gitutil.GetHeadCommit(info.Path); err != nil {
+			info.Status = err.Error()
+		}
+		if updated, err := gitutil.GetLastUpdateTime(info.Path); err != nil {
+			info.Status = err.Error()
+		} else {
+			updated = updated.UTC()
+			info.LastUpdated = &updated
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// printSystemInfo prints the rows of systemInfo and the indexes in the output
+// format.
+func printSystemInfo(out io.Writer, rows [][]string, indexes []indexInfo, format string) error {
+	if format == outputFormatJSON || format == outputFormatYAML {
+		info := struct {
+			Directories []directoryInfo `json:"directories"`
+			Indexes     []indexInfo     `json:"indexes"`
+		}{Indexes: indexes}
+		for _, r := range rows {
+			info.Directories = append(info.Directories, directoryInfo{Name: r[0], Path: r[1], Status: r[2]})
+		}
+		if format == outputFormatJSON {
+			return printJSON(out, info)
+		}
+		return printYAML(out, info)
+	}
+
+	if err := printTable(out, []string{"DIRECTORY", "PATH", "STATUS"}, rows); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	var indexRows [][]string
+	for _, idx := range indexes {
+		commit, updated := idx.Commit, "-"
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if commit == "" {
+			commit = "-"
+		}
+		if idx.LastUpdated != nil {
+			updated = idx.LastUpdated.Format(time.RFC3339)
+		}
+		indexRows = append(indexRows, []string{idx.Name, idx.URL, commit, updated, idx.Status})
+	}
+	return printTable(out, []string{"INDEX", "URL", "COMMIT", "LAST UPDATED", "STATUS"}, indexRows)
+}
+
+// systemInfo returns the rows of the system info table for the krew paths and
+// the PATH environment variable pathEnv, and false if the install or the bin
+// directory is not usable.
+func systemInfo(p environment.Paths, pathEnv string) ([][]string, bool) {
+	ok := true
+	status := func(err error) string {
+		if err != nil {
+			ok = false
+			return err.Error()
+		}
+		return "ok"
+	}
+
+	binStatus := status(environment.EnsureWritableDir(p.BinPath()))
+	if binStatus == "ok" && !containsString(filepath.SplitList(pathEnv), p.BinPath()) {
+		binStatus = "ok, but not in PATH"
+	}
+	indexStatus := "ok"
+	if cloned, err := gitutil.IsGitCloned(p.IndexPath()); err != nil {
+		indexStatus = err.Error()
+	} else if !cloned {
+		indexStatus = `not initialized (run "kubectl krew update")`
+	}
+	return [][]string{
+		{"base", p.BasePath(), "ok"},
+		{"index", p.IndexPath(), indexStatus},
+		{"install", p.InstallPath(), status(environment.EnsureWritableDir(p.InstallPath()))},
+		{"bin", p.BinPath(), binStatus},
+		{"download", p.DownloadPath(), status(environment.EnsureWritableDir(p.DownloadPath()))},
+	}, ok
+}
+
+var systemListPlatformsOutput string
+
+// systemListPlatformsCmd represents the system list-platforms command
+var systemListPlatformsCmd = &cobra.Command{
+	Use:   "list-platforms PLUGIN",
+	Short: "List the platforms a plugin declares",
+	Long: `List all platforms declared in the manifest of a plugin, with the selector
+each platform is matched with and the URI and sha256 checksum of its archive.
+
+The selectors are printed like Kubernetes label selectors, such as
+"os=darwin,arch in (amd64,arm64)". A platform without a selector matches no
+system and is printed as "<none>".
+
+Examples:
+  kubectl krew system list-platforms PLUGIN
+  kubectl krew system list-platforms -o json PLUGIN`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugin, err := indexoperations.LoadPlugin(paths, args[0])
+		if os.IsNotExist(err) {
+			return errors.Errorf("plugin %q not found", args[0])
+		} else if err != nil {
+			return errors.Wrap(err, "failed to load plugin manifest")
+		}
+		return printPlatforms(os.Stdout, plugin, systemListPlatformsOutput)
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(systemListPlatformsOutput); err != nil {
+			return err
+		}
+		return checkIndex(cmd, args)
+	},
+	Args: cobra.ExactArgs(1),
+}
+
+// platformEntry is a platform of a plugin printed by system list-platforms.
+type platformEntry struct {
+	Selector string `json:"selector"`
+	URI      string `json:"uri"`
+	Sha256   string `json:"sha256,omitempty"`
+}
+
+// printPlatforms prints the platforms of the plugin in the order of the
+// manifest in the output format.
+func printPlatforms(out io.Writer, plugin index.Plugin, format string) error {
+	entries := make([]platformEntry, 0, len(plugin.Spec.Platforms))
+	for _, p := range plugin.Spec.Platforms {
+		entries = append(entries, platformEntry{
+			Selector: metav1.FormatLabelSelector(p.Selector),
+			URI:      p.URI,
+			Sha256:   p.Sha256,
+		})
+	}
+	switch format {
+	case outputFormatJSON:
+		return printJSON(out, entries)
+	case outputFormatYAML:
+		return printYAML(out, entries)
+	}
+	var rows [][]string
+	for _, e := range entries {
+		rows = append(rows, []string{e.Selector, e.URI, e.Sha256})
+	}
+	return printTable(out, []string{"SELECTOR", "URI", "SHA256"}, rows)
+}
+
+var systemGCDryRun bool
+
+// systemGCCmd represents the system gc command
+var systemGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove old versions of installed plugins",
+	Long: `Remove the directories of plugin versions that are no longer used, such as
+the versions left behind by upgrades. Only the version each plugin is linked
+to is kept.
+
+Plugins without a working link, such as broken installations, are left alone.
+Use "kubectl krew list" to find and repair them.
+
+Examples:
+  To see the directories that would be removed:
+    kubectl krew system gc --dry-run
+
+  To remove them:
+    kubectl krew system gc`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed, err := installation.RemoveOldVersions(paths.InstallPath(), paths.BinPath(), systemGCDryRun)
+		for _, dir := range removed {
+			if systemGCDryRun {
+				fmt.Fprintf(os.Stdout, "Would remove %s\n", dir)
+			} else {
+				fmt.Fprintf(os.Stdout, "Removed %s\n", dir)
+			}
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to remove old plugin versions")
+		}
+		if len(removed) == 0 {
+			fmt.Fprintln(infoOut, "No old plugin versions found")
+		}
+		return nil
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	systemGCCmd.Flags().BoolVar(&systemGCDryRun, "dry-run", false, "Only print the directories that would be removed")
+	systemCmd.AddCommand(systemGCCmd)
+	systemListPlatformsCmd.Flags().StringVarP(&systemListPlatformsOutput, "output", "o", outputFormatTable, "Output format. One of: table|json|yaml")
+	setArgsCompletion(systemListPlatformsCmd, completeIndexPlugins)
+	systemCmd.AddCommand(systemListPlatformsCmd)
+	systemInfoCmd.Flags().StringVarP(&systemInfoOutput, "output", "o", outputFormatTable, "Output format. One of: table|json|yaml")
+	systemCmd.AddCommand(systemInfoCmd)
+	systemCmd.AddCommand(validateNameCmd)
+	systemCmd.AddCommand(validateIndexCmd)
+	rootCmd.AddCommand(systemCmd)
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_systemInfo(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p, err := environment.NewPaths(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, ok := systemInfo(p, strings.Join([]string{"/usr/bin", p.BinPath()}, string(os.PathListSeparator)))
+	if !ok {
+		t.Fatalf("systemInfo() = %v, expected the directories to be usable", rows)
+	}
+	if got := rows[3]; got[0] != "bin" || got[2] != "ok" {
+		t.Errorf("unexpected bin row %q", got)
+	}
+	if got := rows[1][2]; !strings.Contains(got, "not initialized") {
+		t.Errorf("index status = %q, expected the index not to be initialized", got)
+	}
+
+	rows, ok = systemInfo(p, "/usr/bin")
+	if !ok || rows[3][2] != "ok, but not in PATH" {
+		t.Errorf("systemInfo() bin row = %q, expected a PATH hint", rows[3])
+	}
+
+	if err := os.RemoveAll(p.BinPath()); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("bin", []byte("not a directory"))
+	rows, ok = systemInfo(p, p.BinPath())
+	if ok || rows[3][2] == "ok" {
+		t.Errorf("systemInfo() = %q, %v, expected the bin directory not to be usable", rows[3], ok)
+	}
+}
+
+func Test_systemIndexes_notInitialized(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	p, err := environment.NewPaths(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := systemIndexes(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 1 || indexes[0].Name != "default" || !strings.Contains(indexes[0].Status, "not initialized") {
+		t.Fatalf("systemIndexes() = %+v, expected the default index not to be initialized", indexes)
+	}
+	if indexes[0].Commit != "" || indexes[0].LastUpdated != nil {
+		t.Errorf("systemIndexes() = %+v, expected no commit and update time", indexes[0])
+	}
+}
+
+func Test_printSystemInfo(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := [][]string{{"base", "/krew", "ok"}}
+	indexes := []indexInfo{
+		{Name: "default", Path: "/krew/index", URL: "https://example.com/index.git", Commit: "0123456789abcdef", LastUpdated: &updated, Status: "ok"},
+		{Name: "other", Path: "/krew/indexes/other", Status: "not initialized"},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: outputFormatTable,
+			want: `DIRECTORY PATH  STATUS
+base      /krew ok
+
+INDEX   URL                           COMMIT       LAST UPDATED         STATUS
+default https://example.com/index.git 0123456789ab 2020-01-02T03:04:05Z ok
+other                                 -            -                    not initialized
+`,
+		},
+		{
+			format: outputFormatJSON,
+			want: `{
+  "directories": [
+    {
+      "name": "base",
+      "path": "/krew",
+      "status": "ok"
+    }
+  ],
+  "indexes": [
+    {
+      "name": "default",
+      "path": "/krew/index",
+      "url": "https://example.com/index.git",
+      "commit": "0123456789abcdef",
+      "lastUpdated": "2020-01-02T03:04:05Z",
+      "status": "ok"
+    },
+    {
+      "name": "other",
+      "path": "/krew/indexes/other",
+      "status": "not initialized"
+    }
+  ]
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printSystemInfo(&out, rows, indexes, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("printSystemInfo() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_printPlatforms(t *testing.T) {
+	plugin := index.Plugin{Spec: index.PluginSpec{Platforms: []index.Platform{
+		{
+			URI:    "https://example.com/foo-linux.tar.gz",
+			Sha256: "abc",
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"os": "linux"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "arch", Operator: metav1.LabelSelectorOpIn, Values: []string{"amd64", "arm64"}},
+				},
+			},
+		},
+		{URI: "https://example.com/foo.zip", Sha256: "def"},
+	}}}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: outputFormatTable,
+			want: `SELECTOR                       URI                                  SHA256
+arch in (amd64,arm64),os=linux https://example.com/foo-linux.tar.gz abc
+<none>                         https://example.com/foo.zip          def
+`,
+		},
+		{
+			format: outputFormatJSON,
+			want: `[
+  {
+    "selector": "arch in (amd64,arm64),os=linux",
+    "uri": "https://example.com/foo-linux.tar.gz",
+    "sha256": "abc"
+  },
+  {
+    "selector": "\u003cnone\u003e",
+    "uri": "https://example.com/foo.zip",
+    "sha256": "def"
+  }
+]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printPlatforms(&out, plugin, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("printPlatforms() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
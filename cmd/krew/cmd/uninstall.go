@@ -16,7 +16,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/pkg/errors"
 	"sigs.k8s.io/krew/pkg/installation"
@@ -39,10 +38,10 @@ Remarks:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		for _, name := range args {
 			glog.V(4).Infof("Going to uninstall plugin %s\n", name)
-			if err := installation.Uninstall(paths, name); err != nil {
+			if err := installation.Uninstall(paths.InstallPath(), paths.BinPath(), name); err != nil {
 				return errors.Wrapf(err, "failed to uninstall plugin %s", name)
 			}
-			fmt.Fprintf(os.Stderr, "Uninstalled plugin %s\n", name)
+			fmt.Fprintf(infoOut, "Uninstalled plugin %s\n", name)
 		}
 		return nil
 	},
@@ -52,5 +51,6 @@ Remarks:
 }
 
 func init() {
+	setArgsCompletion(uninstallCmd, completeInstalledPlugins)
 	rootCmd.AddCommand(uninstallCmd)
 }
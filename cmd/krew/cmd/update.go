@@ -16,13 +16,13 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/gitutil"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
 )
 
 // updateCmd represents the update command
@@ -45,10 +45,42 @@ func ensureIndexUpdated(_ *cobra.Command, _ []string) error {
 	if err := gitutil.EnsureUpdated(constants.IndexURI, paths.IndexPath()); err != nil {
 		return errors.Wrap(err, "failed to update the local index")
 	}
-	fmt.Fprintln(os.Stderr, "Updated the local copy of plugin index.")
+
+	indexes, err := indexoperations.ListIndexes(paths)
+	if err != nil {
+		return errors.Wrap(err, "failed to list custom indexes")
+	}
+	for _, idx := range indexes {
+		if idx.Name == constants.DefaultIndexName {
+			continue
+		}
+		glog.V(1).Infof("Updating the local copy of plugin index %q", idx.Name)
+		if err := gitutil.EnsureUpdated(idx.URL, paths.IndexPathFor(idx.Name)); err != nil {
+			return errors.Wrapf(err, "failed to update the local copy of index %q", idx.Name)
+		}
+	}
+	fmt.Fprintln(infoOut, "Updated the local copy of plugin index.")
 	return nil
 }
 
+// noUpdateIndexFlag is the name of the flag to skip updating the local copy of
+// the plugin index before running a command.
+const noUpdateIndexFlag = "no-update-index"
+
+func addNoUpdateIndexFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool(noUpdateIndexFlag, false, "Use the local copy of the plugin index without updating it")
+}
+
+// ensureIndexUpdatedOrExists updates the local copy of the plugin index, or
+// only checks that it exists if --no-update-index is specified.
+func ensureIndexUpdatedOrExists(cmd *cobra.Command, args []string) error {
+	if skip, _ := cmd.Flags().GetBool(noUpdateIndexFlag); skip {
+		glog.V(2).Infof("--%s specified, not updating the plugin index", noUpdateIndexFlag)
+		return checkIndex(cmd, args)
+	}
+	return ensureIndexUpdated(cmd, args)
+}
+
 func init() {
 	rootCmd.AddCommand(updateCmd)
 }
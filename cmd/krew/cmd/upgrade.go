@@ -16,9 +16,11 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
 
-	"sigs.k8s.io/krew/pkg/index/indexscanner"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
 	"sigs.k8s.io/krew/pkg/installation"
 
 	"github.com/golang/glog"
@@ -34,46 +36,188 @@ var upgradeCmd = &cobra.Command{
 This will reinstall all plugins that have a newer version in the local index.
 Use "kubectl krew update" to renew the index.
 To only upgrade single plugins provide them as arguments:
-kubectl krew upgrade foo bar"`,
+kubectl krew upgrade foo bar
+Plugins that fail to upgrade don't stop the upgrade of the other plugins.
+To list the plugins that would be upgraded without upgrading them:
+kubectl krew upgrade --dry-run`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var ignoreUpgraded bool
+		installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+		if err != nil {
+			return errors.Wrap(err, "failed to find all installed versions")
+		}
+
 		var pluginNames []string
 		// Upgrade all plugins.
 		if len(args) == 0 {
-			installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
-			if err != nil {
-				return errors.Wrap(err, "failed to find all installed versions")
-			}
 			for name := range installed {
 				pluginNames = append(pluginNames, name)
 			}
-			ignoreUpgraded = true
+			sort.Strings(pluginNames)
 		} else {
 			pluginNames = args
 		}
 
+		goos, goarch, err := osArchFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if upgradeDryRun {
+			return printOutdatedPlugins(pluginNames, goos, goarch)
+		}
+
+		client, err := httpClientFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		mirrors, err := mirrorsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		extractLimits, err := extractLimitsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		signatureVerifier, err := signatureVerifierFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		opts := installation.UpgradeOpts{
+			HTTPClient:        client,
+			Progress:          progressFromFlags(cmd),
+			Mirrors:           mirrors,
+			AllowLocalURIs:    allowLocalURIsFromFlags(cmd),
+			ExtractLimits:     extractLimits,
+			SignatureVerifier: signatureVerifier,
+			ResumeDownloads:   resumeFromFlags(cmd),
+			RelativeLinks:     relativeLinksFromFlags(cmd),
+			AllowHooks:        allowHooksFromFlags(cmd),
+			HookOutput:        infoOut,
+			ForceOS:           goos,
+			ForceArch:         goarch,
+		}
+
+		var upgraded, skipped, failed []string
+		// the errors of the failed plugins for --error-format=json
+		failedErrs := make(map[string]error)
 		for _, name := range pluginNames {
-			plugin, err := indexscanner.LoadPluginFileFromFS(paths.IndexPath(), name)
+			if err := rootContext.Err(); err != nil {
+				return errors.Wrap(err, "upgrade was interrupted")
+			}
+			if _, ok := installed[name]; !ok {
+				glog.Warningf("failed to upgrade plugin %q: it is not installed", name)
+				failed = append(failed, name)
+				failedErrs[name] = installation.ErrNotInstalled
+				continue
+			}
+			plugin, indexName, err := indexoperations.FindPlugin(paths, receiptPluginRef(name))
 			if err != nil {
-				return errors.Wrapf(err, "failed to load the index file for plugin %s", plugin.Name)
+				glog.Warningf("failed to load the index file for plugin %q: %v", name, err)
+				failed = append(failed, name)
+				failedErrs[name] = err
+				continue
 			}
 
 			glog.V(2).Infof("Upgrading plugin: %s\n", plugin.Name)
-			err = installation.Upgrade(paths, plugin)
-			if ignoreUpgraded && err == installation.ErrIsAlreadyUpgraded {
-				fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is already on the newest version\n", plugin.Name)
+			opts.Index = indexName
+			err = installation.UpgradeContext(rootContext, paths, plugin, opts)
+			if err == installation.ErrIsAlreadyUpgraded {
+				fmt.Fprintf(infoOut, "Skipping plugin %s, it is already on the newest version\n", plugin.Name)
+				skipped = append(skipped, name)
 				continue
 			}
 			if err != nil {
-				return errors.Wrapf(err, "failed to upgrade plugin %q", plugin.Name)
+				glog.Warningf("failed to upgrade plugin %q: %v", plugin.Name, err)
+				failed = append(failed, name)
+				failedErrs[name] = err
+				continue
 			}
-			fmt.Fprintf(os.Stderr, "Upgraded plugin: %s\n", plugin.Name)
+			fmt.Fprintf(infoOut, "Upgraded plugin: %s\n", plugin.Name)
+			upgraded = append(upgraded, name)
+		}
+		if len(pluginNames) > 1 {
+			printUpgradeSummary(infoOut, upgraded, skipped, failed)
+		}
+		if len(failed) > 0 {
+			return failedPluginsError("upgrade", failed, failedErrs)
 		}
 		return nil
 	},
-	PreRunE: ensureIndexUpdated,
+	PreRunE: ensureIndexUpdatedOrExists,
 }
 
+// receiptPluginRef returns the {index}/{plugin} reference of an installed
+// plugin if its receipt records the index it was installed from, so that it is
+// upgraded from the same index. Otherwise it returns the plugin name.
+func receiptPluginRef(name string) string {
+	receipt, err := installation.ReadReceipt(paths.InstallPath(), name)
+	if err != nil || receipt.Index == "" {
+		return name
+	}
+	return receipt.Index + "/" + name
+}
+
+// printUpgradeSummary prints the plugins that were upgraded, skipped because
+// they are on the newest version, and failed to upgrade.
+func printUpgradeSummary(out io.Writer, upgraded, skipped, failed []string) {
+	printSummary(out, []summaryGroup{
+		{"Upgraded", upgraded},
+		{"Skipped (already on the newest version)", skipped},
+		{"Failed", failed},
+	})
+}
+
+// printOutdatedPlugins prints the plugins that have a newer version in the
+// index, or none for the current system.
+func printOutdatedPlugins(pluginNames []string, goos, goarch string) error {
+	installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+	if err != nil {
+		return errors.Wrap(err, "failed to find all installed versions")
+	}
+
+	var rows [][]string
+	for _, name := range pluginNames {
+		plugin, err := indexoperations.LoadPlugin(paths, receiptPluginRef(name))
+		if err != nil {
+			return errors.Wrapf(err, "failed to load the index file for plugin %s", name)
+		}
+		current, ok := installed[plugin.Name]
+		if !ok {
+			return errors.Errorf("plugin %q is not installed", name)
+		}
+		available, ok, err := installation.AvailableVersionFor(plugin, goos, goarch)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get the available version of plugin %s", name)
+		}
+		if !ok {
+			available = "unavailable"
+		}
+		upgrade, err := installation.HasUpgradeFor(paths.InstallPath(), plugin, current, goos, goarch)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check for an upgrade of plugin %s", name)
+		}
+		if !ok || upgrade {
+			rows = append(rows, []string{name, current, available})
+		}
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(infoOut, "All plugins are on the newest version")
+		return nil
+	}
+	return printTable(os.Stdout, []string{"NAME", "CURRENT", "AVAILABLE"}, rows)
+}
+
+var upgradeDryRun bool
+
 func init() {
+	upgradeCmd.Flags().BoolVar(&upgradeDryRun, "dry-run", false, "Print the installed plugins that have a newer version in the index without upgrading them")
+	addNoUpdateIndexFlag(upgradeCmd)
+	addDownloadMirrorFlag(upgradeCmd)
+	addCACertFlag(upgradeCmd)
+	addAllowHooksFlag(upgradeCmd)
+	addAllowLocalURIsFlag(upgradeCmd)
+	addRelativeLinksFlag(upgradeCmd)
+	addExtractLimitFlags(upgradeCmd)
+	addTrustedKeyFlag(upgradeCmd)
+	addResumeFlag(upgradeCmd)
 	rootCmd.AddCommand(upgradeCmd)
 }
@@ -0,0 +1,143 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexoperations"
+	"sigs.k8s.io/krew/pkg/installation"
+)
+
+const (
+	// upgradeCheckInterval is the minimum time between two checks for
+	// upgrades of the installed plugins.
+	upgradeCheckInterval = 24 * time.Hour
+
+	// upgradeCheckFileName is the name of the file in the krew root that
+	// records the time of the last upgrade check.
+	upgradeCheckFileName = "last-upgrade-check"
+
+	// noUpgradeCheckEnvVar disables the upgrade check if it is set to a
+	// non-empty value.
+	noUpgradeCheckEnvVar = "KREW_NO_UPGRADE_CHECK"
+)
+
+// notifyUpgrades prints a line to out if any installed plugin has a newer
+// version in the index. It checks at most once per upgradeCheckInterval and
+// only if stderr is a terminal and out doesn't discard the output (--quiet),
+// and never fails.
+func notifyUpgrades(out io.Writer) {
+	if out == ioutil.Discard || !isTerminal(os.Stderr) {
+		return
+	}
+	stateFile := filepath.Join(paths.BasePath(), upgradeCheckFileName)
+	now := time.Now()
+	if !shouldCheckUpgrades(stateFile, os.Getenv(noUpgradeCheckEnvVar), now) {
+		return
+	}
+	if err := recordUpgradeCheck(stateFile, now); err != nil {
+		glog.V(2).Infof("Failed to record the upgrade check: %v", err)
+	}
+
+	installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+	if err != nil {
+		glog.V(2).Infof("Skipping the upgrade check: %v", err)
+		return
+	}
+	goos, goarch, err := installation.OSArch("", "")
+	if err != nil {
+		glog.V(2).Infof("Skipping the upgrade check: %v", err)
+		return
+	}
+	// only the manifests of the installed plugins are loaded, not the whole index
+	refs := make(map[string]string, len(installed))
+	var refList []string
+	for name := range installed {
+		refs[name] = receiptPluginRef(name)
+		refList = append(refList, refs[name])
+	}
+	plugins := indexoperations.LoadPlugins(paths, refList)
+	load := func(name string) (index.Plugin, error) {
+		plugin, ok := plugins[refs[name]]
+		if !ok {
+			return index.Plugin{}, errors.Errorf("plugin %q is not in the index", refs[name])
+		}
+		return plugin, nil
+	}
+	if outdated := outdatedPlugins(paths.InstallPath(), installed, load, goos, goarch); len(outdated) > 0 {
+		fmt.Fprintf(out, "Upgrades are available for plugins: %s (run \"kubectl krew upgrade\", or set %s=1 to disable this check)\n",
+			strings.Join(outdated, ", "), noUpgradeCheckEnvVar)
+	}
+}
+
+// shouldCheckUpgrades returns true if the upgrade check is not disabled with
+// optOut, the value of noUpgradeCheckEnvVar, and the last check recorded in
+// stateFile was at least upgradeCheckInterval before now. A missing or
+// unreadable stateFile doesn't prevent the check.
+func shouldCheckUpgrades(stateFile, optOut string, now time.Time) bool {
+	if optOut != "" {
+		glog.V(4).Infof("Upgrade check disabled with %s", noUpgradeCheckEnvVar)
+		return false
+	}
+	b, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		glog.V(4).Infof("No previous upgrade check found: %v", err)
+		return true
+	}
+	last, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+	if err != nil {
+		glog.V(2).Infof("Ignoring the invalid time of the last upgrade check: %v", err)
+		return true
+	}
+	// a last check in the future, e.g. after the clock was changed, is ignored
+	return now.Sub(last) >= upgradeCheckInterval || last.After(now)
+}
+
+// recordUpgradeCheck writes now as the time of the last upgrade check to
+// stateFile.
+func recordUpgradeCheck(stateFile string, now time.Time) error {
+	err := ioutil.WriteFile(stateFile, []byte(now.UTC().Format(time.RFC3339)+"\n"), 0644)
+	return errors.Wrap(err, "failed to write the time of the upgrade check")
+}
+
+// outdatedPlugins returns the sorted names of the plugins installed in
+// installDir that have an upgrade for goos/goarch in the plugins loaded with
+// load. Plugins that fail to load are skipped.
+func outdatedPlugins(installDir string, installed map[string]string, load func(name string) (index.Plugin, error), goos, goarch string) []string {
+	var out []string
+	for name, version := range installed {
+		plugin, err := load(name)
+		if err != nil {
+			glog.V(4).Infof("Skipping the upgrade check of plugin %s: %v", name, err)
+			continue
+		}
+		if upgrade, err := installation.HasUpgradeFor(installDir, plugin, version, goos, goarch); err == nil && upgrade {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
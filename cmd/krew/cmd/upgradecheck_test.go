@@ -0,0 +1,128 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_shouldCheckUpgrades(t *testing.T) {
+	now := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		lastCheck string
+		optOut    string
+		want      bool
+	}{
+		{name: "never checked", want: true},
+		{name: "checked recently", lastCheck: "2020-01-02T00:00:00Z", want: false},
+		{name: "checked a day ago", lastCheck: "2020-01-01T12:00:00Z", want: true},
+		{name: "checked long ago", lastCheck: "2019-06-01T00:00:00Z", want: true},
+		{name: "checked in the future", lastCheck: "2020-06-01T00:00:00Z", want: true},
+		{name: "invalid state", lastCheck: "yesterday", want: true},
+		{name: "opted out", optOut: "1", want: false},
+		{name: "opted out after a day", lastCheck: "2019-06-01T00:00:00Z", optOut: "true", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			stateFile := tmpDir.Path(upgradeCheckFileName)
+			if tt.lastCheck != "" {
+				tmpDir.Write(upgradeCheckFileName, []byte(tt.lastCheck+"\n"))
+			}
+			if got := shouldCheckUpgrades(stateFile, tt.optOut, now); got != tt.want {
+				t.Errorf("shouldCheckUpgrades() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recordUpgradeCheck(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	stateFile := tmpDir.Path(upgradeCheckFileName)
+	now := time.Now()
+
+	if err := recordUpgradeCheck(stateFile, now); err != nil {
+		t.Fatal(err)
+	}
+	if shouldCheckUpgrades(stateFile, "", now.Add(time.Hour)) {
+		t.Error("expected no check an hour after the recorded check")
+	}
+	if !shouldCheckUpgrades(stateFile, "", now.Add(25*time.Hour)) {
+		t.Error("expected a check a day after the recorded check")
+	}
+
+	if err := recordUpgradeCheck(tmpDir.Path("missing/dir/state"), now); err == nil {
+		t.Error("expected an error writing to a missing directory")
+	}
+}
+
+func Test_outdatedPlugins(t *testing.T) {
+	plugin := func(name, version string) index.Plugin {
+		return index.Plugin{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: index.PluginSpec{
+				Version: version,
+				Platforms: []index.Platform{{
+					URI:      "https://example.com/" + name + ".tar.gz",
+					Sha256:   "deadbeef",
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": runtime.GOOS}},
+				}},
+			},
+		}
+	}
+	plugins := map[string]index.Plugin{
+		"current":     plugin("current", "v1.0.0"),
+		"outdated":    plugin("outdated", "v1.1.0"),
+		"older":       plugin("older", "v0.9.0"),
+		"republished": plugin("republished", "v1.0.0"),
+	}
+	load := func(name string) (index.Plugin, error) {
+		p, ok := plugins[name]
+		if !ok {
+			return index.Plugin{}, os.ErrNotExist
+		}
+		return p, nil
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	// the receipt of the re-published plugin has the checksum of the old archive
+	tmpDir.Write("republished/receipt.yaml", []byte("plugin: republished\nversion: v1.0.0\nsha256: cafebabe\n"))
+	installed := map[string]string{
+		"republished": "v1.0.0",
+		"current":     "v1.0.0",
+		"outdated":    "v1.0.0",
+		"older":       "v1.0.0",
+		"gone":        "v1.0.0",
+	}
+
+	got := outdatedPlugins(tmpDir.Root(), installed, load, runtime.GOOS, runtime.GOARCH)
+	if want := []string{"outdated", "republished"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outdatedPlugins() = %v, want %v", got, want)
+	}
+	if got := outdatedPlugins(tmpDir.Root(), installed, load, "plan9", "amd64"); len(got) != 0 {
+		t.Errorf("outdatedPlugins() for an unsupported platform = %v, want none", got)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"sigs.k8s.io/krew/pkg/installation"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var all *bool
+
+	// verifyCmd represents the verify command
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that installed plugins were not modified",
+		Long: `Verify that the files of installed plugins were not modified.
+
+The installed files of each plugin are compared with the sha256 checksums
+recorded in its installation receipt when it was installed, including the files
+created by its post-install hook. This doesn't need the network or the index.
+
+Examples:
+  To verify some plugins:
+    kubectl krew verify foo bar
+
+  To verify all installed plugins:
+    kubectl krew verify --all
+
+Remarks:
+  Plugins installed by an older krew version have no recorded checksums and are
+  skipped. Reinstall them with "kubectl krew install --force" to record them.
+
+  The command exits with status 1 if verifying any plugin fails.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := args
+			if *all {
+				installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+				if err != nil {
+					return errors.Wrap(err, "failed to find all installed versions")
+				}
+				for name := range installed {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+			}
+
+			verify := func(name string) error {
+				return installation.Verify(installation.VerifyOpts{
+					Plugin:      name,
+					InstallPath: paths.InstallPath(),
+					BinPath:     paths.BinPath(),
+				})
+			}
+			if failed := verifyPlugins(os.Stdout, names, verify); failed > 0 {
+				return &exitCodeError{code: 1, msg: fmt.Sprintf("%d of %d plugins failed verification", failed, len(names))}
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if *all && len(args) > 0 {
+				return errors.New("plugin names can't be specified with --all")
+			}
+			if !*all && len(args) == 0 {
+				return errors.New("specify the plugins to verify or --all")
+			}
+			return nil
+		},
+	}
+
+	all = verifyCmd.Flags().Bool("all", false, "Verify all installed plugins")
+	setArgsCompletion(verifyCmd, completeInstalledPlugins)
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// verifyPlugins verifies the named plugins with verify and prints the result
+// of each one to out. It returns the number of plugins that failed.
+func verifyPlugins(out io.Writer, names []string, verify func(name string) error) int {
+	var failed int
+	for _, name := range names {
+		if rootContext.Err() != nil {
+			fmt.Fprintf(out, "FAIL %s: verification was interrupted\n", name)
+			failed++
+			continue
+		}
+		glog.V(2).Infof("Verifying plugin: %s", name)
+		switch err := verify(name); err {
+		case nil:
+			fmt.Fprintf(out, "PASS %s\n", name)
+		case installation.ErrNoChecksums:
+			fmt.Fprintf(out, "SKIP %s: %v\n", name, err)
+		default:
+			fmt.Fprintf(out, "FAIL %s: %v\n", name, err)
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/installation"
+)
+
+func Test_verifyPlugins(t *testing.T) {
+	tests := []struct {
+		name       string
+		verifyErr  error
+		want       string
+		wantFailed int
+	}{
+		{"pass", nil, "PASS foo\nPASS bar\n", 0},
+		{"no checksums", installation.ErrNoChecksums, "SKIP foo: " + installation.ErrNoChecksums.Error() + "\nSKIP bar: " + installation.ErrNoChecksums.Error() + "\n", 0},
+		{"fail", errors.New("checksum mismatch"), "FAIL foo: checksum mismatch\nFAIL bar: checksum mismatch\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			failed := verifyPlugins(&out, []string{"foo", "bar"}, func(string) error { return tt.verifyErr })
+			if failed != tt.wantFailed {
+				t.Errorf("verifyPlugins() failed = %d, want %d", failed, tt.wantFailed)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("verifyPlugins() printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -34,10 +34,11 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 )
 
-var flManifest string
+var flManifest, flIndex string
 
 func init() {
 	flag.StringVar(&flManifest, "manifest", "", "path to plugin manifest file")
+	flag.StringVar(&flIndex, "index", "", "path to an index directory whose plugin manifests are all checked to be loadable and valid")
 	flag.Set("logtostderr", "true") // Set glog default to stderr
 	// TODO(ahmetb) iterate over glog flags and hide them (not sure if possible without using pflag)
 	flag.Parse()
@@ -46,8 +47,18 @@ func init() {
 func main() {
 	defer glog.Flush()
 
+	if flIndex != "" {
+		if flManifest != "" {
+			glog.Fatal("-manifest and -index can't be specified together")
+		}
+		if err := validateIndex(flIndex); err != nil {
+			glog.Fatalf("%v", err)
+		}
+		return
+	}
+
 	if flManifest == "" {
-		glog.Fatal("-manifest must be specified")
+		glog.Fatal("-manifest or -index must be specified")
 	}
 
 	if err := validateManifestFile(flManifest); err != nil {
@@ -101,6 +112,17 @@ func validateManifestFile(path string) error {
 	return nil
 }
 
+// validateIndex makes sure all plugin manifests in the index directory can be
+// loaded and are valid.
+func validateIndex(dir string) error {
+	list, err := indexscanner.LoadPluginListFromFSStrict(dir)
+	if err != nil {
+		return errors.Wrap(err, "index validation error")
+	}
+	glog.Infof("all %d plugin manifests in the index are valid", len(list.Items))
+	return nil
+}
+
 // isOverlappingPlatformSelectors validates if multiple platforms have selectors
 // that match to a supported <os,arch> pair.
 func isOverlappingPlatformSelectors(platforms []index.Platform) error {
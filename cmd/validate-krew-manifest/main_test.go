@@ -16,6 +16,7 @@
 package main
 
 import (
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -245,3 +246,10 @@ func Test_isOverlappingPlatformSelectors_overlap(t *testing.T) {
 		t.Fatal("expected overlap")
 	}
 }
+
+func Test_validateIndex(t *testing.T) {
+	err := validateIndex(filepath.Join("..", "..", "pkg", "index", "indexscanner", "testdata", "testindex"))
+	if err == nil || !strings.Contains(err.Error(), "badplugin.yaml") {
+		t.Errorf("validateIndex() error = %v, expected it to report badplugin.yaml", err)
+	}
+}
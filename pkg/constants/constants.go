@@ -20,4 +20,8 @@ const (
 
 	// IndexURI points to the upstream index.
 	IndexURI = "https://github.com/kubernetes-sigs/krew-index.git"
+
+	// DefaultIndexName is the name of the upstream index among the configured
+	// plugin indexes.
+	DefaultIndexName = "default"
 )
@@ -0,0 +1,93 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// extractSpaceFactor is how many times its size an archive is assumed to need
+// on the disk when it is extracted.
+const extractSpaceFactor = 3
+
+// DiskSpaceFunc returns the available disk space in bytes of the filesystem of
+// dir.
+type DiskSpaceFunc func(dir string) (int64, error)
+
+// AvailableDiskSpace returns the disk space in bytes available to the user on
+// the filesystem of dir. It returns an error on platforms where it can't be
+// determined.
+func AvailableDiskSpace(dir string) (int64, error) {
+	return availableDiskSpace(dir)
+}
+
+// requiredDiskSpace estimates the disk space needed to extract an archive of
+// the given size, which is at most the extraction size limit.
+func requiredDiskSpace(size int64, limits ExtractLimits) int64 {
+	need := size * extractSpaceFactor
+	if limits.MaxSize > 0 && limits.MaxSize < need {
+		need = limits.MaxSize
+	}
+	return need
+}
+
+// checkDiskSpace returns an error if there's not enough disk space in dir to
+// extract an archive of the given size. The check is skipped if diskSpace is
+// nil or fails.
+func checkDiskSpace(dir string, size int64, limits ExtractLimits, diskSpace DiskSpaceFunc) error {
+	if diskSpace == nil {
+		return nil
+	}
+	available, err := diskSpace(dir)
+	if err != nil {
+		log.V(2).Infof("Skipping the disk space check, failed to get the available disk space of %q: %v", dir, err)
+		return nil
+	}
+	need := requiredDiskSpace(size, limits)
+	log.V(3).Infof("Extracting the %d byte archive needs about %d bytes, %d bytes are available in %q", size, need, available, dir)
+	if available < need {
+		return errors.Errorf("not enough disk space to extract the %s archive in %q: about %s needed, %s available",
+			formatBytes(size), dir, formatBytes(need), formatBytes(available))
+	}
+	return nil
+}
+
+// sizeCheckKey is the context key of the check withSizeCheck adds.
+type sizeCheckKey struct{}
+
+// withSizeCheck returns a copy of ctx that makes the fetchers call check with
+// the size of the file they get before reading it, e.g. to fail a download that
+// there's not enough disk space for early.
+func withSizeCheck(ctx context.Context, check func(size int64) error) context.Context {
+	return context.WithValue(ctx, sizeCheckKey{}, check)
+}
+
+// checkSize calls the check added to ctx with withSizeCheck, if any, with the
+// size of the file about to be read. It is skipped if the size is unknown, e.g.
+// because the server sent no Content-Length.
+func checkSize(ctx context.Context, size int64) error {
+	check, ok := ctx.Value(sizeCheckKey{}).(func(int64) error)
+	if !ok {
+		return nil
+	}
+	if size < 0 {
+		log.V(2).Infof("Skipping the disk space check, the size of the download is unknown")
+		return nil
+	}
+	return check(size)
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_checkDiskSpace(t *testing.T) {
+	space := func(available int64, err error) DiskSpaceFunc {
+		return func(string) (int64, error) { return available, err }
+	}
+	tests := []struct {
+		name      string
+		size      int64
+		limits    ExtractLimits
+		diskSpace DiskSpaceFunc
+		wantErr   bool
+	}{
+		{name: "no check", size: 1 << 20, diskSpace: nil},
+		{name: "unknown space", size: 1 << 20, diskSpace: space(0, errors.New("not supported"))},
+		{name: "enough space", size: 1 << 20, diskSpace: space(3<<20, nil)},
+		{name: "archive fits without the margin", size: 1 << 20, diskSpace: space(2<<20, nil), wantErr: true},
+		{name: "no space", size: 1 << 20, diskSpace: space(0, nil), wantErr: true},
+		{name: "capped by the extraction limit", size: 1 << 20, limits: ExtractLimits{MaxSize: 2 << 20}, diskSpace: space(2<<20, nil)},
+		{name: "disabled extraction limit", size: 1 << 20, limits: ExtractLimits{MaxSize: -1}, diskSpace: space(2<<20, nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDiskSpace("/dst", tt.size, tt.limits, tt.diskSpace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkDiskSpace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "not enough disk space") {
+				t.Errorf("checkDiskSpace() error = %v, expected it to explain the missing disk space", err)
+			}
+		})
+	}
+}
+
+func TestAvailableDiskSpace(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("the available disk space is not determined on", runtime.GOOS)
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	got, err := AvailableDiskSpace(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got <= 0 {
+		t.Errorf("AvailableDiskSpace() = %d, expected some available disk space", got)
+	}
+	if _, err := AvailableDiskSpace(tmpDir.Path("not-found")); err == nil {
+		t.Error("AvailableDiskSpace() of a directory that does not exist expected an error")
+	}
+}
+
+func TestDownloader_Get_lowDiskSpace(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	var checked string
+	lowSpace := func(dir string) (int64, error) {
+		checked = dir
+		return 10, nil
+	}
+	d := NewDownloader(NewInsecureVerifier(), NewFileFetcher(filepath.Join(testdataPath(), "test-with-directory.zip"))).
+		WithDiskSpaceCheck(lowSpace)
+	err := d.Get("foo/bar/test-with-directory.zip", tmpDir.Root())
+	if err == nil || !strings.Contains(err.Error(), "not enough disk space") {
+		t.Fatalf("Downloader.Get() error = %v, expected not enough disk space", err)
+	}
+	if checked != tmpDir.Root() {
+		t.Errorf("checked the disk space of %q, want the destination %q", checked, tmpDir.Root())
+	}
+	files, err := ioutil.ReadDir(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected nothing to be extracted, got %d files", len(files))
+	}
+}
@@ -19,52 +19,133 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
 )
 
 // download gets a file from the internet in memory and writes it content
 // to a Verifier.
-func download(url string, verifier Verifier, fetcher Fetcher) (io.ReaderAt, int64, error) {
-	glog.V(2).Infof("Fetching %q", url)
-	body, err := fetcher.Get(url)
+func download(ctx context.Context, url string, verifier Verifier, fetcher Fetcher) (io.ReaderAt, int64, error) {
+	log.V(2).Infof("Fetching %q", url)
+	body, err := fetcher.Get(ctx, url)
 	if err != nil {
 		return nil, 0, errors.Wrapf(err, "could not download %q", url)
 	}
 	defer body.Close()
 
-	glog.V(3).Infof("Reading download data into memory")
+	log.V(3).Infof("Reading download data into memory")
 	data, err := ioutil.ReadAll(io.TeeReader(body, verifier))
 	if err != nil {
 		return nil, 0, errors.Wrap(err, "could not read download content")
 	}
-	glog.V(2).Infof("Read %d bytes of download data into memory", len(data))
+	log.V(2).Infof("Read %d bytes of download data into memory", len(data))
 
 	return bytes.NewReader(data), int64(len(data)), verifier.Verify()
 }
 
-// extractZIP extracts a zip file into the target directory.
-func extractZIP(targetDir string, read io.ReaderAt, size int64) error {
-	glog.V(4).Infof("Extracting download zip to %q", targetDir)
+// ExtractLimits caps what extracting an archive may write, so that a malicious
+// archive can't fill the disk. A zero or negative field disables its limit.
+type ExtractLimits struct {
+	// MaxSize is the maximum total size in bytes of the extracted files.
+	MaxSize int64
+	// MaxFiles is the maximum number of files and directories in the archive.
+	MaxFiles int
+}
+
+// DefaultExtractLimits are generous limits for plugin archives.
+var DefaultExtractLimits = ExtractLimits{
+	MaxSize:  512 << 20,
+	MaxFiles: 10000,
+}
+
+// extractLimiter enforces ExtractLimits during the extraction of an archive.
+type extractLimiter struct {
+	limits  ExtractLimits
+	written int64
+	files   int
+}
+
+// addEntry counts an extracted file or directory.
+func (l *extractLimiter) addEntry(name string) error {
+	l.files++
+	if l.limits.MaxFiles > 0 && l.files > l.limits.MaxFiles {
+		return errors.Errorf("the archive has more than %d files, at %q", l.limits.MaxFiles, name)
+	}
+	return nil
+}
+
+// copy copies src to dst, failing once the total size of the extracted files
+// exceeds the limit.
+func (l *extractLimiter) copy(dst io.Writer, src io.Reader, name string) error {
+	if l.limits.MaxSize <= 0 {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	n, err := io.CopyN(dst, src, l.limits.MaxSize-l.written+1)
+	l.written += n
+	if l.written > l.limits.MaxSize {
+		return errors.Errorf("the archive extracts to more than %d bytes, at %q", l.limits.MaxSize, name)
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// stripPath removes the first n components of the slash separated archive
+// entry name, like tar's --strip-components. It returns false if the entry has
+// no more than n components and should not be extracted.
+func stripPath(name string, n int) (string, bool) {
+	if n <= 0 {
+		return name, true
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	parts := strings.Split(name, "/")
+	if len(parts) <= n {
+		return "", false
+	}
+	return strings.Join(parts[n:], "/"), true
+}
+
+// extractZIP extracts a zip file into the target directory, removing the first
+// strip components of the file names.
+func extractZIP(targetDir string, read io.ReaderAt, size int64, strip int, limiter *extractLimiter) error {
+	log.V(4).Infof("Extracting download zip to %q", targetDir)
 	zipReader, err := zip.NewReader(read, size)
 	if err != nil {
 		return err
 	}
 
 	for _, f := range zipReader.File {
-		path := filepath.Join(targetDir, filepath.FromSlash(f.Name))
+		if err := limiter.addEntry(f.Name); err != nil {
+			return err
+		}
+		name, ok := stripPath(f.Name, strip)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(targetDir, filepath.FromSlash(name))
 		if f.FileInfo().IsDir() {
-			os.MkdirAll(path, f.Mode())
+			if err := os.MkdirAll(path, f.Mode()); err != nil {
+				return errors.Wrap(err, "failed to create directory from zip")
+			}
 			continue
 		}
 
+		// archives may not have entries for the parent directories of files
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return errors.Wrap(err, "failed to create directory for zip")
+		}
+
 		src, err := f.Open()
 		if err != nil {
 			return errors.Wrap(err, "could not open inflating zip file")
@@ -72,25 +153,26 @@ func extractZIP(targetDir string, read io.ReaderAt, size int64) error {
 
 		dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode())
 		if err != nil {
+			src.Close()
 			return errors.Wrap(err, "can't create file in zip destination dir")
 		}
 
-		if _, err := io.Copy(dst, src); err != nil {
-			return errors.Wrap(err, "can't copy content to zip destination file")
-		}
+		err = limiter.copy(dst, src, f.Name)
 
 		// Cleanup the open fd. Don't use defer in case of many files.
 		// Don't be blocking
 		src.Close()
 		dst.Close()
+		if err != nil {
+			return errors.Wrap(err, "can't copy content to zip destination file")
+		}
 	}
 
 	return nil
 }
 
 // extractTARGZ extracts a gzipped tar file into the target directory.
-func extractTARGZ(targetDir string, at io.ReaderAt, size int64) error {
-	glog.V(4).Infof("tar: extracting to %q", targetDir)
+func extractTARGZ(targetDir string, at io.ReaderAt, size int64, strip int, limiter *extractLimiter) error {
 	in := io.NewSectionReader(at, 0, size)
 
 	gzr, err := gzip.NewReader(in)
@@ -99,7 +181,19 @@ func extractTARGZ(targetDir string, at io.ReaderAt, size int64) error {
 	}
 	defer gzr.Close()
 
-	tr := tar.NewReader(gzr)
+	return untar(targetDir, gzr, strip, limiter)
+}
+
+// extractTAR extracts a tar file into the target directory.
+func extractTAR(targetDir string, at io.ReaderAt, size int64, strip int, limiter *extractLimiter) error {
+	return untar(targetDir, io.NewSectionReader(at, 0, size), strip, limiter)
+}
+
+// untar extracts the tar stream in r into the target directory, removing the
+// first strip components of the file names.
+func untar(targetDir string, r io.Reader, strip int, limiter *extractLimiter) error {
+	log.V(4).Infof("tar: extracting to %q", targetDir)
+	tr := tar.NewReader(r)
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -108,14 +202,22 @@ func extractTARGZ(targetDir string, at io.ReaderAt, size int64) error {
 		if err != nil {
 			return errors.Wrap(err, "tar extraction error")
 		}
-		glog.V(4).Infof("tar: processing %q (type=%d, mode=%s)", hdr.Name, hdr.Typeflag, os.FileMode(hdr.Mode))
+		log.V(4).Infof("tar: processing %q (type=%d, mode=%s)", hdr.Name, hdr.Typeflag, os.FileMode(hdr.Mode))
 		// see https://golang.org/cl/78355 for handling pax_global_header
 		if hdr.Name == "pax_global_header" {
-			glog.V(4).Infof("tar: skipping pax_global_header file")
+			log.V(4).Infof("tar: skipping pax_global_header file")
+			continue
+		}
+		if err := limiter.addEntry(hdr.Name); err != nil {
+			return err
+		}
+		name, ok := stripPath(hdr.Name, strip)
+		if !ok {
+			log.V(4).Infof("tar: skipping %q, it has no more than %d path components", hdr.Name, strip)
 			continue
 		}
 
-		path := filepath.Join(targetDir, filepath.FromSlash(hdr.Name))
+		path := filepath.Join(targetDir, filepath.FromSlash(name))
 		switch hdr.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(path, os.FileMode(hdr.Mode)); err != nil {
@@ -123,7 +225,7 @@ func extractTARGZ(targetDir string, at io.ReaderAt, size int64) error {
 			}
 		case tar.TypeReg:
 			dir := filepath.Dir(path)
-			glog.V(4).Infof("tar: ensuring parent dirs exist for regular file, dir=%s", dir)
+			log.V(4).Infof("tar: ensuring parent dirs exist for regular file, dir=%s", dir)
 			if err := os.MkdirAll(dir, 0755); err != nil {
 				return errors.Wrap(err, "failed to create directory for tar")
 			}
@@ -131,15 +233,17 @@ func extractTARGZ(targetDir string, at io.ReaderAt, size int64) error {
 			if err != nil {
 				return errors.Wrapf(err, "failed to create file %q", path)
 			}
-			if _, err := io.Copy(f, tr); err != nil {
+			err = limiter.copy(f, tr, hdr.Name)
+			f.Close()
+			if err != nil {
 				return errors.Wrapf(err, "failed to copy %q from tar into file", hdr.Name)
 			}
 		default:
 			return errors.Errorf("unable to handle file type %d for %q in tar", hdr.Typeflag, hdr.Name)
 		}
-		glog.V(4).Infof("tar: processed %q", hdr.Name)
+		log.V(4).Infof("tar: processed %q", hdr.Name)
 	}
-	glog.V(4).Infof("tar extraction to %s complete", targetDir)
+	log.V(4).Infof("tar extraction to %s complete", targetDir)
 	return nil
 }
 
@@ -150,7 +254,13 @@ func detectMIMEType(at io.ReaderAt) (string, error) {
 		return "", errors.Wrap(err, "failed to read first 512 bytes")
 	}
 	if n < 512 {
-		glog.V(5).Infof("Did only read %d of 512 bytes to determine the file type", n)
+		log.V(5).Infof("Did only read %d of 512 bytes to determine the file type", n)
+	}
+
+	// tar files are not detected by http.DetectContentType, they have the
+	// "ustar" magic at offset 257 of the first header.
+	if n >= tarMagicOffset+len(tarMagic) && string(buf[tarMagicOffset:tarMagicOffset+len(tarMagic)]) == tarMagic {
+		return "application/x-tar", nil
 	}
 
 	// Cut off mime extra info beginning with ';' i.e:
@@ -158,14 +268,20 @@ func detectMIMEType(at io.ReaderAt) (string, error) {
 	return strings.Split(http.DetectContentType(buf[:n]), ";")[0], nil
 }
 
-type extractor func(targetDir string, read io.ReaderAt, size int64) error
+const (
+	tarMagic       = "ustar"
+	tarMagicOffset = 257
+)
+
+type extractor func(targetDir string, read io.ReaderAt, size int64, strip int, limiter *extractLimiter) error
 
 var defaultExtractors = map[string]extractor{
 	"application/zip":    extractZIP,
 	"application/x-gzip": extractTARGZ,
+	"application/x-tar":  extractTAR,
 }
 
-func extractArchive(dst string, at io.ReaderAt, size int64) error {
+func extractArchive(dst string, at io.ReaderAt, size int64, strip int, limits ExtractLimits) error {
 	// TODO(ahmetb) This package is not architected well, this method should not
 	// be receiving this many args. Primary problem is at GetInsecure and
 	// GetWithSha256 methods that embed extraction in them, which is orthogonal.
@@ -174,19 +290,25 @@ func extractArchive(dst string, at io.ReaderAt, size int64) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to determine content type")
 	}
-	glog.V(4).Infof("detected %q file type", t)
+	log.V(4).Infof("detected %q file type", t)
 	exf, ok := defaultExtractors[t]
 	if !ok {
 		return errors.Errorf("mime type %q for downloaded file is not a supported archive format", t)
 	}
-	return errors.Wrap(exf(dst, at, size), "failed to extract file")
+	return errors.Wrap(exf(dst, at, size, strip, &extractLimiter{limits: limits}), "failed to extract file")
 
 }
 
 // Downloader is responsible for fetching, verifying and extracting a binary.
 type Downloader struct {
-	verifier Verifier
-	fetcher  Fetcher
+	verifier  Verifier
+	fetcher   Fetcher
+	limits    ExtractLimits
+	strip     int
+	diskSpace DiskSpaceFunc
+
+	signatureURI      string
+	signatureVerifier SignatureVerifier
 }
 
 // NewDownloader builds a new Downloader.
@@ -197,12 +319,86 @@ func NewDownloader(v Verifier, f Fetcher) Downloader {
 	}
 }
 
+// WithExtractLimits returns a copy of the Downloader that enforces the limits
+// when extracting archives. NewDownloader returns a Downloader without limits.
+func (d Downloader) WithExtractLimits(limits ExtractLimits) Downloader {
+	d.limits = limits
+	return d
+}
+
+// WithStripComponents returns a copy of the Downloader that removes the first n
+// path components of the archive entries when extracting them, entries with no
+// more than n components are skipped.
+func (d Downloader) WithStripComponents(n int) Downloader {
+	d.strip = n
+	return d
+}
+
+// WithDiskSpaceCheck returns a copy of the Downloader that checks with
+// diskSpace that the destination has enough disk space for the download once
+// its size is known from the response, before reading it. Downloads of unknown
+// size are not checked. NewDownloader returns a Downloader without the check.
+func (d Downloader) WithDiskSpaceCheck(diskSpace DiskSpaceFunc) Downloader {
+	d.diskSpace = diskSpace
+	return d
+}
+
+// WithSignature returns a copy of the Downloader that verifies the download
+// against the detached signature at signatureURI with v before extracting it.
+// If signatureURI is empty, there is no signature to verify. If v is nil, the
+// signature is not verified and a warning is logged.
+func (d Downloader) WithSignature(signatureURI string, v SignatureVerifier) Downloader {
+	d.signatureURI = signatureURI
+	d.signatureVerifier = v
+	return d
+}
+
 // Get pulls the uri and verifies it. On success, the download gets extracted
 // into dst.
 func (d Downloader) Get(uri, dst string) error {
-	body, size, err := download(uri, d.verifier, d.fetcher)
+	return d.GetContext(context.Background(), uri, dst)
+}
+
+// GetContext is like Get, but aborts the download and the extraction once ctx
+// is canceled. Files extracted until then are left in dst.
+func (d Downloader) GetContext(ctx context.Context, uri, dst string) error {
+	fetchCtx := ctx
+	if d.diskSpace != nil {
+		// checked before the download is read, as it is kept in memory
+		fetchCtx = withSizeCheck(ctx, func(size int64) error {
+			return checkDiskSpace(dst, size, d.limits, d.diskSpace)
+		})
+	}
+	body, size, err := download(fetchCtx, uri, d.verifier, d.fetcher)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get the uri %q", uri)
 	}
-	return extractArchive(dst, body, size)
+	if d.signatureURI != "" {
+		if d.signatureVerifier == nil {
+			log.Warningf("The signature of %q is not verified, no trusted key is configured", uri)
+		} else {
+			data := make([]byte, size)
+			if _, err := body.ReadAt(data, 0); err != nil && err != io.EOF {
+				return errors.Wrap(err, "could not read download content")
+			}
+			if err := verifySignature(ctx, data, d.signatureURI, d.fetcher, d.signatureVerifier); err != nil {
+				return errors.Wrapf(err, "failed to verify the signature of %q", uri)
+			}
+		}
+	}
+	return extractArchive(dst, contextReaderAt{ctx: ctx, r: body}, size, d.strip, d.limits)
+}
+
+// contextReaderAt fails reads once ctx is canceled, which stops extractors
+// reading from it.
+type contextReaderAt struct {
+	ctx context.Context
+	r   io.ReaderAt
+}
+
+func (c contextReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.ReadAt(p, off)
 }
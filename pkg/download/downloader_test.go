@@ -15,7 +15,13 @@
 package download
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"io/ioutil"
 	"os"
@@ -68,7 +74,7 @@ func Test_extractZIP(t *testing.T) {
 		}
 		defer zipReader.Close()
 		stat, _ := zipReader.Stat()
-		if err := extractZIP(tmpDir.Root(), zipReader, stat.Size()); err != nil {
+		if err := extractZIP(tmpDir.Root(), zipReader, stat.Size(), 0, &extractLimiter{}); err != nil {
 			t.Fatalf("extractZIP(%s) error = %v", tt.in, err)
 		}
 
@@ -119,7 +125,7 @@ func Test_extractTARGZ(t *testing.T) {
 			t.Fatal(err)
 			return
 		}
-		if err := extractTARGZ(tmpDir.Root(), tf, st.Size()); err != nil {
+		if err := extractTARGZ(tmpDir.Root(), tf, st.Size(), 0, &extractLimiter{}); err != nil {
 			t.Fatalf("failed to extract %q. error=%v", tt.in, err)
 		}
 
@@ -193,6 +199,113 @@ func TestDownloader_Get(t *testing.T) {
 	}
 }
 
+type bytesFetcher struct{ data []byte }
+
+func (f bytesFetcher) Get(_ context.Context, _ string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+// tarGzArchive creates a tar.gz archive in memory containing the given files.
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	if _, err := gzw.Write(tarArchive(t, files)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// tarArchive creates a tar archive in memory containing the given files.
+func tarArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipArchive creates a zip archive in memory containing the given files,
+// without entries for their directories.
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloader_Get_verifiesChecksum(t *testing.T) {
+	archive := tarGzArchive(t, map[string]string{"foo": "hello"})
+	sum := sha256.Sum256(archive)
+	correct := hex.EncodeToString(sum[:])
+	tampered := strings.Repeat("0", len(correct))
+
+	tests := []struct {
+		name      string
+		sha256    string
+		wantErr   bool
+		wantFiles []string
+	}{
+		{
+			name:      "correct checksum",
+			sha256:    correct,
+			wantErr:   false,
+			wantFiles: []string{"/foo"},
+		},
+		{
+			name:      "tampered checksum",
+			sha256:    tampered,
+			wantErr:   true,
+			wantFiles: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			d := NewDownloader(NewSha256Verifier(tt.sha256), bytesFetcher{archive})
+			err := d.Get("https://example.com/foo.tar.gz", tmpDir.Root())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Downloader.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), correct) {
+				t.Errorf("Downloader.Get() error %q does not report the actual checksum %s", err, correct)
+			}
+			if got := collectFiles(t, tmpDir.Root()); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("extracted files = %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
 func Test_download(t *testing.T) {
 	filePath := filepath.Join(testdataPath(), "test-with-directory.zip")
 	downloadOriginal, err := ioutil.ReadFile(filePath)
@@ -237,7 +350,7 @@ func Test_download(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			reader, size, err := download(tt.args.url, tt.args.verifier, tt.args.fetcher)
+			reader, size, err := download(context.Background(), tt.args.url, tt.args.verifier, tt.args.fetcher)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("download() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -300,6 +413,14 @@ func Test_detectMIMEType(t *testing.T) {
 			want:    "application/x-gzip",
 			wantErr: false,
 		},
+		{
+			name: "type tar",
+			args: args{
+				content: tarArchive(t, map[string]string{"foo": "bar"}),
+			},
+			want:    "application/x-tar",
+			wantErr: false,
+		},
 		{
 			name: "type bash-utf8",
 			args: args{
@@ -386,14 +507,146 @@ func Test_detectMIMEType(t *testing.T) {
 	}
 }
 
+func Test_extractArchive_formats(t *testing.T) {
+	files := map[string]string{
+		"foo-1.0/bin/kubectl-foo": "#!/bin/sh",
+		"foo-1.0/README":          "readme",
+		"LICENSE":                 "license",
+	}
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"tar.gz", tarGzArchive(t, files)},
+		{"tar", tarArchive(t, files)},
+		{"zip", zipArchive(t, files)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			if err := extractArchive(tmpDir.Root(), bytes.NewReader(tt.archive), int64(len(tt.archive)), 0, ExtractLimits{}); err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			for name, content := range files {
+				b, err := ioutil.ReadFile(tmpDir.Path(name))
+				if err != nil {
+					t.Errorf("file %s not extracted: %v", name, err)
+					continue
+				}
+				if string(b) != content {
+					t.Errorf("file %s has content %q, want %q", name, b, content)
+				}
+			}
+		})
+	}
+}
+
+func Test_extractArchive_stripComponents(t *testing.T) {
+	files := map[string]string{
+		"foo-1.0/bin/kubectl-foo": "#!/bin/sh",
+		"foo-1.0/README":          "readme",
+		"LICENSE":                 "license",
+	}
+	want := []string{"/README", "/bin/", "/bin/kubectl-foo"}
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"tar.gz", tarGzArchive(t, files)},
+		{"tar", tarArchive(t, files)},
+		{"zip", zipArchive(t, files)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			if err := extractArchive(tmpDir.Root(), bytes.NewReader(tt.archive), int64(len(tt.archive)), 1, ExtractLimits{}); err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			if got := collectFiles(t, tmpDir.Root()); !reflect.DeepEqual(got, want) {
+				t.Fatalf("extractArchive() with strip=1 extracted %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_stripPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		strip  int
+		want   string
+		wantOK bool
+	}{
+		{"foo-1.0/bin/kubectl-foo", 0, "foo-1.0/bin/kubectl-foo", true},
+		{"foo-1.0/bin/kubectl-foo", 1, "bin/kubectl-foo", true},
+		{"foo-1.0/bin/kubectl-foo", 2, "kubectl-foo", true},
+		{"foo-1.0/bin/kubectl-foo", 3, "", false},
+		{"./foo-1.0/README", 1, "README", true},
+		{"foo-1.0/", 1, "", false},
+		{"LICENSE", 1, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := stripPath(tt.name, tt.strip)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("stripPath(%q, %d) = (%q, %v), want (%q, %v)", tt.name, tt.strip, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func Test_extractArchive_limits(t *testing.T) {
+	files := map[string]string{
+		"kubectl-foo": strings.Repeat("x", 100),
+		"README":      strings.Repeat("y", 100),
+		"LICENSE":     "license",
+	}
+	tests := []struct {
+		name    string
+		limits  ExtractLimits
+		wantErr string
+	}{
+		{name: "within limits", limits: ExtractLimits{MaxSize: 207, MaxFiles: 3}},
+		{name: "too large", limits: ExtractLimits{MaxSize: 150}, wantErr: "more than 150 bytes"},
+		{name: "too many files", limits: ExtractLimits{MaxFiles: 2}, wantErr: "more than 2 files"},
+	}
+	for _, tt := range tests {
+		for format, archive := range map[string][]byte{
+			"tar.gz": tarGzArchive(t, files),
+			"zip":    zipArchive(t, files),
+		} {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				tmpDir, cleanup := testutil.NewTempDir(t)
+				defer cleanup()
+
+				err := extractArchive(tmpDir.Root(), bytes.NewReader(archive), int64(len(archive)), 0, tt.limits)
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Fatalf("extractArchive() error = %v", err)
+					}
+					return
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("extractArchive() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
 func Test_extractArchive(t *testing.T) {
 	oldextractors := defaultExtractors
 	defer func() {
 		defaultExtractors = oldextractors
 	}()
 	defaultExtractors = map[string]extractor{
-		"application/octet-stream": func(targetDir string, read io.ReaderAt, size int64) error { return nil },
-		"text/plain":               func(targetDir string, read io.ReaderAt, size int64) error { return errors.New("fail test") },
+		"application/octet-stream": func(targetDir string, read io.ReaderAt, size int64, strip int, limiter *extractLimiter) error {
+			return nil
+		},
+		"text/plain": func(targetDir string, read io.ReaderAt, size int64, strip int, limiter *extractLimiter) error {
+			return errors.New("fail test")
+		},
 	}
 	type args struct {
 		filename string
@@ -437,7 +690,7 @@ func Test_extractArchive(t *testing.T) {
 				return
 			}
 
-			if err := extractArchive(tt.args.dst, fd, st.Size()); (err != nil) != tt.wantErr {
+			if err := extractArchive(tt.args.dst, fd, st.Size(), 0, ExtractLimits{}); (err != nil) != tt.wantErr {
 				t.Errorf("extractArchive() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
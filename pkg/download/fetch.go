@@ -15,39 +15,447 @@
 package download
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
 )
 
 // Fetcher is used to get files from a URI.
 type Fetcher interface {
-	// Get gets the file and returns an stream to read the file.
-	Get(uri string) (io.ReadCloser, error)
+	// Get gets the file and returns an stream to read the file. Reading the
+	// stream fails once ctx is canceled.
+	Get(ctx context.Context, uri string) (io.ReadCloser, error)
 }
 
 var _ Fetcher = HTTPFetcher{}
 
 // HTTPFetcher is used to get a file from a http:// or https:// schema path.
-type HTTPFetcher struct{}
+type HTTPFetcher struct {
+	// Client is the client used for the requests, http.DefaultClient is used
+	// if it is nil.
+	Client *http.Client
+	// Progress, if set, receives the progress of the downloads.
+	Progress io.Writer
+}
 
-// Get gets the file and returns an stream to read the file.
-func (HTTPFetcher) Get(uri string) (io.ReadCloser, error) {
-	resp, err := http.Get(uri)
+// Get gets the file and returns an stream to read the file. It returns an
+// error if the server does not respond with a 2xx status, or if the size check
+// of ctx fails for the Content-Length of the response.
+func (f HTTPFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %q", uri)
+	}
+	req = req.WithContext(ctx)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, httpStatusError{uri: uri, status: resp.Status, code: resp.StatusCode}
+	}
+	if err := checkSize(ctx, resp.ContentLength); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if f.Progress != nil {
+		return struct {
+			io.Reader
+			io.Closer
+		}{NewProgressReader(resp.Body, resp.ContentLength, f.Progress), resp.Body}, nil
+	}
 	return resp.Body, nil
 }
 
+// getFrom gets the file starting at offset with a range request. It returns
+// false if the server sent the whole file instead, e.g. because it doesn't
+// support range requests.
+func (f HTTPFetcher) getFrom(ctx context.Context, uri string, offset int64) (io.ReadCloser, bool, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to create request for %q", uri)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		log.V(2).Infof("Resuming the download of %q at byte %d", uri, offset)
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299 && resp.StatusCode != http.StatusPartialContent:
+		log.V(2).Infof("Server doesn't support resuming the download of %q, downloading it again", uri)
+		offset = 0
+	default:
+		// e.g. 416 Range Not Satisfiable, start over
+		resp.Body.Close()
+		body, err := f.Get(ctx, uri)
+		return body, false, err
+	}
+	size := resp.ContentLength
+	if size >= 0 {
+		size += offset
+	}
+	if err := checkSize(ctx, size); err != nil {
+		resp.Body.Close()
+		return nil, false, err
+	}
+	if f.Progress != nil {
+		return struct {
+			io.Reader
+			io.Closer
+		}{NewProgressReader(resp.Body, resp.ContentLength, f.Progress), resp.Body}, offset > 0, nil
+	}
+	return resp.Body, offset > 0, nil
+}
+
+// httpStatusError is returned by HTTPFetcher for unsuccessful responses.
+type httpStatusError struct {
+	uri    string
+	status string
+	code   int
+}
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected response status %s", e.uri, e.status)
+}
+
+var _ Fetcher = retryingFetcher{}
+
+// retryingFetcher retries fetching a file with exponential backoff if it
+// fails with a network error or a 5xx response.
+type retryingFetcher struct {
+	f       Fetcher
+	retries int
+	backoff time.Duration
+	sleep   func(time.Duration)
+	// resume, if set, continues a download that failed while reading the
+	// response where it stopped if f supports range requests.
+	resume bool
+	// partialDir, if set, keeps the data of resumable downloads that failed
+	// in a file per URI, which the next download of the URI continues.
+	partialDir string
+}
+
+// resumingFetcher is implemented by Fetchers that can get a file starting at
+// an offset.
+type resumingFetcher interface {
+	getFrom(ctx context.Context, uri string, offset int64) (io.ReadCloser, bool, error)
+}
+
+// NewRetryingFetcher returns a Fetcher that retries f up to retries times if
+// fetching a file fails with a network error or a 5xx response, waiting
+// exponentially longer between the attempts. file:// URIs are not retried.
+func NewRetryingFetcher(f Fetcher, retries int) Fetcher {
+	return retryingFetcher{f: f, retries: retries, backoff: time.Second, sleep: time.Sleep}
+}
+
+// Get gets the file and reads it into memory, so that errors while reading
+// the response are retried as well. It stops retrying once ctx is canceled.
+func (r retryingFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	retries := r.retries
+	if strings.HasPrefix(uri, "file://") {
+		retries = 0
+	}
+	backoff := r.backoff
+	partialFile := r.partialFile(uri)
+	var data []byte
+	if partialFile != "" {
+		if b, err := ioutil.ReadFile(partialFile); err == nil && len(b) > 0 {
+			log.V(1).Infof("Resuming the earlier download of %q after %d bytes", uri, len(b))
+			data = b
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		var err error
+		data, err = r.get(ctx, uri, data)
+		if err == nil {
+			if partialFile != "" {
+				os.Remove(partialFile)
+			}
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+		if ctx.Err() != nil {
+			return nil, errors.Wrapf(ctx.Err(), "downloading %q was interrupted", uri)
+		}
+		if attempt > retries || !isRetryable(err) {
+			if attempt > 1 {
+				return nil, errors.Wrapf(err, "giving up after %d attempts", attempt)
+			}
+			return nil, err
+		}
+		log.Warningf("Downloading %q failed (attempt %d of %d), retrying in %v: %v", uri, attempt, retries+1, backoff, err)
+		r.sleep(backoff)
+		if ctx.Err() != nil {
+			return nil, errors.Wrapf(ctx.Err(), "downloading %q was interrupted", uri)
+		}
+		backoff *= 2
+	}
+}
+
+// get reads the file into memory. If resuming is enabled, the partial data
+// read by a failed attempt is passed in and completed, and the data read until
+// an error is returned. The data is also written to the partial file of the
+// URI, if there is one, so that a later download can continue it.
+func (r retryingFetcher) get(ctx context.Context, uri string, partial []byte) ([]byte, error) {
+	rf, canResume := r.f.(resumingFetcher)
+	var body io.ReadCloser
+	if !r.resume || !canResume || len(partial) == 0 {
+		var err error
+		if body, err = r.f.Get(ctx, uri); err != nil {
+			return nil, err
+		}
+		partial = nil
+	} else {
+		var resumed bool
+		var err error
+		if body, resumed, err = rf.getFrom(ctx, uri, int64(len(partial))); err != nil {
+			return partial, err
+		}
+		if !resumed {
+			partial = nil
+		}
+	}
+	defer body.Close()
+
+	buf := bytes.NewBuffer(partial)
+	var w io.Writer = buf
+	if f, err := openPartialFile(r.partialFile(uri), int64(len(partial))); err != nil {
+		log.V(2).Infof("Failed to keep the partial download of %q: %v", uri, err)
+	} else if f != nil {
+		defer f.Close()
+		w = io.MultiWriter(buf, f)
+	}
+	_, err := io.Copy(w, body)
+	if err != nil && !r.resume {
+		return nil, errors.Wrap(err, "could not read download content")
+	}
+	return buf.Bytes(), errors.Wrap(err, "could not read download content")
+}
+
+// partialFile returns the file keeping the partial download of uri, or "" if
+// partial downloads are not kept.
+func (r retryingFetcher) partialFile(uri string) string {
+	if !r.resume || r.partialDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(r.partialDir, hex.EncodeToString(sum[:])+".partial")
+}
+
+// openPartialFile opens the partial download file to append to the first size
+// bytes already in it. It returns nil if path is empty.
+func openPartialFile(path string, size int64) (*os.File, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// isRetryable returns true for network errors and 5xx responses.
+func isRetryable(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case httpStatusError:
+		return e.code >= 500
+	case net.Error:
+		return true
+	}
+	return errors.Cause(err) == io.ErrUnexpectedEOF
+}
+
+var _ Fetcher = uriFetcher{}
+
+// uriFetcher gets files from http(s):// URIs with an HTTP fetcher. It rejects
+// file:// URIs and absolute paths with ErrLocalURINotAllowed.
+type uriFetcher struct{ http Fetcher }
+
+// ErrLocalURINotAllowed is returned for file:// URIs and absolute paths by the
+// fetchers of NewURIFetcher and NewResumingURIFetcher, unless they are wrapped
+// with AllowLocalURIs.
+var ErrLocalURINotAllowed = errors.New("reading local files is not allowed")
+
+// NewURIFetcher returns a Fetcher that downloads http:// and https:// URIs
+// with client (http.DefaultClient if nil), retrying up to retries times. Local
+// files are only read if it is wrapped with AllowLocalURIs. If progress is not
+// nil, the progress of the downloads is reported to it.
+func NewURIFetcher(client *http.Client, retries int, progress io.Writer) Fetcher {
+	return uriFetcher{http: NewRetryingFetcher(HTTPFetcher{Client: client, Progress: progress}, retries)}
+}
+
+// NewResumingURIFetcher is like NewURIFetcher, but a retried download
+// continues where the failed attempt stopped if the server supports range
+// requests. Otherwise, the file is downloaded again from the start. If
+// partialDir is not empty, the data of a download that failed is kept in it,
+// so that a later download of the same URI continues it, and removed once the
+// download completes.
+func NewResumingURIFetcher(client *http.Client, retries int, progress io.Writer, partialDir string) Fetcher {
+	return uriFetcher{http: retryingFetcher{
+		f:          HTTPFetcher{Client: client, Progress: progress},
+		retries:    retries,
+		backoff:    time.Second,
+		sleep:      time.Sleep,
+		resume:     true,
+		partialDir: partialDir,
+	}}
+}
+
+// NewHTTPClient returns an HTTP client that uses the proxies configured with
+// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables. If caCertFile
+// is not empty, the PEM encoded certificates in it are trusted in addition to
+// the system's certificate authorities.
+func NewHTTPClient(caCertFile string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if caCertFile != "" {
+		pem, err := ioutil.ReadFile(caCertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the CA certificate file")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			log.V(2).Infof("Failed to load the system certificate pool, only trusting %q: %v", caCertFile, err)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no PEM encoded certificates found in %q", caCertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func (f uriFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	_, isLocal, err := localPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	if isLocal {
+		return nil, errors.Wrapf(ErrLocalURINotAllowed, "uri %q", uri)
+	}
+	return f.http.Get(ctx, uri)
+}
+
+var _ Fetcher = localURIFetcher{}
+
+// localURIFetcher reads file:// URIs and absolute paths from the local
+// filesystem and gets the other URIs with next.
+type localURIFetcher struct{ next Fetcher }
+
+// AllowLocalURIs returns a Fetcher that reads file:// URIs and absolute paths
+// from the local filesystem, e.g. for offline mirrors, and gets the other URIs
+// with f. Relative paths are rejected. As a manifest could refer to any file
+// readable by the user, this should only be used if the user opted in.
+func AllowLocalURIs(f Fetcher) Fetcher { return localURIFetcher{next: f} }
+
+func (f localURIFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	path, isLocal, err := localPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	if !isLocal {
+		return f.next.Get(ctx, uri)
+	}
+	log.V(2).Infof("Reading local file %q", path)
+	return openFile(ctx, path)
+}
+
+// openFile opens the local file at path after checking its size with the size
+// check of ctx.
+func openFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := file.Stat(); err == nil {
+		if err := checkSize(ctx, fi.Size()); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// localPath returns the path of the file a file:// URI or an absolute path
+// refers to, and false for http:// and https:// URIs.
+func localPath(uri string) (string, bool, error) {
+	if filepath.IsAbs(uri) {
+		return filepath.Clean(uri), true, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to parse uri %q", uri)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return "", false, nil
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return "", false, errors.Errorf("file uri %q must not have a host", uri)
+		}
+		path := filepath.FromSlash(u.Path)
+		if runtime.GOOS == "windows" {
+			// file:///C:/foo has the path /C:/foo
+			path = strings.TrimPrefix(path, `\`)
+		}
+		if !filepath.IsAbs(path) {
+			return "", false, errors.Errorf("file uri %q must have an absolute path", uri)
+		}
+		return filepath.Clean(path), true, nil
+	case "":
+		return "", false, errors.Errorf("relative path %q is not allowed, use an absolute path or a file:// uri", uri)
+	}
+	return "", false, errors.Errorf("uri %q has an unsupported scheme %q", uri, u.Scheme)
+}
+
 var _ Fetcher = fileFetcher{}
 
 type fileFetcher struct{ f string }
 
-func (f fileFetcher) Get(_ string) (io.ReadCloser, error) {
-	return os.Open(f.f)
+func (f fileFetcher) Get(ctx context.Context, _ string) (io.ReadCloser, error) {
+	return openFile(ctx, f.f)
 }
 
 // NewFileFetcher returns a local file reader.
@@ -57,6 +465,6 @@ var _ Fetcher = errorFetcher{}
 
 type errorFetcher struct{}
 
-func (f errorFetcher) Get(_ string) (io.ReadCloser, error) {
+func (f errorFetcher) Get(_ context.Context, _ string) (io.ReadCloser, error) {
 	return nil, errors.New("test fail")
 }
@@ -0,0 +1,433 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func TestRetryingFetcher_Get(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retries      int
+		wantErr      string
+		wantAttempts int
+		wantSleeps   []time.Duration
+	}{
+		{
+			name:         "success",
+			statuses:     []int{http.StatusOK},
+			retries:      3,
+			wantAttempts: 1,
+		},
+		{
+			name:         "server errors are retried",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			retries:      3,
+			wantAttempts: 3,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:         "not found is not retried",
+			statuses:     []int{http.StatusNotFound, http.StatusOK},
+			retries:      3,
+			wantErr:      "404",
+			wantAttempts: 1,
+		},
+		{
+			name:         "gives up after retries",
+			statuses:     []int{500, 500, 500, 500, 500},
+			retries:      2,
+			wantErr:      "giving up after 3 attempts",
+			wantAttempts: 3,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:         "retries disabled",
+			statuses:     []int{500, http.StatusOK},
+			retries:      0,
+			wantErr:      "500",
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[attempts])
+				attempts++
+				io.WriteString(w, "content")
+			}))
+			defer server.Close()
+
+			var sleeps []time.Duration
+			f := retryingFetcher{
+				f:       HTTPFetcher{},
+				retries: tt.retries,
+				backoff: time.Second,
+				sleep:   func(d time.Duration) { sleeps = append(sleeps, d) },
+			}
+			body, err := f.Get(context.Background(), server.URL)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Get() error = %v, expected to contain %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Get() error = %+v", err)
+				}
+				b, _ := ioutil.ReadAll(body)
+				if string(b) != "content" {
+					t.Fatalf("Get() content = %q", b)
+				}
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("Get() made %d attempts, expected %d", attempts, tt.wantAttempts)
+			}
+			if !reflect.DeepEqual(sleeps, tt.wantSleeps) {
+				t.Errorf("Get() waited %v, expected %v", sleeps, tt.wantSleeps)
+			}
+		})
+	}
+}
+
+type netErrorFetcher struct{ attempts *int }
+
+func (f netErrorFetcher) Get(_ context.Context, _ string) (io.ReadCloser, error) {
+	*f.attempts++
+	return nil, &net.OpError{Op: "dial", Err: io.EOF}
+}
+
+func TestRetryingFetcher_Get_fileURI(t *testing.T) {
+	var attempts int
+	f := retryingFetcher{f: netErrorFetcher{&attempts}, retries: 3, sleep: func(time.Duration) {}}
+
+	if _, err := f.Get(context.Background(), "https://example.com/foo.tar.gz"); err == nil {
+		t.Fatal("Get() expected error")
+	}
+	if attempts != 4 {
+		t.Fatalf("Get() with network errors made %d attempts, expected 4", attempts)
+	}
+
+	attempts = 0
+	if _, err := f.Get(context.Background(), "file:///tmp/foo.tar.gz"); err == nil {
+		t.Fatal("Get() expected error")
+	}
+	if attempts != 1 {
+		t.Fatalf("Get() of a file:// URI made %d attempts, expected 1", attempts)
+	}
+}
+
+func TestRetryingFetcher_Get_resume(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	tests := []struct {
+		name          string
+		supportsRange bool
+		wantRanges    []string
+	}{
+		{
+			name:          "server supports ranges",
+			supportsRange: true,
+			wantRanges:    []string{"", "bytes=10-"},
+		},
+		{
+			name:          "server ignores ranges",
+			supportsRange: false,
+			wantRanges:    []string{"", "bytes=10-"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if len(ranges) == 1 {
+					// send half of the file and drop the connection
+					w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+					_, _ = io.WriteString(w, content[:10])
+					return
+				}
+				if tt.supportsRange && r.Header.Get("Range") == "bytes=10-" {
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+					w.WriteHeader(http.StatusPartialContent)
+					_, _ = io.WriteString(w, content[10:])
+					return
+				}
+				_, _ = io.WriteString(w, content)
+			}))
+			defer server.Close()
+
+			f := retryingFetcher{f: HTTPFetcher{}, retries: 3, sleep: func(time.Duration) {}, resume: true}
+			body, err := f.Get(context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %+v", err)
+			}
+			defer body.Close()
+			got, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != content {
+				t.Errorf("Get() = %q, want %q", got, content)
+			}
+			if !reflect.DeepEqual(ranges, tt.wantRanges) {
+				t.Errorf("Get() sent Range headers %q, want %q", ranges, tt.wantRanges)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcher_Get_sizeCheck(t *testing.T) {
+	const content = "0123456789"
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/known" {
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/known" {
+			// the body is only sent once the test is over
+			<-release
+		}
+		_, _ = io.WriteString(w, content)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	errNoSpace := errors.New("not enough disk space")
+	var checked []int64
+	ctx := withSizeCheck(context.Background(), func(size int64) error {
+		checked = append(checked, size)
+		return errNoSpace
+	})
+
+	if _, err := (HTTPFetcher{}).Get(ctx, server.URL+"/known"); errors.Cause(err) != errNoSpace {
+		t.Errorf("Get() error = %v, want the error of the size check before reading the body", err)
+	}
+	if want := []int64{int64(len(content))}; !reflect.DeepEqual(checked, want) {
+		t.Errorf("checked the sizes %v, want %v", checked, want)
+	}
+
+	checked = nil
+	body, err := (HTTPFetcher{}).Get(ctx, server.URL+"/unknown")
+	if err != nil {
+		t.Fatalf("Get() of a download of unknown size error = %v, expected the size check to be skipped", err)
+	}
+	defer body.Close()
+	if got, _ := ioutil.ReadAll(body); string(got) != content {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+	if len(checked) != 0 {
+		t.Errorf("checked the sizes %v of a download of unknown size", checked)
+	}
+}
+
+func TestRetryingFetcher_Get_resumeEarlierDownload(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// send half of the file and drop the connection
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = io.WriteString(w, content[:10])
+			return
+		}
+		if r.Header.Get("Range") == "bytes=10-" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = io.WriteString(w, content[10:])
+			return
+		}
+		_, _ = io.WriteString(w, content)
+	}))
+	defer server.Close()
+
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	newFetcher := func() Fetcher {
+		return retryingFetcher{f: HTTPFetcher{}, sleep: func(time.Duration) {}, resume: true, partialDir: tmpDir.Root()}
+	}
+
+	// the first download fails without retries and keeps the partial data
+	if _, err := newFetcher().Get(context.Background(), server.URL); err == nil {
+		t.Fatal("Get() of a dropped connection expected error")
+	}
+	partials, _ := filepath.Glob(tmpDir.Path("*.partial"))
+	if len(partials) != 1 {
+		t.Fatalf("expected a partial download file, found %q", partials)
+	}
+	if b, _ := ioutil.ReadFile(partials[0]); string(b) != content[:10] {
+		t.Errorf("partial download = %q, want %q", b, content[:10])
+	}
+
+	// a later download continues it
+	body, err := newFetcher().Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %+v", err)
+	}
+	defer body.Close()
+	if got, _ := ioutil.ReadAll(body); string(got) != content {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+	if want := []string{"", "bytes=10-"}; !reflect.DeepEqual(ranges, want) {
+		t.Errorf("Get() sent Range headers %q, want %q", ranges, want)
+	}
+	if _, err := os.Stat(partials[0]); !os.IsNotExist(err) {
+		t.Errorf("expected the partial download to be removed once complete, stat error = %v", err)
+	}
+}
+
+func Test_localPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses unix paths")
+	}
+	tests := []struct {
+		uri       string
+		wantPath  string
+		wantLocal bool
+		wantErr   bool
+	}{
+		{"https://example.com/foo.tar.gz", "", false, false},
+		{"http://example.com/foo.tar.gz", "", false, false},
+		{"file:///opt/mirror/foo.tar.gz", "/opt/mirror/foo.tar.gz", true, false},
+		{"file://localhost/opt/mirror/foo.tar.gz", "/opt/mirror/foo.tar.gz", true, false},
+		{"/opt/mirror/../mirror/foo.tar.gz", "/opt/mirror/foo.tar.gz", true, false},
+		{"file://opt/mirror/foo.tar.gz", "", false, true},
+		{"file:foo.tar.gz", "", false, true},
+		{"mirror/foo.tar.gz", "", false, true},
+		{"./foo.tar.gz", "", false, true},
+		{"ftp://example.com/foo.tar.gz", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			path, isLocal, err := localPath(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("localPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if path != tt.wantPath || isLocal != tt.wantLocal {
+				t.Errorf("localPath() = (%q, %v), want (%q, %v)", path, isLocal, tt.wantPath, tt.wantLocal)
+			}
+		})
+	}
+}
+
+func TestAllowLocalURIs(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("foo.tar.gz", []byte("archive"))
+	path := tmpDir.Path("foo.tar.gz")
+
+	for _, uri := range []string{path, "file://" + filepath.ToSlash(path)} {
+		if _, err := NewURIFetcher(nil, 0, nil).Get(context.Background(), uri); errors.Cause(err) != ErrLocalURINotAllowed {
+			t.Errorf("Get(%q) error = %v, want %v", uri, err, ErrLocalURINotAllowed)
+		}
+
+		body, err := AllowLocalURIs(NewURIFetcher(nil, 0, nil)).Get(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("Get(%q) with local uris allowed error = %v", uri, err)
+		}
+		b, _ := ioutil.ReadAll(body)
+		body.Close()
+		if string(b) != "archive" {
+			t.Errorf("Get(%q) read %q, want %q", uri, b, "archive")
+		}
+	}
+
+	if _, err := AllowLocalURIs(NewURIFetcher(nil, 0, nil)).Get(context.Background(), "foo.tar.gz"); err == nil {
+		t.Error("Get() of a relative path expected error")
+	}
+}
+
+func TestNewHTTPClient_caCert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	caCert := tmpDir.Write("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})).Path("ca.pem")
+
+	client, err := NewHTTPClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (HTTPFetcher{Client: client}).Get(context.Background(), server.URL); err == nil {
+		t.Error("expected an error for a server with an untrusted certificate")
+	}
+
+	client, err = NewHTTPClient(caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := HTTPFetcher{Client: client}.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("failed to get from the server trusted with --ca-cert: %v", err)
+	}
+	defer body.Close()
+	if b, _ := ioutil.ReadAll(body); string(b) != "ok" {
+		t.Errorf("got body %q, want %q", b, "ok")
+	}
+
+	if _, err := NewHTTPClient(tmpDir.Path("missing.pem")); err == nil {
+		t.Error("expected an error for a missing CA certificate file")
+	}
+	notPEM := tmpDir.Write("not.pem", []byte("foo")).Path("not.pem")
+	if _, err := NewHTTPClient(notPEM); err == nil {
+		t.Error("expected an error for a file without PEM certificates")
+	}
+}
+
+func TestHTTPFetcher_Get_progress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "2048")
+		w.Write(bytes.Repeat([]byte("a"), 2048))
+	}))
+	defer server.Close()
+
+	var progress bytes.Buffer
+	body, err := HTTPFetcher{Progress: &progress}.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if b, _ := ioutil.ReadAll(body); len(b) != 2048 {
+		t.Errorf("read %d bytes, want 2048", len(b))
+	}
+	if want := "100% 2.0 KiB/2.0 KiB\n"; !strings.HasSuffix(progress.String(), want) {
+		t.Errorf("progress %q does not end with %q", progress.String(), want)
+	}
+}
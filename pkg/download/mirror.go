@@ -0,0 +1,56 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// MirrorRule rewrites download URIs starting with From to start with To
+// instead, e.g. to download GitHub release assets from an internal mirror.
+type MirrorRule struct {
+	From string
+	To   string
+}
+
+// ParseMirrorRules parses mirror rules in the FROM=TO format, such as
+// "https://github.com/=https://mirror.internal/github/".
+func ParseMirrorRules(rules []string) ([]MirrorRule, error) {
+	out := make([]MirrorRule, 0, len(rules))
+	for _, rule := range rules {
+		pieces := strings.SplitN(rule, "=", 2)
+		if len(pieces) != 2 || pieces[0] == "" || pieces[1] == "" {
+			return nil, errors.Errorf("invalid mirror rule %q, must be in FROM=TO format", rule)
+		}
+		out = append(out, MirrorRule{From: pieces[0], To: pieces[1]})
+	}
+	return out, nil
+}
+
+// RewriteURI replaces the prefix of uri according to the first rule whose
+// From is a prefix of it. The uri is returned unchanged if no rule matches.
+func RewriteURI(uri string, rules []MirrorRule) string {
+	for _, r := range rules {
+		if strings.HasPrefix(uri, r.From) {
+			rewritten := r.To + strings.TrimPrefix(uri, r.From)
+			log.V(1).Infof("Downloading %q from the mirror %q", uri, rewritten)
+			return rewritten
+		}
+	}
+	return uri
+}
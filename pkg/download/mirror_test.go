@@ -0,0 +1,82 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMirrorRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []string
+		want    []MirrorRule
+		wantErr bool
+	}{
+		{
+			name: "none",
+			want: []MirrorRule{},
+		},
+		{
+			name: "rules",
+			in:   []string{"https://github.com/=https://mirror.internal/github/", "https://a/=https://b/?c=d"},
+			want: []MirrorRule{
+				{From: "https://github.com/", To: "https://mirror.internal/github/"},
+				{From: "https://a/", To: "https://b/?c=d"},
+			},
+		},
+		{name: "no separator", in: []string{"https://github.com/"}, wantErr: true},
+		{name: "empty from", in: []string{"=https://mirror.internal/"}, wantErr: true},
+		{name: "empty to", in: []string{"https://github.com/="}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMirrorRules(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMirrorRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMirrorRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteURI(t *testing.T) {
+	rules := []MirrorRule{
+		{From: "https://github.com/", To: "https://mirror.internal/github/"},
+		{From: "https://github.com/foo/", To: "https://unused/"},
+		{From: "http://example.com", To: "https://mirror.internal/example"},
+	}
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"https://github.com/foo/bar/releases/download/v1/bar.tar.gz", "https://mirror.internal/github/foo/bar/releases/download/v1/bar.tar.gz"},
+		{"http://example.com/a.zip", "https://mirror.internal/example/a.zip"},
+		{"https://github.company.com/a.zip", "https://github.company.com/a.zip"},
+		{"https://example.com/a.zip", "https://example.com/a.zip"},
+		{"file:///tmp/a.zip", "file:///tmp/a.zip"},
+	}
+	for _, tt := range tests {
+		if got := RewriteURI(tt.uri, rules); got != tt.want {
+			t.Errorf("RewriteURI(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+	if got := RewriteURI("https://github.com/a", nil); got != "https://github.com/a" {
+		t.Errorf("RewriteURI() without rules = %q", got)
+	}
+}
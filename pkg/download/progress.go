@@ -0,0 +1,100 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const progressBarWidth = 30
+
+// progressReader reports the number of bytes read from r to out.
+type progressReader struct {
+	r     io.Reader
+	out   io.Writer
+	total int64
+	read  int64
+	// last is the last reported percentage (or KiB if total is unknown), used
+	// to only redraw the progress bar when it changes.
+	last int64
+	done bool
+}
+
+// NewProgressReader returns a reader that reads from r and reports the
+// progress to out as a single line that is redrawn in place, so out should be
+// a terminal. total is the expected number of bytes, or -1 if it is unknown,
+// in which case only the number of bytes read is reported.
+func NewProgressReader(r io.Reader, total int64, out io.Writer) io.Reader {
+	return &progressReader{r: r, out: out, total: total, last: -1}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if err == io.EOF {
+		p.finish()
+	} else if current := p.current(); current != p.last {
+		p.last = current
+		p.report()
+	}
+	return n, err
+}
+
+func (p *progressReader) current() int64 {
+	if p.total > 0 {
+		return p.read * 100 / p.total
+	}
+	return p.read / 1024
+}
+
+// finish reports the final progress and ends the line.
+func (p *progressReader) finish() {
+	if p.done {
+		return
+	}
+	p.done = true
+	p.report()
+	fmt.Fprintln(p.out)
+}
+
+func (p *progressReader) report() {
+	if p.total <= 0 {
+		fmt.Fprintf(p.out, "\rDownloaded %s", formatBytes(p.read))
+		return
+	}
+	read := p.read
+	if read > p.total {
+		read = p.total
+	}
+	filled := int(read * progressBarWidth / p.total)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	fmt.Fprintf(p.out, "\r[%s] %3d%% %s/%s", bar, read*100/p.total, formatBytes(read), formatBytes(p.total))
+}
+
+// formatBytes formats n as a human readable size with binary prefixes.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
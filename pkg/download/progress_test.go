@@ -0,0 +1,88 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewProgressReader(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 4096)
+	tests := []struct {
+		name     string
+		total    int64
+		wantLast string
+		wantIn   string
+	}{
+		{
+			name:     "known size",
+			total:    int64(len(data)),
+			wantLast: "[" + strings.Repeat("=", progressBarWidth) + "] 100% 4.0 KiB/4.0 KiB\n",
+			wantIn:   "[===============               ]  50% 2.0 KiB/4.0 KiB",
+		},
+		{
+			name:     "unknown size",
+			total:    -1,
+			wantLast: "Downloaded 4.0 KiB\n",
+			wantIn:   "Downloaded 2.0 KiB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			r := NewProgressReader(iotest.OneByteReader(bytes.NewReader(data)), tt.total, &out)
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("read %d bytes, want %d", len(got), len(data))
+			}
+			lines := strings.Split(out.String(), "\r")
+			if last := lines[len(lines)-1]; last != tt.wantLast {
+				t.Errorf("last progress line = %q, want %q", last, tt.wantLast)
+			}
+			if !strings.Contains(out.String(), "\r"+tt.wantIn+"\r") {
+				t.Errorf("progress output does not contain %q", tt.wantIn)
+			}
+			if len(lines)-1 > 102 {
+				t.Errorf("progress was reported %d times, expected it only on changes", len(lines)-1)
+			}
+		})
+	}
+}
+
+func Test_formatBytes(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{50 * 1024 * 1024, "50.0 MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.in); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
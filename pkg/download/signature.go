@@ -0,0 +1,134 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// maxSignatureSize is the maximum size of a detached signature.
+const maxSignatureSize = 1 << 20
+
+// SignatureVerifier verifies a detached signature of a downloaded archive
+// against a trusted key.
+type SignatureVerifier interface {
+	VerifySignature(ctx context.Context, data, signature []byte) error
+}
+
+// commandVerifier verifies signatures by running an external program, which
+// gets the paths of the data and the signature files from args and must exit
+// with status 0 for a valid signature.
+type commandVerifier struct {
+	name string
+	args func(dataFile, signatureFile string) []string
+}
+
+// NewGPGVerifier returns a SignatureVerifier that verifies OpenPGP signatures
+// with gpg against the keys in keyring.
+func NewGPGVerifier(keyring string) SignatureVerifier {
+	return commandVerifier{
+		name: "gpg",
+		args: func(data, sig string) []string {
+			return []string{"--batch", "--no-default-keyring", "--keyring", keyring, "--verify", sig, data}
+		},
+	}
+}
+
+// NewCosignVerifier returns a SignatureVerifier that verifies signatures with
+// cosign against the public key in keyFile.
+func NewCosignVerifier(keyFile string) SignatureVerifier {
+	return commandVerifier{
+		name: "cosign",
+		args: func(data, sig string) []string {
+			return []string{"verify-blob", "--key", keyFile, "--signature", sig, data}
+		},
+	}
+}
+
+// ParseSignatureVerifier returns the SignatureVerifier for a trusted key in
+// TYPE:PATH format, where TYPE is gpg (a keyring) or cosign (a public key).
+func ParseSignatureVerifier(key string) (SignatureVerifier, error) {
+	pieces := strings.SplitN(key, ":", 2)
+	if len(pieces) != 2 || pieces[1] == "" {
+		return nil, errors.Errorf("invalid trusted key %q, must be in TYPE:PATH format", key)
+	}
+	path, err := filepath.Abs(pieces[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the absolute path of %q", pieces[1])
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrap(err, "failed to read the trusted key")
+	}
+	switch pieces[0] {
+	case "gpg":
+		return NewGPGVerifier(path), nil
+	case "cosign":
+		return NewCosignVerifier(path), nil
+	}
+	return nil, errors.Errorf("unsupported trusted key type %q, must be one of: gpg, cosign", pieces[0])
+}
+
+func (c commandVerifier) VerifySignature(ctx context.Context, data, signature []byte) error {
+	dir, err := ioutil.TempDir("", "krew-signature-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create a temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	dataFile, sigFile := filepath.Join(dir, "data"), filepath.Join(dir, "signature")
+	if err := ioutil.WriteFile(dataFile, data, 0600); err != nil {
+		return errors.Wrap(err, "failed to write the data to verify")
+	}
+	if err := ioutil.WriteFile(sigFile, signature, 0600); err != nil {
+		return errors.Wrap(err, "failed to write the signature")
+	}
+
+	log.V(2).Infof("Verifying the signature with %s", c.name)
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.name, c.args(dataFile, sigFile)...)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "signature verification with %s failed: %s", c.name, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// verifySignature downloads the signature at signatureURI with fetcher and
+// verifies data against it.
+func verifySignature(ctx context.Context, data []byte, signatureURI string, fetcher Fetcher, verifier SignatureVerifier) error {
+	log.V(2).Infof("Fetching the signature %q", signatureURI)
+	body, err := fetcher.Get(ctx, signatureURI)
+	if err != nil {
+		return errors.Wrapf(err, "could not download the signature %q", signatureURI)
+	}
+	defer body.Close()
+	signature, err := ioutil.ReadAll(io.LimitReader(body, maxSignatureSize+1))
+	if err != nil {
+		return errors.Wrap(err, "could not read the signature")
+	}
+	if len(signature) > maxSignatureSize {
+		return errors.Errorf("the signature %q is larger than %d bytes", signatureURI, maxSignatureSize)
+	}
+	return verifier.VerifySignature(ctx, data, signature)
+}
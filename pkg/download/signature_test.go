@@ -0,0 +1,155 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+// uriFetcherMap serves the content of each URI in the map.
+type uriFetcherMap map[string][]byte
+
+func (m uriFetcherMap) Get(_ context.Context, uri string) (io.ReadCloser, error) {
+	data, ok := m[uri]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// fakeSignatureVerifier accepts the signature "valid", and records the data
+// it verified.
+type fakeSignatureVerifier struct{ verified *[]byte }
+
+func (f fakeSignatureVerifier) VerifySignature(_ context.Context, data, signature []byte) error {
+	*f.verified = data
+	if string(signature) != "valid" {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func TestDownloader_GetContext_signature(t *testing.T) {
+	archive := tarGzArchive(t, map[string]string{"foo": "hello"})
+	fetcher := uriFetcherMap{
+		"https://example.com/foo.tar.gz":         archive,
+		"https://example.com/foo.tar.gz.sig":     []byte("valid"),
+		"https://example.com/foo.tar.gz.bad-sig": []byte("tampered"),
+	}
+
+	tests := []struct {
+		name         string
+		signatureURI string
+		noVerifier   bool
+		wantErr      string
+		wantVerified bool
+	}{
+		{name: "no signature"},
+		{name: "valid signature", signatureURI: "https://example.com/foo.tar.gz.sig", wantVerified: true},
+		{name: "invalid signature", signatureURI: "https://example.com/foo.tar.gz.bad-sig", wantErr: "bad signature", wantVerified: true},
+		{name: "missing signature", signatureURI: "https://example.com/foo.tar.gz.missing", wantErr: "could not download the signature"},
+		{name: "no trusted key", signatureURI: "https://example.com/foo.tar.gz.bad-sig", noVerifier: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			var verified []byte
+			var v SignatureVerifier = fakeSignatureVerifier{&verified}
+			if tt.noVerifier {
+				v = nil
+			}
+			d := NewDownloader(NewInsecureVerifier(), fetcher).WithSignature(tt.signatureURI, v)
+			err := d.GetContext(context.Background(), "https://example.com/foo.tar.gz", tmpDir.Root())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetContext() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(tmpDir.Path("foo")); !os.IsNotExist(err) {
+					t.Errorf("expected the archive not to be extracted, got error %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("GetContext() error = %v", err)
+			}
+			if gotVerified := verified != nil; gotVerified != tt.wantVerified {
+				t.Errorf("signature verified = %v, want %v", gotVerified, tt.wantVerified)
+			}
+			if verified != nil && !bytes.Equal(verified, archive) {
+				t.Errorf("the verified data is not the archive")
+			}
+		})
+	}
+}
+
+func TestParseSignatureVerifier(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("key", []byte("key"))
+
+	tests := []struct {
+		key      string
+		wantName string
+		wantErr  bool
+	}{
+		{key: "gpg:" + tmpDir.Path("key"), wantName: "gpg"},
+		{key: "cosign:" + tmpDir.Path("key"), wantName: "cosign"},
+		{key: "cosign:" + tmpDir.Path("missing"), wantErr: true},
+		{key: "minisign:" + tmpDir.Path("key"), wantErr: true},
+		{key: tmpDir.Path("key"), wantErr: true},
+		{key: "gpg:", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := ParseSignatureVerifier(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSignatureVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.(commandVerifier).name != tt.wantName {
+				t.Errorf("ParseSignatureVerifier() = %s verifier, want %s", got.(commandVerifier).name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestCommandVerifier(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command needs a shell")
+	}
+	// the signature is valid if it equals the data
+	v := commandVerifier{
+		name: "sh",
+		args: func(data, sig string) []string {
+			return []string{"-c", `cmp -s "$0" "$1" || { echo "signature mismatch"; exit 1; }`, data, sig}
+		},
+	}
+	if err := v.VerifySignature(context.Background(), []byte("data"), []byte("data")); err != nil {
+		t.Errorf("VerifySignature() error = %v", err)
+	}
+	err := v.VerifySignature(context.Background(), []byte("data"), []byte("other"))
+	if err == nil || !strings.Contains(err.Error(), "signature mismatch") {
+		t.Errorf("VerifySignature() error = %v, want the output of the failed command", err)
+	}
+}
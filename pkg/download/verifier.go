@@ -18,12 +18,13 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"hash"
 	"io"
 	"io/ioutil"
 
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
 )
 
 // Verifier can check a reader against it's correctness.
@@ -49,13 +50,30 @@ func NewSha256Verifier(hash string) Verifier {
 }
 
 func (v sha256Verifier) Verify() error {
-	glog.V(1).Infof("Compare sha256 (%s) signed version", hex.EncodeToString(v.wantedHash))
+	log.V(1).Infof("Compare sha256 (%s) signed version", hex.EncodeToString(v.wantedHash))
 	if bytes.Equal(v.wantedHash, v.Sum(nil)) {
 		return nil
 	}
-	return errors.Errorf("checksum does not match, wantReader: %x, got %x", v.wantedHash, v.Sum(nil))
+	return &ChecksumError{Expected: hex.EncodeToString(v.wantedHash), Actual: hex.EncodeToString(v.Sum(nil))}
 }
 
+// ErrChecksumMismatch matches a *ChecksumError with errors.Is.
+var ErrChecksumMismatch = errors.New("checksum does not match")
+
+// ChecksumError is returned by a sha256 Verifier when the data doesn't have
+// the expected checksum.
+type ChecksumError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum does not match, expected: %s, actual: %s", e.Expected, e.Actual)
+}
+
+// Is reports whether target is ErrChecksumMismatch.
+func (e *ChecksumError) Is(target error) bool { return target == ErrChecksumMismatch }
+
 var _ Verifier = trueVerifier{}
 
 type trueVerifier struct{ io.Writer }
@@ -16,6 +16,7 @@ package download
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"testing"
 )
@@ -54,6 +55,8 @@ func TestSha256Verifier(t *testing.T) {
 			if err := v.Verify(); (err != nil) != tt.wantError {
 				t.Errorf("NewSha256Verifier().Write(%x).Verify() = %v, wantReader %v", tt.write, err, tt.wantError)
 				return
+			} else if err != nil && !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("NewSha256Verifier().Write(%x).Verify() = %v, want %v", tt.write, err, ErrChecksumMismatch)
 			}
 		})
 	}
@@ -15,13 +15,15 @@
 package environment
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/krew/pkg/log"
 
+	"sigs.k8s.io/krew/pkg/constants"
 	"sigs.k8s.io/krew/pkg/pathutil"
 )
 
@@ -29,6 +31,7 @@ import (
 type Paths struct {
 	base string
 	tmp  string
+	bin  string // overrides the bin directory under base if set
 }
 
 // MustGetKrewPaths returns the inferred paths for krew. By default, it assumes
@@ -38,19 +41,40 @@ func MustGetKrewPaths() Paths {
 	base := filepath.Join(homedir.HomeDir(), ".krew")
 	if fromEnv := os.Getenv("KREW_ROOT"); fromEnv != "" {
 		base = fromEnv
-		glog.V(4).Infof("using environment override KREW_ROOT=%s", fromEnv)
+		log.V(4).Infof("using environment override KREW_ROOT=%s", fromEnv)
 	}
+	p, err := NewPaths(base)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// NewPaths returns the krew paths with base as the base directory, such as
+// the value of the --root flag.
+func NewPaths(base string) (Paths, error) {
 	base, err := filepath.Abs(base)
 	if err != nil {
-		panic(errors.Wrap(err, "cannot get absolute path"))
+		return Paths{}, errors.Wrap(err, "cannot get absolute path")
 	}
-	return newPaths(base)
+	return newPaths(base), nil
 }
 
 func newPaths(base string) Paths {
 	return Paths{base: base, tmp: os.TempDir()}
 }
 
+// WithBinPath returns a copy of p with dir as the bin directory, such as the
+// value of the --bin-dir flag, instead of the one under the base directory.
+func (p Paths) WithBinPath(dir string) (Paths, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Paths{}, errors.Wrap(err, "cannot get absolute path")
+	}
+	p.bin = dir
+	return p, nil
+}
+
 // BasePath returns krew base directory.
 func (p Paths) BasePath() string { return p.base }
 
@@ -59,11 +83,31 @@ func (p Paths) BasePath() string { return p.base }
 // e.g. {IndexPath}/plugins/{plugin}.yaml
 func (p Paths) IndexPath() string { return filepath.Join(p.base, "index") }
 
+// CustomIndexesPath returns the base directory where custom plugin index
+// repositories are cloned.
+//
+// e.g. {CustomIndexesPath}/{index-name}/plugins/{plugin}.yaml
+func (p Paths) CustomIndexesPath() string { return filepath.Join(p.base, "indexes") }
+
+// IndexPathFor returns the directory where the named plugin index repository
+// is cloned. The default index is found at IndexPath.
+func (p Paths) IndexPathFor(name string) string {
+	if name == constants.DefaultIndexName {
+		return p.IndexPath()
+	}
+	return filepath.Join(p.CustomIndexesPath(), name)
+}
+
 // BinPath returns the path where plugin executable symbolic links are found.
 // This path should be added to $PATH in client machine.
 //
 // e.g. {BinPath}/kubectl-foo
-func (p Paths) BinPath() string { return filepath.Join(p.base, "bin") }
+func (p Paths) BinPath() string {
+	if p.bin != "" {
+		return p.bin
+	}
+	return filepath.Join(p.base, "bin")
+}
 
 // DownloadPath returns a temporary directory for downloading plugins. It does
 // not create a new directory on each call.
@@ -133,3 +177,38 @@ func Realpath(path string) (string, error) {
 	}
 	return filepath.Clean(path), nil
 }
+
+// EnsureWritableDirs creates the installation and bin directories of p if they
+// don't exist. It returns an error if they are not writable directories, or if
+// one of them is inside the other, as uninstalling plugins would then remove
+// the links of other plugins or vice versa.
+func EnsureWritableDirs(p Paths) error {
+	install, bin := p.InstallPath(), p.BinPath()
+	if _, ok := pathutil.IsSubPath(install, bin); ok {
+		return errors.Errorf("the bin directory %q must not be inside the install directory %q", bin, install)
+	}
+	if _, ok := pathutil.IsSubPath(bin, install); ok {
+		return errors.Errorf("the install directory %q must not be inside the bin directory %q", install, bin)
+	}
+	if err := EnsureWritableDir(install); err != nil {
+		return errors.Wrapf(err, "can't use the install directory %q", install)
+	}
+	if err := EnsureWritableDir(bin); err != nil {
+		return errors.Wrapf(err, "can't use the bin directory %q", bin)
+	}
+	return nil
+}
+
+// EnsureWritableDir creates dir if it doesn't exist and checks that files can
+// be created in it.
+func EnsureWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create the directory")
+	}
+	f, err := ioutil.TempFile(dir, ".krew-write-check-")
+	if err != nil {
+		return errors.Wrap(err, "the directory is not writable")
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
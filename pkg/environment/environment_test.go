@@ -15,8 +15,10 @@
 package environment
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -56,6 +58,15 @@ func TestPaths(t *testing.T) {
 	if got, expected := p.IndexPath(), filepath.FromSlash("/foo/index"); got != expected {
 		t.Fatalf("IndexPath()=%s; expected=%s", got, expected)
 	}
+	if got, expected := p.CustomIndexesPath(), filepath.FromSlash("/foo/indexes"); got != expected {
+		t.Fatalf("CustomIndexesPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexPathFor("default"), filepath.FromSlash("/foo/index"); got != expected {
+		t.Fatalf("IndexPathFor(default)=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexPathFor("my-index"), filepath.FromSlash("/foo/indexes/my-index"); got != expected {
+		t.Fatalf("IndexPathFor(my-index)=%s; expected=%s", got, expected)
+	}
 	if got, expected := p.InstallPath(), filepath.FromSlash("/foo/store"); got != expected {
 		t.Fatalf("InstallPath()=%s; expected=%s", got, expected)
 	}
@@ -70,6 +81,27 @@ func TestPaths(t *testing.T) {
 	}
 }
 
+func TestPaths_WithBinPath(t *testing.T) {
+	p, err := newPaths(filepath.FromSlash("/foo")).WithBinPath(filepath.FromSlash("/usr/local/bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := p.BinPath(), filepath.FromSlash("/usr/local/bin"); got != expected {
+		t.Fatalf("BinPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.InstallPath(), filepath.FromSlash("/foo/store"); got != expected {
+		t.Fatalf("InstallPath()=%s; expected=%s", got, expected)
+	}
+
+	p, err = newPaths(filepath.FromSlash("/foo")).WithBinPath("bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(p.BinPath()) {
+		t.Fatalf("BinPath()=%s; expected an absolute path", p.BinPath())
+	}
+}
+
 func TestGetExecutedVersion(t *testing.T) {
 	type args struct {
 		paths         Paths
@@ -188,3 +220,54 @@ func TestRealpath(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureWritableDirs(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	p := newPaths(tmpDir.Root())
+	if err := EnsureWritableDirs(p); err != nil {
+		t.Fatalf("EnsureWritableDirs() error = %v", err)
+	}
+	for _, dir := range []string{p.InstallPath(), p.BinPath()} {
+		items, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("expected %s to be created: %v", dir, err)
+		}
+		if len(items) > 0 {
+			t.Errorf("expected no files to be left in %s, found %d", dir, len(items))
+		}
+	}
+}
+
+func TestEnsureWritableDirs_notADirectory(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin", []byte("not a directory"))
+
+	err := EnsureWritableDirs(newPaths(tmpDir.Root()))
+	if err == nil || !strings.Contains(err.Error(), "bin directory") {
+		t.Fatalf("EnsureWritableDirs() error = %v, expected an error about the bin directory", err)
+	}
+}
+
+func TestEnsureWritableDirs_readOnly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory permissions don't prevent creating files on windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("store/.keep", nil)
+	if err := os.Chmod(tmpDir.Path("store"), 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(tmpDir.Path("store"), 0755)
+
+	err := EnsureWritableDirs(newPaths(tmpDir.Root()))
+	if err == nil || !strings.Contains(err.Error(), "not writable") {
+		t.Fatalf("EnsureWritableDirs() error = %v, expected a not writable error", err)
+	}
+}
@@ -21,9 +21,10 @@ import (
 	osexec "os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
 )
 
 // EnsureCloned will clone into the destination path, otherwise will return no error.
@@ -45,16 +46,88 @@ func IsGitCloned(gitPath string) (bool, error) {
 	return err == nil && f.IsDir(), err
 }
 
-// update will fetch origin and set HEAD to origin/HEAD.
+// update will fetch origin and set HEAD to origin/HEAD. The fetch is skipped
+// if the HEAD of origin is still the upstream commit, which "git ls-remote"
+// checks by only transferring the refs of origin, and the working tree is not
+// rewritten if it is already up to date.
 func update(destinationPath string) error {
-	if err := exec(destinationPath, "fetch", "-v"); err != nil {
-		return errors.Wrapf(err, "fetch index at %q failed", destinationPath)
+	changed, err := remoteChanged(destinationPath)
+	if err != nil {
+		log.V(2).Infof("Failed to check if the remote of the index at %q changed: %v", destinationPath, err)
+	}
+	if err != nil || changed {
+		if err := exec(destinationPath, "fetch", "-v"); err != nil {
+			return errors.Wrapf(err, "fetch index at %q failed", destinationPath)
+		}
+	} else {
+		log.V(1).Infof("Remote of the index at %q is unchanged, skipping fetch", destinationPath)
+		if err := touchFetchHead(destinationPath); err != nil {
+			log.V(2).Infof("Failed to record the update time of the index at %q: %v", destinationPath, err)
+		}
+	}
+
+	if upToDate, err := isUpToDate(destinationPath); err != nil {
+		log.V(2).Infof("Failed to check if the index at %q is up to date: %v", destinationPath, err)
+	} else if upToDate {
+		log.V(1).Infof("Index at %q is already up to date", destinationPath)
+		return nil
 	}
 
-	err := exec(destinationPath, "reset", "--hard", "@{upstream}")
+	err = exec(destinationPath, "reset", "--hard", "@{upstream}")
 	return errors.Wrapf(err, "reset index at %q failed", destinationPath)
 }
 
+// remoteChanged returns true if the HEAD of origin is not the upstream commit
+// that was fetched last.
+func remoteChanged(dir string) (bool, error) {
+	out, err := output(dir, "ls-remote", "origin", "HEAD")
+	if err != nil {
+		return false, err
+	}
+	remote := strings.Fields(out)
+	if len(remote) == 0 {
+		return false, errors.New("origin has no HEAD")
+	}
+	upstream, err := output(dir, "rev-parse", "@{upstream}")
+	if err != nil {
+		return false, err
+	}
+	return remote[0] != strings.TrimSpace(upstream), nil
+}
+
+// touchFetchHead sets the modification time of FETCH_HEAD, which
+// GetLastUpdateTime returns, to now if fetching was skipped.
+func touchFetchHead(dir string) error {
+	fetchHead := filepath.Join(dir, ".git", "FETCH_HEAD")
+	f, err := os.OpenFile(fetchHead, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(fetchHead, now, now)
+}
+
+// isUpToDate returns true if HEAD is at the upstream commit and the working
+// tree has no changes.
+func isUpToDate(dir string) (bool, error) {
+	out, err := output(dir, "rev-parse", "HEAD", "@{upstream}")
+	if err != nil {
+		return false, err
+	}
+	revs := strings.Fields(out)
+	if len(revs) != 2 || revs[0] != revs[1] {
+		return false, nil
+	}
+	status, err := output(dir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(status) == "", nil
+}
+
 // EnsureUpdated will ensure the destination path exists and is up to date.
 func EnsureUpdated(uri, destinationPath string) error {
 	if err := EnsureCloned(uri, destinationPath); err != nil {
@@ -63,18 +136,55 @@ func EnsureUpdated(uri, destinationPath string) error {
 	return update(destinationPath)
 }
 
+// GetRemoteURL returns the URL of the "origin" remote of the git repository.
+func GetRemoteURL(dir string) (string, error) {
+	out, err := output(dir, "config", "--get", "remote.origin.url")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the remote url of %q", dir)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// GetHeadCommit returns the hash of the commit checked out in the git
+// repository.
+func GetHeadCommit(dir string) (string, error) {
+	out, err := output(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the commit of %q", dir)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// GetLastUpdateTime returns when the git repository was last fetched or found
+// to be up to date with its remote, or cloned if it was never updated.
+func GetLastUpdateTime(dir string) (time.Time, error) {
+	fi, err := os.Stat(filepath.Join(dir, ".git", "FETCH_HEAD"))
+	if os.IsNotExist(err) {
+		fi, err = os.Stat(filepath.Join(dir, ".git", "HEAD"))
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to get the update time of %q", dir)
+	}
+	return fi.ModTime(), nil
+}
+
 func exec(pwd string, args ...string) error {
-	glog.V(4).Infof("Going to run git %s", strings.Join(args, " "))
+	_, err := output(pwd, args...)
+	return err
+}
+
+func output(pwd string, args ...string) (string, error) {
+	log.V(4).Infof("Going to run git %s", strings.Join(args, " "))
 	cmd := osexec.Command("git", args...)
 	cmd.Dir = pwd
 	buf := bytes.Buffer{}
 	var w io.Writer = &buf
-	if glog.V(2) {
+	if log.V(2).Enabled() {
 		w = io.MultiWriter(w, os.Stderr)
 	}
 	cmd.Stdout, cmd.Stderr = w, w
 	if err := cmd.Run(); err != nil {
-		return errors.Wrapf(err, "command execution failure, output=%q", buf.String())
+		return "", errors.Wrapf(err, "command execution failure, output=%q", buf.String())
 	}
-	return nil
+	return buf.String(), nil
 }
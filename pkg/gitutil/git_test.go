@@ -0,0 +1,199 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func TestEnsureUpdated_upToDate(t *testing.T) {
+	if _, err := osexec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	upstream, local := tmpDir.Path("upstream"), tmpDir.Path("local")
+	commit := func(file, content string) {
+		tmpDir.Write(filepath.Join("upstream", file), []byte(content))
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", file},
+		} {
+			if err := exec(upstream, args...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := exec("", "init", "-q", upstream); err != nil {
+		t.Fatal(err)
+	}
+	commit("plugins/foo.yaml", "foo")
+
+	assertUpToDate := func(want bool) {
+		t.Helper()
+		got, err := isUpToDate(local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("isUpToDate() = %v, want %v", got, want)
+		}
+	}
+
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+	assertUpToDate(true)
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+
+	// local changes are discarded
+	tmpDir.Write("local/plugins/foo.yaml", []byte("changed"))
+	assertUpToDate(false)
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+	assertUpToDate(true)
+	if b, _ := ioutil.ReadFile(filepath.Join(local, "plugins", "foo.yaml")); string(b) != "foo" {
+		t.Errorf("expected the local change to be reset, got %q", b)
+	}
+
+	// upstream changes are fetched
+	commit("plugins/bar.yaml", "bar")
+	if err := exec(local, "fetch", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	assertUpToDate(false)
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+	assertUpToDate(true)
+	if b, _ := ioutil.ReadFile(filepath.Join(local, "plugins", "bar.yaml")); string(b) != "bar" {
+		t.Errorf("expected the upstream change to be checked out, got %q", b)
+	}
+}
+
+func TestEnsureUpdated_skipsFetchIfRemoteUnchanged(t *testing.T) {
+	if _, err := osexec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	upstream, local := tmpDir.Path("upstream"), tmpDir.Path("local")
+	commit := func(file, content string) {
+		tmpDir.Write(filepath.Join("upstream", file), []byte(content))
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", file},
+		} {
+			if err := exec(upstream, args...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := exec("", "init", "-q", upstream); err != nil {
+		t.Fatal(err)
+	}
+	commit("plugins/foo.yaml", "foo")
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fetch overwrites FETCH_HEAD
+	fetchHead := filepath.Join(local, ".git", "FETCH_HEAD")
+	readFetchHead := func() string {
+		t.Helper()
+		b, err := ioutil.ReadFile(fetchHead)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	tmpDir.Write(filepath.Join("local", ".git", "FETCH_HEAD"), []byte("not fetched"))
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(fetchHead, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFetchHead(); got != "not fetched" {
+		t.Errorf("expected no fetch for an unchanged remote, FETCH_HEAD = %q", got)
+	}
+	if got, err := GetLastUpdateTime(local); err != nil || !got.After(old) {
+		t.Errorf("GetLastUpdateTime() = %v, %v, want a time after %v", got, err, old)
+	}
+
+	commit("plugins/bar.yaml", "bar")
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFetchHead(); got == "not fetched" {
+		t.Error("expected a fetch for a changed remote")
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(local, "plugins", "bar.yaml")); string(b) != "bar" {
+		t.Errorf("expected the upstream change to be checked out, got %q", b)
+	}
+}
+
+func TestGetHeadCommit_GetLastUpdateTime(t *testing.T) {
+	if _, err := osexec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	upstream, local := tmpDir.Path("upstream"), tmpDir.Path("local")
+	tmpDir.Write(filepath.Join("upstream", "plugins", "foo.yaml"), []byte("foo"))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "foo"},
+	} {
+		if err := exec(upstream, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now().Add(-time.Second)
+	if err := EnsureUpdated(upstream, local); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := output(upstream, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetHeadCommit(local); err != nil || got != strings.TrimSpace(want) {
+		t.Errorf("GetHeadCommit() = %q, %v, want %q", got, err, strings.TrimSpace(want))
+	}
+	if got, err := GetLastUpdateTime(local); err != nil || got.Before(before) {
+		t.Errorf("GetLastUpdateTime() = %v, %v, want a time after %v", got, err, before)
+	}
+	if _, err := GetLastUpdateTime(tmpDir.Path("missing")); err == nil {
+		t.Errorf("GetLastUpdateTime() expected an error for a missing repository")
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package indexoperations manages the plugin index repositories configured
+// in krew, which consist of the default index and any custom indexes.
+package indexoperations
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+
+	"sigs.k8s.io/krew/pkg/constants"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/gitutil"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/index/indexscanner"
+)
+
+// Index describes a plugin index repository.
+type Index struct {
+	Name string
+	URL  string
+}
+
+// IsValidIndexName checks if the name can be used for a custom index.
+func IsValidIndexName(name string) bool {
+	return name != constants.DefaultIndexName && index.IsSafePluginName(name)
+}
+
+// ListIndexes returns the default index followed by the custom indexes that
+// are cloned on the filesystem.
+func ListIndexes(p environment.Paths) ([]Index, error) {
+	indexes := []Index{{Name: constants.DefaultIndexName, URL: constants.IndexURI}}
+
+	dirs, err := ioutil.ReadDir(p.CustomIndexesPath())
+	if os.IsNotExist(err) {
+		return indexes, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read the custom indexes directory")
+	}
+	for _, d := range dirs {
+		if !d.IsDir() || !IsValidIndexName(d.Name()) {
+			log.V(4).Infof("Skip item in the custom indexes directory: %s", d.Name())
+			continue
+		}
+		indexPath := p.IndexPathFor(d.Name())
+		if ok, err := gitutil.IsGitCloned(indexPath); err != nil {
+			return nil, errors.Wrapf(err, "failed to check index %q", d.Name())
+		} else if !ok {
+			log.V(2).Infof("Skip custom index %q, it is not a git repository", d.Name())
+			continue
+		}
+		url, err := gitutil.GetRemoteURL(indexPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the url of index %q", d.Name())
+		}
+		indexes = append(indexes, Index{Name: d.Name(), URL: url})
+	}
+	return indexes, nil
+}
+
+// AddIndex clones the plugin index repository at url as a custom index with
+// the given name.
+func AddIndex(p environment.Paths, name, url string) error {
+	if !IsValidIndexName(name) {
+		return errors.Errorf("invalid index name %q", name)
+	}
+	indexPath := p.IndexPathFor(name)
+	if _, err := os.Stat(indexPath); err == nil {
+		return errors.Errorf("index %q already exists", name)
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to check index %q", name)
+	}
+	return gitutil.EnsureCloned(url, indexPath)
+}
+
+// DeleteIndex removes the custom index with the given name.
+func DeleteIndex(p environment.Paths, name string) error {
+	if name == constants.DefaultIndexName {
+		return errors.New("the default index can't be removed")
+	}
+	if !IsValidIndexName(name) {
+		return errors.Errorf("invalid index name %q", name)
+	}
+	indexPath := p.IndexPathFor(name)
+	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+		return errors.Errorf("index %q does not exist", name)
+	}
+	return errors.Wrapf(os.RemoveAll(indexPath), "failed to remove index %q", name)
+}
+
+// SplitPluginName splits a plugin reference of the form {index}/{plugin} into
+// its index and plugin names. It returns false if the name is not qualified
+// with an index.
+func SplitPluginName(name string) (indexName, pluginName string, ok bool) {
+	pieces := strings.SplitN(name, "/", 2)
+	if len(pieces) != 2 {
+		return "", name, false
+	}
+	return pieces[0], pieces[1], true
+}
+
+// LoadPlugin loads a plugin manifest from the configured indexes. The name
+// is either a {index}/{plugin} reference or a plain plugin name, which is
+// looked up in the default index first and then in the custom indexes. When
+// the plugin is not found, it returns an error that can be checked with
+// os.IsNotExist.
+func LoadPlugin(p environment.Paths, name string) (index.Plugin, error) {
+	plugin, _, err := FindPlugin(p, name)
+	return plugin, err
+}
+
+// FindPlugin is like LoadPlugin, but also returns the name of the index the
+// plugin manifest was loaded from.
+func FindPlugin(p environment.Paths, name string) (index.Plugin, string, error) {
+	if indexName, pluginName, ok := SplitPluginName(name); ok {
+		if indexName != constants.DefaultIndexName && !IsValidIndexName(indexName) {
+			return index.Plugin{}, "", errors.Errorf("invalid index name %q", indexName)
+		}
+		plugin, err := indexscanner.LoadPluginFileFromFS(p.IndexPathFor(indexName), pluginName)
+		return plugin, indexName, err
+	}
+
+	plugin, err := indexscanner.LoadPluginFileFromFS(p.IndexPath(), name)
+	if !os.IsNotExist(err) {
+		return plugin, constants.DefaultIndexName, err
+	}
+	notFoundErr := err
+
+	indexes, err := ListIndexes(p)
+	if err != nil {
+		return index.Plugin{}, "", err
+	}
+	var found []string
+	for _, idx := range indexes[1:] {
+		pl, err := indexscanner.LoadPluginFileFromFS(p.IndexPathFor(idx.Name), name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return index.Plugin{}, "", errors.Wrapf(err, "failed to load plugin %q from index %q", name, idx.Name)
+		}
+		plugin = pl
+		found = append(found, idx.Name)
+	}
+	switch len(found) {
+	case 0:
+		return index.Plugin{}, "", notFoundErr
+	case 1:
+		return plugin, found[0], nil
+	default:
+		return index.Plugin{}, "", errors.Errorf("plugin %q is found in multiple indexes (%s), specify it as INDEX/%s",
+			name, strings.Join(found, ", "), name)
+	}
+}
+
+// LoadPlugins loads the manifests of the plugins referenced by refs like
+// LoadPlugin, keyed by the references. The plugins of each index are loaded
+// together by name with indexscanner.LoadPluginsFromFS, which avoids loading
+// all plugins of the indexes. Plugins that are not found or fail to load are
+// left out.
+func LoadPlugins(p environment.Paths, refs []string) map[string]index.Plugin {
+	// the references of the plugins of each index by the plugin names
+	byIndex := make(map[string]map[string][]string)
+	for _, ref := range refs {
+		indexName, pluginName, ok := SplitPluginName(ref)
+		if !ok {
+			indexName = constants.DefaultIndexName
+		}
+		if byIndex[indexName] == nil {
+			byIndex[indexName] = make(map[string][]string)
+		}
+		byIndex[indexName][pluginName] = append(byIndex[indexName][pluginName], ref)
+	}
+
+	out := make(map[string]index.Plugin, len(refs))
+	for indexName, pluginRefs := range byIndex {
+		if indexName != constants.DefaultIndexName && !IsValidIndexName(indexName) {
+			log.V(2).Infof("Skipping the plugins of invalid index name %q", indexName)
+			continue
+		}
+		names := make([]string, 0, len(pluginRefs))
+		for name := range pluginRefs {
+			names = append(names, name)
+		}
+		plugins, err := indexscanner.LoadPluginsFromFS(p.IndexPathFor(indexName), names)
+		if err != nil {
+			log.V(2).Infof("Failed to load the plugins of index %q: %v", indexName, err)
+			continue
+		}
+		for name, plugin := range plugins {
+			for _, ref := range pluginRefs[name] {
+				out[ref] = plugin
+			}
+		}
+	}
+
+	// plain names that are not in the default index may be in a custom index
+	for _, ref := range refs {
+		if _, ok := out[ref]; ok {
+			continue
+		}
+		if _, _, ok := SplitPluginName(ref); ok {
+			continue
+		}
+		if plugin, err := LoadPlugin(p, ref); err == nil {
+			out[ref] = plugin
+		}
+	}
+	return out
+}
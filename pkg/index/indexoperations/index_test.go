@@ -0,0 +1,221 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexoperations
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+// newTestPaths returns krew paths rooted at a temporary directory.
+func newTestPaths(t *testing.T) (environment.Paths, *testutil.TempDir, func()) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	os.Setenv("KREW_ROOT", tmpDir.Root())
+	defer os.Unsetenv("KREW_ROOT")
+	return environment.MustGetKrewPaths(), tmpDir, cleanup
+}
+
+func writePlugin(tmpDir *testutil.TempDir, indexDir, name, shortDescription string) {
+	tmpDir.Write(indexDir+"/plugins/"+name+".yaml", []byte(fmt.Sprintf(`apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: %s
+spec:
+  shortDescription: %s
+  platforms:
+  - uri: https://example.com/%s.tar.gz
+    sha256: deadbeef
+    bin: kubectl-%s
+    files:
+    - from: "*"
+      to: "."
+`, name, shortDescription, name, name)))
+}
+
+func initGitRepo(t *testing.T, dir, remote string) {
+	t.Helper()
+	for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", remote}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v, %s", args, err, out)
+		}
+	}
+}
+
+func TestIsValidIndexName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"company", true},
+		{"my-index", true},
+		{"default", false},
+		{"", false},
+		{"foo/bar", false},
+		{"..", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidIndexName(tt.name); got != tt.want {
+			t.Errorf("IsValidIndexName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPluginName(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantIndex  string
+		wantPlugin string
+		wantOK     bool
+	}{
+		{"foo", "", "foo", false},
+		{"company/foo", "company", "foo", true},
+		{"default/foo", "default", "foo", true},
+	}
+	for _, tt := range tests {
+		gotIndex, gotPlugin, gotOK := SplitPluginName(tt.in)
+		if gotIndex != tt.wantIndex || gotPlugin != tt.wantPlugin || gotOK != tt.wantOK {
+			t.Errorf("SplitPluginName(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.in,
+				gotIndex, gotPlugin, gotOK, tt.wantIndex, tt.wantPlugin, tt.wantOK)
+		}
+	}
+}
+
+func TestListIndexes(t *testing.T) {
+	p, tmpDir, cleanup := newTestPaths(t)
+	defer cleanup()
+
+	got, err := ListIndexes(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Index{{Name: "default", URL: "https://github.com/kubernetes-sigs/krew-index.git"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListIndexes() without custom indexes = %v, want %v", got, want)
+	}
+
+	tmpDir.Write("indexes/company/plugins/.keep", nil)
+	initGitRepo(t, p.IndexPathFor("company"), "https://example.com/company-index.git")
+	tmpDir.Write("indexes/not-cloned/plugins/.keep", nil)
+
+	got, err = ListIndexes(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, Index{Name: "company", URL: "https://example.com/company-index.git"})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListIndexes() = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteIndex(t *testing.T) {
+	p, tmpDir, cleanup := newTestPaths(t)
+	defer cleanup()
+
+	if err := DeleteIndex(p, "default"); err == nil {
+		t.Errorf("DeleteIndex(default) expected error")
+	}
+	if err := DeleteIndex(p, "not-exists"); err == nil {
+		t.Errorf("DeleteIndex(not-exists) expected error")
+	}
+
+	tmpDir.Write("indexes/company/plugins/.keep", nil)
+	if err := DeleteIndex(p, "company"); err != nil {
+		t.Fatalf("DeleteIndex(company) error = %v", err)
+	}
+	if _, err := os.Stat(p.IndexPathFor("company")); !os.IsNotExist(err) {
+		t.Fatalf("index directory still exists after DeleteIndex, err=%v", err)
+	}
+}
+
+func TestLoadPlugin(t *testing.T) {
+	p, tmpDir, cleanup := newTestPaths(t)
+	defer cleanup()
+
+	writePlugin(tmpDir, "index", "foo", "default-foo")
+	writePlugin(tmpDir, "indexes/company", "foo", "company-foo")
+	writePlugin(tmpDir, "indexes/company", "bar", "company-bar")
+	writePlugin(tmpDir, "indexes/other", "bar", "other-bar")
+	writePlugin(tmpDir, "indexes/other", "baz", "other-baz")
+	initGitRepo(t, p.IndexPathFor("company"), "https://example.com/company.git")
+	initGitRepo(t, p.IndexPathFor("other"), "https://example.com/other.git")
+
+	tests := []struct {
+		name            string
+		wantDescription string
+		wantErr         bool
+		wantNotExist    bool
+	}{
+		{name: "foo", wantDescription: "default-foo"},
+		{name: "default/foo", wantDescription: "default-foo"},
+		{name: "company/foo", wantDescription: "company-foo"},
+		{name: "baz", wantDescription: "other-baz"},
+		{name: "bar", wantErr: true},
+		{name: "other/bar", wantDescription: "other-bar"},
+		{name: "not-found", wantErr: true, wantNotExist: true},
+		{name: "company/not-found", wantErr: true, wantNotExist: true},
+		{name: "../foo", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadPlugin(p, tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPlugin(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if os.IsNotExist(err) != tt.wantNotExist {
+				t.Fatalf("LoadPlugin(%q) error = %v, wantNotExist %v", tt.name, err, tt.wantNotExist)
+			}
+			if got.Spec.ShortDescription != tt.wantDescription {
+				t.Fatalf("LoadPlugin(%q) loaded %q, want %q", tt.name, got.Spec.ShortDescription, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestLoadPlugins(t *testing.T) {
+	p, tmpDir, cleanup := newTestPaths(t)
+	defer cleanup()
+
+	writePlugin(tmpDir, "index", "foo", "default-foo")
+	writePlugin(tmpDir, "indexes/company", "foo", "company-foo")
+	writePlugin(tmpDir, "indexes/company", "bar", "company-bar")
+	writePlugin(tmpDir, "indexes/other", "bar", "other-bar")
+	writePlugin(tmpDir, "indexes/other", "baz", "other-baz")
+	initGitRepo(t, p.IndexPathFor("company"), "https://example.com/company.git")
+	initGitRepo(t, p.IndexPathFor("other"), "https://example.com/other.git")
+
+	got := LoadPlugins(p, []string{"foo", "default/foo", "company/foo", "baz", "bar", "other/bar", "not-found", "company/not-found", "../foo"})
+	gotDescriptions := make(map[string]string, len(got))
+	for ref, plugin := range got {
+		gotDescriptions[ref] = plugin.Spec.ShortDescription
+	}
+	want := map[string]string{
+		"foo":         "default-foo",
+		"default/foo": "default-foo",
+		"company/foo": "company-foo",
+		"baz":         "other-baz",
+		"other/bar":   "other-bar",
+	}
+	if !reflect.DeepEqual(gotDescriptions, want) {
+		t.Errorf("LoadPlugins() loaded %v, want %v", gotDescriptions, want)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// IndexArchiveName is the name of the gzipped tarball of an index, which is
+// loaded instead of the plugins directory if the index directory has none.
+// The tarball has the plugin manifests in its plugins/ directory, optionally
+// below a single top-level directory.
+const IndexArchiveName = "index.tar.gz"
+
+// archiveManifest is a plugin manifest file read from an index tarball.
+type archiveManifest struct {
+	fileName string
+	data     []byte
+}
+
+// indexArchivePath returns the path of the index tarball if indexDir is the
+// tarball itself or a directory with the tarball and without a plugins
+// directory. It returns false if the index is loaded from a directory.
+func indexArchivePath(indexDir string) (string, bool) {
+	fi, err := os.Stat(indexDir)
+	if err != nil {
+		return "", false
+	}
+	if !fi.IsDir() {
+		return indexDir, true
+	}
+	if _, err := os.Stat(filepath.Join(indexDir, "plugins")); !os.IsNotExist(err) {
+		return "", false
+	}
+	archive := filepath.Join(indexDir, IndexArchiveName)
+	if fi, err := os.Stat(archive); err == nil && !fi.IsDir() {
+		return archive, true
+	}
+	return "", false
+}
+
+// readIndexArchive reads the plugin manifests from the index tarball at path
+// into memory, sorted by their file names. If want is not nil, only the
+// manifests whose file names it returns true for are read.
+func readIndexArchive(archive string, want func(fileName string) bool) ([]archiveManifest, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open index archive")
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read index archive %s", archive)
+	}
+	defer gzr.Close()
+
+	var out []archiveManifest
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to read index archive %s", archive)
+		}
+		fileName, ok := archiveManifestName(hdr.Name)
+		if !ok || hdr.Typeflag != tar.TypeReg {
+			log.V(4).Infof("Skip non-manifest archive entry: %s", hdr.Name)
+			continue
+		}
+		if want != nil && !want(fileName) {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s from index archive %s", hdr.Name, archive)
+		}
+		out = append(out, archiveManifest{fileName: fileName, data: data})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].fileName < out[j].fileName })
+	return out, nil
+}
+
+// archiveManifestName returns the file name of a plugin manifest at
+// plugins/{name}.yaml or {dir}/plugins/{name}.yaml in an index tarball.
+func archiveManifestName(entry string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path.Clean("/"+entry), "/"), "/")
+	if len(parts) > 3 || len(parts) < 2 || parts[len(parts)-2] != "plugins" {
+		return "", false
+	}
+	fileName := parts[len(parts)-1]
+	return fileName, path.Ext(fileName) == ".yaml"
+}
+
+// loadPluginFromArchive loads the plugin from the index tarball. When the
+// plugin file is not found, it returns an error that can be checked with
+// os.IsNotExist.
+func loadPluginFromArchive(archive, pluginName string) (index.Plugin, error) {
+	fileName := pluginName + ".yaml"
+	manifests, err := readIndexArchive(archive, func(name string) bool { return name == fileName })
+	if err != nil {
+		return index.Plugin{}, err
+	}
+	if len(manifests) > 0 {
+		return decodeArchiveManifest(manifests[0])
+	}
+	return index.Plugin{}, &os.PathError{Op: "open", Path: archive + ":plugins/" + pluginName + ".yaml", Err: os.ErrNotExist}
+}
+
+// decodeArchiveManifest decodes and validates a plugin manifest read from an
+// index tarball.
+func decodeArchiveManifest(m archiveManifest) (index.Plugin, error) {
+	pluginName := strings.TrimSuffix(m.fileName, filepath.Ext(m.fileName))
+	if err := index.ValidatePluginName(pluginName); err != nil {
+		return index.Plugin{}, err
+	}
+	p, err := DecodePluginFile(bytes.NewReader(m.data))
+	if err != nil {
+		return index.Plugin{}, errors.Wrap(err, "failed to read the plugin manifest")
+	}
+	return p, p.Validate(pluginName)
+}
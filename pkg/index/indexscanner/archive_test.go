@@ -0,0 +1,114 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func TestLoadPluginListFromFS_archive(t *testing.T) {
+	want, err := LoadPluginListFromFS(filepath.Join(testdataPath(t), "testindex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(testdataPath(t), "testindex.tar.gz")
+
+	// an index directory with the tarball instead of a plugins directory
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	b, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write(IndexArchiveName, b)
+
+	for _, indexDir := range []string{archive, tmpDir.Root()} {
+		got, err := LoadPluginListFromFS(indexDir)
+		if err != nil {
+			t.Fatalf("LoadPluginListFromFS(%s) error = %v", indexDir, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("LoadPluginListFromFS(%s) = %+v, want the plugins of the index directory %+v", indexDir, got, want)
+		}
+
+		_, err = LoadPluginListFromFSStrict(indexDir)
+		if err == nil || !strings.Contains(err.Error(), "failed to load 3 plugin files") {
+			t.Errorf("LoadPluginListFromFSStrict(%s) error = %v, expected the invalid plugin files", indexDir, err)
+		}
+	}
+
+	p, err := LoadPluginFileFromFS(tmpDir.Root(), "foo")
+	if err != nil {
+		t.Fatalf("LoadPluginFileFromFS() from the tarball error = %v", err)
+	}
+	if p.Name != "foo" {
+		t.Errorf("LoadPluginFileFromFS() = %q, want foo", p.Name)
+	}
+	if _, err := LoadPluginFileFromFS(tmpDir.Root(), "not-found"); !os.IsNotExist(err) {
+		t.Errorf("LoadPluginFileFromFS() of a plugin not in the tarball error = %v, want a not exist error", err)
+	}
+}
+
+func TestLoadPluginsFromFS(t *testing.T) {
+	indexDir := filepath.Join(testdataPath(t), "testindex")
+	for _, dir := range []string{indexDir, filepath.Join(testdataPath(t), "testindex.tar.gz")} {
+		got, err := LoadPluginsFromFS(dir, []string{"foo", "bar", "not-found", "badplugin"})
+		if err != nil {
+			t.Fatalf("LoadPluginsFromFS(%s) error = %v", dir, err)
+		}
+		var gotNames []string
+		for name, p := range got {
+			if name != p.Name {
+				t.Errorf("LoadPluginsFromFS(%s) returned plugin %q with key %q", dir, p.Name, name)
+			}
+			gotNames = append(gotNames, name)
+		}
+		sort.Strings(gotNames)
+		if want := []string{"bar", "foo"}; !reflect.DeepEqual(gotNames, want) {
+			t.Errorf("LoadPluginsFromFS(%s) loaded %v, want %v", dir, gotNames, want)
+		}
+	}
+}
+
+func Test_archiveManifestName(t *testing.T) {
+	tests := []struct {
+		entry  string
+		want   string
+		wantOK bool
+	}{
+		{"plugins/foo.yaml", "foo.yaml", true},
+		{"./plugins/foo.yaml", "foo.yaml", true},
+		{"krew-index-master/plugins/foo.yaml", "foo.yaml", true},
+		{"plugins/notyaml.txt", "notyaml.txt", false},
+		{"plugins/", "", false},
+		{"foo.yaml", "", false},
+		{"a/b/plugins/foo.yaml", "", false},
+		{"other/foo.yaml", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := archiveManifestName(tt.entry)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("archiveManifestName(%q) = %q, %v, want %q, %v", tt.entry, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+type cachedPluginList struct {
+	fingerprint string
+	list        index.PluginList
+}
+
+var (
+	pluginListCacheMu sync.Mutex
+	pluginListCache   = make(map[string]cachedPluginList)
+)
+
+// LoadPluginListFromFSCached works like LoadPluginListFromFS, but returns the
+// plugin list parsed by a previous call in this process if none of the plugin
+// files in the index directory were added, removed or modified since.
+func LoadPluginListFromFSCached(indexDir string) (index.PluginList, error) {
+	indexDir, err := filepath.EvalSymlinks(indexDir)
+	if err != nil {
+		return index.PluginList{}, err
+	}
+	fingerprint, err := indexFingerprint(indexDir)
+	if err != nil {
+		return index.PluginList{}, err
+	}
+
+	pluginListCacheMu.Lock()
+	cached, ok := pluginListCache[indexDir]
+	pluginListCacheMu.Unlock()
+	if ok && cached.fingerprint == fingerprint {
+		log.V(4).Infof("Using cached plugin list for dir %s", indexDir)
+		return copyPluginList(cached.list), nil
+	}
+
+	list, err := LoadPluginListFromFS(indexDir)
+	if err != nil {
+		return list, err
+	}
+	pluginListCacheMu.Lock()
+	pluginListCache[indexDir] = cachedPluginList{fingerprint: fingerprint, list: list}
+	pluginListCacheMu.Unlock()
+	return copyPluginList(list), nil
+}
+
+// indexFingerprint returns a string that changes when a file in the plugins
+// directory of the index is added, removed or modified, or when the index
+// tarball is modified.
+func indexFingerprint(indexDir string) (string, error) {
+	if archive, ok := indexArchivePath(indexDir); ok {
+		fi, err := os.Stat(archive)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to open index archive")
+		}
+		return fmt.Sprintf("%s:%d:%d\n", fi.Name(), fi.Size(), fi.ModTime().UnixNano()), nil
+	}
+	files, err := ioutil.ReadDir(filepath.Join(indexDir, "plugins"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open index dir")
+	}
+	var b strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&b, "%s:%d:%d\n", f.Name(), f.Size(), f.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// copyPluginList returns a copy of l so that callers modifying the returned
+// list do not change the cached one.
+func copyPluginList(l index.PluginList) index.PluginList {
+	out := l
+	out.Items = make([]index.Plugin, len(l.Items))
+	copy(out.Items, l.Items)
+	return out
+}
@@ -15,63 +15,276 @@
 package indexscanner
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"sigs.k8s.io/krew/pkg/index"
 
-	"github.com/golang/glog"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/krew/pkg/log"
 )
 
-// LoadPluginListFromFS will parse and retrieve all plugin files.
+// LoadPluginListFromFS will parse and retrieve all plugin files. The files are
+// parsed concurrently and the plugins are sorted by name. indexDir is either a
+// directory or an index tarball, see IndexArchiveName. Files that fail to
+// load or are invalid are skipped with a warning, so that a broken plugin
+// manifest doesn't make the whole index unusable.
 func LoadPluginListFromFS(indexDir string) (index.PluginList, error) {
+	return loadPluginList(indexDir, false)
+}
+
+// LoadPluginListFromFSStrict works like LoadPluginListFromFS, but returns an
+// error listing all the plugin files that fail to load or are invalid.
+func LoadPluginListFromFSStrict(indexDir string) (index.PluginList, error) {
+	return loadPluginList(indexDir, true)
+}
+
+func loadPluginList(indexDir string, strict bool) (index.PluginList, error) {
 	var indexList index.PluginList
 	indexDir, err := filepath.EvalSymlinks(indexDir)
 	if err != nil {
 		return indexList, err
 	}
 
-	files, err := ioutil.ReadDir(filepath.Join(indexDir, "plugins"))
-	if err != nil {
-		return indexList, errors.Wrap(err, "failed to open index dir")
+	var files []string
+	var load func(i int) (index.Plugin, error)
+	if archive, ok := indexArchivePath(indexDir); ok {
+		log.V(4).Infof("Loading the index from archive %s", archive)
+		manifests, err := readIndexArchive(archive, nil)
+		if err != nil {
+			return indexList, err
+		}
+		for _, m := range manifests {
+			files = append(files, m.fileName)
+		}
+		load = func(i int) (index.Plugin, error) { return decodeArchiveManifest(manifests[i]) }
+	} else {
+		items, err := ioutil.ReadDir(filepath.Join(indexDir, "plugins"))
+		if err != nil {
+			return indexList, errors.Wrap(err, "failed to open index dir")
+		}
+		for _, f := range items {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".yaml" {
+				log.V(4).Infof("Skip non-manifest item: %s", f.Name())
+				continue
+			}
+			files = append(files, f.Name())
+		}
+		load = func(i int) (index.Plugin, error) {
+			return LoadPluginFileFromFS(indexDir, strings.TrimSuffix(files[i], filepath.Ext(files[i])))
+		}
+	}
+
+	type result struct {
+		plugin index.Plugin
+		err    error
+	}
+	results := make([]result, len(files))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i].plugin, results[i].err = load(i)
+			}
+		}()
+	}
+	for i := range files {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	var failed, fileNames []string
+	for i, f := range files {
+		if err := results[i].err; err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", f, err))
+			if !strict {
+				log.Warningf("Skipping invalid plugin file %q: %v", f, err)
+			}
+			continue
+		}
+		indexList.Items = append(indexList.Items, results[i].plugin)
+		fileNames = append(fileNames, f)
+	}
+	collisions := findNameCollisions(indexList.Items, fileNames, strict)
+	if strict && len(failed) > 0 {
+		return index.PluginList{}, errors.Errorf("failed to load %d plugin files:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	if strict && len(collisions) > 0 {
+		return index.PluginList{}, errors.Errorf("found %d plugin name collisions:\n  %s", len(collisions), strings.Join(collisions, "\n  "))
+	}
+	if len(failed) > 0 {
+		// Index loading shouldn't fail because of some plugins.
+		log.Warningf("Skipped %d invalid plugin files in %s", len(failed), indexDir)
+	}
+	sort.SliceStable(indexList.Items, func(a, b int) bool {
+		return indexList.Items[a].Name < indexList.Items[b].Name
+	})
+	log.V(4).Infof("Found %d plugins in dir %s", len(indexList.Items), indexDir)
+
+	return indexList, nil
+}
+
+// LoadPluginsFromFS loads the plugins with the given names from the index
+// directory or tarball, keyed by their names. Unlike LoadPluginListFromFS, it
+// only parses the manifests of these plugins, and reads a tarball once, which
+// is cheap for a few plugins of a large index. Plugins that are not in the index
+// are left out, invalid plugin files are skipped with a warning.
+func LoadPluginsFromFS(indexDir string, names []string) (map[string]index.Plugin, error) {
+	out := make(map[string]index.Plugin, len(names))
+	if archive, ok := indexArchivePath(indexDir); ok {
+		want := make(map[string]bool, len(names))
+		for _, name := range names {
+			want[name+".yaml"] = true
+		}
+		manifests, err := readIndexArchive(archive, func(fileName string) bool { return want[fileName] })
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range manifests {
+			p, err := decodeArchiveManifest(m)
+			if err != nil {
+				log.Warningf("Skipping invalid plugin file %q: %v", m.fileName, err)
+				continue
+			}
+			out[p.Name] = p
+		}
+		return out, nil
 	}
 
-	for _, f := range files {
-		if f.IsDir() {
+	for _, name := range names {
+		p, err := LoadPluginFileFromFS(indexDir, name)
+		if os.IsNotExist(err) {
+			log.V(4).Infof("Plugin %q is not in the index %s", name, indexDir)
+			continue
+		} else if err != nil {
+			log.Warningf("Skipping invalid plugin file %q: %v", name+".yaml", err)
 			continue
 		}
+		out[name] = p
+	}
+	return out, nil
+}
 
-		pluginName := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
-		// TODO(lbb): Use go routines to speed up slow FS operations.
-		p, err := LoadPluginFileFromFS(indexDir, pluginName)
+// LoadPluginListFromReader parses all plugins from a stream of plugin manifests
+// separated by "---" lines, e.g. the concatenated files of an index. Unlike
+// LoadPluginListFromFS, it returns an error if any of the manifests fails to
+// load or is invalid, or if plugin names collide. The plugins are sorted by
+// name.
+func LoadPluginListFromReader(r io.Reader) (index.PluginList, error) {
+	var indexList index.PluginList
+	var failed, docNames []string
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	for i := 1; ; i++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return index.PluginList{}, errors.Wrap(err, "failed to read the plugin manifests")
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		docName := fmt.Sprintf("document #%d", i)
+		p, err := DecodePluginFile(bytes.NewReader(doc))
+		if err == nil {
+			err = index.ValidatePlugin(p)
+		}
 		if err != nil {
-			// Index loading shouldn't fail because of one plugin.
-			// Show error instead.
-			glog.Errorf("failed to load file %q, err: %v", pluginName, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", docName, err))
 			continue
 		}
 		indexList.Items = append(indexList.Items, p)
+		docNames = append(docNames, docName)
 	}
-	glog.V(4).Infof("Found %d plugins in dir %s", len(indexList.Items), indexDir)
-
+	if len(failed) > 0 {
+		return index.PluginList{}, errors.Errorf("failed to load %d plugin manifests:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	if collisions := findNameCollisions(indexList.Items, docNames, true); len(collisions) > 0 {
+		return index.PluginList{}, errors.Errorf("found %d plugin name collisions:\n  %s", len(collisions), strings.Join(collisions, "\n  "))
+	}
+	sort.SliceStable(indexList.Items, func(a, b int) bool {
+		return indexList.Items[a].Name < indexList.Items[b].Name
+	})
+	log.V(4).Infof("Read %d plugins from the stream", len(indexList.Items))
 	return indexList, nil
 }
 
+// RenamedPlugins returns the names of the plugins in list by their former
+// names. Former names that are the name of a plugin in list are left out, the
+// plugin with that name takes precedence.
+func RenamedPlugins(list index.PluginList) map[string]string {
+	names := make(map[string]bool, len(list.Items))
+	for _, p := range list.Items {
+		names[p.Name] = true
+	}
+	out := make(map[string]string)
+	for _, p := range list.Items {
+		for _, former := range p.Spec.RenamedFrom {
+			if names[former] {
+				log.V(2).Infof("Ignoring the former name %q of plugin %q, it is the name of another plugin", former, p.Name)
+				continue
+			}
+			if other, ok := out[former]; ok {
+				log.Warningf("Plugins %q and %q were both renamed from %q, ignoring the former name of %q", other, p.Name, former, p.Name)
+				continue
+			}
+			out[former] = p.Name
+		}
+	}
+	return out
+}
+
+// findNameCollisions returns the errors for plugins whose names collide with
+// another plugin's, which is loaded from the file with the same index in
+// fileNames. Names collide if they only differ in case or in dashes and
+// underscores, as their executable links would have the same name on
+// case-insensitive filesystems or after converting dashes to underscores.
+// Colliding plugins are only logged unless strict is set.
+func findNameCollisions(plugins []index.Plugin, fileNames []string, strict bool) []string {
+	var errs []string
+	seen := make(map[string]int)
+	for i, p := range plugins {
+		key := strings.ToLower(strings.Replace(p.Name, "-", "_", -1))
+		j, ok := seen[key]
+		if !ok {
+			seen[key] = i
+			continue
+		}
+		msg := fmt.Sprintf("%s: plugin name %q collides with plugin %q in %s", fileNames[i], p.Name, plugins[j].Name, fileNames[j])
+		errs = append(errs, msg)
+		if !strict {
+			log.Warningf("Plugin name collision in %s", msg)
+		}
+	}
+	return errs
+}
+
 // LoadPluginFileFromFS loads a plugins index file by its name. When plugin
 // file not found, it returns an error that can be checked with os.IsNotExist.
 func LoadPluginFileFromFS(indexDir, pluginName string) (index.Plugin, error) {
-	if !index.IsSafePluginName(pluginName) {
-		return index.Plugin{}, errors.Errorf("plugin name %q not allowed", pluginName)
+	if err := index.ValidatePluginName(pluginName); err != nil {
+		return index.Plugin{}, err
 	}
 
-	glog.V(4).Infof("Reading plugin %q", pluginName)
+	log.V(4).Infof("Reading plugin %q", pluginName)
+	if archive, ok := indexArchivePath(indexDir); ok {
+		return loadPluginFromArchive(archive, pluginName)
+	}
 	indexDir, err := filepath.EvalSymlinks(filepath.Join(indexDir, "plugins"))
 	if err != nil {
 		return index.Plugin{}, err
@@ -15,12 +15,20 @@
 package indexscanner
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/testutil"
 )
 
 func Test_readIndexFile(t *testing.T) {
@@ -90,6 +98,62 @@ func Test_readIndexFile(t *testing.T) {
 	}
 }
 
+func TestDecodePluginFile_requires(t *testing.T) {
+	manifest := `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  shortDescription: foo
+  requires:
+  - bar
+  - baz
+`
+	got, err := DecodePluginFile(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"bar", "baz"}; !reflect.DeepEqual(got.Spec.Requires, want) {
+		t.Errorf("DecodePluginFile() requires = %v, want %v", got.Spec.Requires, want)
+	}
+}
+
+func TestDecodePluginFile_renamedFrom(t *testing.T) {
+	manifest := `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  shortDescription: foo
+  renamedFrom:
+  - old-foo
+`
+	got, err := DecodePluginFile(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"old-foo"}; !reflect.DeepEqual(got.Spec.RenamedFrom, want) {
+		t.Errorf("DecodePluginFile() renamedFrom = %v, want %v", got.Spec.RenamedFrom, want)
+	}
+}
+
+func TestRenamedPlugins(t *testing.T) {
+	plugin := func(name string, renamedFrom ...string) index.Plugin {
+		p := index.Plugin{Spec: index.PluginSpec{RenamedFrom: renamedFrom}}
+		p.Name = name
+		return p
+	}
+	list := index.PluginList{Items: []index.Plugin{
+		plugin("foo", "old-foo", "bar"),
+		plugin("bar"),
+		plugin("baz", "old-foo", "old-baz"),
+	}}
+	want := map[string]string{"old-foo": "foo", "old-baz": "baz"}
+	if got := RenamedPlugins(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("RenamedPlugins() = %v, want %v", got, want)
+	}
+}
+
 func TestLoadIndexListFromFS(t *testing.T) {
 	type args struct {
 		indexDir string
@@ -116,10 +180,250 @@ func TestLoadIndexListFromFS(t *testing.T) {
 				t.Errorf("LoadPluginListFromFS() didn't read enough index files, got %d)", len(got.Items))
 				return
 			}
+			if got.Items[0].Name != "bar" || got.Items[1].Name != "foo" {
+				t.Errorf("LoadPluginListFromFS() returned plugins %q, %q, expected them sorted by name", got.Items[0].Name, got.Items[1].Name)
+			}
 		})
 	}
 }
 
+func TestLoadPluginListFromFSStrict(t *testing.T) {
+	_, err := LoadPluginListFromFSStrict(filepath.Join(testdataPath(t), "testindex"))
+	if err == nil {
+		t.Fatal("LoadPluginListFromFSStrict() expected an error for the invalid plugin files")
+	}
+	for _, s := range []string{"failed to load 3 plugin files", "badplugin.yaml", "badplugin2.yaml", "wrongname.yaml"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("LoadPluginListFromFSStrict() error = %q, expected it to contain %q", err, s)
+		}
+	}
+	if strings.Contains(err.Error(), "notyaml") {
+		t.Errorf("LoadPluginListFromFSStrict() error = %q, files without .yaml extension should be skipped", err)
+	}
+
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	b, err := ioutil.ReadFile(filepath.Join(testdataPath(t), "testindex", "plugins", "foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("plugins/foo.yaml", b)
+	got, err := LoadPluginListFromFSStrict(tmpDir.Root())
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFSStrict() error = %v for a valid index", err)
+	}
+	if len(got.Items) != 1 {
+		t.Fatalf("LoadPluginListFromFSStrict() returned %d plugins, expected 1", len(got.Items))
+	}
+}
+
+func TestLoadPluginListFromFS_nameCollision(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	b, err := ioutil.ReadFile(filepath.Join(testdataPath(t), "testindex", "plugins", "foo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo-bar", "foo_bar", "baz"} {
+		tmpDir.Write("plugins/"+name+".yaml", bytes.Replace(b, []byte("name: foo"), []byte("name: "+name), 1))
+	}
+
+	got, err := LoadPluginListFromFS(tmpDir.Root())
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFS() error = %v", err)
+	}
+	if len(got.Items) != 3 {
+		t.Errorf("LoadPluginListFromFS() returned %d plugins, expected 3", len(got.Items))
+	}
+
+	_, err = LoadPluginListFromFSStrict(tmpDir.Root())
+	if err == nil {
+		t.Fatal("LoadPluginListFromFSStrict() expected an error for colliding plugin names")
+	}
+	for _, s := range []string{"1 plugin name collisions", "foo-bar.yaml", "foo_bar.yaml"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("LoadPluginListFromFSStrict() error = %q, expected it to contain %q", err, s)
+		}
+	}
+}
+
+func BenchmarkLoadPluginListFromFS(b *testing.B) {
+	tmpDir, cleanup := testutil.NewTempDir(b)
+	defer cleanup()
+
+	manifest, err := ioutil.ReadFile(filepath.Join(testdataPath(b), "testindex", "plugins", "foo.yaml"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		name := fmt.Sprintf("plugin-%03d", i)
+		tmpDir.Write("plugins/"+name+".yaml", bytes.Replace(manifest, []byte("name: foo"), []byte("name: "+name), 1))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		got, err := LoadPluginListFromFS(tmpDir.Root())
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(got.Items) != 500 {
+			b.Fatalf("LoadPluginListFromFS() returned %d plugins, expected 500", len(got.Items))
+		}
+	}
+}
+
+// BenchmarkLoadPluginsFromFS compares loading a few installed plugins by name
+// with loading the whole of a large index.
+func BenchmarkLoadPluginsFromFS(b *testing.B) {
+	const indexSize = 2000
+	tmpDir, cleanup := testutil.NewTempDir(b)
+	defer cleanup()
+
+	manifest, err := ioutil.ReadFile(filepath.Join(testdataPath(b), "testindex", "plugins", "foo.yaml"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < indexSize; i++ {
+		name := fmt.Sprintf("plugin-%04d", i)
+		tmpDir.Write("plugins/"+name+".yaml", bytes.Replace(manifest, []byte("name: foo"), []byte("name: "+name), 1))
+	}
+	installed := []string{"plugin-0001", "plugin-0500", "plugin-1999"}
+
+	b.Run("all", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			got, err := LoadPluginListFromFS(tmpDir.Root())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(got.Items) != indexSize {
+				b.Fatalf("LoadPluginListFromFS() returned %d plugins, expected %d", len(got.Items), indexSize)
+			}
+		}
+	})
+	b.Run("installed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			got, err := LoadPluginsFromFS(tmpDir.Root(), installed)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(got) != len(installed) {
+				b.Fatalf("LoadPluginsFromFS() returned %d plugins, expected %d", len(got), len(installed))
+			}
+		}
+	})
+}
+
+func TestLoadPluginListFromReader(t *testing.T) {
+	read := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(testdataPath(t), "testindex", "plugins", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	foo, bar, bad := read("foo.yaml"), read("bar.yaml"), read("badplugin.yaml")
+
+	tests := []struct {
+		name      string
+		in        string
+		wantNames []string
+		wantErr   string
+	}{
+		{"empty", "", nil, ""},
+		{"single", foo, []string{"foo"}, ""},
+		{"sorted", foo + "\n---\n" + bar, []string{"bar", "foo"}, ""},
+		{"leading separator and empty documents", "---\n" + bar + "\n---\n---\n" + foo, []string{"bar", "foo"}, ""},
+		{"invalid", foo + "\n---\n" + bad, nil, "document #2"},
+		{"duplicate", foo + "\n---\n" + foo, nil, "collisions"},
+		{"file operation traversal", strings.Replace(bar, `from: "*"`, `from: "../*"`, 1), nil, "document #1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadPluginListFromReader(strings.NewReader(tt.in))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadPluginListFromReader() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, p := range got.Items {
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("LoadPluginListFromReader() plugins = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestLoadPluginListFromFSCached(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	for _, name := range []string{"foo", "bar"} {
+		b, err := ioutil.ReadFile(filepath.Join(testdataPath(t), "testindex", "plugins", name+".yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpDir.Write("plugins/"+name+".yaml", b)
+	}
+
+	got, err := LoadPluginListFromFSCached(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items) != 2 {
+		t.Fatalf("LoadPluginListFromFSCached() returned %d plugins, expected 2", len(got.Items))
+	}
+
+	// modifying the returned list must not change the cached one
+	got.Items[0].Name = "modified"
+	got, err = LoadPluginListFromFSCached(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range got.Items {
+		if p.Name == "modified" {
+			t.Fatal("LoadPluginListFromFSCached() returned a modified cached list")
+		}
+	}
+
+	// removing a plugin file invalidates the cache
+	if err := os.Remove(tmpDir.Path("plugins/bar.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadPluginListFromFSCached(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items) != 1 || got.Items[0].Name != "foo" {
+		t.Fatalf("LoadPluginListFromFSCached() after removing bar = %v, expected only foo", got.Items)
+	}
+
+	// modifying a plugin file invalidates the cache
+	f := tmpDir.Path("plugins/foo.yaml")
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("plugins/foo.yaml", bytes.Replace(b, []byte("https://example.com/foo"), []byte("https://example.com/changed"), 1))
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(f, later, later); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadPluginListFromFSCached(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items) != 1 || got.Items[0].Spec.Homepage != "https://example.com/changed" {
+		t.Fatalf("LoadPluginListFromFSCached() after modifying foo = %v, expected the updated homepage", got.Items)
+	}
+}
+
 func TestLoadIndexFileFromFS(t *testing.T) {
 	type args struct {
 		indexDir   string
@@ -174,7 +478,7 @@ func TestLoadIndexFileFromFS(t *testing.T) {
 	}
 }
 
-func testdataPath(t *testing.T) string {
+func testdataPath(t testing.TB) string {
 	pwd, err := filepath.Abs(".")
 	if err != nil {
 		t.Fatal(err)
@@ -35,6 +35,15 @@ type PluginSpec struct {
 	Caveats          string `json:"caveats,omitempty"`
 	Homepage         string `json:"homepage,omitempty"`
 
+	// Requires lists the names of the plugins that have to be installed for
+	// the plugin to work. They are installed before the plugin.
+	Requires []string `json:"requires,omitempty"`
+
+	// RenamedFrom lists the former names of the plugin, so that searching
+	// them finds the plugin and plugins installed under them can be
+	// recognized.
+	RenamedFrom []string `json:"renamedFrom,omitempty"`
+
 	Platforms []Platform `json:"platforms,omitempty"`
 }
 
@@ -50,6 +59,21 @@ type Platform struct {
 	// The path is relative to the root of the installation folder.
 	// The binary will be linked after all FileOperations are executed.
 	Bin string `json:"bin"`
+
+	// StripComponents is the number of leading path components removed from
+	// the archive entries when extracting them, like tar's --strip-components.
+	// The From paths of the Files are relative to the stripped archive.
+	StripComponents int `json:"stripComponents,omitempty"`
+
+	// SignatureURI is the URI of a detached signature of the archive. It is
+	// verified before extracting the archive if the user configured a trusted
+	// key.
+	SignatureURI string `json:"signatureURI,omitempty"`
+
+	// PostInstall is the path of a script in the installation folder that is
+	// run after the files are installed, if the user allows hooks. It is run
+	// with sh, or cmd on Windows, in the installation folder.
+	PostInstall string `json:"postInstall,omitempty"`
 }
 
 // FileOperation TODO(lbb)
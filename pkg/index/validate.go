@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/krew/pkg/constants"
 )
 
@@ -31,23 +32,45 @@ var (
 		"LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9"}
 )
 
+// SafePluginNameRules returns a description of the names plugins can have
+// and the regular expression the names must match.
+func SafePluginNameRules() (string, *regexp.Regexp) {
+	return "plugin names may only contain letters (a-z, A-Z), digits (0-9), underscores (_) and dashes (-), " +
+		"and must not be a reserved file name on Windows (" + strings.Join(windowsForbidden, ", ") + ")", safePluginRegexp
+}
+
 // IsSafePluginName checks if the plugin Name is safe to use.
 func IsSafePluginName(name string) bool {
+	return ValidatePluginName(name) == nil
+}
+
+// ValidatePluginName returns an error explaining why the plugin name is not
+// safe to use, or nil if it is.
+func ValidatePluginName(name string) error {
 	if !safePluginRegexp.MatchString(name) {
-		return false
+		return errors.Errorf("the plugin name %q is not allowed, it must match %q: only letters (a-z, A-Z), digits (0-9), underscores (_) and dashes (-) are allowed",
+			name, safePluginRegexp.String())
 	}
 	for _, forbidden := range windowsForbidden {
 		if strings.ToLower(forbidden) == strings.ToLower(name) {
-			return false
+			return errors.Errorf("the plugin name %q is not allowed, it is a reserved file name on Windows", name)
 		}
 	}
-	return true
+	return nil
 }
 
 func isSupportedAPIVersion(apiVersion string) bool {
 	return apiVersion == constants.CurrentAPIVersion
 }
 
+// ValidatePlugin checks that the plugin manifest follows the rules of krew's
+// plugin schema: it has a supported apiVersion and kind, a safe name, a short
+// description, and at least one platform, each with a URI, a valid selector, a
+// bin and file operations that stay within the plugin directory.
+func ValidatePlugin(p Plugin) error {
+	return p.Validate(p.Name)
+}
+
 // Validate TODO(lbb)
 func (p Plugin) Validate(name string) error {
 	if !isSupportedAPIVersion(p.APIVersion) {
@@ -58,8 +81,8 @@ func (p Plugin) Validate(name string) error {
 		return errors.Errorf("plugin manifest has kind=%q, but only %q is supported", p.Kind, constants.PluginKind)
 	}
 
-	if !IsSafePluginName(name) {
-		return errors.Errorf("the plugin name %q is not allowed, must match %q", name, safePluginRegexp.String())
+	if err := ValidatePluginName(name); err != nil {
+		return err
 	}
 	if p.Name != name {
 		return errors.Errorf("plugin should be named %q, not %q", name, p.Name)
@@ -67,6 +90,32 @@ func (p Plugin) Validate(name string) error {
 	if p.Spec.ShortDescription == "" {
 		return errors.New("should have a short description")
 	}
+	seen := make(map[string]bool)
+	for _, req := range p.Spec.Requires {
+		if err := ValidatePluginName(req); err != nil {
+			return errors.Wrap(err, "invalid required plugin")
+		}
+		if req == p.Name {
+			return errors.New("should not require itself")
+		}
+		if seen[req] {
+			return errors.Errorf("required plugin %q is listed more than once", req)
+		}
+		seen[req] = true
+	}
+	formerNames := make(map[string]bool)
+	for _, former := range p.Spec.RenamedFrom {
+		if err := ValidatePluginName(former); err != nil {
+			return errors.Wrap(err, "invalid former plugin name")
+		}
+		if former == p.Name {
+			return errors.New("should not be renamed from itself")
+		}
+		if formerNames[former] {
+			return errors.Errorf("former plugin name %q is listed more than once", former)
+		}
+		formerNames[former] = true
+	}
 	if len(p.Spec.Platforms) == 0 {
 		return errors.New("should have a platform specified")
 	}
@@ -92,5 +141,37 @@ func (p Platform) Validate() error {
 	if len(p.Files) == 0 {
 		return errors.New("can't have a plugin without specifying file operations")
 	}
+	if p.StripComponents < 0 {
+		return errors.Errorf("stripComponents can't be negative, got %d", p.StripComponents)
+	}
+	if _, err := metav1.LabelSelectorAsSelector(p.Selector); err != nil {
+		return errors.Wrap(err, "invalid selector")
+	}
+	if !isSafeRelativePath(p.Bin) {
+		return errors.Errorf("bin %q has to be a relative path within the plugin directory", p.Bin)
+	}
+	if !isSafeRelativePath(p.PostInstall) {
+		return errors.Errorf("postInstall %q has to be a relative path within the plugin directory", p.PostInstall)
+	}
+	for i, fo := range p.Files {
+		if !isSafeRelativePath(fo.From) || !isSafeRelativePath(fo.To) {
+			return errors.Errorf("file operation #%d (from=%q, to=%q) has to use relative paths within the plugin directory", i, fo.From, fo.To)
+		}
+	}
 	return nil
 }
+
+// isSafeRelativePath checks that the slash or backslash separated path is
+// relative and does not traverse to the parent directory.
+func isSafeRelativePath(path string) bool {
+	path = strings.Replace(path, `\`, "/", -1)
+	if strings.HasPrefix(path, "/") || (len(path) >= 2 && path[1] == ':') {
+		return false
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
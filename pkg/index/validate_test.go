@@ -15,6 +15,7 @@
 package index
 
 import (
+	"strings"
 	"testing"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -68,6 +69,59 @@ func Test_IsSafePluginName(t *testing.T) {
 	}
 }
 
+func TestValidatePluginName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{name: "foo"},
+		{name: "foo_bar"},
+		{name: "FOO-9"},
+		{name: "-foo"},
+		{name: "foo-"},
+		{name: "", wantErr: "must match"},
+		{name: "foo.bar", wantErr: "must match"},
+		{name: "..", wantErr: "must match"},
+		{name: ".foo", wantErr: "must match"},
+		{name: "foo/bar", wantErr: "must match"},
+		{name: `foo\bar`, wantErr: "must match"},
+		{name: "foo bar", wantErr: "must match"},
+		{name: "föö", wantErr: "must match"},
+		{name: "con", wantErr: "reserved file name on Windows"},
+		{name: "LPT1", wantErr: "reserved file name on Windows"},
+		{name: "con1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePluginName(tt.name)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePluginName(%q) error = %v", tt.name, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePluginName(%q) error = %v, want containing %q", tt.name, err, tt.wantErr)
+			}
+			if IsSafePluginName(tt.name) {
+				t.Errorf("IsSafePluginName(%q) = true for an invalid name", tt.name)
+			}
+		})
+	}
+}
+
+func TestSafePluginNameRules(t *testing.T) {
+	description, re := SafePluginNameRules()
+	for _, s := range []string{"dashes", "CON", "LPT9"} {
+		if !strings.Contains(description, s) {
+			t.Errorf("SafePluginNameRules() description %q does not contain %q", description, s)
+		}
+	}
+	if !re.MatchString("foo-bar") || re.MatchString("foo.bar") {
+		t.Errorf("SafePluginNameRules() regexp %q does not match the plugin names", re)
+	}
+}
+
 func Test_isSupportedAPIVersion(t *testing.T) {
 	tests := []struct {
 		name string
@@ -295,6 +349,82 @@ func TestPlugin_Validate(t *testing.T) {
 	}
 }
 
+func TestPlugin_Validate_renamedFrom(t *testing.T) {
+	tests := []struct {
+		name        string
+		renamedFrom []string
+		wantErr     bool
+	}{
+		{"none", nil, false},
+		{"valid", []string{"bar", "old-foo"}, false},
+		{"unsafe name", []string{"../bar"}, true},
+		{"itself", []string{"foo"}, true},
+		{"duplicate", []string{"bar", "bar"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Plugin{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: constants.CurrentAPIVersion,
+					Kind:       constants.PluginKind,
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					ShortDescription: "short",
+					RenamedFrom:      tt.renamedFrom,
+					Platforms: []Platform{{
+						URI:    "http://example.com",
+						Sha256: "deadbeef",
+						Files:  []FileOperation{{"", ""}},
+						Bin:    "foo",
+					}},
+				},
+			}
+			if err := p.Validate("foo"); (err != nil) != tt.wantErr {
+				t.Errorf("Plugin.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlugin_Validate_requires(t *testing.T) {
+	tests := []struct {
+		name     string
+		requires []string
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"valid", []string{"bar", "baz"}, false},
+		{"unsafe name", []string{"../bar"}, true},
+		{"itself", []string{"foo"}, true},
+		{"duplicate", []string{"bar", "bar"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Plugin{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: constants.CurrentAPIVersion,
+					Kind:       constants.PluginKind,
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					ShortDescription: "short",
+					Requires:         tt.requires,
+					Platforms: []Platform{{
+						URI:    "http://example.com",
+						Sha256: "deadbeef",
+						Files:  []FileOperation{{"", ""}},
+						Bin:    "foo",
+					}},
+				},
+			}
+			if err := p.Validate("foo"); (err != nil) != tt.wantErr {
+				t.Errorf("Plugin.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestPlatform_Validate(t *testing.T) {
 	type fields struct {
 		URI      string
@@ -302,6 +432,7 @@ func TestPlatform_Validate(t *testing.T) {
 		Selector *metav1.LabelSelector
 		Files    []FileOperation
 		Bin      string
+		Strip    int
 	}
 	tests := []struct {
 		name    string
@@ -362,6 +493,110 @@ func TestPlatform_Validate(t *testing.T) {
 				Bin:      "",
 			},
 			wantErr: true,
+		}, {
+			name: "strip components",
+			fields: fields{
+				URI:      "http://example.com",
+				Sha256:   "deadbeef",
+				Selector: nil,
+				Files:    []FileOperation{{"", ""}},
+				Bin:      "foo",
+				Strip:    1,
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid selector",
+			fields: fields{
+				URI:    "http://example.com",
+				Sha256: "deadbeef",
+				Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key: "os", Operator: metav1.LabelSelectorOpIn, Values: []string{"linux", "darwin"},
+				}}},
+				Files: []FileOperation{{"", ""}},
+				Bin:   "foo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid selector operator",
+			fields: fields{
+				URI:    "http://example.com",
+				Sha256: "deadbeef",
+				Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key: "os", Operator: "Like", Values: []string{"linux"},
+				}}},
+				Files: []FileOperation{{"", ""}},
+				Bin:   "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid selector label",
+			fields: fields{
+				URI:      "http://example.com",
+				Sha256:   "deadbeef",
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"os": "not a label value"}},
+				Files:    []FileOperation{{"", ""}},
+				Bin:      "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "bin outside of the plugin directory",
+			fields: fields{
+				URI:      "http://example.com",
+				Sha256:   "deadbeef",
+				Selector: nil,
+				Files:    []FileOperation{{"", ""}},
+				Bin:      "../foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "file operation from outside of the archive",
+			fields: fields{
+				URI:      "http://example.com",
+				Sha256:   "deadbeef",
+				Selector: nil,
+				Files:    []FileOperation{{From: "../../etc/passwd", To: "."}},
+				Bin:      "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "file operation to an absolute path",
+			fields: fields{
+				URI:      "http://example.com",
+				Sha256:   "deadbeef",
+				Selector: nil,
+				Files:    []FileOperation{{From: "foo", To: "/usr/local/bin"}},
+				Bin:      "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "file operation to a windows absolute path",
+			fields: fields{
+				URI:      "http://example.com",
+				Sha256:   "deadbeef",
+				Selector: nil,
+				Files:    []FileOperation{{From: "foo.exe", To: `C:\Windows`}},
+				Bin:      "foo.exe",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative strip components",
+			fields: fields{
+				URI:      "http://example.com",
+				Sha256:   "deadbeef",
+				Selector: nil,
+				Files:    []FileOperation{{"", ""}},
+				Bin:      "foo",
+				Strip:    -1,
+			},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
@@ -372,6 +607,8 @@ func TestPlatform_Validate(t *testing.T) {
 				Selector: tt.fields.Selector,
 				Files:    tt.fields.Files,
 				Bin:      tt.fields.Bin,
+
+				StripComponents: tt.fields.Strip,
 			}
 			if err := p.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Platform.Validate() error = %v, wantErr %v", err, tt.wantErr)
@@ -379,3 +616,160 @@ func TestPlatform_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePlugin(t *testing.T) {
+	valid := func() Plugin {
+		return Plugin{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: constants.CurrentAPIVersion,
+				Kind:       constants.PluginKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+			Spec: PluginSpec{
+				ShortDescription: "short",
+				Platforms: []Platform{{
+					URI:    "http://example.com",
+					Sha256: "deadbeef",
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"os": "linux"},
+					},
+					Files: []FileOperation{{From: "bin/*", To: "."}, {From: "LICENSE", To: "doc/LICENSE"}},
+					Bin:   "foo",
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(p *Plugin)
+		wantErr string
+	}{
+		{name: "valid", modify: func(p *Plugin) {}},
+		{name: "valid without selector", modify: func(p *Plugin) { p.Spec.Platforms[0].Selector = nil }},
+		{name: "valid nested bin", modify: func(p *Plugin) { p.Spec.Platforms[0].Bin = "bin/foo" }},
+		{
+			name:    "unsupported api version",
+			modify:  func(p *Plugin) { p.APIVersion = "core/v1" },
+			wantErr: "apiVersion",
+		},
+		{
+			name:    "wrong kind",
+			modify:  func(p *Plugin) { p.Kind = "Pod" },
+			wantErr: "kind",
+		},
+		{
+			name:    "unsafe name",
+			modify:  func(p *Plugin) { p.Name = "../foo" },
+			wantErr: "is not allowed",
+		},
+		{
+			name:    "reserved windows name",
+			modify:  func(p *Plugin) { p.Name = "con" },
+			wantErr: "reserved file name",
+		},
+		{
+			name:    "no short description",
+			modify:  func(p *Plugin) { p.Spec.ShortDescription = "" },
+			wantErr: "short description",
+		},
+		{
+			name:    "no platforms",
+			modify:  func(p *Plugin) { p.Spec.Platforms = nil },
+			wantErr: "should have a platform",
+		},
+		{
+			name:    "platform without uri",
+			modify:  func(p *Plugin) { p.Spec.Platforms[0].URI = "" },
+			wantErr: "URI has to be set",
+		},
+		{
+			name:    "platform without bin",
+			modify:  func(p *Plugin) { p.Spec.Platforms[0].Bin = "" },
+			wantErr: "bin has to be set",
+		},
+		{
+			name: "platform with an invalid selector",
+			modify: func(p *Plugin) {
+				p.Spec.Platforms[0].Selector.MatchExpressions = []metav1.LabelSelectorRequirement{{
+					Key: "arch", Operator: metav1.LabelSelectorOpExists, Values: []string{"amd64"},
+				}}
+			},
+			wantErr: "invalid selector",
+		},
+		{
+			name:    "bin with traversal",
+			modify:  func(p *Plugin) { p.Spec.Platforms[0].Bin = "bin/../../foo" },
+			wantErr: "relative path within the plugin directory",
+		},
+		{name: "valid post-install hook", modify: func(p *Plugin) { p.Spec.Platforms[0].PostInstall = "hooks/post-install.sh" }},
+		{
+			name:    "post-install hook outside of the plugin directory",
+			modify:  func(p *Plugin) { p.Spec.Platforms[0].PostInstall = "../post-install.sh" },
+			wantErr: "postInstall",
+		},
+		{
+			name:    "file operation from outside of the archive",
+			modify:  func(p *Plugin) { p.Spec.Platforms[0].Files[1].From = "../LICENSE" },
+			wantErr: "file operation #1",
+		},
+		{
+			name:    "file operation to outside of the plugin directory",
+			modify:  func(p *Plugin) { p.Spec.Platforms[0].Files[0].To = `..\..\bin` },
+			wantErr: "file operation #0",
+		},
+		{
+			name: "second platform invalid",
+			modify: func(p *Plugin) {
+				pl := p.Spec.Platforms[0]
+				pl.Files = []FileOperation{{From: "/etc/passwd", To: "."}}
+				p.Spec.Platforms = append(p.Spec.Platforms, pl)
+			},
+			wantErr: "file operation #0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid()
+			tt.modify(&p)
+			err := ValidatePlugin(p)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidatePlugin() error = %v, expected a valid plugin", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidatePlugin() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_isSafeRelativePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"", true},
+		{".", true},
+		{"foo", true},
+		{"bin/foo", true},
+		{"./bin/*", true},
+		{`bin\foo.exe`, true},
+		{"foo..bar", true},
+		{"..", false},
+		{"../foo", false},
+		{"bin/../../foo", false},
+		{`..\foo`, false},
+		{"/foo", false},
+		{`\foo`, false},
+		{`C:\foo`, false},
+		{"c:foo", false},
+	}
+	for _, tt := range tests {
+		if got := isSafeRelativePath(tt.path); got != tt.want {
+			t.Errorf("isSafeRelativePath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"strings"
+
+	"sigs.k8s.io/krew/pkg/index"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// ResolveDependencies returns plugins together with the plugins they require,
+// directly or indirectly, ordered so that every plugin comes after the plugins
+// it requires. Required plugins that are not in plugins are loaded with load.
+// Each plugin is returned once, and plugins are otherwise kept in their order.
+// It returns an error listing the cycle if plugins require each other.
+func ResolveDependencies(plugins []index.Plugin, load func(name string) (index.Plugin, error)) ([]index.Plugin, error) {
+	known := make(map[string]index.Plugin, len(plugins))
+	for _, p := range plugins {
+		known[p.Name] = p
+	}
+
+	var out []index.Plugin
+	done := make(map[string]bool)
+	var path []string
+	var visit func(p index.Plugin) error
+	visit = func(p index.Plugin) error {
+		if done[p.Name] {
+			return nil
+		}
+		for i, name := range path {
+			if name == p.Name {
+				cycle := append(append([]string{}, path[i:]...), p.Name)
+				return errors.Errorf("plugins have a dependency cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+		path = append(path, p.Name)
+		for _, name := range p.Spec.Requires {
+			dep, ok := known[name]
+			if !ok {
+				log.V(2).Infof("Loading plugin %s required by %s", name, p.Name)
+				var err error
+				if dep, err = load(name); err != nil {
+					return errors.Wrapf(err, "failed to load plugin %q required by %q", name, p.Name)
+				}
+				known[name] = dep
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		done[p.Name] = true
+		out = append(out, p)
+		return nil
+	}
+
+	for _, p := range plugins {
+		if err := visit(p); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/index"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveDependencies(t *testing.T) {
+	plugin := func(name string, requires ...string) index.Plugin {
+		return index.Plugin{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       index.PluginSpec{Requires: requires},
+		}
+	}
+	idx := map[string]index.Plugin{
+		"a":      plugin("a", "b", "c"),
+		"b":      plugin("b", "c"),
+		"c":      plugin("c"),
+		"d":      plugin("d"),
+		"self":   plugin("self", "self"),
+		"cycle1": plugin("cycle1", "cycle2"),
+		"cycle2": plugin("cycle2", "cycle3"),
+		"cycle3": plugin("cycle3", "cycle1"),
+		"broken": plugin("broken", "missing"),
+	}
+	load := func(name string) (index.Plugin, error) {
+		p, ok := idx[name]
+		if !ok {
+			return index.Plugin{}, os.ErrNotExist
+		}
+		return p, nil
+	}
+
+	tests := []struct {
+		name      string
+		requested []string
+		want      []string
+		wantErr   string
+	}{
+		{name: "no dependencies", requested: []string{"d", "c"}, want: []string{"d", "c"}},
+		{name: "transitive", requested: []string{"a"}, want: []string{"c", "b", "a"}},
+		{name: "requested dependency", requested: []string{"d", "a", "c"}, want: []string{"d", "c", "b", "a"}},
+		{name: "duplicates", requested: []string{"c", "c"}, want: []string{"c"}},
+		{name: "self", requested: []string{"self"}, wantErr: "self -> self"},
+		{name: "cycle", requested: []string{"d", "cycle2"}, wantErr: "cycle2 -> cycle3 -> cycle1 -> cycle2"},
+		{name: "missing", requested: []string{"broken"}, wantErr: `plugin "missing" required by "broken"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []index.Plugin
+			for _, name := range tt.requested {
+				requested = append(requested, idx[name])
+			}
+			got, err := ResolveDependencies(requested, load)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveDependencies() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, p := range got {
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("ResolveDependencies() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
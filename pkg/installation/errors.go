@@ -0,0 +1,80 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/download"
+)
+
+// Plugin Lifecycle Errors
+var (
+	ErrAlreadyInstalled   = errors.New("can't install, the newest version is already installed")
+	ErrNotInstalled       = errors.New("plugin is not installed")
+	ErrIsAlreadyUpgraded  = errors.New("can't upgrade, the newest version is already installed")
+	ErrNoMatchingPlatform = errors.New("no matching platform found")
+	// ErrChecksumMismatch is matched by errors.Is if a downloaded archive
+	// doesn't have the sha256 of the platform, errors.As finds the
+	// *download.ChecksumError with the checksums.
+	ErrChecksumMismatch = download.ErrChecksumMismatch
+
+	// ErrIsNotInstalled is the previous name of ErrNotInstalled.
+	//
+	// Deprecated: use ErrNotInstalled.
+	ErrIsNotInstalled = ErrNotInstalled
+	// ErrIsAlreadyInstalled is the previous name of ErrAlreadyInstalled.
+	//
+	// Deprecated: use ErrAlreadyInstalled.
+	ErrIsAlreadyInstalled = ErrAlreadyInstalled
+)
+
+// causeError lets errors.Is and errors.As see the cause of an error wrapped
+// with github.com/pkg/errors, whose vendored version doesn't implement Unwrap.
+type causeError struct {
+	err error
+}
+
+func (e *causeError) Error() string { return e.err.Error() }
+
+// Cause keeps errors.Cause working on the wrapped error.
+func (e *causeError) Cause() error { return e.err }
+
+// Unwrap returns the root cause, skipping the wrappers that only add context.
+func (e *causeError) Unwrap() error { return errors.Cause(e.err) }
+
+// Format prints the wrapped error, so that %+v still has the stack trace.
+func (e *causeError) Format(s fmt.State, verb rune) {
+	if f, ok := e.err.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	io.WriteString(s, e.err.Error())
+}
+
+// typedError prepares an error returned by this package for errors.Is and
+// errors.As. Errors without a cause, such as the sentinel errors, are returned
+// as is so that they can still be compared with ==.
+func typedError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*causeError); ok || errors.Cause(err) == err {
+		return err
+	}
+	return &causeError{err: err}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_typedError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"sentinel", ErrNotInstalled, ErrNotInstalled},
+		{"wrapped sentinel", errors.Wrap(ErrNoMatchingPlatform, "failed to get the download target"), ErrNoMatchingPlatform},
+		{"wrapped twice", errors.Wrap(errors.Wrap(ErrAlreadyInstalled, "a"), "b"), ErrAlreadyInstalled},
+		{"wrapped context error", errors.Wrap(context.Canceled, "failed to download"), context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := typedError(tt.err)
+			if !stderrors.Is(got, tt.want) {
+				t.Fatalf("errors.Is(typedError(%v), %v) = false", tt.err, tt.want)
+			}
+			if errors.Cause(got) != tt.want {
+				t.Fatalf("errors.Cause(typedError(%v)) = %v, want %v", tt.err, errors.Cause(got), tt.want)
+			}
+			if got.Error() != tt.err.Error() {
+				t.Fatalf("typedError() message = %q, want %q", got.Error(), tt.err.Error())
+			}
+			if again := typedError(got); again != got {
+				t.Fatalf("typedError() wrapped its own result again")
+			}
+		})
+	}
+
+	if typedError(nil) != nil {
+		t.Fatal("typedError(nil) should be nil")
+	}
+	if err := typedError(ErrNotInstalled); err != ErrNotInstalled {
+		t.Fatalf("typedError() of a sentinel = %#v, want the sentinel itself", err)
+	}
+	if s := fmt.Sprintf("%+v", typedError(errors.Wrap(ErrNotInstalled, "context"))); !strings.Contains(s, "errors_test.go") {
+		t.Fatalf("%%+v of a typed error should have the stack trace, got %q", s)
+	}
+}
+
+func TestUpgrade_notInstalled(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	p, err := environment.NewPaths(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Upgrade(p, testPlugin(), UpgradeOpts{})
+	if !stderrors.Is(err, ErrNotInstalled) {
+		t.Fatalf("Upgrade() of a plugin that is not installed error = %v, want %v", err, ErrNotInstalled)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
+	"sigs.k8s.io/krew/pkg/pathutil"
+)
+
+// RemoveOldVersions removes the version directories of the installed plugins
+// in installDir other than the version their link in binDir points to, such
+// as the directories left behind by upgrades. The version of krew that is
+// running is kept as well. Plugins without a working link are skipped. If
+// dryRun is set, nothing is removed. It returns the paths of the (to be)
+// removed directories.
+func RemoveOldVersions(installDir, binDir string, dryRun bool) ([]string, error) {
+	items, err := ioutil.ReadDir(installDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read install dir")
+	}
+	var removed []string
+	for _, item := range items {
+		name := item.Name()
+		if !item.IsDir() || index.ValidatePluginName(name) != nil {
+			log.V(4).Infof("Skip item that is not a plugin directory: %s", name)
+			continue
+		}
+		version, ok, err := findInstalledPluginVersion(installDir, binDir, name)
+		if err != nil {
+			log.Warningf("Skipping plugin %s: %v", name, err)
+			continue
+		}
+		if !ok {
+			log.V(2).Infof("Skipping plugin %s, it has no link", name)
+			continue
+		}
+		keep := map[string]bool{version: true}
+		if name == krewPluginName {
+			// the running executable can't be deleted on Windows
+			if v, ok := executedKrewVersion(installDir); ok {
+				keep[v] = true
+			}
+		}
+
+		pluginDir := filepath.Join(installDir, name)
+		versions, err := ioutil.ReadDir(pluginDir)
+		if err != nil {
+			return removed, errors.Wrapf(err, "failed to read the directory of plugin %s", name)
+		}
+		for _, v := range versions {
+			// hidden directories are the staging directories of
+			// installations in progress
+			if !v.IsDir() || keep[v.Name()] || strings.HasPrefix(v.Name(), ".") {
+				continue
+			}
+			dir := filepath.Join(pluginDir, v.Name())
+			if elems, ok := pathutil.IsSubPath(installDir, dir); !ok || len(elems) != 2 {
+				return removed, errors.Errorf("refusing to delete %q, it is not a version directory in %q", dir, installDir)
+			}
+			if !dryRun {
+				log.V(1).Infof("Removing old version %s of plugin %s", v.Name(), name)
+				if err := os.RemoveAll(dir); err != nil {
+					return removed, errors.Wrapf(err, "failed to delete %q", dir)
+				}
+			}
+			removed = append(removed, dir)
+		}
+	}
+	return removed, nil
+}
+
+// executedKrewVersion returns the installed version of krew that is running,
+// and false if the running executable is not an installed krew version.
+func executedKrewVersion(installDir string) (string, bool) {
+	execPath, err := os.Executable()
+	if err != nil {
+		log.V(2).Infof("Failed to get krew's own executable path: %v", err)
+		return "", false
+	}
+	version, ok, err := environment.GetExecutedVersion(installDir, execPath, environment.Realpath)
+	if err != nil {
+		log.V(2).Infof("Failed to find the running krew version: %v", err)
+		return "", false
+	}
+	return version, ok
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func TestRemoveOldVersions(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		t.Run(map[bool]string{false: "remove", true: "dry run"}[dryRun], func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			installDir, binDir := installPlugins(t, tmpDir, 2)
+			tmpDir.Write("store/plugin-0/v0/kubectl-plugin-0", nil)
+			tmpDir.Write("store/plugin-0/v0.5/kubectl-plugin-0", nil)
+			tmpDir.Write("store/plugin-0/receipt.yaml", []byte("plugin: plugin-0\nversion: v1\n"))
+			// staging directory of an installation in progress
+			tmpDir.Write("store/plugin-1/.staging-123/kubectl-plugin-1", nil)
+			// plugins without a link are left alone
+			tmpDir.Write("store/unlinked/v1/kubectl-unlinked", nil)
+			tmpDir.Write("store/unlinked/v2/kubectl-unlinked", nil)
+
+			got, err := RemoveOldVersions(installDir, binDir, dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{tmpDir.Path("store/plugin-0/v0"), tmpDir.Path("store/plugin-0/v0.5")}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("RemoveOldVersions() = %v, want %v", got, want)
+			}
+
+			for _, path := range want {
+				if _, err := os.Stat(path); os.IsNotExist(err) == dryRun {
+					t.Errorf("after RemoveOldVersions(dryRun=%v), %q exists = %v", dryRun, path, dryRun)
+				}
+			}
+			for _, path := range []string{
+				"store/plugin-0/v1/kubectl-plugin-0",
+				"store/plugin-0/receipt.yaml",
+				"store/plugin-1/v1/kubectl-plugin-1",
+				"store/plugin-1/.staging-123",
+				"store/unlinked/v1",
+				"store/unlinked/v2",
+			} {
+				if _, err := os.Stat(tmpDir.Path(path)); err != nil {
+					t.Errorf("expected %q to be kept: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveOldVersions_suspiciousLink(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	installDir, binDir := installPlugins(t, tmpDir, 0)
+	tmpDir.Write("store/foo/v1/kubectl-foo", nil)
+	tmpDir.Write("elsewhere/kubectl-foo", nil)
+	if err := os.Symlink(tmpDir.Path("elsewhere/kubectl-foo"), filepath.Join(binDir, pluginNameToBin("foo", isWindows()))); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := RemoveOldVersions(installDir, binDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("RemoveOldVersions() = %v, expected the plugin with a suspicious link to be skipped", got)
+	}
+	if _, err := os.Stat(tmpDir.Path("store/foo/v1")); err != nil {
+		t.Errorf("expected the version directory to be kept: %v", err)
+	}
+}
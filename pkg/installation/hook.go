@@ -0,0 +1,131 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+	"sigs.k8s.io/krew/pkg/pathutil"
+)
+
+// hookOpts configures how the post-install hooks of plugins are run.
+type hookOpts struct {
+	// allow runs the hooks, otherwise they are skipped with a warning.
+	allow bool
+	// output, if set, receives the output of the hooks while they run.
+	output io.Writer
+}
+
+// hookEnvVars are the environment variables passed on to hooks, the others
+// are not.
+var hookEnvVars = []string{"PATH", "HOME", "USERPROFILE", "SYSTEMROOT", "TMPDIR", "TEMP", "TMP"}
+
+// HookError is returned if the post-install hook of a plugin fails.
+type HookError struct {
+	Plugin string
+	Script string
+	// ExitCode is the exit code of the hook, or -1 if it could not be run or
+	// was killed.
+	ExitCode int
+	// Output is the combined stdout and stderr of the hook.
+	Output string
+	Err    error
+}
+
+func (e *HookError) Error() string {
+	msg := fmt.Sprintf("post-install hook %q of plugin %q failed", e.Script, e.Plugin)
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" with exit code %d", e.ExitCode)
+	} else {
+		msg += ": " + e.Err.Error()
+	}
+	if out := strings.TrimSpace(e.Output); out != "" {
+		msg += ", output:\n" + out
+	}
+	return msg
+}
+
+// runPostInstallHook runs the post-install script of the plugin installed in
+// dir, with dir as the working directory and only a few of krew's environment
+// variables. The script must be a file within dir.
+func runPostInstallHook(ctx context.Context, plugin, version, dir, script string, hooks hookOpts) error {
+	if script == "" {
+		return nil
+	}
+	if !hooks.allow {
+		log.Warningf("Skipping the post-install hook %q of plugin %q, pass --allow-hooks to run it", script, plugin)
+		return nil
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the absolute path of %q", dir)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(script))
+	if _, ok := pathutil.IsSubPath(dir, path); !ok {
+		return errors.Errorf("post-install hook %q of plugin %q is outside of the plugin directory", script, plugin)
+	}
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+		return errors.Errorf("post-install hook %q of plugin %q is not a file in the plugin archive", script, plugin)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", path)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/c", path)
+	}
+	cmd.Dir = dir
+	cmd.Env = hookEnv(plugin, version, dir)
+	var out bytes.Buffer
+	w := io.Writer(&out)
+	if hooks.output != nil {
+		w = io.MultiWriter(&out, hooks.output)
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+
+	log.V(1).Infof("Running the post-install hook %q of plugin %q", script, plugin)
+	if err := cmd.Run(); err != nil {
+		exitCode := -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		return &HookError{Plugin: plugin, Script: script, ExitCode: exitCode, Output: out.String(), Err: err}
+	}
+	log.V(2).Infof("Post-install hook of plugin %q succeeded, output: %s", plugin, out.String())
+	return nil
+}
+
+// hookEnv returns the environment of the hooks: hookEnvVars from krew's
+// environment and the plugin's name, version and directory.
+func hookEnv(plugin, version, dir string) []string {
+	var env []string
+	for _, name := range hookEnvVars {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	return append(env,
+		"KREW_PLUGIN_NAME="+plugin,
+		"KREW_PLUGIN_VERSION="+version,
+		"KREW_PLUGIN_DIR="+dir)
+}
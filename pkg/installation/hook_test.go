@@ -0,0 +1,210 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+// hookPluginOpts returns the options to install a plugin whose archive has the
+// post-install hook script hook.sh.
+func hookPluginOpts(t *testing.T, tmpDir *testutil.TempDir, hook string) InstallOpts {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{
+		"kubectl-foo": "#!/bin/sh",
+		"hook.sh":     hook,
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("foo.tar.gz", buf.Bytes())
+	sum := sha256.Sum256(buf.Bytes())
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = tmpDir.Path("foo.tar.gz")
+	plugin.Spec.Platforms[0].Sha256 = hex.EncodeToString(sum[:])
+	plugin.Spec.Platforms[0].PostInstall = "hook.sh"
+	opts := InstallOpts{
+		Plugin:         plugin,
+		InstallPath:    tmpDir.Path("store"),
+		BinPath:        tmpDir.Path("bin"),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllowLocalURIs: true,
+	}
+	if err := os.MkdirAll(opts.BinPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestInstallPlugin_postInstallHook(t *testing.T) {
+	if isWindows() {
+		t.Skip("the test hooks are shell scripts")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	os.Setenv("KREW_TEST_SECRET", "secret")
+	defer os.Unsetenv("KREW_TEST_SECRET")
+
+	opts := hookPluginOpts(t, tmpDir, `echo "generating completion"
+echo "complete -F _foo kubectl-foo" > completion.bash
+echo "$KREW_PLUGIN_NAME $KREW_TEST_SECRET" > env
+`)
+	var out bytes.Buffer
+	opts.AllowHooks = true
+	opts.HookOutput = &out
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+
+	installDir := tmpDir.Path(filepath.Join("store", "foo", opts.Plugin.Spec.Platforms[0].Sha256))
+	if _, err := os.Stat(filepath.Join(installDir, "completion.bash")); err != nil {
+		t.Errorf("expected the hook to create a file in the installation directory: %v", err)
+	}
+	env, err := ioutil.ReadFile(filepath.Join(installDir, "env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(env)), "foo"; got != want {
+		t.Errorf("hook environment = %q, want %q without krew's other environment variables", got, want)
+	}
+	if !strings.Contains(out.String(), "generating completion") {
+		t.Errorf("hook output = %q, expected the output of the hook", out.String())
+	}
+	if _, err := os.Lstat(filepath.Join(opts.BinPath, "kubectl-foo")); err != nil {
+		t.Errorf("expected the plugin to be linked: %v", err)
+	}
+}
+
+func TestInstallPlugin_failingPostInstallHook(t *testing.T) {
+	if isWindows() {
+		t.Skip("the test hooks are shell scripts")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	opts := hookPluginOpts(t, tmpDir, "echo boom >&2\nexit 3\n")
+	opts.AllowHooks = true
+	err := InstallPlugin(opts)
+	var hookErr *HookError
+	if !stderrors.As(err, &hookErr) {
+		t.Fatalf("InstallPlugin() error = %v, want a *HookError", err)
+	}
+	if hookErr.ExitCode != 3 {
+		t.Errorf("HookError.ExitCode = %d, want 3", hookErr.ExitCode)
+	}
+	if !strings.Contains(hookErr.Output, "boom") || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("InstallPlugin() error = %v, expected it to contain the output of the hook", err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.InstallPath, "foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the plugin directory to be removed, stat error = %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(opts.BinPath, "kubectl-foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the plugin not to be linked, stat error = %v", err)
+	}
+}
+
+func TestInstallPlugin_forceReinstallFailingPostInstallHook(t *testing.T) {
+	if isWindows() {
+		t.Skip("the test hooks are shell scripts")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	// installed without running the hook, then reinstalled with it
+	opts := hookPluginOpts(t, tmpDir, "echo boom >&2\nexit 3\n")
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	installDir := tmpDir.Path(filepath.Join("store", "foo", opts.Plugin.Spec.Platforms[0].Sha256))
+	tmpDir.Write(filepath.Join("store", "foo", opts.Plugin.Spec.Platforms[0].Sha256, "installed"), nil)
+
+	opts.Force = true
+	opts.AllowHooks = true
+	err := InstallPlugin(opts)
+	var hookErr *HookError
+	if !stderrors.As(err, &hookErr) {
+		t.Fatalf("InstallPlugin() error = %v, want a *HookError", err)
+	}
+	if _, err := os.Stat(filepath.Join(installDir, "installed")); err != nil {
+		t.Errorf("expected the replaced installation to be restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(installDir, "kubectl-foo")); err != nil {
+		t.Errorf("expected the linked executable to be restored: %v", err)
+	}
+	entries, err := ioutil.ReadDir(tmpDir.Path(filepath.Join("store", "foo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("expected no temporary directory to be left in the plugin directory, found %q", e.Name())
+		}
+	}
+	if _, ok, err := findInstalledPluginVersion(opts.InstallPath, opts.BinPath, "foo"); err != nil || !ok {
+		t.Errorf("findInstalledPluginVersion() = (%v, %v), expected the plugin to stay installed", ok, err)
+	}
+}
+
+func TestInstallPlugin_postInstallHookNotAllowed(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	opts := hookPluginOpts(t, tmpDir, "echo ran > ran\nexit 1\n")
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	installDir := tmpDir.Path(filepath.Join("store", "foo", opts.Plugin.Spec.Platforms[0].Sha256))
+	if _, err := os.Stat(filepath.Join(installDir, "ran")); !os.IsNotExist(err) {
+		t.Errorf("expected the hook not to run without AllowHooks, stat error = %v", err)
+	}
+}
+
+func TestInstallPlugin_missingPostInstallHook(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	opts := hookPluginOpts(t, tmpDir, "")
+	opts.Plugin.Spec.Platforms[0].PostInstall = "not-found.sh"
+	opts.AllowHooks = true
+	err := InstallPlugin(opts)
+	if err == nil || !strings.Contains(err.Error(), "not a file in the plugin archive") {
+		t.Fatalf("InstallPlugin() error = %v, expected the hook to be missing", err)
+	}
+}
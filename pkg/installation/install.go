@@ -15,6 +15,10 @@
 package installation
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -24,63 +28,433 @@ import (
 	"sigs.k8s.io/krew/pkg/download"
 	"sigs.k8s.io/krew/pkg/environment"
 	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
 	"sigs.k8s.io/krew/pkg/pathutil"
-
-	"github.com/golang/glog"
-)
-
-// Plugin Lifecycle Errors
-var (
-	ErrIsAlreadyInstalled = errors.New("can't install, the newest version is already installed")
-	ErrIsNotInstalled     = errors.New("plugin is not installed")
-	ErrIsAlreadyUpgraded  = errors.New("can't upgrade, the newest version is already installed")
 )
 
 const (
 	krewPluginName = "krew"
 )
 
-func downloadAndMove(version, uri string, fos []index.FileOperation, downloadPath, installPath, forceDownloadFile string) (dst string, err error) {
-	glog.V(3).Infof("Creating download dir %q", downloadPath)
-	if err = os.MkdirAll(downloadPath, 0755); err != nil {
-		return "", errors.Wrapf(err, "could not create download path %q", downloadPath)
+// fetchOpts configures how the plugin archives are fetched.
+type fetchOpts struct {
+	// archiveFileOverride, if set, is a local file used instead of the URI.
+	archiveFileOverride string
+	// retries is the number of times a failed download is retried.
+	retries int
+	// httpClient is used for downloading http(s) URIs, the default client
+	// is used if nil.
+	httpClient *http.Client
+	// progress, if set, receives the progress of http(s) downloads.
+	progress io.Writer
+	// mirrors rewrite the URI before it is downloaded.
+	mirrors []download.MirrorRule
+	// allowLocalURIs reads file:// URIs and absolute paths from the local
+	// filesystem.
+	allowLocalURIs bool
+	// extractLimits cap the extraction of the archive, the zero value uses
+	// download.DefaultExtractLimits.
+	extractLimits download.ExtractLimits
+	// resume continues retried http(s) downloads where they stopped, also the
+	// ones of earlier installations whose partial download is kept in the
+	// download path of the plugin.
+	resume bool
+	// signatureVerifier, if set, verifies the signature of archives whose
+	// platform has a signature URI.
+	signatureVerifier download.SignatureVerifier
+	// diskSpace, if set, returns the available disk space to check before
+	// extracting the archive.
+	diskSpace download.DiskSpaceFunc
+	// goos is the OS the archive is installed for, the executable of a
+	// windows archive doesn't need to be executable and is linked with the
+	// .exe extension.
+	goos string
+}
+
+// downloadAndMove downloads and extracts the platform to the version directory
+// in installPath, where setup, if set, is run as in moveToInstallDir.
+func downloadAndMove(ctx context.Context, version string, platform index.Platform, downloadPath, installPath string, fetch fetchOpts, setup func(dst string) error) (dst string, err error) {
+	sha256, uri := platform.Sha256, platform.URI
+	// the archive is extracted to a directory that is always removed, the
+	// partial downloads to resume are kept in downloadPath next to it
+	extractPath := filepath.Join(downloadPath, "extract")
+	log.V(3).Infof("Creating download dir %q", extractPath)
+	if err = os.RemoveAll(extractPath); err != nil {
+		return "", errors.Wrapf(err, "could not clean up download path %q", extractPath)
+	}
+	if err = os.MkdirAll(extractPath, 0755); err != nil {
+		return "", errors.Wrapf(err, "could not create download path %q", extractPath)
 	}
-	defer os.RemoveAll(downloadPath)
+	defer func() {
+		os.RemoveAll(extractPath)
+		// only removed if no partial download is kept
+		os.Remove(downloadPath)
+	}()
 
-	var fetcher download.Fetcher = download.HTTPFetcher{}
-	if forceDownloadFile != "" {
-		fetcher = download.NewFileFetcher(forceDownloadFile)
+	uri = download.RewriteURI(uri, fetch.mirrors)
+	fetcher := download.NewURIFetcher(fetch.httpClient, fetch.retries, fetch.progress)
+	if fetch.resume {
+		fetcher = download.NewResumingURIFetcher(fetch.httpClient, fetch.retries, fetch.progress, downloadPath)
+	}
+	if fetch.allowLocalURIs {
+		fetcher = download.AllowLocalURIs(fetcher)
+	}
+	if fetch.archiveFileOverride != "" {
+		fetcher = download.NewFileFetcher(fetch.archiveFileOverride)
 	}
 
-	verifier := download.NewSha256Verifier(version)
-	if err := download.NewDownloader(verifier, fetcher).Get(uri, downloadPath); err != nil {
+	var verifier download.Verifier
+	if sha256 == "" {
+		log.Warningf("No sha256 checksum specified for %q, the download will not be verified", uri)
+		verifier = download.NewInsecureVerifier()
+	} else {
+		verifier = download.NewSha256Verifier(sha256)
+	}
+	limits := fetch.extractLimits
+	if limits == (download.ExtractLimits{}) {
+		limits = download.DefaultExtractLimits
+	}
+	signatureURI := platform.SignatureURI
+	if fetch.archiveFileOverride != "" {
+		// the signature is for the archive at the URI
+		signatureURI = ""
+	} else if signatureURI != "" {
+		signatureURI = download.RewriteURI(signatureURI, fetch.mirrors)
+	}
+	downloader := download.NewDownloader(verifier, fetcher).
+		WithExtractLimits(limits).
+		WithStripComponents(platform.StripComponents).
+		WithSignature(signatureURI, fetch.signatureVerifier).
+		WithDiskSpaceCheck(fetch.diskSpace)
+	if err := downloader.GetContext(ctx, uri, extractPath); err != nil {
 		return "", errors.Wrap(err, "failed to download and verify file")
 	}
-	return moveToInstallDir(downloadPath, installPath, version, fos)
+	if err := ctx.Err(); err != nil {
+		return "", errors.Wrap(err, "installation was interrupted")
+	}
+	return moveToInstallDir(extractPath, installPath, version, platform.Bin, fetch.goos == "windows", platform.Files, setup)
+}
+
+// InstallOpts specifies a plugin and the locations to install it with
+// InstallPlugin.
+type InstallOpts struct {
+	// Plugin is the parsed manifest of the plugin to install.
+	Plugin index.Plugin
+	// Index is the name of the index the manifest is from, which is recorded
+	// in the installation receipt.
+	Index string
+
+	// InstallPath is the base directory for plugin installations. The plugin
+	// is installed to {InstallPath}/{plugin}/{version}.
+	InstallPath string
+	// BinPath is the directory where the plugin executable link is created.
+	BinPath string
+	// DownloadPath is the directory for temporarily downloading the plugin
+	// archive. Defaults to a directory in os.TempDir() if empty.
+	DownloadPath string
+
+	// Version, if set, is the version of the plugin to install. Installing
+	// fails if the index has a different version of the plugin.
+	Version string
+
+	// ArchiveFileOverride, if set, is a local archive file used instead of
+	// downloading the URI of the matching platform.
+	ArchiveFileOverride string
+
+	// ForceOS and ForceArch, if set, override the OS/arch used to find the
+	// matching platform of the plugin. Otherwise the current system's values
+	// (or the KREW_OS and KREW_ARCH overrides) are used.
+	ForceOS   string
+	ForceArch string
+
+	// AllowEmulation, if set, installs the binary of an architecture the
+	// system can emulate when the plugin has no binary for the system's
+	// architecture. Setting KREW_ALLOW_EMULATION=1 has the same effect.
+	AllowEmulation bool
+
+	// AllPlatforms, if set, also downloads and extracts the platforms of the
+	// plugin other than the matching one to their directories in the
+	// PlatformsPath of the installed version, e.g. for a krew root shared by
+	// systems with other OS/archs. Only the executable of the matching
+	// platform is linked. It can't be used with ArchiveFileOverride.
+	AllPlatforms bool
+
+	// Force reinstalls the plugin if it is already installed. The installed
+	// version is only removed after the new one is downloaded and verified.
+	Force bool
+
+	// Retries is the number of times a failed download is retried if it
+	// failed with a network error or a 5xx response.
+	Retries int
+
+	// HTTPClient, if set, is used for downloading the plugin archive instead
+	// of http.DefaultClient, which uses the proxies in the HTTPS_PROXY,
+	// HTTP_PROXY and NO_PROXY environment variables.
+	HTTPClient *http.Client
+	// Progress, if set, receives the progress of downloading the plugin
+	// archive from an http(s) URI.
+	Progress io.Writer
+
+	// Mirrors rewrite the URI of the plugin archive before downloading it.
+	// The archive is still verified against the checksum in the manifest.
+	Mirrors []download.MirrorRule
+
+	// AllowLocalURIs, if set, reads plugin archives with a file:// URI or an
+	// absolute path from the local filesystem, e.g. from an offline mirror.
+	// Otherwise, installing them fails with download.ErrLocalURINotAllowed.
+	AllowLocalURIs bool
+
+	// SignatureVerifier, if set, verifies the archive against its detached
+	// signature if the platform has a signature URI. Without it, signatures
+	// are not verified.
+	SignatureVerifier download.SignatureVerifier
+
+	// ResumeDownloads, if set, continues a retried download of the plugin
+	// archive where the failed attempt stopped, if the server supports range
+	// requests. The archive is verified against the checksum in the manifest
+	// as a whole.
+	ResumeDownloads bool
+
+	// ExtractLimits cap the total size and the number of files extracted
+	// from the plugin archive. The zero value uses
+	// download.DefaultExtractLimits, a negative field disables its limit.
+	ExtractLimits download.ExtractLimits
+
+	// DiskSpace returns the available disk space of a directory, which is
+	// checked before extracting the plugin archive. If nil,
+	// download.AvailableDiskSpace is used. The check is skipped if it fails.
+	DiskSpace download.DiskSpaceFunc
+
+	// AllowHooks runs the post-install hook of the plugin's platform, if it
+	// has one. Hooks run arbitrary commands, so they are skipped with a
+	// warning by default. A failing hook fails the installation with a
+	// *HookError.
+	AllowHooks bool
+	// HookOutput, if set, receives the output of the post-install hook while
+	// it runs.
+	HookOutput io.Writer
+
+	// RelativeLinks, if set, creates the link to the plugin executable with
+	// a path relative to BinPath, so that the links keep working if the
+	// directory containing InstallPath and BinPath is moved.
+	RelativeLinks bool
 }
 
+// DefaultDownloadRetries is the number of times failed plugin downloads are
+// retried by default.
+const DefaultDownloadRetries = 3
+
 // Install will download and install a plugin. The operation tries
 // to not get the plugin dir in a bad state if it fails during the process.
 func Install(p environment.Paths, plugin index.Plugin, forceDownloadFile string) error {
-	glog.V(2).Infof("Looking for installed versions")
-	_, ok, err := findInstalledPluginVersion(p.InstallPath(), p.BinPath(), plugin.Name)
+	return InstallPlugin(InstallOpts{
+		Plugin:              plugin,
+		InstallPath:         p.InstallPath(),
+		BinPath:             p.BinPath(),
+		DownloadPath:        p.DownloadPath(),
+		ArchiveFileOverride: forceDownloadFile,
+		Retries:             DefaultDownloadRetries,
+	})
+}
+
+// InstallPlan describes what InstallPlugin does for a plugin on the resolved
+// OS/arch.
+type InstallPlan struct {
+	// Version is the version the plugin is installed as.
+	Version string
+	// Platform is the platform of the plugin matching the OS/arch, it has the
+	// download URI, file operations and the executable path.
+	Platform index.Platform
+	// InstallDir is the directory the plugin files are moved to.
+	InstallDir string
+	// BinLink is the path of the symbolic link created to the plugin
+	// executable.
+	BinLink string
+	// BinTarget is the path of the plugin executable the BinLink points to.
+	BinTarget string
+	// ReplacedVersion is the installed version of the plugin that is replaced
+	// if it is reinstalled with InstallOpts.Force.
+	ReplacedVersion string
+	// OS and Arch are the resolved platform the plugin is installed for.
+	OS   string
+	Arch string
+}
+
+// PlanInstall resolves the platform and version of the plugin described by
+// opts and where it would be installed, without downloading anything or
+// modifying the filesystem.
+//
+// It returns ErrAlreadyInstalled if the plugin is already installed (unless
+// opts.Force is set), a *SuspiciousLinkError if the link of the installed plugin
+// points outside of its installation directory (unless opts.Force is set, which
+// replaces the link) and ErrNoMatchingPlatform if none of the plugin's
+// platforms match the OS/arch.
+func PlanInstall(opts InstallOpts) (InstallPlan, error) {
+	plan, err := planInstall(opts)
+	return plan, typedError(err)
+}
+
+func planInstall(opts InstallOpts) (InstallPlan, error) {
+	plugin := opts.Plugin
+	goos, goarch, err := OSArch(opts.ForceOS, opts.ForceArch)
 	if err != nil {
-		return err
+		return InstallPlan{}, err
+	}
+
+	log.V(2).Infof("Looking for installed versions")
+	installedVersion, ok, err := findInstalledPluginVersionFor(opts.InstallPath, opts.BinPath, plugin.Name, goos == "windows")
+	if _, suspicious := err.(*SuspiciousLinkError); suspicious && opts.Force {
+		// reinstalling replaces the link
+		log.Warningf("%v", err)
+		installedVersion, ok, err = "", false, nil
+	}
+	if err != nil {
+		return InstallPlan{}, err
+	}
+	if ok && !opts.Force {
+		return InstallPlan{}, ErrAlreadyInstalled
+	}
+
+	log.V(1).Infof("Finding download target for plugin %s", plugin.Name)
+	allowEmulation := opts.AllowEmulation || os.Getenv("KREW_ALLOW_EMULATION") == "1"
+	version, platform, err := getDownloadTargetFor(plugin, goos, goarch, allowEmulation)
+	if err != nil {
+		return InstallPlan{}, err
 	}
-	if ok {
-		return ErrIsAlreadyInstalled
+	if opts.Version != "" && !versionMatches(opts.Version, version) && opts.Version != plugin.Spec.Version {
+		return InstallPlan{}, errors.Errorf("version %q of plugin %q is not in the index, the available version is %q",
+			opts.Version, plugin.Name, version)
 	}
 
-	glog.V(1).Infof("Finding download target for plugin %s", plugin.Name)
-	version, uri, fos, bin, err := getDownloadTarget(plugin)
+	installDir := filepath.Join(opts.InstallPath, plugin.Name, version)
+	if err := validateFileOperations(installDir, platform.Files); err != nil {
+		return InstallPlan{}, errors.Wrapf(err, "invalid file operations in plugin %q", plugin.Name)
+	}
+	return InstallPlan{
+		Version:    version,
+		Platform:   platform,
+		InstallDir: installDir,
+		BinLink:    filepath.Join(opts.BinPath, pluginNameToBin(plugin.Name, goos == "windows")),
+		BinTarget:  filepath.Join(installDir, filepath.FromSlash(platform.Bin)),
+
+		ReplacedVersion: installedVersion,
+		OS:              goos,
+		Arch:            goarch,
+	}, nil
+}
+
+// InstallPlugin downloads and installs the plugin described by opts without
+// requiring a krew index on the filesystem.
+//
+// It returns ErrAlreadyInstalled if the plugin is already installed and
+// ErrNoMatchingPlatform if none of the plugin's platforms match the OS/arch.
+// Wrapped errors can be matched with errors.Is, e.g. ErrChecksumMismatch if the
+// downloaded archive has a different sha256.
+func InstallPlugin(opts InstallOpts) error {
+	return InstallPluginContext(context.Background(), opts)
+}
+
+// InstallPluginContext is like InstallPlugin, but stops downloading and
+// extracting the plugin once ctx is canceled. The downloaded files are removed
+// and the plugin is left as it was before, the error wraps ctx.Err().
+func InstallPluginContext(ctx context.Context, opts InstallOpts) error {
+	return typedError(installPluginContext(ctx, opts))
+}
+
+func installPluginContext(ctx context.Context, opts InstallOpts) error {
+	plan, err := planInstall(opts)
 	if err != nil {
 		return err
 	}
-	return install(plugin.Name, version, uri, bin, p, fos, forceDownloadFile)
+
+	downloadPath := opts.DownloadPath
+	if downloadPath == "" {
+		downloadPath = filepath.Join(os.TempDir(), "krew-downloads")
+	}
+	fetch := fetchOpts{
+		archiveFileOverride: opts.ArchiveFileOverride,
+		retries:             opts.Retries,
+		httpClient:          opts.HTTPClient,
+		progress:            opts.Progress,
+		mirrors:             opts.Mirrors,
+		allowLocalURIs:      opts.AllowLocalURIs,
+		extractLimits:       opts.ExtractLimits,
+		resume:              opts.ResumeDownloads,
+		signatureVerifier:   opts.SignatureVerifier,
+		diskSpace:           opts.DiskSpace,
+		goos:                plan.OS,
+	}
+	if fetch.diskSpace == nil {
+		fetch.diskSpace = download.AvailableDiskSpace
+	}
+	var platformsDir string
+	if opts.AllPlatforms {
+		if opts.ArchiveFileOverride != "" {
+			return errors.New("all platforms can't be installed from an archive file")
+		}
+		// the other platforms are installed first, so that the plugin is not
+		// linked if any of them fails
+		dir, err := installOtherPlatforms(ctx, opts.Plugin, plan.Platform, opts.InstallPath, downloadPath, fetch)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// left behind if the installation failed
+			if os.RemoveAll(dir) == nil {
+				os.Remove(filepath.Join(opts.InstallPath, opts.Plugin.Name))
+			}
+		}()
+		platformsDir = dir
+	}
+	hooks := hookOpts{allow: opts.AllowHooks, output: opts.HookOutput}
+	if err := install(ctx, opts.Plugin.Name, plan.Version, plan.Platform, opts.InstallPath, opts.BinPath, downloadPath, platformsDir, opts.RelativeLinks, fetch, hooks); err != nil {
+		return err
+	}
+	uri := plan.Platform.URI
+	if opts.ArchiveFileOverride != "" {
+		uri = opts.ArchiveFileOverride
+	}
+	recordInstallation(opts.InstallPath, Receipt{
+		Plugin:  opts.Plugin.Name,
+		Version: plan.Version,
+		Index:   opts.Index,
+		URI:     uri,
+		Sha256:  strings.ToLower(plan.Platform.Sha256),
+	})
+
+	// The replaced installation is only removed after the new version was
+	// downloaded, verified and linked. Reinstalling the same version replaces
+	// its directory when moving the new files in.
+	if plan.ReplacedVersion != "" && plan.ReplacedVersion != plan.Version {
+		log.V(1).Infof("Removing the replaced version %s of plugin %s", plan.ReplacedVersion, opts.Plugin.Name)
+		oldDir := filepath.Join(opts.InstallPath, opts.Plugin.Name, plan.ReplacedVersion)
+		if err := removeInstallDir(opts.InstallPath, oldDir); err != nil {
+			return errors.Wrapf(err, "failed to remove the replaced version %s", plan.ReplacedVersion)
+		}
+	}
+	return nil
 }
 
-func install(plugin, version, uri, bin string, p environment.Paths, fos []index.FileOperation, forceDownloadFile string) error {
-	dst, err := downloadAndMove(version, uri, fos, filepath.Join(p.DownloadPath(), plugin), p.PluginInstallPath(plugin), forceDownloadFile)
+// install downloads and extracts the platform of the plugin version and links
+// its executable. If platformsDir is set, the other platforms extracted to it
+// are moved into the version directory before the executable is linked.
+func install(ctx context.Context, plugin, version string, platform index.Platform, installPath, binPath, downloadPath, platformsDir string, relativeLink bool, fetch fetchOpts, hooks hookOpts) error {
+	bin := platform.Bin
+	if err := validateFileOperations(filepath.Join(installPath, plugin, version), platform.Files); err != nil {
+		return errors.Wrapf(err, "invalid file operations in plugin %q", plugin)
+	}
+	// the hook runs before a replaced installation of the same version is
+	// removed, so that it is restored if the hook fails
+	setup := func(dst string) error {
+		if platformsDir != "" {
+			log.V(2).Infof("Move directory %q to %q", platformsDir, filepath.Join(dst, platformsDirName))
+			if err := moveOrCopyDir(platformsDir, filepath.Join(dst, platformsDirName)); err != nil {
+				return errors.Wrap(err, "failed to move the other platforms to the installation directory")
+			}
+		}
+		return runPostInstallHook(ctx, plugin, version, dst, platform.PostInstall, hooks)
+	}
+	dst, err := downloadAndMove(ctx, version, platform, filepath.Join(downloadPath, plugin), filepath.Join(installPath, plugin), fetch, setup)
 	if err != nil {
 		return errors.Wrap(err, "failed to download and move during installation")
 	}
@@ -95,59 +469,154 @@ func install(plugin, version, uri, bin string, p environment.Paths, fos []index.
 		return errors.Wrapf(err, "failed to get the absolute fullPath of %q", fullPath)
 	}
 	if _, ok := pathutil.IsSubPath(subPathAbs, pathAbs); !ok {
-		return errors.Wrapf(err, "the fullPath %q does not extend the sub-fullPath %q", fullPath, dst)
+		return errors.Errorf("the fullPath %q does not extend the sub-fullPath %q", fullPath, dst)
 	}
-	return createOrUpdateLink(p.BinPath(), filepath.Join(dst, filepath.FromSlash(bin)), plugin)
+	return createOrUpdateLink(binPath, filepath.Join(dst, filepath.FromSlash(bin)), plugin, relativeLink, fetch.goos == "windows")
+}
+
+// Uninstall removes the executable link of the plugin from binDir and its
+// installed versions from installDir. A plugin without a link, e.g. because its
+// installation was interrupted, only has its directory removed. It returns
+// ErrNotInstalled if the plugin has neither a link nor a directory.
+func Uninstall(installDir, binDir, name string) error {
+	return typedError(uninstall(installDir, binDir, name))
 }
 
-// Uninstall will uninstall a plugin.
-func Uninstall(p environment.Paths, name string) error {
+func uninstall(installDir, binDir, name string) error {
+	if err := index.ValidatePluginName(name); err != nil {
+		return errors.Wrap(err, "can't uninstall plugin")
+	}
 	if name == krewPluginName {
-		return errors.Errorf("removing krew is not allowed through krew. Please run:\n\t rm -r %s", p.BasePath())
+		return errors.Errorf("removing krew is not allowed through krew. Please run:\n\t rm -r %s", filepath.Dir(filepath.Clean(installDir)))
+	}
+	log.V(3).Infof("Finding installed version to delete")
+	version, installed, err := findInstalledPluginVersion(installDir, binDir, name)
+	if _, suspicious := err.(*SuspiciousLinkError); suspicious {
+		// the link is removed, the file it points to is left alone
+		log.Warningf("%v", err)
+		version, err = "", nil
 	}
-	glog.V(3).Infof("Finding installed version to delete")
-	version, installed, err := findInstalledPluginVersion(p.InstallPath(), p.BinPath(), name)
 	if err != nil {
 		return errors.Wrap(err, "can't uninstall plugin")
 	}
 	if !installed {
-		return ErrIsNotInstalled
+		// a plugin whose installation was interrupted has no link
+		pluginDir := filepath.Join(installDir, name)
+		if _, err := os.Stat(pluginDir); os.IsNotExist(err) {
+			return ErrNotInstalled
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to check the plugin directory %q", pluginDir)
+		}
+		log.V(1).Infof("Deleting the broken installation of plugin %s", name)
+		return removeInstallDir(installDir, pluginDir)
 	}
-	glog.V(1).Infof("Deleting plugin version %s", version)
-	glog.V(3).Infof("Deleting path %q", p.PluginInstallPath(name))
+	log.V(1).Infof("Deleting plugin version %s", version)
 
-	symlinkPath := filepath.Join(p.BinPath(), pluginNameToBin(name, isWindows()))
-	if err := removeLink(symlinkPath); err != nil {
+	if err := removePluginLinks(binDir, name, isWindows()); err != nil {
 		return errors.Wrap(err, "could not uninstall symlink of plugin")
 	}
-	return os.RemoveAll(p.PluginInstallPath(name))
+	return removeInstallDir(installDir, filepath.Join(installDir, name))
+}
+
+// removeInstallDir deletes dir, which must be a subdirectory of installDir,
+// and then its parent directories up to installDir that are left empty.
+func removeInstallDir(installDir, dir string) error {
+	installDir, dir = filepath.Clean(installDir), filepath.Clean(dir)
+	if elems, ok := pathutil.IsSubPath(installDir, dir); !ok || len(elems) == 0 {
+		return errors.Errorf("refusing to delete %q, it is not under the installation directory %q", dir, installDir)
+	}
+	log.V(3).Infof("Deleting path %q", dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrapf(err, "failed to delete %q", dir)
+	}
+
+	for parent := filepath.Dir(dir); parent != installDir; parent = filepath.Dir(parent) {
+		items, err := ioutil.ReadDir(parent)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to read directory %q", parent)
+		}
+		if len(items) > 0 {
+			break
+		}
+		log.V(3).Infof("Deleting empty directory %q", parent)
+		if err := os.Remove(parent); err != nil {
+			return errors.Wrapf(err, "failed to delete empty directory %q", parent)
+		}
+	}
+	return nil
 }
 
-func createOrUpdateLink(binDir string, binary string, plugin string) error {
-	dst := filepath.Join(binDir, pluginNameToBin(plugin, isWindows()))
+// createOrUpdateLink creates the link to the plugin binary in binDir. If
+// relative is set, the link target is the path of the binary relative to binDir.
+// If windows is set, the link has the .exe extension, and a shim is created
+// instead if the user can't create symlinks, shims always have the absolute path
+// of the binary.
+func createOrUpdateLink(binDir string, binary string, plugin string, relative, windows bool) error {
+	dst := filepath.Join(binDir, pluginNameToBin(plugin, windows))
 
-	if err := removeLink(dst); err != nil {
+	if err := removePluginLinks(binDir, plugin, windows); err != nil {
 		return errors.Wrap(err, "failed to remove old symlink")
 	}
 	if _, err := os.Stat(binary); os.IsNotExist(err) {
 		return errors.Wrapf(err, "can't create symbolic link, source binary (%q) cannot be found in extracted archive", binary)
 	}
 
+	target := binary
+	if relative {
+		var err error
+		if target, err = relativeLinkTarget(binDir, binary); err != nil {
+			return err
+		}
+	}
+
 	// Create new
-	glog.V(2).Infof("Creating symlink from %q to %q", binary, dst)
-	if err := os.Symlink(binary, dst); err != nil {
-		return errors.Wrapf(err, "failed to create a symlink form %q to %q", binDir, dst)
+	log.V(2).Infof("Creating symlink from %q to %q", target, dst)
+	if err := os.Symlink(target, dst); err != nil {
+		if !windows {
+			return errors.Wrapf(err, "failed to create a symlink form %q to %q", binDir, dst)
+		}
+		log.V(1).Infof("Failed to create a symlink, creating a shim instead: %v", err)
+		shim := filepath.Join(binDir, pluginNameToShim(plugin))
+		return errors.Wrapf(writeShim(shim, binary), "failed to create a shim at %q", shim)
 	}
-	glog.V(2).Infof("Created symlink at %q", dst)
+	log.V(2).Infof("Created symlink at %q", dst)
 
 	return nil
 }
 
+// relativeLinkTarget returns the path of binary relative to binDir.
+func relativeLinkTarget(binDir, binary string) (string, error) {
+	binDirAbs, err := filepath.Abs(binDir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the absolute path of %q", binDir)
+	}
+	binaryAbs, err := filepath.Abs(binary)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the absolute path of %q", binary)
+	}
+	rel, err := filepath.Rel(binDirAbs, binaryAbs)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the path of %q relative to %q", binary, binDir)
+	}
+	return rel, nil
+}
+
+// removePluginLinks removes the symlink and the shim of the plugin from binDir.
+// If windows is set, the symlink has the .exe extension.
+func removePluginLinks(binDir, plugin string, windows bool) error {
+	if err := removeLink(filepath.Join(binDir, pluginNameToBin(plugin, windows))); err != nil {
+		return err
+	}
+	return removeShim(filepath.Join(binDir, pluginNameToShim(plugin)))
+}
+
 // removeLink removes a symlink reference if exists.
 func removeLink(path string) error {
 	fi, err := os.Lstat(path)
 	if os.IsNotExist(err) {
-		glog.V(3).Infof("No file found at %q", path)
+		log.V(3).Infof("No file found at %q", path)
 		return nil
 	} else if err != nil {
 		return errors.Wrapf(err, "failed to read the symlink in %q", path)
@@ -159,7 +628,7 @@ func removeLink(path string) error {
 	if err := os.Remove(path); err != nil {
 		return errors.Wrapf(err, "failed to remove the symlink in %q", path)
 	}
-	glog.V(3).Infof("Removed symlink from %q", path)
+	log.V(3).Infof("Removed symlink from %q", path)
 	return nil
 }
 
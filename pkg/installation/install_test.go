@@ -15,7 +15,17 @@
 package installation
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -23,6 +33,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/download"
 	"sigs.k8s.io/krew/pkg/environment"
 	"sigs.k8s.io/krew/pkg/index"
 	"sigs.k8s.io/krew/pkg/testutil"
@@ -105,7 +118,7 @@ func Test_createOrUpdateLink(t *testing.T) {
 			tmpDir, cleanup := testutil.NewTempDir(t)
 			defer cleanup()
 
-			if err := createOrUpdateLink(tmpDir.Root(), tt.binary, tt.pluginName); (err != nil) != tt.wantErr {
+			if err := createOrUpdateLink(tmpDir.Root(), tt.binary, tt.pluginName, false, isWindows()); (err != nil) != tt.wantErr {
 				t.Errorf("createOrUpdateLink() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -141,12 +154,142 @@ func Test_removeLink_notExists(t *testing.T) {
 func TestUninstall_cantUninstallItself(t *testing.T) {
 	envPath := environment.MustGetKrewPaths()
 	expectedErrorMessagePart := "not allowed"
-	if err := Uninstall(envPath, "krew"); !strings.Contains(err.Error(), expectedErrorMessagePart) {
+	if err := Uninstall(envPath.InstallPath(), envPath.BinPath(), "krew"); !strings.Contains(err.Error(), expectedErrorMessagePart) {
 		t.Fatalf("wrong error message for 'uninstall krew' action, expected message contains %q; got %q",
 			expectedErrorMessagePart, err.Error())
 	}
 }
 
+func TestUninstall_notInstalled(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("store/bar/v1/kubectl-bar", nil)
+
+	err := Uninstall(tmpDir.Path("store"), tmpDir.Path("bin"), "foo")
+	if err != ErrNotInstalled {
+		t.Fatalf("Uninstall() error = %v, want %v", err, ErrNotInstalled)
+	}
+}
+
+func TestUninstall_broken(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("store/foo/v1/kubectl-foo", nil)
+	tmpDir.Write("store/bar/v1/kubectl-bar", nil)
+
+	if err := Uninstall(tmpDir.Path("store"), tmpDir.Path("bin"), "foo"); err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if _, err := os.Stat(tmpDir.Path("store/foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the directory of the plugin without a link to be removed, stat error = %v", err)
+	}
+	if _, err := os.Stat(tmpDir.Path("store/bar/v1/kubectl-bar")); err != nil {
+		t.Errorf("expected other plugins to be kept: %v", err)
+	}
+}
+
+func TestUninstall_unsafeName(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("store/foo/v1/kubectl-foo", nil)
+
+	for _, name := range []string{"", ".", "..", "../store", "foo/v1", `foo\v1`} {
+		err := Uninstall(tmpDir.Path("store"), tmpDir.Path("bin"), name)
+		if err == nil || err == ErrNotInstalled {
+			t.Errorf("Uninstall(%q) error = %v, expected the name to be rejected", name, err)
+		}
+	}
+	if _, err := os.Stat(tmpDir.Path("store/foo/v1/kubectl-foo")); err != nil {
+		t.Errorf("expected the plugin files to be kept: %v", err)
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	tests := []struct {
+		name          string
+		removeVersion bool
+	}{
+		{"installed", false},
+		{"dangling link", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			os.Setenv("KREW_ROOT", tmpDir.Root())
+			defer os.Unsetenv("KREW_ROOT")
+			p := environment.MustGetKrewPaths()
+
+			tmpDir.Write("store/foo/v1/kubectl-foo", nil)
+			tmpDir.Write("store/bar/v1/kubectl-bar", nil)
+			if err := os.MkdirAll(p.BinPath(), 0755); err != nil {
+				t.Fatal(err)
+			}
+			link := filepath.Join(p.BinPath(), pluginNameToBin("foo", isWindows()))
+			if err := os.Symlink(tmpDir.Path("store/foo/v1/kubectl-foo"), link); err != nil {
+				t.Fatal(err)
+			}
+			if tt.removeVersion {
+				if err := os.RemoveAll(tmpDir.Path("store/foo/v1")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := Uninstall(p.InstallPath(), p.BinPath(), "foo"); err != nil {
+				t.Fatalf("Uninstall() error = %+v", err)
+			}
+			if _, err := os.Lstat(link); !os.IsNotExist(err) {
+				t.Errorf("expected the link to be removed, lstat error = %v", err)
+			}
+			if _, err := os.Stat(tmpDir.Path("store/foo")); !os.IsNotExist(err) {
+				t.Errorf("expected the plugin directory to be removed, stat error = %v", err)
+			}
+			if _, err := os.Stat(tmpDir.Path("store/bar/v1/kubectl-bar")); err != nil {
+				t.Errorf("expected other plugins to be kept: %v", err)
+			}
+		})
+	}
+}
+
+func Test_removeInstallDir(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	tmpDir.Write("store/foo/v1/kubectl-foo", nil)
+	tmpDir.Write("store/bar/v1/kubectl-bar", nil)
+	tmpDir.Write("store/bar/v2/kubectl-bar", nil)
+	tmpDir.Write("outside/file", nil)
+	installDir := tmpDir.Path("store")
+
+	for _, dir := range []string{installDir, tmpDir.Path("outside"), tmpDir.Path("store/../outside")} {
+		if err := removeInstallDir(installDir, dir); err == nil {
+			t.Errorf("removeInstallDir(%q) expected error", dir)
+		}
+	}
+	if _, err := os.Stat(tmpDir.Path("outside/file")); err != nil {
+		t.Fatalf("file outside of the installation directory was removed: %v", err)
+	}
+
+	// removing the only version prunes the plugin directory
+	if err := removeInstallDir(installDir, tmpDir.Path("store/foo/v1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("store/foo")); !os.IsNotExist(err) {
+		t.Errorf("expected empty plugin directory to be removed, stat error = %v", err)
+	}
+
+	// removing one of the versions keeps the plugin directory
+	if err := removeInstallDir(installDir, tmpDir.Path("store/bar/v1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("store/bar/v2")); err != nil {
+		t.Errorf("expected the other version to be kept: %v", err)
+	}
+	if _, err := os.Stat(installDir); err != nil {
+		t.Errorf("expected the installation directory to be kept: %v", err)
+	}
+}
+
 func Test_removeLink_linkExists(t *testing.T) {
 	tmpDir, cleanup := testutil.NewTempDir(t)
 	defer cleanup()
@@ -191,6 +334,462 @@ func Test_removeLink_regularFileExists(t *testing.T) {
 	}
 }
 
+// testPlugin returns a plugin manifest whose only platform matches the current
+// system and installs testdata/archives/foo.tar.gz.
+func testPlugin() index.Plugin {
+	return index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{{
+				URI:    "https://example.com/foo.tar.gz",
+				Sha256: "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116",
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"os": runtime.GOOS},
+				},
+				Files: []index.FileOperation{{From: "*", To: "."}},
+				Bin:   "kubectl-foo",
+			}},
+		},
+	}
+}
+
+func TestInstallPlugin(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	opts := InstallOpts{
+		Plugin:              testPlugin(),
+		InstallPath:         tmpDir.Path("store"),
+		BinPath:             tmpDir.Path("bin"),
+		DownloadPath:        tmpDir.Path("downloads"),
+		ArchiveFileOverride: filepath.Join(testdataPath(t), "archives", "foo.tar.gz"),
+	}
+	if err := os.MkdirAll(opts.BinPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+
+	version, ok, err := findInstalledPluginVersion(opts.InstallPath, opts.BinPath, "foo")
+	if err != nil || !ok {
+		t.Fatalf("plugin not installed: installed=%v err=%v", ok, err)
+	}
+	if want := "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116"; version != want {
+		t.Fatalf("installed version = %q, want %q", version, want)
+	}
+
+	if err := InstallPlugin(opts); err != ErrAlreadyInstalled {
+		t.Fatalf("second InstallPlugin() error = %v, want %v", err, ErrAlreadyInstalled)
+	}
+}
+
+func TestInstallPlugin_force(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	version := "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116"
+	opts := InstallOpts{
+		Plugin:              testPlugin(),
+		InstallPath:         tmpDir.Path("store"),
+		BinPath:             tmpDir.Path("bin"),
+		DownloadPath:        tmpDir.Path("downloads"),
+		ArchiveFileOverride: filepath.Join(testdataPath(t), "archives", "foo.tar.gz"),
+		Force:               true,
+	}
+	if err := os.MkdirAll(opts.BinPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// an older version is installed
+	oldDir := tmpDir.Path("store/foo/v0.1.0")
+	tmpDir.Write("store/foo/v0.1.0/kubectl-foo", []byte("old"))
+	if err := createOrUpdateLink(opts.BinPath, filepath.Join(oldDir, "kubectl-foo"), "foo", false, isWindows()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failing reinstall keeps the installed version
+	badOpts := opts
+	badOpts.Plugin = testPlugin()
+	badOpts.Plugin.Spec.Platforms[0].Sha256 = "deadbeef"
+	if err := InstallPlugin(badOpts); err == nil {
+		t.Fatal("InstallPlugin() with a wrong checksum expected error")
+	}
+	if got, _, _ := findInstalledPluginVersion(opts.InstallPath, opts.BinPath, "foo"); got != "v0.1.0" {
+		t.Fatalf("installed version after a failed reinstall = %q, want v0.1.0", got)
+	}
+
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	if got, _, _ := findInstalledPluginVersion(opts.InstallPath, opts.BinPath, "foo"); got != version {
+		t.Fatalf("installed version = %q, want %q", got, version)
+	}
+	if _, err := os.Stat(oldDir); !os.IsNotExist(err) {
+		t.Fatalf("replaced version directory should be removed, stat error = %v", err)
+	}
+
+	// reinstalling the same version restores a corrupted executable
+	bin := tmpDir.Path(filepath.Join("store", "foo", version, "kubectl-foo"))
+	want, err := ioutil.ReadFile(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(bin, []byte("corrupted"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() of the installed version error = %+v", err)
+	}
+	got, err := ioutil.ReadFile(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("reinstalled executable has content %q, want %q", got, want)
+	}
+}
+
+func TestInstallPlugin_failureLeavesNoPartialInstall(t *testing.T) {
+	archive, err := ioutil.ReadFile(filepath.Join(testdataPath(t), "archives", "foo.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := archive[:len(archive)/2]
+	sum := sha256.Sum256(truncated)
+
+	tests := []struct {
+		name     string
+		archive  []byte
+		sha256   string
+		files    []index.FileOperation
+		existing bool
+	}{
+		{
+			name:    "extraction error",
+			archive: truncated,
+			sha256:  hex.EncodeToString(sum[:]),
+			files:   []index.FileOperation{{From: "*", To: "."}},
+		},
+		{
+			name:    "file operation error",
+			archive: archive,
+			sha256:  testPlugin().Spec.Platforms[0].Sha256,
+			files:   []index.FileOperation{{From: "kubectl-foo", To: "."}, {From: "missing", To: "."}},
+		},
+		{
+			name:     "file operation error with another version installed",
+			archive:  archive,
+			sha256:   testPlugin().Spec.Platforms[0].Sha256,
+			files:    []index.FileOperation{{From: "kubectl-foo", To: "."}, {From: "missing", To: "."}},
+			existing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			tmpDir.Write("foo.tar.gz", tt.archive)
+			if tt.existing {
+				tmpDir.Write("store/foo/v0.1.0/kubectl-foo", []byte("old"))
+			}
+
+			plugin := testPlugin()
+			plugin.Spec.Platforms[0].URI = tmpDir.Path("foo.tar.gz")
+			plugin.Spec.Platforms[0].Sha256 = tt.sha256
+			plugin.Spec.Platforms[0].Files = tt.files
+			err := InstallPlugin(InstallOpts{
+				Plugin:       plugin,
+				InstallPath:  tmpDir.Path("store"),
+				BinPath:      tmpDir.Path("bin"),
+				DownloadPath: tmpDir.Path("downloads"),
+			})
+			if err == nil {
+				t.Fatal("InstallPlugin() expected error")
+			}
+
+			var got []string
+			if items, err := ioutil.ReadDir(tmpDir.Path("store/foo")); err == nil {
+				for _, item := range items {
+					got = append(got, item.Name())
+				}
+			} else if !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			var want []string
+			if tt.existing {
+				want = []string{"v0.1.0"}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("plugin directory has %v after a failed installation, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestInstallPluginContext_cancelMidDownload(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+		cancel() // the client has started reading the body
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = server.URL + "/foo.tar.gz"
+	err := InstallPluginContext(ctx, InstallOpts{
+		Plugin:       plugin,
+		InstallPath:  tmpDir.Path("store"),
+		BinPath:      tmpDir.Path("bin"),
+		DownloadPath: tmpDir.Path("downloads"),
+		Retries:      3,
+	})
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("InstallPluginContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	for _, dir := range []string{"store/foo", "downloads/foo"} {
+		if _, err := os.Stat(tmpDir.Path(dir)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed after cancelling the installation, err = %v", dir, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(tmpDir.Path("bin"), pluginNameToBin("foo", isWindows()))); !os.IsNotExist(err) {
+		t.Errorf("expected no plugin link after cancelling the installation, err = %v", err)
+	}
+}
+
+func TestInstallPlugin_mirror(t *testing.T) {
+	mirrorDir := "file://" + filepath.ToSlash(filepath.Join(testdataPath(t), "archives")) + "/"
+	mirrors := []download.MirrorRule{{From: "https://github.com/foo/releases/", To: mirrorDir}}
+
+	tests := []struct {
+		name    string
+		sha256  string
+		wantErr bool
+	}{
+		{"verified", testPlugin().Spec.Platforms[0].Sha256, false},
+		{"checksum mismatch", "deadbeef", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			tmpDir.Write("bin/.keep", nil)
+
+			plugin := testPlugin()
+			plugin.Spec.Platforms[0].URI = "https://github.com/foo/releases/foo.tar.gz"
+			plugin.Spec.Platforms[0].Sha256 = tt.sha256
+			err := InstallPlugin(InstallOpts{
+				Plugin:         plugin,
+				InstallPath:    tmpDir.Path("store"),
+				BinPath:        tmpDir.Path("bin"),
+				DownloadPath:   tmpDir.Path("downloads"),
+				Mirrors:        mirrors,
+				AllowLocalURIs: true,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InstallPlugin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstallPlugin_localURI(t *testing.T) {
+	archive := filepath.Join(testdataPath(t), "archives", "foo.tar.gz")
+	for _, uri := range []string{archive, "file://" + filepath.ToSlash(archive)} {
+		t.Run(uri, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+
+			plugin := testPlugin()
+			plugin.Spec.Platforms[0].URI = uri
+			opts := InstallOpts{
+				Plugin:         plugin,
+				InstallPath:    tmpDir.Path("store"),
+				BinPath:        tmpDir.Path("bin"),
+				DownloadPath:   tmpDir.Path("downloads"),
+				AllowLocalURIs: true,
+			}
+			if err := os.MkdirAll(opts.BinPath, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := InstallPlugin(opts); err != nil {
+				t.Fatalf("InstallPlugin() error = %+v", err)
+			}
+			if _, err := os.Stat(filepath.Join(opts.BinPath, pluginNameToBin("foo", isWindows()))); err != nil {
+				t.Fatalf("plugin link not created: %v", err)
+			}
+		})
+	}
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		tmpDir, cleanup := testutil.NewTempDir(t)
+		defer cleanup()
+
+		plugin := testPlugin()
+		plugin.Spec.Platforms[0].URI = archive
+		plugin.Spec.Platforms[0].Sha256 = "deadbeef"
+		err := InstallPlugin(InstallOpts{
+			Plugin:         plugin,
+			InstallPath:    tmpDir.Path("store"),
+			BinPath:        tmpDir.Path("bin"),
+			DownloadPath:   tmpDir.Path("downloads"),
+			AllowLocalURIs: true,
+		})
+		if !stderrors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("InstallPlugin() of a local archive with a wrong checksum error = %v, want %v", err, ErrChecksumMismatch)
+		}
+		var checksumErr *download.ChecksumError
+		if !stderrors.As(err, &checksumErr) || checksumErr.Expected != "deadbeef" {
+			t.Fatalf("InstallPlugin() error = %#v, want a *download.ChecksumError expecting deadbeef", err)
+		}
+	})
+
+	t.Run("relative path", func(t *testing.T) {
+		tmpDir, cleanup := testutil.NewTempDir(t)
+		defer cleanup()
+
+		plugin := testPlugin()
+		plugin.Spec.Platforms[0].URI = filepath.Join("testdata", "archives", "foo.tar.gz")
+		err := InstallPlugin(InstallOpts{
+			Plugin:         plugin,
+			InstallPath:    tmpDir.Path("store"),
+			BinPath:        tmpDir.Path("bin"),
+			DownloadPath:   tmpDir.Path("downloads"),
+			AllowLocalURIs: true,
+		})
+		if err == nil {
+			t.Fatal("InstallPlugin() with a relative path uri expected error")
+		}
+	})
+
+	t.Run("local uris not allowed", func(t *testing.T) {
+		tmpDir, cleanup := testutil.NewTempDir(t)
+		defer cleanup()
+
+		plugin := testPlugin()
+		plugin.Spec.Platforms[0].URI = archive
+		err := InstallPlugin(InstallOpts{
+			Plugin:       plugin,
+			InstallPath:  tmpDir.Path("store"),
+			BinPath:      tmpDir.Path("bin"),
+			DownloadPath: tmpDir.Path("downloads"),
+		})
+		if errors.Cause(err) != download.ErrLocalURINotAllowed {
+			t.Fatalf("InstallPlugin() error = %v, want %v", err, download.ErrLocalURINotAllowed)
+		}
+	})
+}
+
+func TestInstallPlugin_noMatchingPlatform(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	forceOS := "windows"
+	if runtime.GOOS == forceOS {
+		forceOS = "linux"
+	}
+	err := InstallPlugin(InstallOpts{
+		Plugin:      testPlugin(),
+		InstallPath: tmpDir.Path("store"),
+		BinPath:     tmpDir.Path("bin"),
+		ForceOS:     forceOS,
+	})
+	if err != ErrNoMatchingPlatform {
+		t.Fatalf("InstallPlugin() with ForceOS=%s error = %v, want %v", forceOS, err, ErrNoMatchingPlatform)
+	}
+}
+
+func TestInstallPlugin_fileOperationEscapesInstallDir(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].Files = []index.FileOperation{{From: "*", To: "../../.."}}
+	err := InstallPlugin(InstallOpts{
+		Plugin:              plugin,
+		InstallPath:         tmpDir.Path("store"),
+		BinPath:             tmpDir.Path("bin"),
+		DownloadPath:        tmpDir.Path("downloads"),
+		ArchiveFileOverride: filepath.Join(testdataPath(t), "archives", "foo.tar.gz"),
+	})
+	if err == nil {
+		t.Fatal("InstallPlugin() with a file operation escaping the installation directory expected error")
+	}
+	for _, dir := range []string{"store", "downloads"} {
+		if _, err := os.Stat(tmpDir.Path(dir)); !os.IsNotExist(err) {
+			t.Fatalf("InstallPlugin() should fail before creating %q, stat error = %v", dir, err)
+		}
+	}
+}
+
+func TestPlanInstall(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	opts := InstallOpts{
+		Plugin:      testPlugin(),
+		InstallPath: tmpDir.Path("store"),
+		BinPath:     tmpDir.Path("bin"),
+		ForceOS:     runtime.GOOS,
+	}
+	got, err := PlanInstall(opts)
+	if err != nil {
+		t.Fatalf("PlanInstall() error = %+v", err)
+	}
+
+	version := "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116"
+	want := InstallPlan{
+		Version:    version,
+		Platform:   testPlugin().Spec.Platforms[0],
+		InstallDir: tmpDir.Path("store/foo/" + version),
+		BinLink:    tmpDir.Path("bin/" + pluginNameToBin("foo", runtime.GOOS == "windows")),
+		BinTarget:  tmpDir.Path("store/foo/" + version + "/kubectl-foo"),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PlanInstall() = %+v, want %+v", got, want)
+	}
+
+	for _, dir := range []string{opts.InstallPath, opts.BinPath} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("PlanInstall() should not create %q, stat error = %v", dir, err)
+		}
+	}
+}
+
+func TestPlanInstall_version(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	version := "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116"
+	opts := InstallOpts{
+		Plugin:      testPlugin(),
+		InstallPath: tmpDir.Path("store"),
+		BinPath:     tmpDir.Path("bin"),
+		ForceOS:     runtime.GOOS,
+		Version:     version,
+	}
+	if _, err := PlanInstall(opts); err != nil {
+		t.Fatalf("PlanInstall() with the available version error = %+v", err)
+	}
+
+	opts.Version = "v0.0.1"
+	_, err := PlanInstall(opts)
+	if err == nil || !strings.Contains(err.Error(), `version "v0.0.1" of plugin "foo" is not in the index`) {
+		t.Fatalf("PlanInstall() with an unavailable version error = %v", err)
+	}
+}
+
 func Test_isWindows(t *testing.T) {
 	expected := runtime.GOOS == "windows"
 	got := isWindows()
@@ -212,3 +811,462 @@ func Test_isWindows_envOverride(t *testing.T) {
 		t.Fatalf("isWindows()=true when KREW_OS != windows")
 	}
 }
+
+func TestInstallPlugin_zipFileOperations(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"foo-1.0/bin/kubectl-foo": "#!/bin/sh",
+		"foo-1.0/bin/lib/helper":  "helper",
+		"foo-1.0/README":          "readme",
+	} {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		hdr.SetMode(0755)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("foo.zip", buf.Bytes())
+	sum := sha256.Sum256(buf.Bytes())
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = tmpDir.Path("foo.zip")
+	plugin.Spec.Platforms[0].Sha256 = hex.EncodeToString(sum[:])
+	plugin.Spec.Platforms[0].Files = []index.FileOperation{{From: "foo-1.0/bin/*", To: "."}}
+	opts := InstallOpts{
+		Plugin:         plugin,
+		InstallPath:    tmpDir.Path("store"),
+		BinPath:        tmpDir.Path("bin"),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllowLocalURIs: true,
+	}
+	if err := os.MkdirAll(opts.BinPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+
+	installDir := tmpDir.Path(filepath.Join("store", "foo", plugin.Spec.Platforms[0].Sha256))
+	for _, f := range []string{"kubectl-foo", filepath.Join("lib", "helper")} {
+		if _, err := os.Stat(filepath.Join(installDir, f)); err != nil {
+			t.Errorf("expected %s to be installed: %v", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(installDir, "README")); !os.IsNotExist(err) {
+		t.Errorf("expected README not to be installed, stat error = %v", err)
+	}
+}
+
+func TestInstallPlugin_forceWindows(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	// windows executables are not executable in the archive
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	hdr := &zip.FileHeader{Name: "kubectl-foo.exe", Method: zip.Deflate}
+	hdr.SetMode(0644)
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("MZ")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("foo.zip", buf.Bytes())
+	sum := sha256.Sum256(buf.Bytes())
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = tmpDir.Path("foo.zip")
+	plugin.Spec.Platforms[0].Sha256 = hex.EncodeToString(sum[:])
+	plugin.Spec.Platforms[0].Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"os": "windows"}}
+	plugin.Spec.Platforms[0].Bin = "kubectl-foo.exe"
+	opts := InstallOpts{
+		Plugin:         plugin,
+		InstallPath:    tmpDir.Path("store"),
+		BinPath:        tmpDir.Path("bin"),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllowLocalURIs: true,
+		ForceOS:        "windows",
+		ForceArch:      "amd64",
+	}
+	plan, err := PlanInstall(opts)
+	if err != nil {
+		t.Fatalf("PlanInstall() error = %+v", err)
+	}
+	if want := tmpDir.Path("bin/kubectl-foo.exe"); plan.BinLink != want {
+		t.Errorf("PlanInstall() BinLink = %q, want %q", plan.BinLink, want)
+	}
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	if _, err := os.Lstat(plan.BinLink); err != nil {
+		t.Errorf("expected the link %q to be created: %v", plan.BinLink, err)
+	}
+	if _, err := os.Lstat(tmpDir.Path("bin/kubectl-foo")); !os.IsNotExist(err) {
+		t.Errorf("expected no link without the .exe extension, got error %v", err)
+	}
+	if err := InstallPlugin(opts); !stderrors.Is(err, ErrAlreadyInstalled) {
+		t.Errorf("InstallPlugin() again error = %v, want %v", err, ErrAlreadyInstalled)
+	}
+}
+
+func Test_checkBin(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("kubectl-foo.exe", []byte("MZ"))
+	if err := os.Chmod(tmpDir.Path("kubectl-foo.exe"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkBin(tmpDir.Root(), "kubectl-foo.exe", true, nil); err != nil {
+		t.Errorf("checkBin() on windows error = %v, want nil", err)
+	}
+	if err := checkBin(tmpDir.Root(), "kubectl-foo.exe", false, nil); err == nil || !strings.Contains(err.Error(), "is not executable") {
+		t.Errorf("checkBin() error = %v, want the bin not to be executable", err)
+	}
+}
+
+func TestInstallPlugin_stripComponents(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{
+		"foo-1.2.3/bin/kubectl-foo": "#!/bin/sh",
+		"foo-1.2.3/README":          "readme",
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir.Write("foo.tar.gz", buf.Bytes())
+	sum := sha256.Sum256(buf.Bytes())
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = tmpDir.Path("foo.tar.gz")
+	plugin.Spec.Platforms[0].Sha256 = hex.EncodeToString(sum[:])
+	plugin.Spec.Platforms[0].StripComponents = 1
+	plugin.Spec.Platforms[0].Files = []index.FileOperation{{From: "bin/*", To: "."}}
+	opts := InstallOpts{
+		Plugin:         plugin,
+		InstallPath:    tmpDir.Path("store"),
+		BinPath:        tmpDir.Path("bin"),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllowLocalURIs: true,
+	}
+	if err := os.MkdirAll(opts.BinPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+
+	installDir := tmpDir.Path(filepath.Join("store", "foo", plugin.Spec.Platforms[0].Sha256))
+	if _, err := os.Stat(filepath.Join(installDir, "kubectl-foo")); err != nil {
+		t.Errorf("expected kubectl-foo to be installed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(installDir, "foo-1.2.3")); !os.IsNotExist(err) {
+		t.Errorf("expected the top directory to be stripped, stat error = %v", err)
+	}
+}
+
+func TestInstallPlugin_lowDiskSpace(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	var checked []string
+	opts := InstallOpts{
+		Plugin:              testPlugin(),
+		InstallPath:         tmpDir.Path("store"),
+		BinPath:             tmpDir.Path("bin"),
+		DownloadPath:        tmpDir.Path("downloads"),
+		ArchiveFileOverride: filepath.Join(testdataPath(t), "archives", "foo.tar.gz"),
+		DiskSpace: func(dir string) (int64, error) {
+			checked = append(checked, dir)
+			return 1, nil
+		},
+	}
+	if err := os.MkdirAll(opts.BinPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := InstallPlugin(opts)
+	if err == nil || !strings.Contains(err.Error(), "not enough disk space") {
+		t.Fatalf("InstallPlugin() error = %v, expected not enough disk space", err)
+	}
+	if len(checked) != 1 || checked[0] != filepath.Join(opts.DownloadPath, "foo", "extract") {
+		t.Errorf("checked the disk space of %v, want the extraction directory of the plugin", checked)
+	}
+	if _, err := os.Stat(filepath.Join(opts.InstallPath, "foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the plugin not to be installed, stat error = %v", err)
+	}
+
+	// the check is skipped if the disk space can't be determined
+	opts.DiskSpace = func(string) (int64, error) { return 0, errors.New("not supported") }
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() without the available disk space error = %+v", err)
+	}
+}
+
+func TestInstallPlugin_relativeLinks(t *testing.T) {
+	if isWindows() {
+		t.Skip("shims have absolute paths")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	const version = "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116"
+	tmpDir.Write("root/bin/.keep", nil)
+	if err := InstallPlugin(InstallOpts{
+		Plugin:              testPlugin(),
+		InstallPath:         tmpDir.Path("root/store"),
+		BinPath:             tmpDir.Path("root/bin"),
+		DownloadPath:        tmpDir.Path("downloads"),
+		ArchiveFileOverride: filepath.Join(testdataPath(t), "archives", "foo.tar.gz"),
+		RelativeLinks:       true,
+	}); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	link, err := os.Readlink(tmpDir.Path("root/bin/kubectl-foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("..", "store", "foo", version, "kubectl-foo"); link != want {
+		t.Fatalf("link target = %q, want %q", link, want)
+	}
+
+	// the moved root has no receipt, so the version is resolved from the link
+	if err := os.Rename(tmpDir.Path("root"), tmpDir.Path("moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(tmpDir.Path("moved/store/foo/" + receiptFileName)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("moved/bin/kubectl-foo")); err != nil {
+		t.Fatalf("link is broken after moving the root: %v", err)
+	}
+	got, installed, err := findInstalledPluginVersion(tmpDir.Path("moved/store"), tmpDir.Path("moved/bin"), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !installed || got != version {
+		t.Fatalf("findInstalledPluginVersion() = (%q, %v), want (%q, true)", got, installed, version)
+	}
+}
+
+func TestInstallPlugin_extractLimits(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	err := InstallPlugin(InstallOpts{
+		Plugin:              testPlugin(),
+		InstallPath:         tmpDir.Path("store"),
+		BinPath:             tmpDir.Path("bin"),
+		DownloadPath:        tmpDir.Path("downloads"),
+		ArchiveFileOverride: filepath.Join(testdataPath(t), "archives", "foo.tar.gz"),
+		ExtractLimits:       download.ExtractLimits{MaxSize: 4},
+	})
+	if err == nil || !strings.Contains(err.Error(), "more than 4 bytes") {
+		t.Fatalf("InstallPlugin() error = %v, want the extract size limit to be exceeded", err)
+	}
+	if _, err := os.Stat(tmpDir.Path("downloads/foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the staging directory to be removed, got error %v", err)
+	}
+	if _, ok, _ := findInstalledPluginVersion(tmpDir.Path("store"), tmpDir.Path("bin"), "foo"); ok {
+		t.Errorf("expected the plugin not to be installed")
+	}
+}
+
+func TestInstallPlugin_binNotFound(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].Bin = "kubectl-fo"
+	err := InstallPlugin(InstallOpts{
+		Plugin:              plugin,
+		InstallPath:         tmpDir.Path("store"),
+		BinPath:             tmpDir.Path("bin"),
+		DownloadPath:        tmpDir.Path("downloads"),
+		ArchiveFileOverride: filepath.Join(testdataPath(t), "archives", "foo.tar.gz"),
+	})
+	if err == nil || !strings.Contains(err.Error(), `bin "kubectl-fo" not found in extracted files, the archive contains: kubectl-foo`) {
+		t.Fatalf("InstallPlugin() error = %v, want the missing bin and the archive entries", err)
+	}
+	if _, err := os.Stat(tmpDir.Path("store/foo")); !os.IsNotExist(err) {
+		t.Errorf("expected no version directory to be left behind, got error %v", err)
+	}
+	if _, err := os.Lstat(tmpDir.Path("bin/kubectl-fo")); !os.IsNotExist(err) {
+		t.Errorf("expected no link to be created, got error %v", err)
+	}
+}
+
+func TestInstallPlugin_allPlatforms(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = filepath.Join(testdataPath(t), "archives", "foo.tar.gz")
+	other := plugin.Spec.Platforms[0]
+	other.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"os": "plan9", "arch": "386"}}
+	plugin.Spec.Platforms = append(plugin.Spec.Platforms, other)
+
+	if err := InstallPlugin(InstallOpts{
+		Plugin:         plugin,
+		InstallPath:    tmpDir.Path("store"),
+		BinPath:        tmpDir.Path("bin"),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllPlatforms:   true,
+		AllowLocalURIs: true,
+	}); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+
+	version, ok, err := findInstalledPluginVersion(tmpDir.Path("store"), tmpDir.Path("bin"), "foo")
+	if err != nil || !ok {
+		t.Fatalf("findInstalledPluginVersion() = (%q, %v, %v), want the installed version", version, ok, err)
+	}
+	if want := testPlugin().Spec.Platforms[0].Sha256; version != want {
+		t.Errorf("findInstalledPluginVersion() = %q, want %q", version, want)
+	}
+	if _, err := os.Stat(filepath.Join(PlatformsPath(tmpDir.Path("store"), "foo", version), "plan9-386", "kubectl-foo")); err != nil {
+		t.Errorf("expected the other platform to be extracted: %v", err)
+	}
+	installed, err := ListInstalledPlugins(tmpDir.Path("store"), tmpDir.Path("bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"foo": version}; !reflect.DeepEqual(installed, want) {
+		t.Errorf("ListInstalledPlugins() = %v, want %v", installed, want)
+	}
+}
+
+func TestInstallPlugin_allPlatformsFailure(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = filepath.Join(testdataPath(t), "archives", "foo.tar.gz")
+	extracted := plugin.Spec.Platforms[0]
+	extracted.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"os": "plan9", "arch": "386"}}
+	broken := plugin.Spec.Platforms[0]
+	broken.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"os": "plan9", "arch": "arm"}}
+	broken.Sha256 = strings.Repeat("0", 64)
+	plugin.Spec.Platforms = append(plugin.Spec.Platforms, extracted, broken)
+
+	err := InstallPlugin(InstallOpts{
+		Plugin:         plugin,
+		InstallPath:    tmpDir.Path("store"),
+		BinPath:        tmpDir.Path("bin"),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllPlatforms:   true,
+		AllowLocalURIs: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to install platform plan9-arm") {
+		t.Fatalf("InstallPlugin() error = %v, want the failed platform", err)
+	}
+	if _, err := os.Stat(tmpDir.Path("store/foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the extracted platforms to be removed, got error %v", err)
+	}
+	if _, err := os.Lstat(tmpDir.Path("bin/kubectl-foo")); !os.IsNotExist(err) {
+		t.Errorf("expected no link to be created, got error %v", err)
+	}
+}
+
+func Test_platformDirName(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		want   string
+	}{
+		{labels: map[string]string{"os": "linux", "arch": "arm64"}, want: "linux-arm64"},
+		{labels: map[string]string{"os": "darwin"}, want: "darwin"},
+		{labels: nil, want: "platform-2"},
+	}
+	for _, tt := range tests {
+		p := index.Platform{Selector: &metav1.LabelSelector{MatchLabels: tt.labels}}
+		if got := platformDirName(p, 2); got != tt.want {
+			t.Errorf("platformDirName(%v) = %q, want %q", tt.labels, got, tt.want)
+		}
+	}
+}
+
+// signatureVerifierFunc adapts a function to a download.SignatureVerifier.
+type signatureVerifierFunc func(data, signature []byte) error
+
+func (f signatureVerifierFunc) VerifySignature(_ context.Context, data, signature []byte) error {
+	return f(data, signature)
+}
+
+func TestInstallPlugin_signature(t *testing.T) {
+	archive := filepath.Join(testdataPath(t), "archives", "foo.tar.gz")
+	tests := []struct {
+		name      string
+		signature string
+		wantErr   bool
+	}{
+		{name: "valid signature", signature: "trusted"},
+		{name: "invalid signature", signature: "forged", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			tmpDir.Write("bin/.keep", nil)
+			tmpDir.Write("foo.tar.gz.sig", []byte(tt.signature))
+
+			plugin := testPlugin()
+			plugin.Spec.Platforms[0].URI = archive
+			plugin.Spec.Platforms[0].SignatureURI = tmpDir.Path("foo.tar.gz.sig")
+			err := InstallPlugin(InstallOpts{
+				Plugin:       plugin,
+				InstallPath:  tmpDir.Path("store"),
+				BinPath:      tmpDir.Path("bin"),
+				DownloadPath: tmpDir.Path("downloads"),
+				SignatureVerifier: signatureVerifierFunc(func(data, signature []byte) error {
+					if string(signature) != "trusted" {
+						return errors.New("untrusted signature")
+					}
+					return nil
+				}),
+				AllowLocalURIs: true,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InstallPlugin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, installed, _ := findInstalledPluginVersion(tmpDir.Path("store"), tmpDir.Path("bin"), "foo")
+			if installed == tt.wantErr {
+				t.Errorf("plugin installed = %v, want %v", installed, !tt.wantErr)
+			}
+		})
+	}
+}
@@ -19,13 +19,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"sigs.k8s.io/krew/pkg/index"
 	"sigs.k8s.io/krew/pkg/pathutil"
 
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
 )
 
 type move struct {
@@ -41,15 +42,15 @@ func findMoveTargets(fromDir, toDir string, fo index.FileOperation) ([]move, err
 		return nil, errors.Wrap(err, "could not get the relative path for the move src")
 	}
 
-	glog.V(4).Infof("Trying to move single file directly from=%q to=%q with file operation=%#v", fromDir, toDir, fo)
+	log.V(4).Infof("Trying to move single file directly from=%q to=%q with file operation=%#v", fromDir, toDir, fo)
 	if m, ok, err := getDirectMove(fromDir, toDir, fo); err != nil {
 		return nil, errors.Wrap(err, "failed to detect single move operation")
 	} else if ok {
-		glog.V(3).Infof("Detected single move from file operation=%#v", fo)
+		log.V(3).Infof("Detected single move from file operation=%#v", fo)
 		return []move{m}, nil
 	}
 
-	glog.V(4).Infoln("Wasn't a single file, proceeding with Glob move")
+	log.V(4).Infoln("Wasn't a single file, proceeding with Glob move")
 	newDir, err := filepath.Abs(filepath.Join(filepath.FromSlash(toDir), filepath.FromSlash(fo.To)))
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get the relative path for the move dst")
@@ -115,6 +116,24 @@ func getDirectMove(fromDir, toDir string, fo index.FileOperation) (move, bool, e
 	return m, true, nil
 }
 
+// validateFileOperations checks that the files of each file operation are
+// moved within installDir, and that the files are taken from within the
+// extracted archive.
+func validateFileOperations(installDir string, fos []index.FileOperation) error {
+	installDir = filepath.Clean(installDir)
+	for i, fo := range fos {
+		to := filepath.Join(installDir, filepath.FromSlash(fo.To))
+		if _, ok := pathutil.IsSubPath(installDir, to); !ok {
+			return errors.Errorf("file operation #%d (from=%q, to=%q) moves files outside of the installation directory", i, fo.From, fo.To)
+		}
+		from := filepath.Join(installDir, filepath.FromSlash(fo.From))
+		if _, ok := pathutil.IsSubPath(installDir, from); !ok {
+			return errors.Errorf("file operation #%d (from=%q, to=%q) takes files from outside of the plugin archive", i, fo.From, fo.To)
+		}
+	}
+	return nil
+}
+
 func isMoveAllowed(fromBase, toBase string, m move) bool {
 	_, okFrom := pathutil.IsSubPath(fromBase, m.from)
 	_, okTo := pathutil.IsSubPath(toBase, m.to)
@@ -122,23 +141,23 @@ func isMoveAllowed(fromBase, toBase string, m move) bool {
 }
 
 func moveFiles(fromDir, toDir string, fo index.FileOperation) error {
-	glog.V(4).Infof("Finding move targets from %q to %q with file operation=%#v", fromDir, toDir, fo)
+	log.V(4).Infof("Finding move targets from %q to %q with file operation=%#v", fromDir, toDir, fo)
 	moves, err := findMoveTargets(fromDir, toDir, fo)
 	if err != nil {
 		return errors.Wrap(err, "could not find move targets")
 	}
 
 	for _, m := range moves {
-		glog.V(2).Infof("Move file from %q to %q", m.from, m.to)
+		log.V(2).Infof("Move file from %q to %q", m.from, m.to)
 		if err := os.MkdirAll(filepath.Dir(m.to), 0755); err != nil {
 			return errors.Wrapf(err, "failed to create move path %q", filepath.Dir(m.to))
 		}
 
-		if err = os.Rename(m.from, m.to); err != nil {
+		if err = renameOrCopy(m.from, m.to); err != nil {
 			return errors.Wrapf(err, "could not rename file from %q to %q", m.from, m.to)
 		}
 	}
-	glog.V(4).Infoln("Move operations are complete")
+	log.V(4).Infoln("Move operations are complete")
 	return nil
 }
 
@@ -151,33 +170,126 @@ func moveAllFiles(fromDir, toDir string, fos []index.FileOperation) error {
 	return nil
 }
 
-func moveToInstallDir(download, pluginDir, version string, fos []index.FileOperation) (string, error) {
-	glog.V(4).Infof("Creating plugin dir %q", pluginDir)
+// moveToInstallDir moves the files of the file operations from the extracted
+// download to the {pluginDir}/{version} directory. The files are staged in a
+// temporary directory in pluginDir, which is only renamed to the version
+// directory after all file operations succeed and the staged files have the
+// executable bin, so that a failed installation doesn't leave a partial version
+// directory behind.
+//
+// If set, setup is run on the version directory once the files are moved there,
+// e.g. to run the post-install hook. An existing version directory, e.g. of a
+// reinstalled plugin, is only removed once setup succeeds and restored if
+// moving the files or setup fails.
+func moveToInstallDir(download, pluginDir, version, bin string, windows bool, fos []index.FileOperation, setup func(dst string) error) (dst string, err error) {
+	installPath := filepath.Join(pluginDir, version)
+	if _, ok := pathutil.IsSubPath(pluginDir, installPath); !ok || installPath == filepath.Clean(pluginDir) {
+		return "", errors.Errorf("version %q is not a directory in the plugin directory %q", version, pluginDir)
+	}
+
+	log.V(4).Infof("Creating plugin dir %q", pluginDir)
 	if err := os.MkdirAll(pluginDir, 0755); err != nil {
 		return "", errors.Wrapf(err, "error creating path to %q", pluginDir)
 	}
+	defer func() {
+		if err != nil {
+			// only removed if no other version is installed
+			os.Remove(pluginDir)
+		}
+	}()
 
-	tempdir, err := ioutil.TempDir("", "krew-temp-move")
-	glog.V(4).Infof("Creating temp plugin move operations dir %q", tempdir)
+	tempdir, err := ioutil.TempDir(pluginDir, ".staging-")
+	log.V(4).Infof("Creating temp plugin move operations dir %q", tempdir)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to find a temporary director")
+		return "", errors.Wrap(err, "failed to create a temporary directory")
 	}
 	defer os.RemoveAll(tempdir)
 
+	// the files are moved out of the download
+	entries := topLevelEntries(download)
 	if err = moveAllFiles(download, tempdir, fos); err != nil {
 		return "", errors.Wrap(err, "failed to move files")
 	}
+	if err = checkBin(tempdir, bin, windows, entries); err != nil {
+		return "", err
+	}
 
-	installPath := filepath.Join(pluginDir, version)
-	glog.V(2).Infof("Move directory %q to %q", tempdir, installPath)
+	if _, statErr := os.Stat(installPath); statErr == nil {
+		var replacedDir string
+		if replacedDir, err = ioutil.TempDir(pluginDir, ".replaced-"); err != nil {
+			return "", errors.Wrap(err, "failed to create a temporary directory for the replaced installation")
+		}
+		defer os.RemoveAll(replacedDir)
+		replaced := filepath.Join(replacedDir, version)
+		log.V(2).Infof("Move the replaced installation %q to %q", installPath, replaced)
+		if err = os.Rename(installPath, replaced); err != nil {
+			return "", errors.Wrapf(err, "could not move the replaced installation %q", installPath)
+		}
+		defer func() {
+			if err != nil {
+				log.V(2).Infof("Restoring the replaced installation %q", installPath)
+				os.RemoveAll(installPath)
+				os.Rename(replaced, installPath)
+			}
+		}()
+	}
+
+	log.V(2).Infof("Move directory %q to %q", tempdir, installPath)
 	if err = moveOrCopyDir(tempdir, installPath); err != nil {
 		defer os.Remove(installPath)
 		return "", errors.Wrapf(err, "could not rename file from %q to %q", tempdir, installPath)
 	}
+	if setup != nil {
+		if err = setup(installPath); err != nil {
+			os.RemoveAll(installPath)
+			return "", err
+		}
+	}
 
 	return installPath, nil
 }
 
+// maxListedEntries is the number of archive entries listed in the error of a
+// missing bin.
+const maxListedEntries = 20
+
+// topLevelEntries returns the names of the first maxListedEntries entries in
+// dir, with a "/" suffix for directories.
+func topLevelEntries(dir string) []string {
+	items, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.V(2).Infof("Failed to list the entries of %q: %v", dir, err)
+		return nil
+	}
+	var entries []string
+	for _, item := range items {
+		if len(entries) == maxListedEntries {
+			return append(entries, "...")
+		}
+		name := item.Name()
+		if item.IsDir() {
+			name += "/"
+		}
+		entries = append(entries, name)
+	}
+	return entries
+}
+
+// checkBin returns an error if the plugin executable bin, relative to dir, is
+// not a file or, unless windows is set, not executable. The error lists the top
+// level entries of the extracted archive to help fixing the bin of the
+// manifest.
+func checkBin(dir, bin string, windows bool, entries []string) error {
+	fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(bin)))
+	if err != nil || fi.IsDir() {
+		return errors.Errorf("bin %q not found in extracted files, the archive contains: %s", bin, strings.Join(entries, ", "))
+	}
+	if !windows && fi.Mode()&0111 == 0 {
+		return errors.Errorf("bin %q is not executable (mode %s)", bin, fi.Mode())
+	}
+	return nil
+}
+
 // moveOrCopyDir will try to rename a dir or file. If rename is not supported a
 // manual copy will be performed. Existing files at "to" will be deleted
 func moveOrCopyDir(from, to string) error {
@@ -187,18 +299,24 @@ func moveOrCopyDir(from, to string) error {
 		return errors.Wrapf(err, "error checking move target dir %q", to)
 	}
 	if fi != nil && fi.IsDir() {
-		glog.V(4).Infof("There's already a directory at move target %q. deleting.", to)
+		log.V(4).Infof("There's already a directory at move target %q. deleting.", to)
 		if err := os.RemoveAll(to); err != nil {
 			return errors.Wrapf(err, "error cleaning up dir %q", to)
 		}
-		glog.V(4).Infof("Move target directory %q cleaned up", to)
+		log.V(4).Infof("Move target directory %q cleaned up", to)
 	}
 
-	err = os.Rename(from, to)
+	return renameOrCopy(from, to)
+}
+
+// renameOrCopy renames the file or directory at from to to. If they are on
+// different devices, it is copied instead.
+func renameOrCopy(from, to string) error {
+	err := os.Rename(from, to)
 	// Fallback for invalid cross-device link (errno:18).
 	if le, ok := err.(*os.LinkError); err != nil && ok {
 		if errno, ok := le.Err.(syscall.Errno); ok && errno == 18 {
-			glog.V(4).Infof("Cross-device link error (ERRNO=18), fallback to manual copy")
+			log.V(4).Infof("Cross-device link error (ERRNO=18), fallback to manual copy")
 			return copyDir(from, to)
 		}
 	}
@@ -212,10 +330,10 @@ func copyDir(from string, to string) (err error) {
 		}
 		newPath, _ := pathutil.ReplaceBase(path, from, to)
 		if info.IsDir() {
-			glog.V(4).Infof("Creating new dir %q", newPath)
+			log.V(4).Infof("Creating new dir %q", newPath)
 			err = os.MkdirAll(newPath, info.Mode())
 		} else {
-			glog.V(4).Infof("Copying file %q", newPath)
+			log.V(4).Infof("Copying file %q", newPath)
 			err = copyFile(path, newPath, info.Mode())
 		}
 		return err
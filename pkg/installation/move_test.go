@@ -185,6 +185,30 @@ func Test_getDirectMove(t *testing.T) {
 	}
 }
 
+func Test_validateFileOperations(t *testing.T) {
+	installDir := filepath.FromSlash("/krew/store/foo/v1")
+	tests := []struct {
+		name    string
+		fos     []index.FileOperation
+		wantErr bool
+	}{
+		{"root", []index.FileOperation{{From: "*", To: "."}}, false},
+		{"subdirectory", []index.FileOperation{{From: "bin/*", To: "bin"}}, false},
+		{"dot-dot within install dir", []index.FileOperation{{From: "*", To: "bin/../lib"}}, false},
+		{"to parent", []index.FileOperation{{From: "*", To: ".."}}, true},
+		{"to escapes", []index.FileOperation{{From: "*", To: "../../../../etc"}}, true},
+		{"to escapes after valid", []index.FileOperation{{From: "*", To: "."}, {From: "a", To: "bin/../../bar"}}, true},
+		{"from escapes", []index.FileOperation{{From: "../../../../etc/passwd", To: "."}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateFileOperations(installDir, tt.fos); (err != nil) != tt.wantErr {
+				t.Errorf("validateFileOperations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func Test_moveOrCopyDir_canMoveToNonExistingDir(t *testing.T) {
 	srcDir, cleanupSrc := testutil.NewTempDir(t)
 	defer cleanupSrc()
@@ -0,0 +1,104 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// platformsDirName is the name of the directory in the version directory of
+// an installed plugin that InstallOpts.AllPlatforms extracts the other
+// platforms to. It is hidden, so that it is not taken for a file of the plugin.
+const platformsDirName = ".platforms"
+
+// PlatformsPath returns the directory the platforms of the plugin version other
+// than the installed one are extracted to with InstallOpts.AllPlatforms, such
+// as {PlatformsPath}/linux-arm64/kubectl-foo.
+func PlatformsPath(installDir, plugin, version string) string {
+	return filepath.Join(installDir, plugin, version, platformsDirName)
+}
+
+// platformDirName returns the name of the directory the i-th platform of a
+// plugin is extracted to, which is OS-ARCH if its selector matches the os and
+// arch labels, the OS without an arch label, or the index of the platform.
+func platformDirName(p index.Platform, i int) string {
+	if p.Selector != nil {
+		goos, goarch := p.Selector.MatchLabels["os"], p.Selector.MatchLabels["arch"]
+		if goos != "" && goarch != "" {
+			return goos + "-" + goarch
+		}
+		if goos != "" {
+			return goos
+		}
+	}
+	return fmt.Sprintf("platform-%d", i)
+}
+
+// installOtherPlatforms downloads and extracts the platforms of the plugin
+// except for current to their directories in a staging directory in the plugin
+// directory, which install moves to PlatformsPath. Their executables are not
+// linked. If any of them fails, the platforms extracted so far are removed.
+func installOtherPlatforms(ctx context.Context, plugin index.Plugin, current index.Platform, installDir, downloadPath string, fetch fetchOpts) (platformsDir string, err error) {
+	pluginDir := filepath.Join(installDir, plugin.Name)
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		return "", errors.Wrapf(err, "error creating path to %q", pluginDir)
+	}
+	dir, err := ioutil.TempDir(pluginDir, platformsDirName+"-")
+	if err != nil {
+		os.Remove(pluginDir)
+		return "", errors.Wrap(err, "failed to create a temporary directory for the platforms")
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+			// only removed if no version is installed
+			os.Remove(pluginDir)
+		}
+	}()
+
+	seen := make(map[string]bool)
+	for i, p := range plugin.Spec.Platforms {
+		if reflect.DeepEqual(p, current) {
+			continue
+		}
+		name := platformDirName(p, i)
+		if seen[name] {
+			// another platform selects the same OS/arch with other labels
+			name = fmt.Sprintf("%s-%d", name, i)
+		}
+		seen[name] = true
+		if err := validateFileOperations(filepath.Join(dir, name), p.Files); err != nil {
+			return "", errors.Wrapf(err, "invalid file operations of platform %s", name)
+		}
+		log.V(1).Infof("Installing platform %s of plugin %s", name, plugin.Name)
+		platformFetch := fetch
+		if p.Selector != nil {
+			platformFetch.goos = p.Selector.MatchLabels["os"]
+		}
+		if _, err := downloadAndMove(ctx, name, p, filepath.Join(downloadPath, plugin.Name), dir, platformFetch, nil); err != nil {
+			return "", errors.Wrapf(err, "failed to install platform %s", name)
+		}
+	}
+	return dir, nil
+}
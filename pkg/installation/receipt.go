@@ -0,0 +1,140 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
+	"sigs.k8s.io/krew/pkg/pathutil"
+)
+
+// receiptFileName is the name of the receipt file in the directory of an
+// installed plugin, next to its version directories.
+const receiptFileName = "receipt.yaml"
+
+// Receipt records how the installed version of a plugin was installed.
+type Receipt struct {
+	// Plugin is the name of the plugin.
+	Plugin string `json:"plugin"`
+	// Version is the installed version, the name of the version directory.
+	Version string `json:"version"`
+	// Index is the name of the index the plugin manifest was loaded from. It
+	// is empty for plugins installed from a manifest file.
+	Index string `json:"index,omitempty"`
+	// URI is the download URI of the installed archive, before applying any
+	// mirror rules.
+	URI string `json:"uri"`
+	// Sha256 is the checksum the archive was verified against.
+	Sha256 string `json:"sha256,omitempty"`
+	// Files are the sha256 checksums of the regular files in the version
+	// directory after the installation, keyed by their slash separated path
+	// relative to it. Verify checks the installed files against them.
+	Files map[string]string `json:"files,omitempty"`
+	// InstalledAt is the time the installation completed.
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// ReadReceipt reads the receipt of the plugin in installDir. If the plugin has
+// no receipt, e.g. because it was installed by an older krew version, it returns
+// an error that can be checked with os.IsNotExist.
+func ReadReceipt(installDir, name string) (Receipt, error) {
+	if err := index.ValidatePluginName(name); err != nil {
+		return Receipt{}, err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(installDir, name, receiptFileName))
+	if err != nil {
+		return Receipt{}, err
+	}
+	var r Receipt
+	if err := yaml.Unmarshal(b, &r); err != nil {
+		return Receipt{}, errors.Wrapf(err, "failed to parse the receipt of plugin %s", name)
+	}
+	return r, nil
+}
+
+// writeReceipt replaces the receipt of the plugin in installDir. The receipt is
+// written to a temporary file first, so that it is never left half-written.
+func writeReceipt(installDir string, r Receipt) error {
+	b, err := yaml.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the receipt")
+	}
+	pluginDir := filepath.Join(installDir, r.Plugin)
+	f, err := ioutil.TempFile(pluginDir, ".receipt-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create a temporary receipt file")
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write the receipt")
+	}
+	path := filepath.Join(pluginDir, receiptFileName)
+	log.V(3).Infof("Writing the receipt of plugin %s to %q", r.Plugin, path)
+	return errors.Wrap(os.Rename(f.Name(), path), "failed to replace the receipt")
+}
+
+// recordInstallation writes the receipt of a completed installation with the
+// checksums of the installed files. Failing to write it doesn't fail the
+// installation, the installed version is then found from the plugin link
+// instead, so a receipt of a previous version is removed.
+func recordInstallation(installDir string, r Receipt) {
+	r.InstalledAt = time.Now().UTC()
+	if files, err := hashFiles(filepath.Join(installDir, r.Plugin, r.Version)); err != nil {
+		log.Warningf("Failed to record the checksums of the installed files of plugin %s, it can't be verified: %v", r.Plugin, err)
+	} else {
+		r.Files = files
+	}
+	if err := writeReceipt(installDir, r); err != nil {
+		log.Warningf("Failed to write the installation receipt of plugin %s: %v", r.Plugin, err)
+		if err := os.Remove(filepath.Join(installDir, r.Plugin, receiptFileName)); err != nil && !os.IsNotExist(err) {
+			log.Warningf("Failed to remove the outdated receipt of plugin %s: %v", r.Plugin, err)
+		}
+	}
+}
+
+// receiptVersion returns the installed version of the plugin recorded in its
+// receipt, if it has a receipt whose version directory exists.
+func receiptVersion(installDir, name string) (string, bool) {
+	r, err := ReadReceipt(installDir, name)
+	if os.IsNotExist(err) {
+		log.V(4).Infof("Plugin %s has no receipt", name)
+		return "", false
+	} else if err != nil {
+		log.Warningf("Ignoring the receipt of plugin %s: %v", name, err)
+		return "", false
+	}
+	pluginDir := filepath.Join(installDir, name)
+	versionDir := filepath.Join(pluginDir, r.Version)
+	if elems, ok := pathutil.IsSubPath(pluginDir, versionDir); !ok || len(elems) != 1 {
+		log.Warningf("Ignoring the receipt of plugin %s, it has an invalid version %q", name, r.Version)
+		return "", false
+	}
+	if fi, err := os.Stat(versionDir); err != nil || !fi.IsDir() {
+		log.V(2).Infof("Ignoring the receipt of plugin %s, the version %s is not installed", name, r.Version)
+		return "", false
+	}
+	return r.Version, true
+}
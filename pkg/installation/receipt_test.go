@@ -0,0 +1,123 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func TestInstallPlugin_writesReceipt(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+
+	plugin := testPlugin()
+	plugin.Spec.Platforms[0].URI = filepath.Join(testdataPath(t), "archives", "foo.tar.gz")
+	if err := InstallPlugin(InstallOpts{
+		Plugin:         plugin,
+		Index:          "company",
+		InstallPath:    tmpDir.Path("store"),
+		BinPath:        tmpDir.Path("bin"),
+		DownloadPath:   tmpDir.Path("downloads"),
+		AllowLocalURIs: true,
+	}); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+
+	r, err := ReadReceipt(tmpDir.Path("store"), "foo")
+	if err != nil {
+		t.Fatalf("ReadReceipt() error = %v", err)
+	}
+	sha := plugin.Spec.Platforms[0].Sha256
+	if r.Plugin != "foo" || r.Version != sha || r.Index != "company" || r.URI != plugin.Spec.Platforms[0].URI || r.Sha256 != sha {
+		t.Errorf("unexpected receipt %+v", r)
+	}
+	if r.InstalledAt.IsZero() {
+		t.Error("expected the receipt to have the installation time")
+	}
+}
+
+func TestReadReceipt_notExists(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	if _, err := ReadReceipt(tmpDir.Root(), "foo"); !os.IsNotExist(err) {
+		t.Fatalf("ReadReceipt() error = %v, want a not exist error", err)
+	}
+	if _, err := ReadReceipt(tmpDir.Root(), "../foo"); err == nil {
+		t.Fatal("ReadReceipt() expected an error for an unsafe plugin name")
+	}
+}
+
+func Test_findInstalledPluginVersion_receipt(t *testing.T) {
+	tests := []struct {
+		name    string
+		receipt string
+		want    string
+	}{
+		{
+			name:    "version from the receipt",
+			receipt: "plugin: foo\nversion: v1.0.0\n",
+			want:    "v1.0.0",
+		},
+		{
+			name:    "receipt of a version that is not installed",
+			receipt: "plugin: foo\nversion: v2.0.0\n",
+			want:    "v0.1.0",
+		},
+		{
+			name:    "receipt with an unsafe version",
+			receipt: "plugin: foo\nversion: ../v1.0.0\n",
+			want:    "v0.1.0",
+		},
+		{
+			name:    "invalid receipt",
+			receipt: "version: [",
+			want:    "v0.1.0",
+		},
+		{
+			name: "no receipt",
+			want: "v0.1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			tmpDir.Write("store/foo/v0.1.0/kubectl-foo", nil)
+			tmpDir.Write("store/foo/v1.0.0/kubectl-foo", nil)
+			if tt.receipt != "" {
+				tmpDir.Write("store/foo/"+receiptFileName, []byte(tt.receipt))
+			}
+			tmpDir.Write("bin/.keep", nil)
+			link := filepath.Join(tmpDir.Path("bin"), pluginNameToBin("foo", isWindows()))
+			if err := os.Symlink(tmpDir.Path("store/foo/v0.1.0/kubectl-foo"), link); err != nil {
+				t.Skipf("can't create symlinks: %v", err)
+			}
+
+			got, ok, err := findInstalledPluginVersion(tmpDir.Path("store"), tmpDir.Path("bin"), "foo")
+			if err != nil || !ok {
+				t.Fatalf("findInstalledPluginVersion() = %v, %v", ok, err)
+			}
+			if got != tt.want {
+				t.Errorf("findInstalledPluginVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// Shims are batch files that run the plugin executable. They are created on
+// Windows instead of symlinks if the user is not allowed to create symlinks.
+
+const shimHeader = "@echo off\r\nREM Generated by krew, do not edit.\r\n"
+
+var shimTargetRegexp = regexp.MustCompile(`(?m)^"([^"]+)" %\*\r?$`)
+
+// pluginNameToShim returns the name of the shim file for the plugin name.
+func pluginNameToShim(name string) string {
+	return pluginNameToBin(name, false) + ".cmd"
+}
+
+// writeShim writes a shim at path that runs binary with the arguments it is
+// invoked with.
+func writeShim(path, binary string) error {
+	if strings.Contains(binary, `"`) {
+		return errors.Errorf("can't create a shim for %q, the path contains quotes", binary)
+	}
+	content := shimHeader + fmt.Sprintf("\"%s\" %%*\r\n", binary)
+	log.V(2).Infof("Creating shim at %q for %q", path, binary)
+	return ioutil.WriteFile(path, []byte(content), 0755)
+}
+
+// readShim returns the path of the executable that the shim at path runs. If
+// there's no file at path, it returns an error that can be checked with
+// os.IsNotExist.
+func readShim(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	content := string(b)
+	m := shimTargetRegexp.FindStringSubmatch(content)
+	if !strings.HasPrefix(content, shimHeader) || m == nil {
+		return "", errors.Errorf("file %q is not a shim created by krew", path)
+	}
+	return m[1], nil
+}
+
+// removeShim removes the shim at path if it exists. Files that are not shims
+// created by krew are not removed.
+func removeShim(path string) error {
+	if _, err := readShim(path); os.IsNotExist(err) {
+		log.V(3).Infof("No shim found at %q", path)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return errors.Wrapf(err, "failed to remove the shim in %q", path)
+	}
+	log.V(3).Infof("Removed shim from %q", path)
+	return nil
+}
+
+// isDanglingShim returns true if path is a shim whose target does not exist.
+func isDanglingShim(path string) bool {
+	target, err := readShim(path)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(target)
+	return os.IsNotExist(err)
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"os"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_pluginNameToShim(t *testing.T) {
+	if got, want := pluginNameToShim("foo-bar"), "kubectl-foo_bar.cmd"; got != want {
+		t.Errorf("pluginNameToShim() = %q, want %q", got, want)
+	}
+}
+
+func Test_writeShim_readShim(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	target := `C:\Users\some user\.krew\store\foo\v1.0.0\kubectl-foo.exe`
+	if err := writeShim(tmpDir.Path("kubectl-foo.cmd"), target); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readShim(tmpDir.Path("kubectl-foo.cmd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != target {
+		t.Errorf("readShim() = %q, want %q", got, target)
+	}
+
+	if err := writeShim(tmpDir.Path("quoted.cmd"), `C:\foo"bar\kubectl-foo.exe`); err == nil {
+		t.Error("expected an error for a path with quotes")
+	}
+	if _, err := readShim(tmpDir.Path("missing.cmd")); !os.IsNotExist(err) {
+		t.Errorf("readShim() error = %v for a missing file, want a not exist error", err)
+	}
+	tmpDir.Write("other.cmd", []byte("@echo off\r\n\"C:\\foo.exe\" %*\r\n"))
+	if _, err := readShim(tmpDir.Path("other.cmd")); err == nil {
+		t.Error("expected an error for a batch file not created by krew")
+	}
+}
+
+func Test_removeShim(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	if err := removeShim(tmpDir.Path("missing.cmd")); err != nil {
+		t.Errorf("removeShim() error = %v for a missing file", err)
+	}
+
+	tmpDir.Write("other.cmd", []byte("echo hello"))
+	if err := removeShim(tmpDir.Path("other.cmd")); err == nil {
+		t.Error("expected an error for a file that is not a shim")
+	}
+	if _, err := os.Stat(tmpDir.Path("other.cmd")); err != nil {
+		t.Errorf("expected the file that is not a shim to be kept: %v", err)
+	}
+
+	if err := writeShim(tmpDir.Path("kubectl-foo.cmd"), tmpDir.Path("kubectl-foo.exe")); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeShim(tmpDir.Path("kubectl-foo.cmd")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("kubectl-foo.cmd")); !os.IsNotExist(err) {
+		t.Errorf("expected the shim to be removed, stat error = %v", err)
+	}
+}
+
+func Test_findInstalledPluginVersion_shim(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("store/foo-bar/deadbeef/kubectl-foo-bar.exe", nil)
+	tmpDir.Write("bin/.keep", nil)
+	if err := writeShim(tmpDir.Path("bin/kubectl-foo_bar.cmd"), tmpDir.Path("store/foo-bar/deadbeef/kubectl-foo-bar.exe")); err != nil {
+		t.Fatal(err)
+	}
+
+	version, ok, err := findInstalledPluginVersion(tmpDir.Path("store"), tmpDir.Path("bin"), "foo-bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || version != "deadbeef" {
+		t.Errorf("findInstalledPluginVersion() = %q, %v, want %q, true", version, ok, "deadbeef")
+	}
+
+	if err := Uninstall(tmpDir.Path("store"), tmpDir.Path("bin"), "foo-bar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmpDir.Path("bin/kubectl-foo_bar.cmd")); !os.IsNotExist(err) {
+		t.Errorf("expected the shim to be removed on uninstall, stat error = %v", err)
+	}
+}
+
+func TestRemoveDanglingLinks_shim(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("store/foo/v1/kubectl-foo.exe", nil)
+	tmpDir.Write("bin/.keep", nil)
+	if err := writeShim(tmpDir.Path("bin/kubectl-foo.cmd"), tmpDir.Path("store/foo/v1/kubectl-foo.exe")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeShim(tmpDir.Path("bin/kubectl-gone.cmd"), tmpDir.Path("store/gone/v1/kubectl-gone.exe")); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := RemoveDanglingLinks(tmpDir.Path("bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != tmpDir.Path("bin/kubectl-gone.cmd") {
+		t.Errorf("RemoveDanglingLinks() = %v, want only the shim of the missing plugin", removed)
+	}
+}
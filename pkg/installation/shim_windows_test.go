@@ -0,0 +1,73 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package installation
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func Test_createOrUpdateLink_windows(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("store/foo/v1/kubectl-foo.exe", nil)
+	tmpDir.Write("bin/.keep", nil)
+
+	// Depending on the privileges of the user, either a symlink or a shim is
+	// created, and both must be detected as the installed version.
+	if err := createOrUpdateLink(tmpDir.Path("bin"), tmpDir.Path("store/foo/v1/kubectl-foo.exe"), "foo", false, true); err != nil {
+		t.Fatal(err)
+	}
+	version, ok, err := findInstalledPluginVersion(tmpDir.Path("store"), tmpDir.Path("bin"), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || version != "v1" {
+		t.Errorf("findInstalledPluginVersion() = %q, %v, want %q, true", version, ok, "v1")
+	}
+
+	if err := Uninstall(tmpDir.Path("store"), tmpDir.Path("bin"), "foo"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kubectl-foo.exe", "kubectl-foo.cmd"} {
+		if _, err := os.Lstat(tmpDir.Path("bin/" + name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, lstat error = %v", name, err)
+		}
+	}
+}
+
+func Test_writeShim_runs(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("some dir/target.cmd", []byte("@echo off\r\necho args:%*\r\nexit /b 3\r\n"))
+	if err := writeShim(tmpDir.Path("kubectl-foo.cmd"), tmpDir.Path("some dir/target.cmd")); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(tmpDir.Path("kubectl-foo.cmd"), "a", "b").Output()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("expected the shim to exit with the status of the target, got %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "args:a b" {
+		t.Errorf("shim output = %q, want %q", got, "args:a b")
+	}
+}
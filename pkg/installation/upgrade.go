@@ -15,47 +15,230 @@
 package installation
 
 import (
+	"context"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"regexp"
+	"strings"
 
+	"sigs.k8s.io/krew/pkg/download"
 	"sigs.k8s.io/krew/pkg/environment"
 	"sigs.k8s.io/krew/pkg/index"
 
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
 )
 
+// UpgradeOpts configures how Upgrade downloads the new plugin version.
+type UpgradeOpts struct {
+	// Index is the name of the index the plugin manifest is from, which is
+	// recorded in the installation receipt.
+	Index string
+
+	// HTTPClient, if set, is used for downloading the plugin archive instead
+	// of http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Progress, if set, receives the progress of downloading the plugin
+	// archive from an http(s) URI.
+	Progress io.Writer
+
+	// Mirrors rewrite the URI of the plugin archive before downloading it.
+	Mirrors []download.MirrorRule
+
+	// AllowLocalURIs, if set, reads local plugin archives like
+	// InstallOpts.AllowLocalURIs.
+	AllowLocalURIs bool
+
+	// ExtractLimits cap the extraction of the plugin archive like
+	// InstallOpts.ExtractLimits.
+	ExtractLimits download.ExtractLimits
+
+	// SignatureVerifier, if set, verifies the signature of the archive like
+	// InstallOpts.SignatureVerifier.
+	SignatureVerifier download.SignatureVerifier
+
+	// ResumeDownloads, if set, resumes retried downloads like
+	// InstallOpts.ResumeDownloads.
+	ResumeDownloads bool
+
+	// RelativeLinks, if set, links the new version with a relative path like
+	// InstallOpts.RelativeLinks.
+	RelativeLinks bool
+
+	// AllowHooks and HookOutput configure running the post-install hook of
+	// the new version like InstallOpts.AllowHooks and InstallOpts.HookOutput.
+	AllowHooks bool
+	HookOutput io.Writer
+
+	// ForceOS and ForceArch, if set, override the OS/arch used to find the
+	// new version like InstallOpts.ForceOS and InstallOpts.ForceArch.
+	ForceOS   string
+	ForceArch string
+}
+
 // Upgrade will reinstall and delete the old plugin. The operation tries
 // to not get the plugin dir in a bad state if it fails during the process.
-func Upgrade(p environment.Paths, plugin index.Plugin) error {
-	oldVersion, ok, err := findInstalledPluginVersion(p.InstallPath(), p.BinPath(), plugin.Name)
+// The new version is downloaded as configured by opts.
+func Upgrade(p environment.Paths, plugin index.Plugin, opts UpgradeOpts) error {
+	return UpgradeContext(context.Background(), p, plugin, opts)
+}
+
+// UpgradeContext is like Upgrade, but stops downloading the new version once
+// ctx is canceled, leaving the installed version in place.
+func UpgradeContext(ctx context.Context, p environment.Paths, plugin index.Plugin, opts UpgradeOpts) error {
+	return typedError(upgradeContext(ctx, p, plugin, opts))
+}
+
+func upgradeContext(ctx context.Context, p environment.Paths, plugin index.Plugin, opts UpgradeOpts) error {
+	goos, goarch, err := OSArch(opts.ForceOS, opts.ForceArch)
+	if err != nil {
+		return err
+	}
+
+	oldVersion, ok, err := findInstalledPluginVersionFor(p.InstallPath(), p.BinPath(), plugin.Name, goos == "windows")
 	if err != nil {
 		return errors.Wrap(err, "could not detect installed plugin oldVersion")
 	}
 	if !ok {
-		return errors.Errorf("can't upgrade plugin %q, it is not installed", plugin.Name)
+		return errors.Wrapf(ErrNotInstalled, "can't upgrade plugin %q", plugin.Name)
 	}
 
 	// Check allowed installation
-	newVersion, uri, fos, binName, err := getDownloadTarget(plugin)
-	if oldVersion == newVersion {
-		return ErrIsAlreadyUpgraded
-	}
+	newVersion, platform, err := getDownloadTarget(plugin, goos, goarch)
 	if err != nil {
 		return errors.Wrap(err, "failed to get the current download target")
 	}
+	if !IsArchiveUpgrade(oldVersion, installedSha256(p.InstallPath(), plugin.Name, oldVersion), newVersion, platform.Sha256) {
+		return ErrIsAlreadyUpgraded
+	}
 
 	// Re-Install
-	glog.V(1).Infof("Installing new version %s", newVersion)
-	if err := install(plugin.Name, newVersion, uri, binName, p, fos, ""); err != nil {
+	log.V(1).Infof("Installing new version %s", newVersion)
+	if err := install(ctx, plugin.Name, newVersion, platform, p.InstallPath(), p.BinPath(), p.DownloadPath(), "", opts.RelativeLinks, fetchOpts{
+		retries:           DefaultDownloadRetries,
+		httpClient:        opts.HTTPClient,
+		progress:          opts.Progress,
+		mirrors:           opts.Mirrors,
+		allowLocalURIs:    opts.AllowLocalURIs,
+		extractLimits:     opts.ExtractLimits,
+		resume:            opts.ResumeDownloads,
+		signatureVerifier: opts.SignatureVerifier,
+		diskSpace:         download.AvailableDiskSpace,
+		goos:              goos,
+	}, hookOpts{allow: opts.AllowHooks, output: opts.HookOutput}); err != nil {
 		return errors.Wrap(err, "failed to install new version")
 	}
+	recordInstallation(p.InstallPath(), Receipt{
+		Plugin:  plugin.Name,
+		Version: newVersion,
+		Index:   opts.Index,
+		URI:     platform.URI,
+		Sha256:  strings.ToLower(platform.Sha256),
+	})
+
+	if newVersion == oldVersion {
+		// the re-published archive replaced the version directory
+		return nil
+	}
 
 	// Clean old installations
-	glog.V(4).Infof("Starting old version cleanup")
+	log.V(4).Infof("Starting old version cleanup")
 	return removePluginVersionFromFS(p, plugin, newVersion, oldVersion)
 }
 
+// IsUpgrade returns true if the available version of a plugin should replace
+// the installed one. Semantic versions are only upgraded to newer versions,
+// other versions (such as checksums) whenever they differ.
+func IsUpgrade(installed, available string) bool {
+	if installed == available {
+		return false
+	}
+	newer, err := IsNewerVersion(installed, available)
+	if err != nil {
+		return true
+	}
+	return newer
+}
+
+// IsArchiveUpgrade returns true if the available version of a plugin, whose
+// archive has the checksum availableSha256, should replace the installed
+// version installed from an archive with the checksum installedSha256. The
+// checksums are empty if they are unknown. The versions are compared with
+// IsUpgrade, except that an archive with the same checksum is never an
+// upgrade, e.g. of a version installed by an older krew under its checksum,
+// and the same version is upgraded if its archive changed, e.g. because the
+// release was re-published.
+func IsArchiveUpgrade(installed, installedSha256, available, availableSha256 string) bool {
+	installedSha256, availableSha256 = strings.ToLower(installedSha256), strings.ToLower(availableSha256)
+	if installedSha256 != "" && availableSha256 != "" {
+		if installedSha256 == availableSha256 {
+			return false
+		}
+		if installed == available {
+			return true
+		}
+	}
+	return IsUpgrade(installed, available)
+}
+
+// HasUpgradeFor returns true if the plugin installed in installDir as version
+// installed has an upgrade on the given OS/arch according to IsArchiveUpgrade.
+// It returns false if none of the plugin's platforms match.
+func HasUpgradeFor(installDir string, plugin index.Plugin, installed, goos, goarch string) (bool, error) {
+	available, platform, err := getDownloadTarget(plugin, goos, goarch)
+	if err == ErrNoMatchingPlatform {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return IsArchiveUpgrade(installed, installedSha256(installDir, plugin.Name, installed), available, platform.Sha256), nil
+}
+
+// installedSha256 returns the checksum of the archive the version of the
+// plugin in installDir was installed from, as recorded in its receipt. A
+// version without it that is named after a checksum, as by older krew
+// versions, is its checksum. It is empty if it is unknown.
+func installedSha256(installDir, plugin, version string) string {
+	if r, err := ReadReceipt(installDir, plugin); err == nil && r.Version == version && r.Sha256 != "" {
+		return strings.ToLower(r.Sha256)
+	}
+	if sha256VersionRegexp.MatchString(version) {
+		return strings.ToLower(version)
+	}
+	return ""
+}
+
+// sha256VersionRegexp matches versions named after the sha256 checksum of the
+// plugin archive.
+var sha256VersionRegexp = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// AvailableVersion returns the version of the plugin that would be installed
+// on the current system. It returns false if none of the plugin's platforms
+// match the system.
+func AvailableVersion(plugin index.Plugin) (string, bool, error) {
+	goos, goarch, err := osArch()
+	if err != nil {
+		return "", false, err
+	}
+	return AvailableVersionFor(plugin, goos, goarch)
+}
+
+// AvailableVersionFor returns the version of the plugin that would be
+// installed on the given OS/arch. It returns false if none of the plugin's
+// platforms match.
+func AvailableVersionFor(plugin index.Plugin, goos, goarch string) (string, bool, error) {
+	version, _, err := getDownloadTarget(plugin, goos, goarch)
+	if err == ErrNoMatchingPlatform {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return version, true, nil
+}
+
 // removePluginVersionFromFS will remove a plugin directly if it not krew.
 
 // Krew on Windows needs special care because active directories can't be
@@ -64,7 +247,7 @@ func Upgrade(p environment.Paths, plugin index.Plugin) error {
 func removePluginVersionFromFS(p environment.Paths, plugin index.Plugin, newVersion, oldVersion string) error {
 	// Cleanup if we haven't updated krew during this execution.
 	if plugin.Name == krewPluginName {
-		glog.V(1).Infof("Handling removal for older version of krew")
+		log.V(1).Infof("Handling removal for older version of krew")
 		execPath, err := os.Executable()
 		if err != nil {
 			return errors.Wrap(err, "could not get krew's own executable path")
@@ -73,12 +256,12 @@ func removePluginVersionFromFS(p environment.Paths, plugin index.Plugin, newVers
 		if err != nil {
 			return errors.Wrap(err, "failed to find current krew version")
 		}
-		glog.V(1).Infof("Detected running krew version=%s", executedKrewVersion)
+		log.V(1).Infof("Detected running krew version=%s", executedKrewVersion)
 		return handleKrewRemove(p, plugin, newVersion, executedKrewVersion)
 	}
 
-	glog.V(1).Infof("Remove old plugin installation under %q", p.PluginVersionInstallPath(plugin.Name, oldVersion))
-	return os.RemoveAll(p.PluginVersionInstallPath(plugin.Name, oldVersion))
+	log.V(1).Infof("Remove old plugin installation under %q", p.PluginVersionInstallPath(plugin.Name, oldVersion))
+	return removeInstallDir(p.InstallPath(), p.PluginVersionInstallPath(plugin.Name, oldVersion))
 }
 
 // handleKrewRemove will remove and unlink old krew versions.
@@ -94,12 +277,12 @@ func handleKrewRemove(p environment.Paths, plugin index.Plugin, newVersion, curr
 		}
 		// Delete old dir
 		if f.Name() != newVersion && f.Name() != currentKrewVersion {
-			glog.V(1).Infof("Remove old krew installation under %q", pluginVersionPath)
+			log.V(1).Infof("Remove old krew installation under %q", pluginVersionPath)
 			if err = os.RemoveAll(pluginVersionPath); err != nil {
 				return errors.Wrapf(err, "can't remove plugin oldVersion=%q, path=%q", f.Name(), pluginVersionPath)
 			}
 		} else if f.Name() != newVersion {
-			glog.V(1).Infof("Unlink krew installation under %q", pluginVersionPath)
+			log.V(1).Infof("Unlink krew installation under %q", pluginVersionPath)
 			// TODO(ahmetb,lbb) is this part implemented???
 		}
 	}
@@ -0,0 +1,123 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/environment"
+	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func localTestPlugin(t *testing.T, version string) index.Plugin {
+	plugin := testPlugin()
+	plugin.Spec.Version = version
+	plugin.Spec.Platforms[0].URI = filepath.Join(testdataPath(t), "archives", "foo.tar.gz")
+	return plugin
+}
+
+func TestUpgrade_republishedArchive(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+	p, err := environment.NewPaths(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := localTestPlugin(t, "v1.0.0")
+	if err := InstallPlugin(InstallOpts{
+		Plugin:         plugin,
+		InstallPath:    p.InstallPath(),
+		BinPath:        p.BinPath(),
+		DownloadPath:   p.DownloadPath(),
+		AllowLocalURIs: true,
+	}); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	// pretend v1.0.0 was installed from an archive published earlier
+	r, err := ReadReceipt(p.InstallPath(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Sha256 = strings.Repeat("0", 64)
+	if err := writeReceipt(p.InstallPath(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if upgrade, err := HasUpgradeFor(p.InstallPath(), plugin, "v1.0.0", runtime.GOOS, runtime.GOARCH); err != nil || !upgrade {
+		t.Fatalf("HasUpgradeFor() = (%v, %v), want an upgrade", upgrade, err)
+	}
+	if err := Upgrade(p, plugin, UpgradeOpts{AllowLocalURIs: true}); err != nil {
+		t.Fatalf("Upgrade() error = %+v", err)
+	}
+	r, err = ReadReceipt(p.InstallPath(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := plugin.Spec.Platforms[0].Sha256; r.Version != "v1.0.0" || r.Sha256 != want {
+		t.Errorf("receipt = (%q, %q), want (%q, %q)", r.Version, r.Sha256, "v1.0.0", want)
+	}
+	if _, err := os.Stat(filepath.Join(p.PluginVersionInstallPath("foo", "v1.0.0"), "kubectl-foo")); err != nil {
+		t.Errorf("expected the re-published version to stay installed: %v", err)
+	}
+
+	if err := Upgrade(p, plugin, UpgradeOpts{AllowLocalURIs: true}); !stderrors.Is(err, ErrIsAlreadyUpgraded) {
+		t.Errorf("Upgrade() error = %v, want %v", err, ErrIsAlreadyUpgraded)
+	}
+}
+
+func TestUpgrade_checksumVersion(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+	tmpDir.Write("bin/.keep", nil)
+	p, err := environment.NewPaths(tmpDir.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a semantic version the plugin is installed under its checksum,
+	// like older krew versions did
+	if err := InstallPlugin(InstallOpts{
+		Plugin:         localTestPlugin(t, ""),
+		InstallPath:    p.InstallPath(),
+		BinPath:        p.BinPath(),
+		DownloadPath:   p.DownloadPath(),
+		AllowLocalURIs: true,
+	}); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	if err := os.Remove(filepath.Join(p.PluginInstallPath("foo"), receiptFileName)); err != nil {
+		t.Fatal(err)
+	}
+	sha := testPlugin().Spec.Platforms[0].Sha256
+
+	plugin := localTestPlugin(t, "v1.0.0")
+	if upgrade, err := HasUpgradeFor(p.InstallPath(), plugin, sha, runtime.GOOS, runtime.GOARCH); err != nil || upgrade {
+		t.Errorf("HasUpgradeFor() = (%v, %v), want no upgrade", upgrade, err)
+	}
+	if err := Upgrade(p, plugin, UpgradeOpts{AllowLocalURIs: true}); !stderrors.Is(err, ErrIsAlreadyUpgraded) {
+		t.Errorf("Upgrade() error = %v, want %v", err, ErrIsAlreadyUpgraded)
+	}
+	if _, err := os.Stat(p.PluginVersionInstallPath("foo", sha)); err != nil {
+		t.Errorf("expected the checksum version to stay installed: %v", err)
+	}
+}
@@ -15,18 +15,21 @@
 package installation
 
 import (
+	stderrors "errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
 	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
 	"sigs.k8s.io/krew/pkg/pathutil"
 )
 
@@ -34,117 +37,465 @@ import (
 // matches the OS/arch of the current machine (can be overridden via KREW_OS
 // and/or KREW_ARCH).
 func GetMatchingPlatform(p index.Plugin) (index.Platform, bool, error) {
-	os, arch := osArch()
-	glog.V(4).Infof("Using os=%s arch=%s", os, arch)
+	os, arch, err := osArch()
+	if err != nil {
+		return index.Platform{}, false, err
+	}
+	return GetMatchingPlatformFor(p, os, arch)
+}
+
+// GetMatchingPlatformFor finds the platform spec in the specified plugin that
+// matches the given OS/arch.
+func GetMatchingPlatformFor(p index.Plugin, os, arch string) (index.Platform, bool, error) {
+	log.V(4).Infof("Using os=%s arch=%s", os, arch)
 	return matchPlatformToSystemEnvs(p, os, arch)
 }
 
+// OSArch returns the OS/arch combination to match plugin platforms against.
+// Non-empty overrideOS and overrideArch values take precedence over the
+// KREW_OS and KREW_ARCH environment variables, which take precedence over the
+// current system.
+func OSArch(overrideOS, overrideArch string) (string, string, error) {
+	goos, goarch, err := osArch()
+	if err != nil {
+		return "", "", err
+	}
+	if overrideOS != "" {
+		if !contains(knownOS, overrideOS) {
+			return "", "", errors.Errorf("%q is not a recognized operating system, must be one of: %s", overrideOS, strings.Join(knownOS, ", "))
+		}
+		goos = overrideOS
+	}
+	if overrideArch != "" {
+		if !contains(knownArch, overrideArch) {
+			return "", "", errors.Errorf("%q is not a recognized architecture, must be one of: %s", overrideArch, strings.Join(knownArch, ", "))
+		}
+		goarch = overrideArch
+	}
+	return goos, goarch, nil
+}
+
+var (
+	// knownOS and knownArch are the GOOS and GOARCH values accepted in the
+	// KREW_OS and KREW_ARCH overrides.
+	knownOS = []string{"aix", "android", "darwin", "dragonfly", "freebsd",
+		"illumos", "js", "linux", "netbsd", "openbsd", "plan9", "solaris",
+		"windows"}
+	knownArch = []string{"386", "amd64", "arm", "arm64", "mips", "mips64",
+		"mips64le", "mipsle", "ppc64", "ppc64le", "riscv64", "s390x", "wasm"}
+)
+
 // osArch returns the OS/arch combination to be used on the current system. It
-// can be overridden by setting KREW_OS and/or KREW_ARCH environment variables.
-func osArch() (string, string) {
+// can be overridden by setting KREW_OS and/or KREW_ARCH environment variables,
+// which must be valid GOOS and GOARCH values.
+func osArch() (string, string, error) {
 	goos, goarch := runtime.GOOS, runtime.GOARCH
 	envOS, envArch := os.Getenv("KREW_OS"), os.Getenv("KREW_ARCH")
 	if envOS != "" {
+		if !contains(knownOS, envOS) {
+			return "", "", errors.Errorf("KREW_OS=%q is not a recognized operating system, must be one of: %s", envOS, strings.Join(knownOS, ", "))
+		}
 		goos = envOS
 	}
 	if envArch != "" {
+		if !contains(knownArch, envArch) {
+			return "", "", errors.Errorf("KREW_ARCH=%q is not a recognized architecture, must be one of: %s", envArch, strings.Join(knownArch, ", "))
+		}
 		goarch = envArch
 	}
-	return goos, goarch
+	return goos, goarch, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 func matchPlatformToSystemEnvs(p index.Plugin, os, arch string) (index.Platform, bool, error) {
-	envLabels := labels.Set{
-		"os":   os,
-		"arch": arch,
+	matches, err := MatchingPlatforms(p, os, arch)
+	if err != nil || len(matches) == 0 {
+		return index.Platform{}, false, err
+	}
+	return mostSpecificPlatform(matches), true, nil
+}
+
+// mostSpecificPlatform returns the platform in matches whose selector has the
+// most requirements, or the first of them in manifest order if several have as
+// many.
+func mostSpecificPlatform(matches []index.Platform) index.Platform {
+	best := matches[0]
+	for _, p := range matches[1:] {
+		if selectorRequirements(p.Selector) > selectorRequirements(best.Selector) {
+			best = p
+		}
+	}
+	return best
+}
+
+// selectorRequirements returns the number of labels and expressions of sel.
+func selectorRequirements(sel *metav1.LabelSelector) int {
+	if sel == nil {
+		return 0
 	}
-	glog.V(2).Infof("Matching platform for labels(%v)", envLabels)
+	return len(sel.MatchLabels) + len(sel.MatchExpressions)
+}
+
+// platformLabelsEnv is the environment variable with extra labels that platform
+// selectors are matched against, e.g. "libc=musl,distro=alpine".
+const platformLabelsEnv = "KREW_PLATFORM_LABELS"
+
+// platformLabels returns the os and arch labels and the extra labels in the
+// KREW_PLATFORM_LABELS environment variable, which can't override os or arch.
+func platformLabels(goos, goarch string) (labels.Set, error) {
+	envLabels, err := labels.ConvertSelectorToLabelsMap(os.Getenv(platformLabelsEnv))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", platformLabelsEnv)
+	}
+	for _, key := range []string{"os", "arch"} {
+		if _, ok := envLabels[key]; ok {
+			return nil, errors.Errorf("%s can't set the %q label, use KREW_OS and KREW_ARCH instead", platformLabelsEnv, key)
+		}
+	}
+	envLabels["os"] = goos
+	envLabels["arch"] = goarch
+	return envLabels, nil
+}
+
+// MatchingPlatforms returns all platforms in the specified plugin whose
+// selectors match the given OS/arch and the labels in KREW_PLATFORM_LABELS, in
+// the order they appear in the manifest. A well-formed manifest has at most one
+// matching platform per OS/arch; if there are several, the one with the most
+// selector requirements is installed.
+func MatchingPlatforms(p index.Plugin, goos, goarch string) ([]index.Platform, error) {
+	envLabels, err := platformLabels(goos, goarch)
+	if err != nil {
+		return nil, err
+	}
+	log.V(2).Infof("Matching platform for labels(%v)", envLabels)
+	var out []index.Platform
 	for i, platform := range p.Spec.Platforms {
 		sel, err := metav1.LabelSelectorAsSelector(platform.Selector)
 		if err != nil {
-			return index.Platform{}, false, errors.Wrap(err, "failed to compile label selector")
+			return nil, errors.Wrap(err, "failed to compile label selector")
 		}
 		if sel.Matches(envLabels) {
-			glog.V(2).Infof("Found matching platform with index (%d)", i)
-			return platform, true, nil
+			log.V(2).Infof("Found matching platform with index (%d)", i)
+			out = append(out, platform)
 		}
 	}
-	return index.Platform{}, false, nil
+	return out, nil
+}
+
+// SuspiciousLinkError is returned for a plugin whose link (or shim) points
+// outside of a version directory of the plugin. Krew never creates such links,
+// so the link may have been tampered with.
+type SuspiciousLinkError struct {
+	Plugin string
+	// Target is the path the link points to.
+	Target string
 }
 
+func (e *SuspiciousLinkError) Error() string {
+	return fmt.Sprintf("the link of plugin %s points to %q outside of its installation directory, it may have been tampered with (reinstall the plugin with \"kubectl krew install --force %s\")",
+		e.Plugin, e.Target, e.Plugin)
+}
+
+// findInstalledPluginVersion returns the installed version of the plugin. It
+// returns a *SuspiciousLinkError if the plugin link doesn't point into the
+// installation directory of the plugin.
 func findInstalledPluginVersion(installPath, binDir, pluginName string) (name string, installed bool, err error) {
-	if !index.IsSafePluginName(pluginName) {
-		return "", false, errors.Errorf("the plugin name %q is not allowed", pluginName)
+	return findInstalledPluginVersionFor(installPath, binDir, pluginName, isWindows())
+}
+
+// findInstalledPluginVersionFor is like findInstalledPluginVersion, but looks
+// for the link with the .exe extension if windows is set.
+func findInstalledPluginVersionFor(installPath, binDir, pluginName string, windows bool) (name string, installed bool, err error) {
+	if err := index.ValidatePluginName(pluginName); err != nil {
+		return "", false, err
+	}
+	log.V(3).Infof("Searching for installed versions of %s in %q", pluginName, binDir)
+	link, err := os.Readlink(filepath.Join(binDir, pluginNameToBin(pluginName, windows)))
+	if os.IsNotExist(err) {
+		link, err = readShim(filepath.Join(binDir, pluginNameToShim(pluginName)))
 	}
-	glog.V(3).Infof("Searching for installed versions of %s in %q", pluginName, binDir)
-	link, err := os.Readlink(filepath.Join(binDir, pluginNameToBin(pluginName, isWindows())))
 	if os.IsNotExist(err) {
 		return "", false, nil
 	} else if err != nil {
 		return "", false, errors.Wrap(err, "could not read plugin link")
 	}
 
-	if !filepath.IsAbs(link) {
-		if link, err = filepath.Abs(filepath.Join(binDir, link)); err != nil {
+	target := link
+	if !filepath.IsAbs(target) {
+		if target, err = filepath.Abs(filepath.Join(binDir, target)); err != nil {
 			return "", true, errors.Wrapf(err, "failed to get the absolute path for the link of %q", link)
 		}
 	}
+	pluginDir, err := filepath.Abs(filepath.Join(installPath, pluginName))
+	if err != nil {
+		return "", true, errors.Wrapf(err, "failed to get the absolute path of %q", installPath)
+	}
+	// the link may point to another link, such as a "current" pointer
+	target, pluginDir = evalSymlinks(target), evalSymlinks(pluginDir)
+	if elems, ok := pathutil.IsSubPath(pluginDir, target); !ok || len(elems) < 2 {
+		return "", true, &SuspiciousLinkError{Plugin: pluginName, Target: link}
+	}
 
-	name, err = pluginVersionFromPath(installPath, link)
+	// plugins installed by older krew versions have no receipt
+	if version, ok := receiptVersion(installPath, pluginName); ok {
+		return version, true, nil
+	}
+	name, err = pluginVersionFromPath(filepath.Dir(pluginDir), target)
 	if err != nil {
 		return "", true, errors.Wrap(err, "cloud not parse plugin version")
 	}
 	return name, true, nil
 }
 
+// pluginVersionFromPath returns the version directory of the plugin file at
+// pluginPath. The symbolic links in the paths are resolved first, so that a
+// link to a link, e.g. through a "current" pointer, finds the real version.
 func pluginVersionFromPath(installPath, pluginPath string) (string, error) {
 	// plugin path: {install_path}/{plugin_name}/{version}/...
-	elems, ok := pathutil.IsSubPath(installPath, pluginPath)
+	elems, ok := pathutil.IsSubPath(evalSymlinks(installPath), evalSymlinks(pluginPath))
 	if !ok || len(elems) < 2 {
 		return "", errors.Errorf("failed to get the version from execution path=%q, with install path=%q", pluginPath, installPath)
 	}
 	return elems[1], nil
 }
 
+// evalSymlinks returns path with its symbolic links resolved, or path if it
+// can't be resolved, e.g. because it doesn't exist.
+func evalSymlinks(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		log.V(4).Infof("Failed to resolve the symbolic links of %q: %v", path, err)
+		return path
+	}
+	return resolved
+}
+
 func getPluginVersion(p index.Platform) (version, uri string) {
 	return strings.ToLower(p.Sha256), p.URI
 }
 
-func getDownloadTarget(index index.Plugin) (version, uri string, fos []index.FileOperation, bin string, err error) {
-	p, ok, err := GetMatchingPlatform(index)
+// getDownloadTarget finds the platform of the plugin to install on the
+// specified os/arch, allowing emulation if KREW_ALLOW_EMULATION=1 is set.
+func getDownloadTarget(plugin index.Plugin, goos, goarch string) (version string, p index.Platform, err error) {
+	return getDownloadTargetFor(plugin, goos, goarch, os.Getenv("KREW_ALLOW_EMULATION") == "1")
+}
+
+// emulatedPlatforms maps an os/arch to the os/arch whose binaries it can run
+// through emulation.
+var emulatedPlatforms = map[string]string{
+	"darwin/arm64": "darwin/amd64",
+}
+
+// getDownloadTargetFor finds the platform matching the specified os/arch and
+// the version it is installed as. If allowEmulation is set and no platform
+// matches, the platform of the os/arch in emulatedPlatforms is used. It
+// returns ErrNoMatchingPlatform if no platform matches.
+func getDownloadTargetFor(plugin index.Plugin, goos, goarch string, allowEmulation bool) (version string, p index.Platform, err error) {
+	matches, err := MatchingPlatforms(plugin, goos, goarch)
 	if err != nil {
-		return "", "", nil, p.Bin, errors.Wrap(err, "failed to get matching platforms")
+		return "", p, errors.Wrap(err, "failed to get matching platforms")
+	}
+	if fallback, ok := emulatedPlatforms[goos+"/"+goarch]; ok && allowEmulation && len(matches) == 0 {
+		pieces := strings.SplitN(fallback, "/", 2)
+		matches, err = MatchingPlatforms(plugin, pieces[0], pieces[1])
+		if err != nil {
+			return "", p, errors.Wrap(err, "failed to get matching platforms")
+		}
+		if len(matches) > 0 {
+			log.Warningf("Plugin %q has no %s/%s binary, installing the %s binary which will run through emulation", plugin.Name, goos, goarch, fallback)
+			goos, goarch = pieces[0], pieces[1]
+		}
+	}
+	if len(matches) == 0 {
+		return "", p, ErrNoMatchingPlatform
+	}
+	if len(matches) > 1 {
+		log.Warningf("%d platforms of plugin %q match %s/%s, using the most specific one", len(matches), plugin.Name, goos, goarch)
 	}
-	if !ok {
-		return "", "", nil, p.Bin, errors.New("no matching platform found")
+	p = mostSpecificPlatform(matches)
+	version = PlatformVersion(plugin, p)
+	if version == "" {
+		return "", p, errors.Errorf("plugin %q has neither a sha256 checksum nor a version", plugin.Name)
 	}
-	version, uri = getPluginVersion(p)
-	glog.V(4).Infof("Matching plugin version is %s", version)
+	log.V(4).Infof("Matching plugin version is %s", version)
 
-	return version, uri, p.Files, p.Bin, nil
+	return version, p, nil
+}
+
+// PlatformVersion returns the version a plugin is installed as from the
+// platform, such as a platform returned by GetMatchingPlatform. It is empty if
+// the plugin has neither a version nor a sha256 checksum.
+func PlatformVersion(plugin index.Plugin, p index.Platform) string {
+	if version, ok := semverOf(plugin, p); ok {
+		return version
+	}
+	// without a semantic version, fall back to the checksum and then to any
+	// version in the manifest
+	if version, _ := getPluginVersion(p); version != "" {
+		return version
+	}
+	return plugin.Spec.Version
 }
 
 // ListInstalledPlugins returns a list of all name:version for all plugins.
+// The installed versions are resolved concurrently, if resolving any of them
+// fails, the error of the first plugin in directory order is returned.
 func ListInstalledPlugins(installDir, binDir string) (map[string]string, error) {
 	installed := make(map[string]string)
 	plugins, err := ioutil.ReadDir(installDir)
 	if err != nil {
 		return installed, errors.Wrap(err, "failed to read install dir")
 	}
-	glog.V(4).Infof("Read installation directory: %s (%d items)", installDir, len(plugins))
-	for _, plugin := range plugins {
+	log.V(4).Infof("Read installation directory: %s (%d items)", installDir, len(plugins))
+
+	type result struct {
+		version  string
+		ok       bool
+		dangling bool
+		err      error
+	}
+	results := make([]result, len(plugins))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				var r result
+				name := plugins[i].Name()
+				if isDanglingLink(filepath.Join(binDir, pluginNameToBin(name, isWindows()))) {
+					r.dangling = true
+				} else {
+					r.version, r.ok, r.err = findInstalledPluginVersion(installDir, binDir, name)
+				}
+				results[i] = r
+			}
+		}()
+	}
+	for i, plugin := range plugins {
 		if !plugin.IsDir() {
-			glog.V(4).Infof("Skip non-directory item: %s", plugin.Name())
+			log.V(4).Infof("Skip non-directory item: %s", plugin.Name())
 			continue
 		}
-		version, ok, err := findInstalledPluginVersion(installDir, binDir, plugin.Name())
-		if err != nil {
-			return installed, errors.Wrap(err, "failed to get plugin version")
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	for i, plugin := range plugins {
+		r := results[i]
+		if r.dangling {
+			log.Warningf("Skipping plugin %q, the target of its link in %q does not exist (run \"kubectl krew list --repair\" to remove it)", plugin.Name(), binDir)
+			continue
+		}
+		var suspicious *SuspiciousLinkError
+		if stderrors.As(r.err, &suspicious) {
+			log.Warningf("Skipping plugin %q, its link %q points to %q outside of its installation directory (reinstall it with \"kubectl krew install --force %s\")",
+				plugin.Name(), filepath.Join(binDir, pluginNameToBin(plugin.Name(), isWindows())), suspicious.Target, plugin.Name())
+			continue
 		}
-		if ok {
-			installed[plugin.Name()] = version
-			glog.V(4).Infof("Found %q, with version %s", plugin.Name(), version)
+		if r.err != nil {
+			return installed, errors.Wrap(r.err, "failed to get plugin version")
+		}
+		if r.ok {
+			installed[plugin.Name()] = r.version
+			log.V(4).Infof("Found %q, with version %s", plugin.Name(), r.version)
 		}
 	}
 	return installed, nil
 }
+
+// ListBrokenPlugins returns the sorted names of the plugins that have a
+// directory in installDir, but no link in binDir that resolves to an installed
+// version, e.g. because their installation was interrupted.
+func ListBrokenPlugins(installDir, binDir string) ([]string, error) {
+	items, err := ioutil.ReadDir(installDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read install dir")
+	}
+	var broken []string
+	for _, item := range items {
+		name := item.Name()
+		if !item.IsDir() || index.ValidatePluginName(name) != nil {
+			log.V(4).Infof("Skip item that is not a plugin directory: %s", name)
+			continue
+		}
+		_, ok, err := findInstalledPluginVersion(installDir, binDir, name)
+		var suspicious *SuspiciousLinkError
+		if stderrors.As(err, &suspicious) {
+			// not repaired, ListInstalledPlugins warns about it
+			log.V(2).Infof("Skipping plugin %s, its link points outside of its installation directory", name)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the installed version of plugin %s", name)
+		}
+		if !ok ||
+			isDanglingLink(filepath.Join(binDir, pluginNameToBin(name, isWindows()))) ||
+			isDanglingShim(filepath.Join(binDir, pluginNameToShim(name))) {
+			log.V(2).Infof("Plugin %s has a directory in %q, but no working link", name, installDir)
+			broken = append(broken, name)
+		}
+	}
+	return broken, nil
+}
+
+// ListDanglingLinks returns the paths of the symbolic links and shims in binDir
+// whose targets do not exist, such as the links of plugins whose installation
+// directory was deleted manually.
+func ListDanglingLinks(binDir string) ([]string, error) {
+	items, err := ioutil.ReadDir(binDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read bin dir")
+	}
+	var dangling []string
+	for _, item := range items {
+		path := filepath.Join(binDir, item.Name())
+		if isDanglingShim(path) || isDanglingLink(path) {
+			dangling = append(dangling, path)
+		}
+	}
+	return dangling, nil
+}
+
+// RemoveDanglingLinks removes the links listed by ListDanglingLinks. It returns
+// the paths of the removed links.
+func RemoveDanglingLinks(binDir string) ([]string, error) {
+	dangling, err := ListDanglingLinks(binDir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, path := range dangling {
+		remove := removeLink
+		if isDanglingShim(path) {
+			remove = removeShim
+		}
+		log.V(2).Infof("Removing dangling link %q", path)
+		if err := remove(path); err != nil {
+			return removed, errors.Wrapf(err, "failed to remove dangling link %q", path)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// isDanglingLink returns true if path is a symbolic link whose target does
+// not exist.
+func isDanglingLink(path string) bool {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	_, err = os.Stat(path)
+	return os.IsNotExist(err)
+}
@@ -15,19 +15,26 @@
 package installation
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/krew/pkg/index"
+	"sigs.k8s.io/krew/pkg/log"
+	"sigs.k8s.io/krew/pkg/testutil"
 )
 
 func Test_osArch_default(t *testing.T) {
 	inOS, inArch := runtime.GOOS, runtime.GOARCH
-	outOS, outArch := osArch()
+	outOS, outArch, err := osArch()
+	if err != nil {
+		t.Fatal(err)
+	}
 	if inOS != outOS {
 		t.Fatalf("returned OS=%q; expected=%q", outOS, inOS)
 	}
@@ -36,13 +43,16 @@ func Test_osArch_default(t *testing.T) {
 	}
 }
 func Test_osArch_override(t *testing.T) {
-	customOS, customArch := "dragons", "v1"
+	customOS, customArch := "dragonfly", "amd64"
 	os.Setenv("KREW_OS", customOS)
 	defer os.Unsetenv("KREW_OS")
 	os.Setenv("KREW_ARCH", customArch)
 	defer os.Unsetenv("KREW_ARCH")
 
-	outOS, outArch := osArch()
+	outOS, outArch, err := osArch()
+	if err != nil {
+		t.Fatal(err)
+	}
 	if customOS != outOS {
 		t.Fatalf("returned OS=%q; expected=%q", outOS, customOS)
 	}
@@ -51,6 +61,58 @@ func Test_osArch_override(t *testing.T) {
 	}
 }
 
+func Test_osArch_invalidOverride(t *testing.T) {
+	tests := []struct {
+		env     string
+		value   string
+		wantErr bool
+	}{
+		{"KREW_ARCH", "x86_64", true},
+		{"KREW_ARCH", "amd64", false},
+		{"KREW_OS", "macos", true},
+		{"KREW_OS", "darwin", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			os.Setenv(tt.env, tt.value)
+			defer os.Unsetenv(tt.env)
+
+			_, _, err := osArch()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("osArch() with %s=%s error = %v, wantErr %v", tt.env, tt.value, err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.env) {
+				t.Fatalf("osArch() error %q does not name the variable %s", err, tt.env)
+			}
+		})
+	}
+}
+
+func TestOSArch_precedence(t *testing.T) {
+	os.Setenv("KREW_OS", "windows")
+	defer os.Unsetenv("KREW_OS")
+
+	gotOS, gotArch, err := OSArch("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotOS != "windows" || gotArch != runtime.GOARCH {
+		t.Fatalf("OSArch() without overrides = %s/%s; expected windows/%s", gotOS, gotArch, runtime.GOARCH)
+	}
+
+	gotOS, gotArch, err = OSArch("darwin", "386")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotOS != "darwin" || gotArch != "386" {
+		t.Fatalf("OSArch(darwin, 386) = %s/%s; expected darwin/386", gotOS, gotArch)
+	}
+
+	if _, _, err := OSArch("", "x86_64"); err == nil {
+		t.Fatalf("OSArch() with unrecognized arch expected error")
+	}
+}
+
 func Test_matchPlatformToSystemEnvs(t *testing.T) {
 	matchingPlatform := index.Platform{
 		URI: "A",
@@ -133,6 +195,168 @@ func Test_matchPlatformToSystemEnvs(t *testing.T) {
 	}
 }
 
+func TestMatchingPlatforms(t *testing.T) {
+	linux := index.Platform{
+		URI: "A",
+		Selector: &v1.LabelSelector{
+			MatchLabels: map[string]string{"os": "linux"},
+		},
+	}
+	linuxAmd64 := index.Platform{
+		URI: "B",
+		Selector: &v1.LabelSelector{
+			MatchLabels: map[string]string{"os": "linux", "arch": "amd64"},
+		},
+	}
+	darwin := index.Platform{
+		URI: "C",
+		Selector: &v1.LabelSelector{
+			MatchLabels: map[string]string{"os": "darwin"},
+		},
+	}
+	plugin := index.Plugin{
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{linux, darwin, linuxAmd64},
+		},
+	}
+
+	tests := []struct {
+		name string
+		os   string
+		arch string
+		want []index.Platform
+	}{
+		{"overlapping selectors", "linux", "amd64", []index.Platform{linux, linuxAmd64}},
+		{"single match", "linux", "arm", []index.Platform{linux}},
+		{"single match other os", "darwin", "amd64", []index.Platform{darwin}},
+		{"no match", "windows", "amd64", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchingPlatforms(plugin, tt.os, tt.arch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchingPlatforms(%s, %s) = %v, want %v", tt.os, tt.arch, got, tt.want)
+			}
+		})
+	}
+
+	got, ok, err := GetMatchingPlatformFor(plugin, "linux", "amd64")
+	if err != nil || !ok {
+		t.Fatalf("GetMatchingPlatformFor() found=%v err=%v", ok, err)
+	}
+	if !reflect.DeepEqual(got, linuxAmd64) {
+		t.Errorf("GetMatchingPlatformFor() = %v, want the most specific matching platform %v", got, linuxAmd64)
+	}
+}
+
+func TestMatchingPlatforms_extraLabels(t *testing.T) {
+	defer os.Unsetenv("KREW_PLATFORM_LABELS")
+
+	musl := index.Platform{
+		URI: "musl",
+		Selector: &v1.LabelSelector{
+			MatchLabels: map[string]string{"os": "linux", "libc": "musl"},
+		},
+	}
+	notMusl := index.Platform{
+		URI: "glibc",
+		Selector: &v1.LabelSelector{
+			MatchLabels:      map[string]string{"os": "linux"},
+			MatchExpressions: []v1.LabelSelectorRequirement{{Key: "libc", Operator: v1.LabelSelectorOpNotIn, Values: []string{"musl"}}},
+		},
+	}
+	plugin := index.Plugin{
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{musl, notMusl},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		labels  string
+		want    []index.Platform
+		wantErr bool
+	}{
+		{name: "no extra labels", labels: "", want: []index.Platform{notMusl}},
+		{name: "extra label", labels: "libc=musl", want: []index.Platform{musl}},
+		{name: "other extra labels", labels: "libc=glibc, distro=debian", want: []index.Platform{notMusl}},
+		{name: "invalid labels", labels: "libc", wantErr: true},
+		{name: "overriding os", labels: "os=darwin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("KREW_PLATFORM_LABELS", tt.labels)
+			got, err := MatchingPlatforms(plugin, "linux", "amd64")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchingPlatforms() with KREW_PLATFORM_LABELS=%q error = %v, wantErr %v", tt.labels, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchingPlatforms() with KREW_PLATFORM_LABELS=%q = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMatchingPlatformFor(t *testing.T) {
+	platform := func(uri string, matchLabels map[string]string) index.Platform {
+		return index.Platform{
+			URI:      uri,
+			Sha256:   uri,
+			Selector: &v1.LabelSelector{MatchLabels: matchLabels},
+		}
+	}
+	linuxAmd64 := platform("linux-amd64", map[string]string{"os": "linux", "arch": "amd64"})
+	linuxArm64 := platform("linux-arm64", map[string]string{"os": "linux", "arch": "arm64"})
+	darwin := platform("darwin", map[string]string{"os": "darwin"})
+	windowsAmd64 := platform("windows-amd64", map[string]string{"os": "windows", "arch": "amd64"})
+	plugin := index.Plugin{
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{linuxAmd64, linuxArm64, darwin, windowsAmd64},
+		},
+	}
+
+	tests := []struct {
+		os, arch  string
+		want      index.Platform
+		wantFound bool
+	}{
+		{"linux", "amd64", linuxAmd64, true},
+		{"linux", "arm64", linuxArm64, true},
+		{"linux", "arm", index.Platform{}, false},
+		{"darwin", "amd64", darwin, true},
+		{"darwin", "arm64", darwin, true},
+		{"windows", "amd64", windowsAmd64, true},
+		{"windows", "386", index.Platform{}, false},
+		{"freebsd", "amd64", index.Platform{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.os+"/"+tt.arch, func(t *testing.T) {
+			// the process environment must not affect explicit values
+			os.Setenv("KREW_OS", "plan9")
+			defer os.Unsetenv("KREW_OS")
+
+			got, found, err := GetMatchingPlatformFor(plugin, tt.os, tt.arch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != tt.wantFound || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetMatchingPlatformFor(%s, %s) = %v, %v, want %v, %v", tt.os, tt.arch, got.URI, found, tt.want.URI, tt.wantFound)
+			}
+
+			version, found, err := AvailableVersionFor(plugin, tt.os, tt.arch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != tt.wantFound || version != tt.want.Sha256 {
+				t.Errorf("AvailableVersionFor(%s, %s) = %q, %v, want %q, %v", tt.os, tt.arch, version, found, tt.want.Sha256, tt.wantFound)
+			}
+		})
+	}
+}
+
 func Test_getPluginVersion(t *testing.T) {
 	wantVersion := "deadbeef"
 	wantURI := "https://uri.git"
@@ -225,7 +449,7 @@ func Test_getDownloadTarget(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotVersion, gotURI, gotFos, bin, err := getDownloadTarget(tt.args.index)
+			gotVersion, gotPlatform, err := getDownloadTarget(tt.args.index, runtime.GOOS, runtime.GOARCH)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getDownloadTarget() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -233,19 +457,118 @@ func Test_getDownloadTarget(t *testing.T) {
 			if gotVersion != tt.wantVersion {
 				t.Errorf("getDownloadTarget() gotVersion = %v, want %v", gotVersion, tt.wantVersion)
 			}
-			if bin != tt.wantBin {
-				t.Errorf("getDownloadTarget() bin = %v, want %v", bin, tt.wantBin)
+			if gotPlatform.Bin != tt.wantBin {
+				t.Errorf("getDownloadTarget() bin = %v, want %v", gotPlatform.Bin, tt.wantBin)
 			}
-			if gotURI != tt.wantURI {
-				t.Errorf("getDownloadTarget() gotURI = %v, want %v", gotURI, tt.wantURI)
+			if gotPlatform.URI != tt.wantURI {
+				t.Errorf("getDownloadTarget() gotURI = %v, want %v", gotPlatform.URI, tt.wantURI)
 			}
-			if !reflect.DeepEqual(gotFos, tt.wantFos) {
-				t.Errorf("getDownloadTarget() gotFos = %v, want %v", gotFos, tt.wantFos)
+			if !reflect.DeepEqual(gotPlatform.Files, tt.wantFos) {
+				t.Errorf("getDownloadTarget() gotFos = %v, want %v", gotPlatform.Files, tt.wantFos)
+			}
+		})
+	}
+}
+
+func Test_getDownloadTargetFor_emulation(t *testing.T) {
+	plugin := index.Plugin{
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{{
+				URI:    "https://example.com/darwin-amd64.tar.gz",
+				Sha256: "deadbeef",
+				Selector: &v1.LabelSelector{
+					MatchLabels: map[string]string{"os": "darwin", "arch": "amd64"},
+				},
+			}},
+		},
+	}
+	tests := []struct {
+		name           string
+		os, arch       string
+		allowEmulation bool
+		wantURI        string
+		wantErr        error
+	}{
+		{"direct match", "darwin", "amd64", false, "https://example.com/darwin-amd64.tar.gz", nil},
+		{"emulation not allowed", "darwin", "arm64", false, "", ErrNoMatchingPlatform},
+		{"emulation allowed", "darwin", "arm64", true, "https://example.com/darwin-amd64.tar.gz", nil},
+		{"no fallback", "linux", "arm64", true, "", ErrNoMatchingPlatform},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := getDownloadTargetFor(plugin, tt.os, tt.arch, tt.allowEmulation)
+			if err != tt.wantErr {
+				t.Fatalf("getDownloadTargetFor() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.URI != tt.wantURI {
+				t.Errorf("getDownloadTargetFor() uri = %q, want %q", got.URI, tt.wantURI)
+			}
+		})
+	}
+}
+
+// warningLogger records the warnings logged by the krew packages.
+type warningLogger struct{ warnings []string }
+
+func (*warningLogger) Enabled(int) bool     { return false }
+func (*warningLogger) Info(int, string)     {}
+func (l *warningLogger) Warning(msg string) { l.warnings = append(l.warnings, msg) }
+
+func Test_mostSpecificPlatform(t *testing.T) {
+	platform := func(uri string, sel *v1.LabelSelector) index.Platform {
+		return index.Platform{URI: uri, Selector: sel}
+	}
+	linux := &v1.LabelSelector{MatchLabels: map[string]string{"os": "linux"}}
+	linuxAmd64 := &v1.LabelSelector{MatchLabels: map[string]string{"os": "linux", "arch": "amd64"}}
+	linuxNotMusl := &v1.LabelSelector{
+		MatchLabels:      map[string]string{"os": "linux"},
+		MatchExpressions: []v1.LabelSelectorRequirement{{Key: "libc", Operator: v1.LabelSelectorOpNotIn, Values: []string{"musl"}}},
+	}
+
+	tests := []struct {
+		name    string
+		matches []index.Platform
+		want    string
+	}{
+		{"single match", []index.Platform{platform("A", linux)}, "A"},
+		{"more labels", []index.Platform{platform("A", linux), platform("B", linuxAmd64)}, "B"},
+		{"expressions count", []index.Platform{platform("A", linux), platform("B", linuxNotMusl)}, "B"},
+		{"empty selector", []index.Platform{platform("A", nil), platform("B", linux)}, "B"},
+		{"tie keeps manifest order", []index.Platform{platform("A", linuxAmd64), platform("B", linuxNotMusl)}, "A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mostSpecificPlatform(tt.matches); got.URI != tt.want {
+				t.Errorf("mostSpecificPlatform() = %q, want %q", got.URI, tt.want)
 			}
 		})
 	}
 }
 
+func Test_getDownloadTargetFor_overlappingPlatformsWarning(t *testing.T) {
+	linux := &v1.LabelSelector{MatchLabels: map[string]string{"os": "linux"}}
+	plugin := index.Plugin{
+		ObjectMeta: v1.ObjectMeta{Name: "foo"},
+		Spec: index.PluginSpec{
+			Platforms: []index.Platform{
+				{URI: "https://example.com/a.tar.gz", Sha256: "deadbeef", Selector: linux},
+				{URI: "https://example.com/b.tar.gz", Sha256: "deadbeef", Selector: linux},
+			},
+		},
+	}
+
+	l := &warningLogger{}
+	log.SetLogger(l)
+	defer log.SetLogger(nil)
+
+	if _, _, err := getDownloadTargetFor(plugin, "linux", "amd64", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.warnings) != 1 || !strings.Contains(l.warnings[0], "2 platforms of plugin \"foo\" match linux/amd64") {
+		t.Errorf("expected a warning about the overlapping platforms without -v, got %q", l.warnings)
+	}
+}
+
 func Test_findInstalledPluginVersion(t *testing.T) {
 	type args struct {
 		installPath string
@@ -308,6 +631,234 @@ func Test_findInstalledPluginVersion(t *testing.T) {
 	}
 }
 
+func Test_findInstalledPluginVersion_suspiciousLink(t *testing.T) {
+	if isWindows() {
+		t.Skip("creating symlinks requires privileges on Windows")
+	}
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{name: "outside of the install root", target: "elsewhere/kubectl-foo"},
+		{name: "another plugin", target: "store/bar/v1.0.0/kubectl-bar"},
+		{name: "not in a version directory", target: "store/foo/kubectl-foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			tmpDir.Write("store/foo/v1.0.0/kubectl-foo", nil)
+			tmpDir.Write(tt.target, nil)
+			tmpDir.Write("bin/.keep", nil)
+			if err := os.Symlink(tmpDir.Path(tt.target), tmpDir.Path("bin/kubectl-foo")); err != nil {
+				t.Fatal(err)
+			}
+
+			_, installed, err := findInstalledPluginVersion(tmpDir.Path("store"), tmpDir.Path("bin"), "foo")
+			if _, ok := err.(*SuspiciousLinkError); !ok {
+				t.Fatalf("findInstalledPluginVersion() error = %v, want a *SuspiciousLinkError", err)
+			}
+			if !installed {
+				t.Errorf("findInstalledPluginVersion() installed = false, want true")
+			}
+
+			if err := Uninstall(tmpDir.Path("store"), tmpDir.Path("bin"), "foo"); err != nil {
+				t.Fatalf("Uninstall() error = %v", err)
+			}
+			if _, err := os.Lstat(tmpDir.Path("bin/kubectl-foo")); !os.IsNotExist(err) {
+				t.Errorf("expected the link to be removed, got error %v", err)
+			}
+			if _, err := os.Stat(tmpDir.Path(tt.target)); err != nil && !strings.HasPrefix(tt.target, "store/foo/") {
+				t.Errorf("expected the link target to be left alone, got error %v", err)
+			}
+		})
+	}
+}
+
+// installPlugins creates n installed plugins named plugin-{i} in tmpDir and
+// returns their install and bin directories.
+func installPlugins(tb testing.TB, tmpDir *testutil.TempDir, n int) (installDir, binDir string) {
+	installDir, binDir = tmpDir.Path("store"), tmpDir.Path("bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("plugin-%d", i)
+		tmpDir.Write("store/"+name+"/v1/kubectl-"+name, nil)
+		target := filepath.Join(installDir, name, "v1", "kubectl-"+name)
+		if err := os.Symlink(target, filepath.Join(binDir, pluginNameToBin(name, isWindows()))); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return installDir, binDir
+}
+
+func TestListInstalledPlugins(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	installDir, binDir := installPlugins(t, tmpDir, 20)
+	tmpDir.Write("store/not-a-dir", nil)
+	// installed, but not linked
+	tmpDir.Write("store/unlinked/v1/kubectl-unlinked", nil)
+
+	got, err := ListInstalledPlugins(installDir, binDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		want[fmt.Sprintf("plugin-%d", i)] = "v1"
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListInstalledPlugins() = %v, want %v", got, want)
+	}
+}
+
+func TestListInstalledPlugins_firstErrorReturned(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	installDir, binDir := installPlugins(t, tmpDir, 5)
+	// directories that are not valid plugin names fail version resolution
+	for _, name := range []string{"a.bad", "z.bad"} {
+		tmpDir.Write("store/"+name+"/v1/kubectl-"+name, nil)
+	}
+
+	got, err := ListInstalledPlugins(installDir, binDir)
+	if err == nil {
+		t.Fatal("ListInstalledPlugins() expected error")
+	}
+	if !strings.Contains(err.Error(), "a.bad") || strings.Contains(err.Error(), "z.bad") {
+		t.Fatalf("ListInstalledPlugins() error = %v, expected the error of a.bad", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("ListInstalledPlugins() returned plugins after a.bad: %v", got)
+	}
+}
+
+func TestListInstalledPlugins_danglingLink(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	installDir, binDir := installPlugins(t, tmpDir, 3)
+	// simulate removing the installation of a plugin by hand
+	if err := os.RemoveAll(filepath.Join(installDir, "plugin-1", "v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListInstalledPlugins(installDir, binDir)
+	if err != nil {
+		t.Fatalf("ListInstalledPlugins() with a dangling link error = %+v", err)
+	}
+	want := map[string]string{"plugin-0": "v1", "plugin-2": "v1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListInstalledPlugins() = %v, want %v", got, want)
+	}
+
+	wantRemoved := []string{filepath.Join(binDir, pluginNameToBin("plugin-1", isWindows()))}
+	dangling, err := ListDanglingLinks(binDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dangling, wantRemoved) {
+		t.Fatalf("ListDanglingLinks() = %v, want %v", dangling, wantRemoved)
+	}
+
+	removed, err := RemoveDanglingLinks(binDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Fatalf("RemoveDanglingLinks() = %v, want %v", removed, wantRemoved)
+	}
+	for _, name := range []string{"plugin-0", "plugin-2"} {
+		if _, err := os.Stat(filepath.Join(binDir, pluginNameToBin(name, isWindows()))); err != nil {
+			t.Fatalf("RemoveDanglingLinks() removed the link of %s: %v", name, err)
+		}
+	}
+}
+
+func TestListInstalledPlugins_suspiciousLink(t *testing.T) {
+	if isWindows() {
+		t.Skip("creating symlinks requires privileges on Windows")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	installDir, binDir := installPlugins(t, tmpDir, 2)
+	// simulate tampering with the link of a plugin
+	tmpDir.Write("elsewhere/kubectl-plugin-1", nil)
+	link := filepath.Join(binDir, pluginNameToBin("plugin-1", false))
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(tmpDir.Path("elsewhere/kubectl-plugin-1"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &warningLogger{}
+	log.SetLogger(l)
+	defer log.SetLogger(nil)
+
+	got, err := ListInstalledPlugins(installDir, binDir)
+	if err != nil {
+		t.Fatalf("ListInstalledPlugins() with a suspicious link error = %+v", err)
+	}
+	if want := map[string]string{"plugin-0": "v1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListInstalledPlugins() = %v, want %v", got, want)
+	}
+	if len(l.warnings) != 1 || !strings.Contains(l.warnings[0], link) || !strings.Contains(l.warnings[0], tmpDir.Path("elsewhere/kubectl-plugin-1")) {
+		t.Errorf("expected a warning with the link and its target, got %q", l.warnings)
+	}
+
+	broken, err := ListBrokenPlugins(installDir, binDir)
+	if err != nil {
+		t.Fatalf("ListBrokenPlugins() with a suspicious link error = %+v", err)
+	}
+	if len(broken) != 0 {
+		t.Errorf("ListBrokenPlugins() = %v, expected the suspicious link not to be repaired", broken)
+	}
+}
+
+func TestListBrokenPlugins(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	installDir, binDir := installPlugins(t, tmpDir, 3)
+	tmpDir.Write("store/not-a-dir", nil)
+	// installation interrupted before linking
+	tmpDir.Write("store/unlinked/v1/kubectl-unlinked", nil)
+	// staging directory of an interrupted installation
+	tmpDir.Write("store/staged/.staging-123/kubectl-staged", nil)
+	// installation directory removed by hand
+	if err := os.RemoveAll(filepath.Join(installDir, "plugin-1", "v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListBrokenPlugins(installDir, binDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"plugin-1", "staged", "unlinked"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListBrokenPlugins() = %v, want %v", got, want)
+	}
+}
+
+func BenchmarkListInstalledPlugins(b *testing.B) {
+	tmpDir, cleanup := testutil.NewTempDir(b)
+	defer cleanup()
+
+	installDir, binDir := installPlugins(b, tmpDir, 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ListInstalledPlugins(installDir, binDir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func testdataPath(t *testing.T) string {
 	pwd, err := filepath.Abs(".")
 	if err != nil {
@@ -350,3 +901,54 @@ func Test_pluginVersionFromPath(t *testing.T) {
 		})
 	}
 }
+
+func Test_pluginVersionFromPath_symlinks(t *testing.T) {
+	if isWindows() {
+		t.Skip("creating symlinks requires privileges on Windows")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	installPath := tmpDir.Path("store")
+	tmpDir.Write(filepath.Join("store", "foo", "v1.2.0", "kubectl-foo"), []byte("#!/bin/sh"))
+	symlink := func(oldname, newname string) {
+		if err := os.MkdirAll(filepath.Dir(newname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(oldname, newname); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a floating "current" pointer to the version directory
+	symlink("v1.2.0", filepath.Join(installPath, "foo", "current"))
+	// a two-hop chain of links to the executable
+	symlink(filepath.Join(installPath, "foo", "v1.2.0", "kubectl-foo"), tmpDir.Path(filepath.Join("links", "hop2")))
+	symlink(tmpDir.Path(filepath.Join("links", "hop2")), tmpDir.Path(filepath.Join("links", "hop1")))
+
+	tests := []struct {
+		name       string
+		pluginPath string
+	}{
+		{"current indirection", filepath.Join(installPath, "foo", "current", "kubectl-foo")},
+		{"two-hop chain", tmpDir.Path(filepath.Join("links", "hop1"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pluginVersionFromPath(installPath, tt.pluginPath)
+			if err != nil {
+				t.Fatalf("pluginVersionFromPath() error = %v", err)
+			}
+			if got != "v1.2.0" {
+				t.Errorf("pluginVersionFromPath() = %q, want %q", got, "v1.2.0")
+			}
+		})
+	}
+
+	// the bin link points to the "current" pointer of a plugin without receipt
+	binDir := tmpDir.Path("bin")
+	symlink(filepath.Join(installPath, "foo", "current", "kubectl-foo"), filepath.Join(binDir, "kubectl-foo"))
+	version, installed, err := findInstalledPluginVersion(installPath, binDir, "foo")
+	if err != nil || !installed || version != "v1.2.0" {
+		t.Fatalf("findInstalledPluginVersion() = %q, %v, %v, want v1.2.0", version, installed, err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/log"
+)
+
+// ErrNoChecksums is returned by Verify if the installation receipt of the
+// plugin has no checksums of the installed files, e.g. because it was installed
+// by an older krew version, so there is nothing to verify against.
+var ErrNoChecksums = errors.New("the checksums of the installed files were not recorded, reinstall the plugin to record them")
+
+// VerifyOpts specifies the installed plugin to verify with Verify.
+type VerifyOpts struct {
+	// Plugin is the name of the installed plugin.
+	Plugin string
+
+	// InstallPath is the base directory of the plugin installations.
+	InstallPath string
+	// BinPath is the directory of the plugin executable links.
+	BinPath string
+}
+
+// VerifyError lists the installed files of a plugin that differ from the
+// files recorded when it was installed.
+type VerifyError struct {
+	Modified []string
+	Missing  []string
+	Extra    []string
+}
+
+func (e *VerifyError) Error() string {
+	var problems []string
+	for _, group := range []struct {
+		title string
+		files []string
+	}{
+		{"modified", e.Modified},
+		{"missing", e.Missing},
+		{"unexpected", e.Extra},
+	} {
+		if len(group.files) > 0 {
+			problems = append(problems, group.title+": "+strings.Join(group.files, ", "))
+		}
+	}
+	return "installed files differ from the installation (" + strings.Join(problems, "; ") + ")"
+}
+
+// Verify checks that the installed files of a plugin have not been modified
+// since it was installed. The sha256 checksums of the files in the version
+// directory are recorded in the installation receipt once the archive was
+// verified and extracted and the post-install hook ran, so verifying doesn't
+// need the network or the index.
+//
+// It returns ErrNotInstalled if the plugin is not installed, ErrNoChecksums if
+// the receipt of the installed version has no checksums, and a *VerifyError if
+// the installed files differ.
+func Verify(opts VerifyOpts) error {
+	return typedError(verify(opts))
+}
+
+func verify(opts VerifyOpts) error {
+	installedVersion, ok, err := findInstalledPluginVersion(opts.InstallPath, opts.BinPath, opts.Plugin)
+	if err != nil {
+		return errors.Wrap(err, "failed to find the installed version")
+	}
+	if !ok {
+		return ErrNotInstalled
+	}
+
+	r, err := ReadReceipt(opts.InstallPath, opts.Plugin)
+	if os.IsNotExist(err) {
+		return ErrNoChecksums
+	} else if err != nil {
+		return errors.Wrap(err, "failed to read the installation receipt")
+	}
+	if r.Version != installedVersion || len(r.Files) == 0 {
+		log.V(1).Infof("The receipt of plugin %s has no checksums of the installed version %s", opts.Plugin, installedVersion)
+		return ErrNoChecksums
+	}
+	return compareInstalledFiles(r.Files, filepath.Join(opts.InstallPath, opts.Plugin, installedVersion))
+}
+
+// compareInstalledFiles returns a *VerifyError if the regular files in the
+// installed directory differ from the wantSums checksums of their paths.
+func compareInstalledFiles(wantSums map[string]string, installed string) error {
+	gotSums, err := hashFiles(installed)
+	if err != nil {
+		return errors.Wrap(err, "failed to hash the installed files")
+	}
+
+	var verr VerifyError
+	for path, sum := range wantSums {
+		got, ok := gotSums[path]
+		if !ok {
+			verr.Missing = append(verr.Missing, path)
+		} else if got != sum {
+			verr.Modified = append(verr.Modified, path)
+		}
+	}
+	for path := range gotSums {
+		if _, ok := wantSums[path]; !ok {
+			verr.Extra = append(verr.Extra, path)
+		}
+	}
+	if len(verr.Modified)+len(verr.Missing)+len(verr.Extra) == 0 {
+		return nil
+	}
+	sort.Strings(verr.Modified)
+	sort.Strings(verr.Missing)
+	sort.Strings(verr.Extra)
+	return &verr
+}
+
+// hashFiles returns the hex encoded sha256 sums of the regular files in dir,
+// keyed by their slash separated path relative to dir.
+func hashFiles(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	return sums, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "failed to read %q", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
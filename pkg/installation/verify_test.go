@@ -0,0 +1,173 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/krew/pkg/testutil"
+)
+
+func TestVerify(t *testing.T) {
+	const version = "8b40a4ad57aceea70cc35652113a63e80c963310af781d2a7e116e0cdad21116"
+	tests := []struct {
+		name    string
+		modify  func(t *testing.T, pluginDir string)
+		want    *VerifyError
+		wantErr error
+	}{
+		{
+			name: "unmodified",
+		},
+		{
+			name: "modified binary",
+			modify: func(t *testing.T, dir string) {
+				writeFile(t, filepath.Join(dir, version, "kubectl-foo"), "tampered")
+			},
+			want: &VerifyError{Modified: []string{"kubectl-foo"}},
+		},
+		{
+			name: "extra and missing files",
+			modify: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, version, "kubectl-foo")); err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, filepath.Join(dir, version, "lib", "extra.so"), "")
+			},
+			want: &VerifyError{Missing: []string{"kubectl-foo"}, Extra: []string{"lib/extra.so"}},
+		},
+		{
+			name: "no receipt",
+			modify: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, receiptFileName)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrNoChecksums,
+		},
+		{
+			name: "receipt without checksums",
+			modify: func(t *testing.T, dir string) {
+				if err := writeReceipt(filepath.Dir(dir), Receipt{Plugin: "foo", Version: version}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrNoChecksums,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, cleanup := testutil.NewTempDir(t)
+			defer cleanup()
+			tmpDir.Write("bin/.keep", nil)
+
+			plugin := testPlugin()
+			plugin.Spec.Platforms[0].URI = filepath.Join(testdataPath(t), "archives", "foo.tar.gz")
+			if err := InstallPlugin(InstallOpts{
+				Plugin:         plugin,
+				InstallPath:    tmpDir.Path("store"),
+				BinPath:        tmpDir.Path("bin"),
+				DownloadPath:   tmpDir.Path("downloads"),
+				AllowLocalURIs: true,
+			}); err != nil {
+				t.Fatalf("InstallPlugin() error = %+v", err)
+			}
+			if tt.modify != nil {
+				tt.modify(t, tmpDir.Path(filepath.Join("store", "foo")))
+			}
+
+			err := Verify(VerifyOpts{
+				Plugin:      "foo",
+				InstallPath: tmpDir.Path("store"),
+				BinPath:     tmpDir.Path("bin"),
+			})
+			if tt.wantErr != nil {
+				if err != tt.wantErr {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Verify() error = %+v", err)
+				}
+				return
+			}
+			got, ok := err.(*VerifyError)
+			if !ok {
+				t.Fatalf("Verify() error = %+v, want a *VerifyError", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Verify() error = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify_postInstallHookOutputs(t *testing.T) {
+	if isWindows() {
+		t.Skip("the test hooks are shell scripts")
+	}
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	opts := hookPluginOpts(t, tmpDir, "echo generated > completion.bash\n")
+	opts.AllowHooks = true
+	if err := InstallPlugin(opts); err != nil {
+		t.Fatalf("InstallPlugin() error = %+v", err)
+	}
+	// verifying doesn't download the archive
+	if err := os.Remove(tmpDir.Path("foo.tar.gz")); err != nil {
+		t.Fatal(err)
+	}
+
+	verifyOpts := VerifyOpts{Plugin: "foo", InstallPath: opts.InstallPath, BinPath: opts.BinPath}
+	if err := Verify(verifyOpts); err != nil {
+		t.Fatalf("Verify() error = %+v, want the files created by the hook to be expected", err)
+	}
+	writeFile(t, filepath.Join(opts.InstallPath, "foo", opts.Plugin.Spec.Platforms[0].Sha256, "completion.bash"), "tampered")
+	want := &VerifyError{Modified: []string{"completion.bash"}}
+	if err := Verify(verifyOpts); !reflect.DeepEqual(err, want) {
+		t.Fatalf("Verify() error = %#v, want %#v", err, want)
+	}
+}
+
+func TestVerify_notInstalled(t *testing.T) {
+	tmpDir, cleanup := testutil.NewTempDir(t)
+	defer cleanup()
+
+	err := Verify(VerifyOpts{
+		Plugin:      "foo",
+		InstallPath: tmpDir.Path("store"),
+		BinPath:     tmpDir.Path("bin"),
+	})
+	if err != ErrNotInstalled {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrNotInstalled)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+// semverRegexp matches semantic versions with an optional "v" prefix, see
+// https://semver.org.
+var semverRegexp = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+type semver struct {
+	major, minor, patch uint64
+	prerelease          []string
+}
+
+func parseSemver(s string) (semver, error) {
+	m := semverRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, errors.Errorf("%q is not a semantic version", s)
+	}
+	var v semver
+	var err error
+	for i, p := range []*uint64{&v.major, &v.minor, &v.patch} {
+		if *p, err = strconv.ParseUint(m[i+1], 10, 64); err != nil {
+			return semver{}, errors.Wrapf(err, "failed to parse version %q", s)
+		}
+	}
+	if m[4] != "" {
+		v.prerelease = strings.Split(m[4], ".")
+	}
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 if v has a lower, equal or higher precedence
+// than o. Build metadata is ignored.
+func (v semver) compare(o semver) int {
+	for _, c := range [][2]uint64{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if c[0] != c[1] {
+			return cmpUint(c[0], c[1])
+		}
+	}
+
+	// a release has a higher precedence than its pre-releases
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := comparePrereleaseIdentifier(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpUint(uint64(len(v.prerelease)), uint64(len(o.prerelease)))
+}
+
+// comparePrereleaseIdentifier compares numeric identifiers numerically and
+// others lexically, numeric identifiers have a lower precedence.
+func comparePrereleaseIdentifier(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmpUint(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// IsNewerVersion returns true if available is a newer semantic version than
+// installed. It returns an error if either of them is not a semantic version,
+// such as the sha256 checksums plugins without a version are installed as.
+func IsNewerVersion(installed, available string) (bool, error) {
+	vi, err := parseSemver(installed)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse the installed version")
+	}
+	va, err := parseSemver(available)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse the available version")
+	}
+	return va.compare(vi) > 0, nil
+}
+
+// CompareVersions returns -1, 0 or 1 if version a sorts before, equal to or
+// after version b. Semantic versions are ordered by their precedence and sort
+// before other versions, such as sha256 checksums, which are compared as
+// strings.
+func CompareVersions(a, b string) int {
+	va, errA := parseSemver(a)
+	vb, errB := parseSemver(b)
+	switch {
+	case errA == nil && errB == nil:
+		return va.compare(vb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// versionMatches returns true if the requested version refers to version,
+// either by being equal to it or by being the same semantic version (such as
+// 1.2.3 for v1.2.3).
+func versionMatches(requested, version string) bool {
+	if requested == version {
+		return true
+	}
+	vr, err := parseSemver(requested)
+	if err != nil {
+		return false
+	}
+	v, err := parseSemver(version)
+	if err != nil {
+		return false
+	}
+	return vr.compare(v) == 0
+}
+
+// semverOf returns the semantic version of the plugin on the platform. It is
+// the version in the plugin manifest, or a path segment of the platform URI
+// that is a semantic version, such as the tag in GitHub release URLs. It
+// returns false if neither is a semantic version.
+func semverOf(plugin index.Plugin, platform index.Platform) (string, bool) {
+	if _, err := parseSemver(plugin.Spec.Version); err == nil {
+		return plugin.Spec.Version, true
+	}
+	u, err := url.Parse(platform.URI)
+	if err != nil {
+		return "", false
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if _, err := parseSemver(segment); err == nil {
+			return segment, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/krew/pkg/index"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	tests := []struct {
+		installed string
+		available string
+		want      bool
+		wantErr   bool
+	}{
+		{"v1.0.0", "v1.0.1", true, false},
+		{"v1.0.1", "v1.0.0", false, false},
+		{"v1.0.0", "v1.0.0", false, false},
+		{"1.0.0", "v1.0.0", false, false},
+		{"v1.9.0", "v1.10.0", true, false},
+		{"v1.10.0", "v2.0.0", true, false},
+		{"v0.9.9", "v0.10.0", true, false},
+		{"v1.0.0-rc.1", "v1.0.0", true, false},
+		{"v1.0.0", "v1.0.0-rc.1", false, false},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", true, false},
+		{"v1.0.0-alpha.1", "v1.0.0-alpha.beta", true, false},
+		{"v1.0.0-alpha.beta", "v1.0.0-beta", true, false},
+		{"v1.0.0-beta.2", "v1.0.0-beta.11", true, false},
+		{"v1.0.0-rc.1", "v1.0.0-beta.11", false, false},
+		{"v1.0.0+build.1", "v1.0.0+build.2", false, false},
+		{"v1.0.0", "v1.0.1+build.1", true, false},
+		{"deadbeef", "v1.0.0", false, true},
+		{"v1.0.0", "deadbeef", false, true},
+		{"v1.0", "v1.0.1", false, true},
+		{"v01.0.0", "v1.0.1", false, true},
+		{"v1.0.0-", "v1.0.1", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.installed+"_"+tt.available, func(t *testing.T) {
+			got, err := IsNewerVersion(tt.installed, tt.available)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsNewerVersion(%q, %q) error = %v, wantErr %v", tt.installed, tt.available, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsNewerVersion(%q, %q) = %v, want %v", tt.installed, tt.available, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUpgrade(t *testing.T) {
+	tests := []struct {
+		installed string
+		available string
+		want      bool
+	}{
+		{"v1.0.0", "v1.1.0", true},
+		{"v1.1.0", "v1.0.0", false},
+		{"v1.0.0", "v1.0.0", false},
+		{"deadbeef", "cafebabe", true},
+		{"deadbeef", "deadbeef", false},
+		{"deadbeef", "v1.0.0", true},
+	}
+	for _, tt := range tests {
+		if got := IsUpgrade(tt.installed, tt.available); got != tt.want {
+			t.Errorf("IsUpgrade(%q, %q) = %v, want %v", tt.installed, tt.available, got, tt.want)
+		}
+	}
+}
+
+func TestIsArchiveUpgrade(t *testing.T) {
+	sha := strings.Repeat("a", 64)
+	tests := []struct {
+		name            string
+		installed       string
+		installedSha256 string
+		available       string
+		availableSha256 string
+		want            bool
+	}{
+		{"newer version", "v1.0.0", "deadbeef", "v1.1.0", "cafebabe", true},
+		{"older version", "v1.1.0", "deadbeef", "v1.0.0", "cafebabe", false},
+		{"same archive", "v1.0.0", "deadbeef", "v1.0.0", "deadbeef", false},
+		{"same archive different case", "v1.0.0", "DEADBEEF", "v1.0.0", "deadbeef", false},
+		{"re-published archive", "v1.0.0", "deadbeef", "v1.0.0", "cafebabe", true},
+		{"unknown installed checksum", "v1.0.0", "", "v1.0.0", "cafebabe", false},
+		{"checksum version of same archive", sha, sha, "v1.0.0", sha, false},
+		{"checksum version of other archive", sha, sha, "v1.0.0", "cafebabe", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsArchiveUpgrade(tt.installed, tt.installedSha256, tt.available, tt.availableSha256); got != tt.want {
+				t.Errorf("IsArchiveUpgrade(%q, %q, %q, %q) = %v, want %v", tt.installed, tt.installedSha256, tt.available, tt.availableSha256, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.0.0", "v1.1.0", -1},
+		{"v1.10.0", "v1.9.0", 1},
+		{"1.0.0", "v1.0.0", 0},
+		{"v1.0.0-alpha", "v1.0.0", -1},
+		{"v2.0.0", "deadbeef", -1},
+		{"deadbeef", "v0.0.1", 1},
+		{"cafebabe", "deadbeef", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func Test_versionMatches(t *testing.T) {
+	tests := []struct {
+		requested string
+		version   string
+		want      bool
+	}{
+		{"v1.0.0", "v1.0.0", true},
+		{"1.0.0", "v1.0.0", true},
+		{"v1.0.0+build", "v1.0.0", true},
+		{"v1.0.1", "v1.0.0", false},
+		{"v1.0.0-rc.1", "v1.0.0", false},
+		{"deadbeef", "deadbeef", true},
+		{"deadbeef", "v1.0.0", false},
+		{"v1.0.0", "deadbeef", false},
+	}
+	for _, tt := range tests {
+		if got := versionMatches(tt.requested, tt.version); got != tt.want {
+			t.Errorf("versionMatches(%q, %q) = %v, want %v", tt.requested, tt.version, got, tt.want)
+		}
+	}
+}
+
+func Test_getDownloadTargetFor_version(t *testing.T) {
+	platform := func(uri string) index.Platform {
+		return index.Platform{
+			URI:    uri,
+			Sha256: "DEADBEEF",
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"os": "linux"},
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		specVersion string
+		uri         string
+		want        string
+	}{
+		{"manifest version", "v1.2.3", "https://example.com/foo.tar.gz", "v1.2.3"},
+		{"tag in uri", "", "https://github.com/foo/foo/releases/download/v0.4.0-rc.1/foo.tar.gz", "v0.4.0-rc.1"},
+		{"manifest version before uri", "v1.2.3", "https://example.com/v0.4.0/foo.tar.gz", "v1.2.3"},
+		{"no semantic version", "latest", "https://example.com/foo.tar.gz", "deadbeef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := index.Plugin{
+				Spec: index.PluginSpec{
+					Version:   tt.specVersion,
+					Platforms: []index.Platform{platform(tt.uri)},
+				},
+			}
+			got, _, err := getDownloadTargetFor(plugin, "linux", "amd64", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("getDownloadTargetFor() version = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package log is the logging facade of the krew packages. Messages are logged
+// with glog by default, like the krew CLI does. Programs embedding the krew
+// packages can route the messages to their own logging with SetLogger.
+package log
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// Logger receives the log messages of the krew packages.
+type Logger interface {
+	// Enabled reports whether informational messages of the verbosity level
+	// are logged. Messages of disabled levels are not formatted.
+	Enabled(level int) bool
+	// Info logs an informational message of the verbosity level. Level 0 is
+	// the least verbose, the krew packages use levels 1 to 5.
+	Info(level int, msg string)
+	// Warning logs a warning, which is always enabled.
+	Warning(msg string)
+}
+
+var (
+	mu     sync.RWMutex
+	logger Logger = glogLogger{}
+)
+
+// SetLogger replaces the logger of the krew packages. A nil logger restores
+// the default glog logger. Use Discard to disable logging.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = glogLogger{}
+	}
+	mu.Lock()
+	logger = l
+	mu.Unlock()
+}
+
+func current() Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger
+}
+
+// Verbose logs informational messages at a verbosity level, like glog.Verbose.
+type Verbose int
+
+// V returns the Verbose logging messages at the level.
+func V(level int) Verbose { return Verbose(level) }
+
+// Enabled reports whether messages of the level are logged.
+func (v Verbose) Enabled() bool { return current().Enabled(int(v)) }
+
+// Infof formats and logs the message if the level is enabled.
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if l := current(); l.Enabled(int(v)) {
+		l.Info(int(v), fmt.Sprintf(format, args...))
+	}
+}
+
+// Infoln logs the operands separated by spaces if the level is enabled.
+func (v Verbose) Infoln(args ...interface{}) {
+	if l := current(); l.Enabled(int(v)) {
+		s := fmt.Sprintln(args...)
+		l.Info(int(v), s[:len(s)-1])
+	}
+}
+
+// Warningf formats and logs a warning.
+func Warningf(format string, args ...interface{}) {
+	current().Warning(fmt.Sprintf(format, args...))
+}
+
+// Discard is a Logger that drops all messages.
+var Discard Logger = discardLogger{}
+
+type discardLogger struct{}
+
+func (discardLogger) Enabled(int) bool { return false }
+func (discardLogger) Info(int, string) {}
+func (discardLogger) Warning(string)   {}
+
+// glogLogger logs with glog, attributing the messages to the callers of the
+// package functions.
+type glogLogger struct{}
+
+// glogDepth is the number of stack frames between the caller of a package
+// function, e.g. Verbose.Infof, and the glog function.
+const glogDepth = 2
+
+func (glogLogger) Enabled(level int) bool { return bool(glog.V(glog.Level(level))) }
+
+func (glogLogger) Info(_ int, msg string) { glog.InfoDepth(glogDepth, msg) }
+
+func (glogLogger) Warning(msg string) { glog.WarningDepth(glogDepth, msg) }
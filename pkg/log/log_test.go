@@ -0,0 +1,61 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type recordingLogger struct {
+	verbosity int
+	messages  []string
+}
+
+func (r *recordingLogger) Enabled(level int) bool { return level <= r.verbosity }
+
+func (r *recordingLogger) Info(level int, msg string) {
+	r.messages = append(r.messages, fmt.Sprintf("I%d %s", level, msg))
+}
+
+func (r *recordingLogger) Warning(msg string) { r.messages = append(r.messages, "W "+msg) }
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(nil)
+	r := &recordingLogger{verbosity: 2}
+	SetLogger(r)
+
+	V(1).Infof("downloading %q", "foo")
+	V(3).Infof("not logged %d", 3)
+	V(2).Infoln("moving", 2, "files")
+	Warningf("no sha256 for %s", "bar")
+
+	want := []string{`I1 downloading "foo"`, "I2 moving 2 files", "W no sha256 for bar"}
+	if !reflect.DeepEqual(r.messages, want) {
+		t.Fatalf("logged %q, want %q", r.messages, want)
+	}
+	if !V(2).Enabled() || V(3).Enabled() {
+		t.Fatalf("V(2).Enabled() = %v, V(3).Enabled() = %v, want true, false", V(2).Enabled(), V(3).Enabled())
+	}
+}
+
+func TestSetLogger_nilRestoresDefault(t *testing.T) {
+	SetLogger(Discard)
+	SetLogger(nil)
+	if _, ok := current().(glogLogger); !ok {
+		t.Fatalf("SetLogger(nil) set %T, want the glog logger", current())
+	}
+}
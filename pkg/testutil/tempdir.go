@@ -23,13 +23,13 @@ import (
 )
 
 type TempDir struct {
-	t    *testing.T
+	t    testing.TB
 	root string
 }
 
 // NewTempDir creates a temporary directory and a cleanup function.
 // It is the responsibility of calling code to call cleanup when done.
-func NewTempDir(t *testing.T) (tmpDir *TempDir, cleanup func()) {
+func NewTempDir(t testing.TB) (tmpDir *TempDir, cleanup func()) {
 	t.Helper()
 	root, err := ioutil.TempDir("", "krew-test")
 	if err != nil {